@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultAPI = "http://localhost:8080"
+
+// fileConfig é o arquivo ~/.config/doctl/config.yaml (ou $DOCTL_CONFIG):
+//
+//	api: https://orchestrator.example.com
+//	token: <bearer token>
+type fileConfig struct {
+	API   string `yaml:"api"`
+	Token string `yaml:"token"`
+}
+
+// cli guarda as flags comuns e o cliente HTTP de um comando.
+type cli struct {
+	out, errOut io.Writer
+
+	api    string
+	config string
+	output string
+	token  string
+	http   *http.Client
+}
+
+// flags cria o FlagSet do comando com as flags comuns.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("doctl "+name, flag.ContinueOnError)
+	fs.SetOutput(c.errOut)
+	fs.StringVar(&c.api, "api", "", "URL base da API (padrão: config ou "+defaultAPI+")")
+	fs.StringVar(&c.config, "config", "", "arquivo de config (padrão: $DOCTL_CONFIG ou ~/.config/doctl/config.yaml)")
+	fs.StringVar(&c.output, "o", "table", "formato de saída: table|json")
+	return fs
+}
+
+// parse aceita flags antes e depois dos argumentos posicionais
+// (ex: "status ID -o json") e carrega a configuração.
+func (c *cli) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) { return nil, err }
+			return nil, usageError{err.Error()}
+		}
+		if fs.NArg() == 0 { break }
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if c.output != "table" && c.output != "json" { return nil, usageError{"-o must be table or json"} }
+	return pos, c.load()
+}
+
+func (c *cli) load() error {
+	path, explicit := c.config, c.config != ""
+	if path == "" { path, explicit = os.Getenv("DOCTL_CONFIG"), os.Getenv("DOCTL_CONFIG") != "" }
+	if path == "" {
+		if dir, err := os.UserConfigDir(); err == nil { path = filepath.Join(dir, "doctl", "config.yaml") }
+	}
+	var fc fileConfig
+	if path != "" {
+		b, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			if err := yaml.Unmarshal(b, &fc); err != nil { return fmt.Errorf("config %s: %w", path, err) }
+			if st, err := os.Stat(path); err == nil && fc.Token != "" && st.Mode().Perm()&0o077 != 0 {
+				fmt.Fprintf(c.errOut, "doctl: warning: %s is readable by other users (chmod 600)\n", path)
+			}
+		case explicit || !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("config: %w", err)
+		}
+	}
+	if c.api == "" { c.api = fc.API }
+	if c.api == "" { c.api = defaultAPI }
+	c.api = strings.TrimRight(c.api, "/")
+	// o env tem precedência sobre o arquivo (mesmo nome usado no CI)
+	c.token = fc.Token
+	if tok := os.Getenv("ORCHESTRATOR_TOKEN"); tok != "" { c.token = tok }
+	c.http = &http.Client{}
+	return nil
+}
+
+// apiError é uma resposta não-2xx da API.
+type apiError struct {
+	Status int
+	Body   string
+}
+
+func (e *apiError) Error() string {
+	msg := strings.TrimSpace(e.Body)
+	// respostas JSON de erro trazem {"error": "..."}
+	var v struct{ Error string `json:"error"` }
+	if json.Unmarshal([]byte(msg), &v) == nil && v.Error != "" { msg = v.Error }
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), msg)
+}
+
+func (c *cli) request(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil { return nil, err }
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.api+path, rd)
+	if err != nil { return nil, err }
+	if body != nil { req.Header.Set("Content-Type", "application/json") }
+	if c.token != "" { req.Header.Set("Authorization", "Bearer "+c.token) }
+	return c.http.Do(req)
+}
+
+// call faz a requisição com timeout e decodifica a resposta JSON em out (se não nil).
+func (c *cli) call(ctx context.Context, method, path string, body, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	resp, err := c.request(ctx, method, path, body)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil { return err }
+	if resp.StatusCode/100 != 2 { return &apiError{Status: resp.StatusCode, Body: string(raw)} }
+	if out == nil { return nil }
+	if err := json.Unmarshal(raw, out); err != nil { return fmt.Errorf("decode response: %w", err) }
+	return nil
+}
+
+func (c *cli) get(ctx context.Context, id string) (*deployRecord, error) {
+	var rec deployRecord
+	if err := c.call(ctx, http.MethodGet, "/deploys/"+url.PathEscape(id), nil, &rec); err != nil { return nil, err }
+	return &rec, nil
+}
+
+// stream consome o SSE de /deploys/{id}/watch a partir de last e chama fn a
+// cada evento. Devolve o último seq visto; o stream fecha no evento final.
+func (c *cli) stream(ctx context.Context, id string, last uint64, fn func(event)) (uint64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.api+"/deploys/"+url.PathEscape(id)+"/watch", nil)
+	if err != nil { return last, err }
+	if c.token != "" { req.Header.Set("Authorization", "Bearer "+c.token) }
+	if last > 0 { req.Header.Set("Last-Event-ID", strconv.FormatUint(last, 10)) }
+	resp, err := c.http.Do(req)
+	if err != nil { return last, err }
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return last, &apiError{Status: resp.StatusCode, Body: string(raw)}
+	}
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok { continue }
+		var ev event
+		if json.Unmarshal([]byte(data), &ev) != nil { continue }
+		if ev.Seq <= last { continue }
+		last = ev.Seq
+		if fn != nil { fn(ev) }
+	}
+	return last, sc.Err()
+}
+
+// wait acompanha o deploy até um status final. Quedas do stream são
+// retomadas do último evento; só desiste após erros seguidos.
+func (c *cli) wait(ctx context.Context, id string, fn func(event)) (*deployRecord, error) {
+	var last uint64
+	fails := 0
+	for {
+		seq, err := c.stream(ctx, id, last, fn)
+		if ctx.Err() != nil { return nil, ctx.Err() }
+		var ae *apiError
+		if errors.As(err, &ae) && ae.Status/100 == 4 { return nil, err }
+		if seq > last { fails = 0 }
+		last = seq
+		rec, gerr := c.get(ctx, id)
+		if gerr == nil && rec.finished() { return rec, nil }
+		if err == nil { err = gerr }
+		if fails++; fails >= 5 {
+			if err == nil { err = errors.New("watch stream closed before the deploy finished") }
+			return nil, err
+		}
+		select {
+		case <-ctx.Done(): return nil, ctx.Err()
+		case <-time.After(time.Duration(fails) * 2 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type deployReq struct {
+	App             string            `json:"app"`
+	Namespace       string            `json:"namespace"`
+	Image           string            `json:"image"`
+	Strategy        string            `json:"strategy"` // canary|bluegreen
+	Params          map[string]string `json:"params"`   // ex: canaryStep=20, maxError=0.02, maxP95=0.5
+	RequireApproval bool              `json:"requireApproval"`
+	Waves           wavesFlag         `json:"waves,omitempty"`
+}
+
+type wave struct {
+	Name     string   `json:"name,omitempty"`
+	Clusters []string `json:"clusters"`
+	BakeTime string   `json:"bakeTime,omitempty"`
+}
+
+// wavesFlag lê "-waves staging:10m,eu+us:30m": waves separadas por vírgula,
+// clusters da mesma wave por "+" e o bake time opcional depois de ":".
+type wavesFlag []wave
+
+func (f *wavesFlag) String() string {
+	var out []string
+	for _, w := range *f {
+		s := strings.Join(w.Clusters, "+")
+		if w.BakeTime != "" { s += ":" + w.BakeTime }
+		out = append(out, s)
+	}
+	return strings.Join(out, ",")
+}
+
+func (f *wavesFlag) Set(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part == "" { continue }
+		clusters, bake, _ := strings.Cut(part, ":")
+		w := wave{BakeTime: bake}
+		for _, c := range strings.Split(clusters, "+") {
+			if c = strings.TrimSpace(c); c != "" { w.Clusters = append(w.Clusters, c) }
+		}
+		if len(w.Clusters) == 0 { return fmt.Errorf("invalid wave %q, want cluster[+cluster][:bakeTime]", part) }
+		if bake != "" {
+			if _, err := time.ParseDuration(bake); err != nil { return fmt.Errorf("invalid bake time in wave %q: %v", part, err) }
+		}
+		*f = append(*f, w)
+	}
+	return nil
+}
+
+// paramsFlag acumula k=v de "-params a=1,b=2" e de "-params" repetido.
+type paramsFlag map[string]string
+
+func (p paramsFlag) String() string {
+	var kv []string
+	for k, v := range p { kv = append(kv, k+"="+v) }
+	sort.Strings(kv)
+	return strings.Join(kv, ",")
+}
+
+func (p paramsFlag) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" { continue }
+		k, v, ok := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" { return fmt.Errorf("invalid param %q (want key=value)", pair) }
+		p[k] = strings.TrimSpace(v)
+	}
+	return nil
+}
+
+// waitFlags são as flags de quem inicia um deploy e pode esperar o resultado.
+type waitFlags struct {
+	wait    bool
+	follow  bool
+	timeout time.Duration
+}
+
+func (w *waitFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&w.wait, "wait", false, "espera o deploy terminar; exit code reflete o resultado")
+	fs.BoolVar(&w.follow, "follow", false, "como -wait, imprimindo a timeline")
+	fs.DurationVar(&w.timeout, "timeout", 0, "tempo máximo de espera com -wait/-follow (0 = sem limite)")
+}
+
+// finish imprime o deploy recém-criado e, com -wait/-follow, espera o status final.
+func (c *cli) finish(ctx context.Context, rec *deployRecord, w waitFlags) error {
+	if !w.wait && !w.follow {
+		if c.output == "json" { return printJSON(c.out, rec) }
+		fmt.Fprintf(c.out, "deploy %s %s (%s/%s %s)\n", rec.ID, rec.Status, rec.Namespace, rec.App, rec.ImageNew)
+		return nil
+	}
+	if c.output == "table" { fmt.Fprintf(c.errOut, "deploy %s %s, waiting...\n", rec.ID, rec.Status) }
+	return c.waitOutcome(ctx, rec.ID, w)
+}
+
+func (c *cli) waitOutcome(ctx context.Context, id string, w waitFlags) error {
+	if w.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.timeout)
+		defer cancel()
+	}
+	var fn func(event)
+	if w.follow {
+		fn = func(ev event) {
+			if c.output == "json" { _ = printJSON(c.out, ev) } else { printEvent(c.out, ev) }
+		}
+	}
+	rec, err := c.wait(ctx, id, fn)
+	if errors.Is(err, context.DeadlineExceeded) { return fmt.Errorf("timed out after %s waiting for deploy %s", w.timeout, id) }
+	if err != nil { return err }
+	if c.output == "json" {
+		if !w.follow { _ = printJSON(c.out, rec) }
+	} else {
+		fmt.Fprintf(c.out, "deploy %s %s after %s\n", rec.ID, rec.Status, rec.duration())
+	}
+	if rec.Status != "succeeded" { return outcomeError{id: rec.ID, status: rec.Status, reason: rec.Reason} }
+	return nil
+}
+
+func cmdDeploy(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("deploy")
+	req := deployReq{Params: map[string]string{}}
+	var w waitFlags
+	var queue, plan bool
+	fs.StringVar(&req.App, "app", "", "app (nome do target)")
+	fs.StringVar(&req.Namespace, "ns", "default", "namespace")
+	fs.StringVar(&req.Image, "image", "", "imagem nova")
+	fs.StringVar(&req.Strategy, "strategy", "canary", "canary|bluegreen")
+	fs.Var(paramsFlag(req.Params), "params", "k=v,k=v (pode repetir)")
+	fs.BoolVar(&req.RequireApproval, "approve", false, "exige aprovação manual")
+	fs.Var(&req.Waves, "waves", "deploy multi-cluster: staging:10m,eu+us:30m (vazio = waves do target)")
+	fs.BoolVar(&queue, "queue", false, "enfileira se já houver deploy ativo do app (senão 409)")
+	fs.BoolVar(&plan, "plan", false, "mostra o plano (etapas, análises e diff) sem executar o deploy")
+	w.register(fs)
+	pos, err := c.parse(fs, args)
+	if err != nil { return err }
+	if len(pos) > 0 { return usageError{"unexpected argument " + pos[0]} }
+	if req.App == "" || req.Image == "" { return usageError{"-app and -image are required"} }
+
+	q := url.Values{}
+	if plan { q.Set("dryRun", "true") }
+	if queue { q.Set("queue", "true") }
+	path := "/deploys"
+	if len(q) > 0 { path += "?" + q.Encode() }
+	if plan {
+		var p deployPlan
+		if c.output == "json" {
+			var raw map[string]any
+			if err := c.call(ctx, http.MethodPost, path, req, &raw); err != nil { return err }
+			return printJSON(c.out, raw)
+		}
+		if err := c.call(ctx, http.MethodPost, path, req, &p); err != nil { return err }
+		printPlan(c.out, p)
+		return nil
+	}
+	var rec deployRecord
+	if err := c.call(ctx, http.MethodPost, path, req, &rec); err != nil { return err }
+	return c.finish(ctx, &rec, w)
+}
+
+func cmdStatus(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("status")
+	n := fs.Int("events", 10, "quantos eventos recentes mostrar (table)")
+	id, err := c.parseID(fs, args)
+	if err != nil { return err }
+	rec, err := c.get(ctx, id)
+	if err != nil { return err }
+	if c.output == "json" { return printJSON(c.out, rec) }
+	printRecord(c.out, *rec)
+	if *n <= 0 { return nil }
+	var evs []event
+	if err := c.call(ctx, http.MethodGet, "/deploys/"+url.PathEscape(id)+"/events", nil, &evs); err != nil { return err }
+	if len(evs) > *n { evs = evs[len(evs)-*n:] }
+	fmt.Fprintln(c.out, "\nEvents:")
+	for _, ev := range evs { printEvent(c.out, ev) }
+	return nil
+}
+
+func cmdList(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("list")
+	app := fs.String("app", "", "filtra por app")
+	ns := fs.String("ns", "", "filtra por namespace")
+	status := fs.String("status", "", "filtra por status")
+	since := fs.String("since", "", "RFC3339 ou duração (ex: 24h, 7d)")
+	limit := fs.Int("limit", 20, "itens por página")
+	cursor := fs.String("cursor", "", "nextCursor da página anterior")
+	pos, err := c.parse(fs, args)
+	if err != nil { return err }
+	if len(pos) > 0 { return usageError{"unexpected argument " + pos[0]} }
+	if *limit < 1 { return usageError{"-limit must be positive"} }
+
+	q := url.Values{"limit": {strconv.Itoa(*limit)}}
+	for k, v := range map[string]string{"app": *app, "namespace": *ns, "status": *status, "since": *since, "cursor": *cursor} {
+		if v != "" { q.Set(k, v) }
+	}
+	var page listPage
+	if err := c.call(ctx, http.MethodGet, "/deploys?"+q.Encode(), nil, &page); err != nil { return err }
+	if c.output == "json" { return printJSON(c.out, page) }
+	printRecords(c.out, page.Items)
+	if page.NextCursor != "" { fmt.Fprintf(c.errOut, "\nmore results: -cursor %s\n", page.NextCursor) }
+	return nil
+}
+
+func cmdWatch(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("watch")
+	timeout := fs.Duration("timeout", 0, "tempo máximo de espera (0 = sem limite)")
+	id, err := c.parseID(fs, args)
+	if err != nil { return err }
+	return c.waitOutcome(ctx, id, waitFlags{follow: true, timeout: *timeout})
+}
+
+func cmdApprove(ctx context.Context, c *cli, args []string) error {
+	id, err := c.parseID(c.flags("approve"), args)
+	if err != nil { return err }
+	if err := c.call(ctx, http.MethodPost, "/deploys/"+url.PathEscape(id)+"/approve", nil, nil); err != nil { return err }
+	fmt.Fprintf(c.out, "deploy %s approved\n", id)
+	return nil
+}
+
+func cmdAbort(ctx context.Context, c *cli, args []string) error {
+	id, err := c.parseID(c.flags("abort"), args)
+	if err != nil { return err }
+	if err := c.call(ctx, http.MethodPost, "/deploys/"+url.PathEscape(id)+"/abort", nil, nil); err != nil { return err }
+	fmt.Fprintf(c.out, "abort requested for deploy %s\n", id)
+	return nil
+}
+
+// cmdRollback pede ao orquestrador um novo deploy que restaura o snapshot de
+// um deploy anterior com sucesso: o indicado em -to (ID ou revision) ou o
+// último com imagem diferente da atual.
+func cmdRollback(ctx context.Context, c *cli, args []string) error {
+	fs := c.flags("rollback")
+	params := paramsFlag{}
+	var w waitFlags
+	var app, ns, to, strategy string
+	var queue bool
+	fs.StringVar(&app, "app", "", "app (nome do target)")
+	fs.StringVar(&ns, "ns", "default", "namespace")
+	fs.StringVar(&to, "to", "", "ID ou revision do deploy com sucesso (padrão: o anterior ao atual)")
+	fs.StringVar(&strategy, "strategy", "fast", "fast|canary")
+	fs.Var(params, "params", "k=v,k=v (pode repetir)")
+	fs.BoolVar(&queue, "queue", false, "enfileira se já houver deploy ativo do app (senão 409)")
+	w.register(fs)
+	pos, err := c.parse(fs, args)
+	if err != nil { return err }
+	if len(pos) > 0 { return usageError{"unexpected argument " + pos[0]} }
+	if app == "" { return usageError{"-app is required"} }
+
+	q := url.Values{"strategy": {strategy}}
+	if to != "" { q.Set("to", to) }
+	if queue { q.Set("queue", "true") }
+	path := "/apps/" + url.PathEscape(ns) + "/" + url.PathEscape(app) + "/rollback?" + q.Encode()
+	var rec deployRecord
+	if err := c.call(ctx, http.MethodPost, path, map[string]any{"params": params}, &rec); err != nil { return err }
+	if c.output == "table" {
+		fmt.Fprintf(c.errOut, "rolling back %s/%s to %s (deploy %s)\n", ns, app, rec.ImageNew, rec.RollbackOf)
+	}
+	return c.finish(ctx, &rec, w)
+}
+
+// parseID exige exatamente um argumento posicional (o ID do deploy).
+func (c *cli) parseID(fs *flag.FlagSet, args []string) (string, error) {
+	pos, err := c.parse(fs, args)
+	if err != nil { return "", err }
+	if len(pos) != 1 { return "", usageError{"usage: " + fs.Name() + " ID"} }
+	return pos[0], nil
+}
@@ -1,64 +1,114 @@
+// doctl é o CLI do orquestrador: dispara, acompanha e controla deploys.
 package main
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"net/http"
+	"io"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
+)
+
+// Códigos de saída. deploy --wait e watch terminam com o resultado do deploy,
+// então um pipeline de CI falha quando houve rollback.
+const (
+	exitOK         = 0
+	exitError      = 1 // erro de rede, da API ou do próprio CLI
+	exitUsage      = 2
+	exitRolledBack = 3
+	exitFailed     = 4
+	exitAborted    = 5
 )
 
-type deployReq struct {
-	App       string            `json:"app"`
-	Namespace string            `json:"namespace"`
-	Image     string            `json:"image"`
-	Strategy  string            `json:"strategy"` // canary|bluegreen
-	Params    map[string]string `json:"params"`   // ex: canaryStep=20, maxError=0.02, maxP95=0.5
-	RequireApproval bool        `json:"requireApproval"`
+type command struct {
+	name  string
+	args  string
+	help  string
+	run   func(ctx context.Context, c *cli, args []string) error
 }
 
-func main() {
-	api := flag.String("api", "http://localhost:8080", "API base")
-	app := flag.String("app", "", "app name (Deployment)")
-	ns := flag.String("ns", "default", "namespace")
-	img := flag.String("image", "", "image")
-	strategy := flag.String("strategy", "canary", "canary|bluegreen")
-	params := flag.String("params", "", "k=v,k=v")
-	require := flag.Bool("approve", false, "require manual approval")
-	flag.Parse()
+var commands = []command{
+	{"deploy", "-app APP -image IMAGE [flags]", "inicia um deploy (--wait/--follow acompanha até o fim)", cmdDeploy},
+	{"status", "ID", "mostra o deploy e os últimos eventos", cmdStatus},
+	{"list", "[-app APP] [-ns NS] [-status S]", "histórico de deploys, do mais novo ao mais antigo", cmdList},
+	{"watch", "ID", "timeline ao vivo até o deploy terminar", cmdWatch},
+	{"approve", "ID", "aprova um deploy em waiting_approval", cmdApprove},
+	{"abort", "ID", "aborta um deploy (em execução, faz rollback)", cmdAbort},
+	{"rollback", "-app APP [-to ID|REVISION] [flags]", "volta o app ao snapshot de um deploy anterior com sucesso", cmdRollback},
+}
 
-	if *app == "" || *img == "" { fmt.Println("app and image required"); os.Exit(1) }
+// usageError faz o CLI sair com exitUsage.
+type usageError struct{ msg string }
 
-	p := map[string]string{}
-	if *params != "" {
-		for _, pair := range split(*params, ",") {
-			kv := split(pair, "=")
-			if len(kv)==2 { p[kv[0]] = kv[1] }
-		}
-	}
+func (e usageError) Error() string { return e.msg }
 
-	body, _ := json.Marshal(deployReq{
-		App: *app, Namespace: *ns, Image: *img, Strategy: *strategy, Params: p, RequireApproval: *require,
-	})
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// outcomeError carrega o status final de um deploy que não teve sucesso.
+type outcomeError struct{ id, status, reason string }
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, *api + "/deploys", bytesReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil { panic(err) }
-	defer resp.Body.Close()
-	ioCopy(os.Stdout, resp.Body)
+func (e outcomeError) Error() string {
+	msg := "deploy " + e.id + " " + e.status
+	if e.reason != "" { msg += ": " + e.reason }
+	return msg
 }
 
-func split(s, sep string) []string { return strings.Split(s, sep) }
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
 
-import (
-	"bytes"
-	"io"
-	"strings"
-)
-func bytesReader(b []byte) *bytes.Reader { return bytes.NewReader(b) }
-func ioCopy(dst io.Writer, src io.Reader) { _, _ = io.Copy(dst, src) }
\ No newline at end of file
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		if len(args) == 0 { return exitUsage }
+		return exitOK
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == args[0] { cmd = &commands[i] }
+	}
+	if cmd == nil {
+		fmt.Fprintf(stderr, "doctl: unknown command %q\n\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+	c := &cli{out: stdout, errOut: stderr}
+	err := cmd.run(ctx, c, args[1:])
+	return exitCode(stderr, cmd.name, err)
+}
+
+func exitCode(stderr io.Writer, name string, err error) int {
+	if err == nil || errors.Is(err, flag.ErrHelp) { return exitOK }
+	fmt.Fprintf(stderr, "doctl %s: %v\n", name, err)
+	var ue usageError
+	var oe outcomeError
+	switch {
+	case errors.As(err, &ue): return exitUsage
+	case errors.As(err, &oe): return outcomeCode(oe.status)
+	}
+	return exitError
+}
+
+func outcomeCode(status string) int {
+	switch status {
+	case "succeeded": return exitOK
+	case "rolled_back": return exitRolledBack
+	case "aborted": return exitAborted
+	}
+	return exitFailed
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: doctl <command> [flags]")
+	fmt.Fprintln(w, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %s %s\n      %s\n", c.name, c.args, c.help)
+	}
+	fmt.Fprintln(w, "\nflags comuns: -api URL, -config FILE, -o table|json")
+	fmt.Fprintln(w, "token: ORCHESTRATOR_TOKEN ou \"token\" em ~/.config/doctl/config.yaml (DOCTL_CONFIG)")
+	fmt.Fprintln(w, "\nexit codes: 0 ok, 1 erro, 2 uso, 3 rolled_back, 4 failed, 5 aborted")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParamsFlag(t *testing.T) {
+	p := paramsFlag{}
+	for _, s := range []string{"canaryStep=20, maxError=0.02", "nodeLabel=topology.kubernetes.io/zone", "empty="} {
+		if err := p.Set(s); err != nil { t.Fatalf("Set(%q): %v", s, err) }
+	}
+	if got, want := p.String(), "canaryStep=20,empty=,maxError=0.02,nodeLabel=topology.kubernetes.io/zone"; got != want {
+		t.Fatalf("params = %q, want %q", got, want)
+	}
+	for _, bad := range []string{"canaryStep", "=1"} {
+		if err := (paramsFlag{}).Set(bad); err == nil { t.Errorf("Set(%q) accepted", bad) }
+	}
+}
+
+// fakeAPI responde como o orquestrador para um único deploy que termina com status.
+func fakeAPI(t *testing.T, status string) *httptest.Server {
+	t.Helper()
+	rec := deployRecord{ID: "abc", App: "myapp", Namespace: "default", ImageNew: "repo/myapp:2", Status: "started"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /deploys", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" { http.Error(w, "unauthorized", http.StatusUnauthorized); return }
+		_ = json.NewEncoder(w).Encode(rec)
+	})
+	mux.HandleFunc("GET /deploys/abc/watch", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, st := range []string{"running", status} {
+			b, _ := json.Marshal(event{Seq: uint64(i + 1), Type: "status", Message: "deploy " + st, Data: map[string]string{"status": st}})
+			fmt.Fprintf(w, "id: %d\nevent: status\ndata: %s\n\n", i+1, b)
+		}
+	})
+	mux.HandleFunc("GET /deploys/abc", func(w http.ResponseWriter, r *http.Request) {
+		final := rec
+		final.Status = status
+		_ = json.NewEncoder(w).Encode(final)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDeployWaitExitCode(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfg, []byte("token: s3cret\n"), 0o600); err != nil { t.Fatal(err) }
+	t.Setenv("ORCHESTRATOR_TOKEN", "")
+
+	for status, want := range map[string]int{"succeeded": exitOK, "rolled_back": exitRolledBack, "aborted": exitAborted} {
+		srv := fakeAPI(t, status)
+		var out, errOut bytes.Buffer
+		args := []string{"deploy", "-api", srv.URL, "-config", cfg, "-app", "myapp", "-image", "repo/myapp:2", "-wait", "-params", "canaryStep=50"}
+		if got := run(context.Background(), args, &out, &errOut); got != want {
+			t.Errorf("%s: exit = %d, want %d (stderr: %s)", status, got, want, errOut.String())
+		}
+		if !strings.Contains(out.String(), "deploy abc "+status) { t.Errorf("%s: output %q", status, out.String()) }
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	for _, args := range [][]string{{}, {"nope"}, {"status"}, {"deploy", "-app", "x"}, {"list", "-o", "yaml"}, {"deploy", "-params", "x"}} {
+		var out, errOut bytes.Buffer
+		if got := run(context.Background(), args, &out, &errOut); got != exitUsage {
+			t.Errorf("run(%q) = %d, want %d", args, got, exitUsage)
+		}
+	}
+}
+
+func TestPrintEventFailedOrder(t *testing.T) {
+	ev := event{Type: "analysis", Step: "scale_2", Message: "analysis failed", Data: map[string]string{
+		"errorRate": "0.1", "p95": "0.2", "p95.failed": "p95=0.2 > max 0.1", "errorRate.failed": "errorRate=0.1 > max 0.02", "latency.failed": "latency: timeout",
+	}}
+	for i := 0; i < 20; i++ {
+		var b bytes.Buffer
+		printEvent(&b, ev)
+		if !strings.HasSuffix(b.String(), "[errorRate=0.1 > max 0.02] [latency: timeout] [p95=0.2 > max 0.1]\n") { t.Fatalf("output = %q", b.String()) }
+	}
+}
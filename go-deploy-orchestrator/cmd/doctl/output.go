@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type deployRecord struct {
+	ID          string            `json:"id"`
+	App         string            `json:"app"`
+	Namespace   string            `json:"namespace"`
+	ImageNew    string            `json:"imageNew"`
+	ImageOld    string            `json:"imageOld"`
+	RevisionOld string            `json:"revisionOld,omitempty"`
+	Revision    string            `json:"revision,omitempty"`
+	RollbackOf  string            `json:"rollbackOf,omitempty"`
+	Strategy    string            `json:"strategy"`
+	Status      string            `json:"status"`
+	Reason      string            `json:"reason,omitempty"`
+	StartedAt   time.Time         `json:"startedAt"`
+	FinishedAt  *time.Time        `json:"finishedAt,omitempty"`
+	Params      map[string]string `json:"params"`
+	RequestedBy string            `json:"requestedBy,omitempty"`
+	ApprovedBy  string            `json:"approvedBy,omitempty"`
+	Clusters    []clusterStatus   `json:"clusters,omitempty"`
+}
+
+type clusterStatus struct {
+	Cluster  string `json:"cluster"`
+	Wave     string `json:"wave"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	ImageOld string `json:"imageOld,omitempty"`
+}
+
+func (r deployRecord) finished() bool {
+	switch r.Status {
+	case "succeeded", "failed", "rolled_back", "aborted": return true
+	}
+	return false
+}
+
+// duration é o tempo de execução (até agora, se ainda não terminou).
+func (r deployRecord) duration() time.Duration {
+	end := time.Now()
+	if r.FinishedAt != nil { end = *r.FinishedAt }
+	return end.Sub(r.StartedAt).Round(time.Second)
+}
+
+type listPage struct {
+	Items      []deployRecord `json:"items"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+type event struct {
+	Seq     uint64            `json:"seq"`
+	Time    time.Time         `json:"time"`
+	Type    string            `json:"type"`
+	Step    string            `json:"step"`
+	Message string            `json:"message"`
+	Data    map[string]string `json:"data"`
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printRecords(w io.Writer, recs []deployRecord) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAMESPACE\tAPP\tSTRATEGY\tSTATUS\tIMAGE\tSTARTED\tDURATION")
+	for _, r := range recs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Namespace, r.App, r.Strategy, r.Status,
+			r.ImageNew, r.StartedAt.Local().Format("2006-01-02 15:04:05"), r.duration())
+	}
+	_ = tw.Flush()
+}
+
+func printRecord(w io.Writer, r deployRecord) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(k, v string) {
+		if v != "" { fmt.Fprintf(tw, "%s:\t%s\n", k, v) }
+	}
+	row("ID", r.ID)
+	row("App", r.Namespace+"/"+r.App)
+	row("Strategy", r.Strategy)
+	row("Status", r.Status)
+	row("Reason", r.Reason)
+	row("Image", r.ImageNew)
+	row("Previous", r.ImageOld)
+	row("Revision", r.Revision)
+	row("Previous revision", r.RevisionOld)
+	row("Rollback of", r.RollbackOf)
+	row("Requested by", r.RequestedBy)
+	row("Approved by", r.ApprovedBy)
+	row("Started", r.StartedAt.Local().Format(time.RFC3339))
+	if r.FinishedAt != nil { row("Finished", r.FinishedAt.Local().Format(time.RFC3339)) }
+	row("Duration", r.duration().String())
+	if len(r.Params) > 0 {
+		var kv []string
+		for k, v := range r.Params { kv = append(kv, k+"="+v) }
+		sort.Strings(kv)
+		row("Params", strings.Join(kv, ","))
+	}
+	_ = tw.Flush()
+	if len(r.Clusters) > 0 {
+		fmt.Fprintln(w, "\nClusters:")
+		tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "  CLUSTER\tWAVE\tSTATUS\tPREVIOUS\tREASON")
+		for _, c := range r.Clusters {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\n", c.Cluster, c.Wave, c.Status, c.ImageOld, c.Reason)
+		}
+		_ = tw.Flush()
+	}
+}
+
+func printEvent(w io.Writer, ev event) {
+	step := ev.Step
+	if step == "" { step = "-" }
+	fmt.Fprintf(w, "%s  %-13s %-10s %s", ev.Time.Local().Format("15:04:05"), ev.Type, step, ev.Message)
+	if ev.Type == "analysis" {
+		fmt.Fprintf(w, " (error=%s p95=%s)", ev.Data["errorRate"], ev.Data["p95"])
+		var failed []string
+		for k := range ev.Data {
+			if strings.HasSuffix(k, ".failed") { failed = append(failed, k) }
+		}
+		sort.Strings(failed) // ordem estável entre execuções
+		for _, k := range failed { fmt.Fprintf(w, " [%s]", ev.Data[k]) }
+	}
+	fmt.Fprintln(w)
+}
+
+type planStep struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PauseSec    int    `json:"pauseSec"`
+	Analysis    []struct {
+		Metric   string  `json:"metric"`
+		Provider string  `json:"provider"`
+		Query    string  `json:"query"`
+		Range    string  `json:"range"`
+		Min      float64 `json:"min"`
+		Max      float64 `json:"max"`
+	} `json:"analysis"`
+}
+
+type deployPlan struct {
+	App          string     `json:"app"`
+	Namespace    string     `json:"namespace"`
+	Strategy     string     `json:"strategy"`
+	ImageOld     string     `json:"imageOld"`
+	ImageNew     string     `json:"imageNew"`
+	Replicas     int32      `json:"replicas"`
+	Policy       struct {
+		Allowed bool   `json:"allowed"`
+		Rule    string `json:"rule"`
+		Reason  string `json:"reason"`
+	} `json:"policy"`
+	Steps        []planStep `json:"steps"`
+	TemplateDiff string     `json:"templateDiff"`
+	ServerDryRun bool       `json:"serverDryRun"`
+	Warnings     []string   `json:"warnings"`
+}
+
+// printPlan formata a resposta de POST /deploys?dryRun=true.
+func printPlan(w io.Writer, p deployPlan) {
+	fmt.Fprintf(w, "plan for %s/%s (%s, %d replicas)\n", p.Namespace, p.App, p.Strategy, p.Replicas)
+	fmt.Fprintf(w, "  image: %s -> %s\n", p.ImageOld, p.ImageNew)
+	if !p.Policy.Allowed { fmt.Fprintf(w, "  policy: REJECTED by %s: %s\n", p.Policy.Rule, p.Policy.Reason) }
+	for _, warn := range p.Warnings { fmt.Fprintf(w, "  warning: %s\n", warn) }
+	fmt.Fprintln(w, "\nsteps:")
+	for i, st := range p.Steps {
+		fmt.Fprintf(w, "  %d. %-10s %s\n", i+1, st.Name, st.Description)
+		for _, a := range st.Analysis {
+			cond := fmt.Sprintf("<= %g", a.Max)
+			if a.Min != 0 { cond = fmt.Sprintf(">= %g", a.Min) }
+			if a.Provider == "" {
+				fmt.Fprintf(w, "       %s %s over %s: %s\n", a.Metric, cond, a.Range, a.Query)
+			} else {
+				fmt.Fprintf(w, "       %s %s [%s]: %s\n", a.Metric, cond, a.Provider, a.Query)
+			}
+		}
+		if st.PauseSec > 0 { fmt.Fprintf(w, "       pause %ds\n", st.PauseSec) }
+	}
+	src := "local"
+	if p.ServerDryRun { src = "server dry-run" }
+	fmt.Fprintf(w, "\npod template diff (%s):\n", src)
+	if p.TemplateDiff == "" { fmt.Fprintln(w, "  (no changes)") } else { fmt.Fprint(w, p.TemplateDiff) }
+}
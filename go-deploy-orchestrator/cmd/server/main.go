@@ -7,12 +7,14 @@ import (
 	"syscall"
 
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/api"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/orchestrator"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/trigger"
 )
 
 func main() {
@@ -37,6 +39,12 @@ func main() {
 	orc, err := orchestrator.New(log, cfg, db, prom)
 	if err != nil { log.Fatal().Err(err).Msg("init orchestrator") }
 
+	authz, err := auth.New(cfg.Auth, cfg.AuthToken)
+	if err != nil { log.Fatal().Err(err).Msg("init auth") }
+
+	triggers, err := trigger.New(cfg.Triggers)
+	if err != nil { log.Fatal().Err(err).Msg("init triggers") }
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go func() {
@@ -45,12 +53,17 @@ func main() {
 		<-c
 		cancel()
 	}()
+	// a fila volta antes da API, para deploys novos não furarem a ordem
+	if err := orc.Resume(ctx); err != nil { log.Error().Err(err).Msg("resume queued deploys") }
+	go orc.Run(ctx)
 
 	srv := api.NewServer(api.Deps{
 		Log:   log,
 		Orc:   orc,
 		Store: db,
 		Cfg:   cfg,
+		Auth:  authz,
+		Triggers: triggers,
 	}, api.Config{Addr: httpAddr})
 
 	if err := srv.Run(ctx); err != nil {
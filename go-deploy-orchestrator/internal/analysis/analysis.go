@@ -0,0 +1,168 @@
+// Package analysis avalia as métricas de cada etapa do deploy em backends
+// diferentes (Prometheus, HTTP JSON, Jobs, Graphite, InfluxDB), para que um
+// template de análise misture SLOs técnicas e métricas de negócio.
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+)
+
+// Provider busca o valor atual de uma métrica.
+type Provider interface {
+	Value(ctx context.Context, m config.Metric, v hooks.Vars) (float64, error)
+}
+
+// OnErrorSkip deixa inconclusiva (sem reprovar) uma métrica cuja consulta falhou.
+const OnErrorSkip = "skip"
+
+// Providers são os backends configurados, pelo nome.
+type Providers map[string]Provider
+
+// NewProviders monta os providers de analysis.providers. "prometheus" usa o
+// Evaluator existente, a menos que a config o redefina.
+func NewProviders(cfg []config.AnalysisProvider, prom *prometheus.Evaluator, cs kubernetes.Interface) (Providers, error) {
+	ps := Providers{"prometheus": promProvider{prom}}
+	for _, c := range cfg {
+		if c.Name == "" { return nil, errors.New("analysis provider: name is required") }
+		cl := &http.Client{Timeout: c.Timeout}
+		switch c.Type {
+		case "prometheus":
+			ps[c.Name] = promProvider{prometheus.NewEvaluator(c.URL, c.Timeout)}
+		case "http":
+			ps[c.Name] = &httpProvider{base: c.URL, headers: c.Headers, cl: cl}
+		case "graphite":
+			if c.URL == "" { return nil, fmt.Errorf("analysis provider %q: url is required", c.Name) }
+			ps[c.Name] = &graphiteProvider{base: c.URL, headers: c.Headers, cl: cl}
+		case "influx":
+			if c.URL == "" || c.Database == "" { return nil, fmt.Errorf("analysis provider %q: url and database are required", c.Name) }
+			ps[c.Name] = &influxProvider{base: c.URL, db: c.Database, headers: c.Headers, cl: cl}
+		case "job":
+			ps[c.Name] = &jobProvider{cs: cs, timeout: c.Timeout, poll: 2 * time.Second}
+		default:
+			return nil, fmt.Errorf("analysis provider %q: unknown type %q", c.Name, c.Type)
+		}
+	}
+	return ps, nil
+}
+
+// Validate confere os templates contra os providers (chamado na inicialização).
+func (ps Providers) Validate(tpls []config.AnalysisTemplate) error {
+	for _, t := range tpls {
+		if t.Name == "" { return errors.New("analysis template: name is required") }
+		for _, m := range t.Metrics {
+			if m.Name == "" { return fmt.Errorf("analysis template %q: metric name is required", t.Name) }
+			p, ok := ps[m.Provider]
+			if !ok { return fmt.Errorf("analysis template %q: metric %q uses unknown provider %q", t.Name, m.Name, m.Provider) }
+			if _, err := time.ParseDuration(m.Range); err != nil { return fmt.Errorf("analysis template %q: metric %q: range: %w", t.Name, m.Name, err) }
+			if m.OnError != "" && m.OnError != "fail" && m.OnError != OnErrorSkip { return fmt.Errorf("analysis template %q: metric %q: onError must be fail or skip", t.Name, m.Name) }
+			var err error
+			switch p := p.(type) {
+			case *jobProvider:
+				if m.Job == nil { err = errors.New("job.template is required") } else { _, err = hooks.NewJob(m.Job.Template, m.Name, hooks.Vars{}) }
+			case *httpProvider:
+				_, err = p.path(m)
+			default:
+				if m.Query == "" { err = errors.New("query is required") }
+			}
+			if err != nil { return fmt.Errorf("analysis template %q: metric %q: %w", t.Name, m.Name, err) }
+		}
+	}
+	return nil
+}
+
+// For prepara a análise de um deploy com as métricas do template do app.
+func (ps Providers) For(metrics []config.Metric, v hooks.Vars) *Analysis {
+	return &Analysis{providers: ps, metrics: metrics, vars: v}
+}
+
+type Analysis struct {
+	providers Providers
+	metrics   []config.Metric
+	vars      hooks.Vars
+}
+
+// Measurement é o resultado de uma métrica numa etapa.
+type Measurement struct {
+	Metric   string
+	Provider string
+	Value    float64
+	Err      error
+	Failed   string // motivo da reprovação; vazio = passou
+}
+
+type Result struct {
+	Measurements []Measurement
+}
+
+// Run avalia base (as SLOs da estratégia) e depois as métricas do template.
+// Uma métrica com limite reprova se passar do limite ou se a consulta falhar
+// (com onError: skip o erro só é registrado); sem limite, só é registrada.
+func (a *Analysis) Run(ctx context.Context, step string, base ...config.Metric) Result {
+	v := a.vars
+	v.Phase, v.Step = "analysis", step
+	var r Result
+	for _, m := range append(base, a.metrics...) {
+		ms := Measurement{Metric: m.Name, Provider: m.Provider}
+		p, ok := a.providers[m.Provider]
+		if !ok {
+			ms.Err = fmt.Errorf("unknown provider %q", m.Provider)
+		} else {
+			ms.Value, ms.Err = p.Value(ctx, m, v)
+		}
+		ms.Failed = check(m, ms)
+		r.Measurements = append(r.Measurements, ms)
+	}
+	return r
+}
+
+func check(m config.Metric, ms Measurement) string {
+	if m.Min == nil && m.Max == nil { return "" }
+	if ms.Err != nil && m.OnError == OnErrorSkip { return "" }
+	if ms.Err != nil { return m.Name + ": " + ms.Err.Error() }
+	if m.Max != nil && ms.Value > *m.Max { return fmt.Sprintf("%s=%g > max %g", m.Name, ms.Value, *m.Max) }
+	if m.Min != nil && ms.Value < *m.Min { return fmt.Sprintf("%s=%g < min %g", m.Name, ms.Value, *m.Min) }
+	return ""
+}
+
+func (r Result) OK() bool {
+	for _, m := range r.Measurements {
+		if m.Failed != "" { return false }
+	}
+	return true
+}
+
+// Summary lista as métricas reprovadas (ou os valores, se todas passaram).
+func (r Result) Summary() string {
+	var failed, all []string
+	for _, m := range r.Measurements {
+		if m.Failed != "" { failed = append(failed, m.Failed) }
+		all = append(all, fmt.Sprintf("%s=%g", m.Metric, m.Value))
+	}
+	if len(failed) > 0 { return strings.Join(failed, "; ") }
+	return strings.Join(all, " ")
+}
+
+// Data vira o data do evento analysis: valor por métrica, mais provider e
+// erro das métricas que não vieram do Prometheus ou falharam.
+func (r Result) Data() map[string]string {
+	data := map[string]string{}
+	for _, m := range r.Measurements {
+		data[m.Metric] = strconv.FormatFloat(m.Value, 'f', -1, 64)
+		if m.Provider != "prometheus" { data[m.Metric+".provider"] = m.Provider }
+		if m.Err != nil { data[m.Metric+".error"] = m.Err.Error() }
+		if m.Failed != "" { data[m.Metric+".failed"] = m.Failed }
+	}
+	return data
+}
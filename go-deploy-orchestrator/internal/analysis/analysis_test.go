@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+)
+
+func ptr(v float64) *float64 { return &v }
+
+// backends simula Prometheus, uma API de negócio, Graphite e InfluxDB.
+func backends(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"value":[1700000000,"0.01"]}]}}`)
+	})
+	mux.HandleFunc("GET /business/{app}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "t0k" || r.PathValue("app") != "web" { http.Error(w, "forbidden", http.StatusForbidden); return }
+		fmt.Fprint(w, `{"data":{"checkout":{"conversion":"0.93","orders":120}}}`)
+	})
+	mux.HandleFunc("GET /render", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("target") != "web.login.p99" || r.URL.Query().Get("from") != "-600s" { http.Error(w, "bad query", http.StatusBadRequest); return }
+		fmt.Fprint(w, `[{"target":"web.login.p99","datapoints":[[0.4,1700000000],[0.7,1700000060],[null,1700000120]]}]`)
+	})
+	mux.HandleFunc("GET /query", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("db") != "metrics" { fmt.Fprint(w, `{"results":[{"error":"database not found"}]}`); return }
+		fmt.Fprint(w, `{"results":[{"series":[{"name":"cpu","columns":["time","mean"],"values":[["2024-01-01T00:00:00Z",55.5],["2024-01-01T00:01:00Z",61.2]]}]}]}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMixedTemplate(t *testing.T) {
+	srv := backends(t)
+	ps, err := NewProviders([]config.AnalysisProvider{
+		{Name: "shop", Type: "http", URL: srv.URL, Headers: map[string]string{"X-Token": "t0k"}, Timeout: time.Second},
+		{Name: "graphite", Type: "graphite", URL: srv.URL, Timeout: time.Second},
+		{Name: "influx", Type: "influx", URL: srv.URL, Database: "metrics", Timeout: time.Second},
+	}, prometheus.NewEvaluator(srv.URL, time.Second), fake.NewSimpleClientset())
+	if err != nil { t.Fatal(err) }
+
+	metrics := []config.Metric{
+		{Name: "conversion", Provider: "shop", Query: "/business/${APP}", JSONPath: "$.data.checkout.conversion", Min: ptr(0.9)},
+		{Name: "orders", Provider: "shop", Query: "/business/${APP}", JSONPath: "{.data.checkout.orders}"},
+		{Name: "loginP99", Provider: "graphite", Query: "${APP}.login.p99", Range: "10m", Max: ptr(1)},
+		{Name: "cpu", Provider: "influx", Query: `SELECT mean("usage") FROM "cpu" WHERE "app" = '${APP}'`, Max: ptr(60)},
+	}
+	tpl := []config.AnalysisTemplate{{Name: "web", Metrics: metrics}}
+	for i := range tpl[0].Metrics {
+		if tpl[0].Metrics[i].Range == "" { tpl[0].Metrics[i].Range = "5m" }
+	}
+	if err := ps.Validate(tpl); err != nil { t.Fatal(err) }
+
+	an := ps.For(tpl[0].Metrics, hooks.Vars{DeployID: "d1", Namespace: "prod", App: "web"})
+	r := an.Run(context.Background(), "scale_1", config.Metric{Name: "errorRate", Provider: "prometheus", Query: "up", Range: "5m", Max: ptr(0.02)})
+	want := map[string]float64{"errorRate": 0.01, "conversion": 0.93, "orders": 120, "loginP99": 0.7, "cpu": 61.2}
+	for _, m := range r.Measurements {
+		if m.Err != nil || m.Value != want[m.Metric] { t.Errorf("%s = %v (err %v), want %v", m.Metric, m.Value, m.Err, want[m.Metric]) }
+	}
+	if r.OK() { t.Fatal("cpu above max passed") }
+	if got := r.Summary(); got != "cpu=61.2 > max 60" { t.Fatalf("summary = %q", got) }
+	if d := r.Data(); d["conversion.provider"] != "shop" || d["cpu.failed"] == "" || d["errorRate"] != "0.01" { t.Fatalf("data = %v", d) }
+}
+
+func TestProviderErrors(t *testing.T) {
+	srv := backends(t)
+	ps, err := NewProviders([]config.AnalysisProvider{
+		{Name: "shop", Type: "http", URL: srv.URL, Timeout: time.Second},
+		{Name: "badinflux", Type: "influx", URL: srv.URL, Database: "nope", Timeout: time.Second},
+	}, prometheus.NewEvaluator(srv.URL, time.Second), fake.NewSimpleClientset())
+	if err != nil { t.Fatal(err) }
+	an := ps.For([]config.Metric{
+		{Name: "conversion", Provider: "shop", Query: "/business/web", JSONPath: "$.data.checkout.conversion", Min: ptr(0.9)}, // sem header: 403
+		{Name: "cpu", Provider: "badinflux", Query: "SELECT 1", Max: ptr(60)},
+		{Name: "recorded", Provider: "shop", Query: "/missing", JSONPath: "$.x"}, // sem limite: erro só é registrado
+		{Name: "inconclusive", Provider: "badinflux", Query: "SELECT 1", Max: ptr(60), OnError: OnErrorSkip},
+	}, hooks.Vars{App: "web"})
+	r := an.Run(context.Background(), "probe")
+	failed := 0
+	for _, m := range r.Measurements {
+		if m.Err == nil { t.Errorf("%s: expected error", m.Metric) }
+		if m.Failed != "" { failed++ }
+	}
+	if failed != 2 { t.Fatalf("failed = %d, want 2: %+v", failed, r.Measurements) }
+
+	for _, tpl := range []config.AnalysisTemplate{
+		{Name: "x", Metrics: []config.Metric{{Name: "m", Provider: "nope", Query: "q", Range: "5m"}}},
+		{Name: "x", Metrics: []config.Metric{{Name: "m", Provider: "shop", Query: "/q", Range: "5m"}}},
+		{Name: "x", Metrics: []config.Metric{{Name: "m", Provider: "prometheus", Query: "q", Range: "5 minutes"}}},
+		{Name: "x", Metrics: []config.Metric{{Name: "m", Provider: "prometheus", Query: "q", Range: "5m", OnError: "ignore"}}},
+	} {
+		if err := ps.Validate([]config.AnalysisTemplate{tpl}); err == nil { t.Errorf("Validate(%+v) accepted", tpl.Metrics[0]) }
+	}
+	if _, err := NewProviders([]config.AnalysisProvider{{Name: "x", Type: "statsd"}}, nil, nil); err == nil { t.Error("unknown provider type accepted") }
+}
+
+func TestJobProvider(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	p := &jobProvider{cs: cs, timeout: 5 * time.Second, poll: time.Millisecond}
+	m := config.Metric{Name: "synthetic-login", Provider: "synthetic", Max: ptr(0), Job: &config.JobHook{Template: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "probe", "image": "ghcr.io/acme/synthetics:1"}},
+		}}},
+	}}}
+
+	// simula o controller: o Job falha e o pod termina com exit code 3
+	go func() {
+		for {
+			list, _ := cs.BatchV1().Jobs("prod").List(context.Background(), meta.ListOptions{})
+			if len(list.Items) > 0 {
+				j := list.Items[0]
+				pod := &corev1.Pod{
+					ObjectMeta: meta.ObjectMeta{Name: j.Name + "-x", Namespace: "prod", Labels: map[string]string{"job-name": j.Name}},
+					Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{Name: "probe", State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 3}}}}},
+				}
+				_, _ = cs.CoreV1().Pods("prod").Create(context.Background(), pod, meta.CreateOptions{})
+				j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+				_, _ = cs.BatchV1().Jobs("prod").UpdateStatus(context.Background(), &j, meta.UpdateOptions{})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+	an := Providers{"synthetic": p}.For([]config.Metric{m}, hooks.Vars{DeployID: "d1", Namespace: "prod", App: "web"})
+	r := an.Run(context.Background(), "probe")
+	if ms := r.Measurements[0]; ms.Err != nil || ms.Value != 3 || r.OK() { t.Fatalf("measurement = %+v", ms) }
+}
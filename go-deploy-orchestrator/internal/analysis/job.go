@@ -0,0 +1,73 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+)
+
+// jobProvider roda o Job da métrica (ex: teste sintético) e devolve o exit
+// code do container; por padrão a métrica exige max 0.
+type jobProvider struct {
+	cs      kubernetes.Interface
+	timeout time.Duration
+	poll    time.Duration
+}
+
+func (p *jobProvider) Value(ctx context.Context, m config.Metric, v hooks.Vars) (float64, error) {
+	if m.Job == nil { return 0, fmt.Errorf("metric %s: job.template is required", m.Name) }
+	job, err := hooks.NewJob(m.Job.Template, m.Name, v)
+	if err != nil { return 0, err }
+	jobs := p.cs.BatchV1().Jobs(job.Namespace)
+	if _, err := jobs.Create(ctx, job, meta.CreateOptions{}); err != nil { return 0, fmt.Errorf("create job: %w", err) }
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	for {
+		cur, err := jobs.Get(ctx, job.Name, meta.GetOptions{})
+		if err == nil {
+			for _, c := range cur.Status.Conditions {
+				if c.Status != corev1.ConditionTrue { continue }
+				switch c.Type {
+				case batchv1.JobComplete: return p.exitCode(ctx, job, 0)
+				case batchv1.JobFailed: return p.exitCode(ctx, job, 1)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			bg := meta.DeletePropagationBackground
+			_ = jobs.Delete(context.WithoutCancel(ctx), job.Name, meta.DeleteOptions{PropagationPolicy: &bg})
+			return 0, fmt.Errorf("job %s did not finish in %s", job.Name, p.timeout)
+		case <-time.After(p.poll):
+		}
+	}
+}
+
+// exitCode lê o exit code do pod mais recente do Job (o primeiro container
+// que saiu com erro); sem status de container, usa def.
+func (p *jobProvider) exitCode(ctx context.Context, job *batchv1.Job, def int32) (float64, error) {
+	pods, err := p.cs.CoreV1().Pods(job.Namespace).List(ctx, meta.ListOptions{LabelSelector: "job-name=" + job.Name})
+	if err != nil { return 0, err }
+	var last *corev1.Pod
+	for i := range pods.Items {
+		if last == nil || last.CreationTimestamp.Before(&pods.Items[i].CreationTimestamp) { last = &pods.Items[i] }
+	}
+	if last == nil { return float64(def), nil }
+	code, seen := int32(0), false
+	for _, cs := range last.Status.ContainerStatuses {
+		if t := cs.State.Terminated; t != nil {
+			seen = true
+			if t.ExitCode != 0 && code == 0 { code = t.ExitCode }
+		}
+	}
+	if !seen { return float64(def), nil }
+	return float64(code), nil
+}
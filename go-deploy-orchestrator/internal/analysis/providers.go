@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+)
+
+var errNoData = errors.New("query returned no data")
+
+type promProvider struct{ e *prometheus.Evaluator }
+
+func (p promProvider) Value(ctx context.Context, m config.Metric, v hooks.Vars) (float64, error) {
+	return p.e.QueryRange(ctx, v.Expand(m.Query), m.Range)
+}
+
+// httpProvider faz GET numa URL que responde JSON e extrai o valor com JSONPath.
+type httpProvider struct {
+	base    string
+	headers map[string]string
+	cl      *http.Client
+}
+
+// path aceita "$.a.b", ".a.b" ou a sintaxe do kubectl "{.a.b}".
+func (p *httpProvider) path(m config.Metric) (*jsonpath.JSONPath, error) {
+	if m.JSONPath == "" { return nil, errors.New("jsonPath is required") }
+	if p.base == "" && m.Query == "" { return nil, errors.New("query (url) is required") }
+	expr := m.JSONPath
+	if !strings.HasPrefix(expr, "{") { expr = "{" + expr + "}" }
+	jp := jsonpath.New(m.Name)
+	if err := jp.Parse(expr); err != nil { return nil, fmt.Errorf("jsonPath: %w", err) }
+	return jp, nil
+}
+
+func (p *httpProvider) Value(ctx context.Context, m config.Metric, v hooks.Vars) (float64, error) {
+	jp, err := p.path(m)
+	if err != nil { return 0, err }
+	u := v.Expand(m.Query)
+	if !strings.Contains(u, "://") { u = strings.TrimRight(p.base, "/") + "/" + strings.TrimLeft(u, "/") }
+	var body any
+	if err := getJSON(ctx, p.cl, u, p.headers, v, &body); err != nil { return 0, err }
+	res, err := jp.FindResults(body)
+	if err != nil { return 0, err }
+	if len(res) == 0 || len(res[0]) == 0 { return 0, errNoData }
+	return toFloat(res[0][0].Interface())
+}
+
+// graphiteProvider usa a render API (format=json) e pega o último ponto não nulo.
+type graphiteProvider struct {
+	base    string
+	headers map[string]string
+	cl      *http.Client
+}
+
+func (p *graphiteProvider) Value(ctx context.Context, m config.Metric, v hooks.Vars) (float64, error) {
+	win, err := time.ParseDuration(m.Range)
+	if err != nil { return 0, err }
+	q := url.Values{"target": {v.Expand(m.Query)}, "from": {fmt.Sprintf("-%ds", int(win.Seconds()))}, "format": {"json"}}
+	var series []struct {
+		Target     string       `json:"target"`
+		Datapoints [][2]*float64 `json:"datapoints"` // [valor, timestamp]
+	}
+	if err := getJSON(ctx, p.cl, strings.TrimRight(p.base, "/")+"/render?"+q.Encode(), p.headers, v, &series); err != nil { return 0, err }
+	if len(series) == 0 { return 0, errNoData }
+	dps := series[0].Datapoints
+	for i := len(dps) - 1; i >= 0; i-- {
+		if dps[i][0] != nil { return *dps[i][0], nil }
+	}
+	return 0, errNoData
+}
+
+// influxProvider roda uma InfluxQL (API /query do 1.x) e usa a última coluna
+// da última linha da primeira série.
+type influxProvider struct {
+	base    string
+	db      string
+	headers map[string]string
+	cl      *http.Client
+}
+
+func (p *influxProvider) Value(ctx context.Context, m config.Metric, v hooks.Vars) (float64, error) {
+	q := url.Values{"db": {p.db}, "q": {v.Expand(m.Query)}}
+	var payload struct {
+		Results []struct {
+			Error  string `json:"error"`
+			Series []struct {
+				Columns []string `json:"columns"`
+				Values  [][]any  `json:"values"`
+			} `json:"series"`
+		} `json:"results"`
+	}
+	if err := getJSON(ctx, p.cl, strings.TrimRight(p.base, "/")+"/query?"+q.Encode(), p.headers, v, &payload); err != nil { return 0, err }
+	if len(payload.Results) == 0 { return 0, errNoData }
+	res := payload.Results[0]
+	if res.Error != "" { return 0, errors.New("influx: " + res.Error) }
+	if len(res.Series) == 0 || len(res.Series[0].Values) == 0 { return 0, errNoData }
+	row := res.Series[0].Values[len(res.Series[0].Values)-1]
+	if len(row) == 0 { return 0, errNoData }
+	return toFloat(row[len(row)-1])
+}
+
+func getJSON(ctx context.Context, cl *http.Client, u string, headers map[string]string, v hooks.Vars, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil { return err }
+	req.Header.Set("Accept", "application/json")
+	for k, val := range headers { req.Header.Set(k, v.Expand(val)) }
+	resp, err := cl.Do(req)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("GET %s: %s: %s", req.URL.Redacted(), resp.Status, strings.TrimSpace(string(b)))
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(out); err != nil { return fmt.Errorf("GET %s: %w", req.URL.Redacted(), err) }
+	return nil
+}
+
+func toFloat(x any) (float64, error) {
+	switch x := x.(type) {
+	case float64: return x, nil
+	case json.Number: return x.Float64()
+	case string: return strconv.ParseFloat(strings.TrimSpace(x), 64)
+	case bool:
+		if x { return 1, nil }
+		return 0, nil
+	case nil: return 0, errNoData
+	}
+	return 0, fmt.Errorf("value %v (%T) is not a number", x, x)
+}
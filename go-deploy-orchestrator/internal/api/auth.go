@@ -0,0 +1,56 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// authn resolve a identidade e a coloca no contexto. Token inválido é 401;
+// ausência de token segue como anônimo e cada handler decide via can.
+func (s *Server) authn(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := s.d.Auth.Authenticate(r)
+		if err != nil && !errors.Is(err, auth.ErrNoToken) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized); return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+	})
+}
+
+// can verifica a ação para a identidade da requisição e responde 401/403 se negar.
+func (s *Server) can(w http.ResponseWriter, r *http.Request, action, ns, app string) bool {
+	id := auth.FromContext(r.Context())
+	return s.d.Auth.Can(id, action, ns, app) || deny(w, id, action, ns, app)
+}
+
+// canScope é can para escopos em glob (overrides), via Service.CanScope.
+func (s *Server) canScope(w http.ResponseWriter, r *http.Request, action, ns, app string) bool {
+	id := auth.FromContext(r.Context())
+	return s.d.Auth.CanScope(id, action, ns, app) || deny(w, id, action, ns, app)
+}
+
+func deny(w http.ResponseWriter, id auth.Identity, action, ns, app string) bool {
+	if id.Anonymous() {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	} else {
+		http.Error(w, "forbidden: "+id.Name+" cannot "+action+" "+ns+"/"+app, http.StatusForbidden)
+	}
+	return false
+}
+
+// record carrega o deploy {id} e verifica a ação no namespace/app dele.
+func (s *Server) record(w http.ResponseWriter, r *http.Request, action string) (*store.DeployRecord, bool) {
+	rec, _ := s.d.Store.Get(chi.URLParam(r, "id"))
+	if rec == nil { http.Error(w, "not found", http.StatusNotFound); return nil, false }
+	if !s.can(w, r, action, rec.Namespace, rec.App) { return nil, false }
+	return rec, true
+}
+
+func (s *Server) handleWhoami(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, auth.FromContext(r.Context()))
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// GET /deploys/{id}/events?after=N -> timeline completa (ou a partir de N)
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.record(w, r, auth.ActionRead)
+	if !ok { return }
+	id := rec.ID
+	after, _ := strconv.ParseUint(r.URL.Query().Get("after"), 10, 64)
+	evs, err := s.d.Store.Events(id, after)
+	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	w.Header().Set("Content-Type","application/json")
+	_ = json.NewEncoder(w).Encode(evs)
+}
+
+// GET /deploys/{id}/watch -> Server-Sent Events com a timeline ao vivo.
+// Reenvia o histórico (respeitando Last-Event-ID) e fecha quando o deploy termina.
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.record(w, r, auth.ActionRead)
+	if !ok { return }
+	id := rec.ID
+	fl, ok := w.(http.Flusher)
+	if !ok { http.Error(w, "streaming unsupported", http.StatusInternalServerError); return }
+
+	last, _ := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64)
+	wake, cancel := s.d.Orc.Subscribe(id)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fl.Flush()
+
+	ping := time.NewTicker(15 * time.Second)
+	defer ping.Stop()
+	for {
+		evs, err := s.d.Store.Events(id, last)
+		if err != nil { return }
+		done := false
+		for _, ev := range evs {
+			b, _ := json.Marshal(ev)
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.Seq, ev.Type, b)
+			last = ev.Seq
+			done = done || isFinalEvent(ev)
+		}
+		fl.Flush()
+		if done { return }
+		select {
+		case <-r.Context().Done(): return
+		case <-wake:
+		case <-ping.C:
+			// evento final pode ter falhado ao gravar; não prende o cliente para sempre
+			if cur, _ := s.d.Store.Get(id); cur != nil && cur.Finished() { return }
+			fmt.Fprint(w, ": ping\n\n")
+			fl.Flush()
+		}
+	}
+}
+
+func isFinalEvent(ev store.Event) bool {
+	return ev.Type == "status" && store.DeployRecord{Status: ev.Data["status"]}.Finished()
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/orchestrator"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// kubeconfig aponta para um API server que nunca é chamado nestes testes.
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters: [{name: test, cluster: {server: "http://127.0.0.1:1"}}]
+contexts: [{name: test, context: {cluster: test, user: test}}]
+users: [{name: test, user: {}}]
+current-context: test
+`
+
+func newWatchServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	dir := t.TempDir()
+	kc := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(kc, []byte(kubeconfig), 0o600); err != nil { t.Fatal(err) }
+	db, err := store.Open(filepath.Join(dir, "deploys.db"))
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { _ = db.Close() })
+	cfg := &config.Config{}
+	cfg.Kube.Kubeconfig = kc
+	log := logger.New("error")
+	orc, err := orchestrator.New(log, cfg, db, nil)
+	if err != nil { t.Fatal(err) }
+	authz, err := auth.New(config.Auth{}, "")
+	if err != nil { t.Fatal(err) }
+	s := NewServer(Deps{Log: log, Orc: orc, Store: db, Cfg: cfg, Auth: authz}, Config{})
+
+	r := chi.NewRouter()
+	r.Use(s.authn)
+	r.Get("/deploys/{id}/watch", s.handleWatch)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return s, srv
+}
+
+// readSSE lê o stream até o servidor fechar e devolve os campos "id: ..." e "event: ...".
+func readSSE(t *testing.T, resp *http.Response) []string {
+	t.Helper()
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" { t.Fatalf("content-type = %q", ct) }
+	var out []string
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		if l := sc.Text(); strings.HasPrefix(l, "id: ") || strings.HasPrefix(l, "event: ") { out = append(out, l) }
+	}
+	return out
+}
+
+func TestWatchReplaysAndCloses(t *testing.T) {
+	s, srv := newWatchServer(t)
+	if err := s.d.Store.Put(store.DeployRecord{ID: "d1", App: "web", Namespace: "prod", Status: "succeeded"}); err != nil { t.Fatal(err) }
+	for _, ev := range []store.Event{
+		{Type: "status", Data: map[string]string{"status": "started"}},
+		{Type: "scale", Step: "scale_1"},
+		{Type: "status", Data: map[string]string{"status": "succeeded"}},
+	} {
+		if _, err := s.d.Store.AppendEvent("d1", ev); err != nil { t.Fatal(err) }
+	}
+
+	resp, err := http.Get(srv.URL + "/deploys/d1/watch")
+	if err != nil { t.Fatal(err) }
+	got := strings.Join(readSSE(t, resp), "|")
+	if want := "id: 1|event: status|id: 2|event: scale|id: 3|event: status"; got != want { t.Fatalf("stream = %s", got) }
+
+	// Last-Event-ID retoma depois do último evento recebido
+	req, _ := http.NewRequest("GET", srv.URL+"/deploys/d1/watch", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil { t.Fatal(err) }
+	if got := strings.Join(readSSE(t, resp), "|"); got != "id: 3|event: status" { t.Fatalf("retomado = %s", got) }
+
+	resp, err = http.Get(srv.URL + "/deploys/nope/watch")
+	if err != nil { t.Fatal(err) }
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound { t.Fatalf("inexistente: %d", resp.StatusCode) }
+}
+
+func TestWatchLive(t *testing.T) {
+	s, srv := newWatchServer(t)
+	if err := s.d.Store.Put(store.DeployRecord{ID: "d1", App: "web", Namespace: "prod", Status: "waiting_approval"}); err != nil { t.Fatal(err) }
+	if _, err := s.d.Store.AppendEvent("d1", store.Event{Type: "status", Data: map[string]string{"status": "waiting_approval"}}); err != nil { t.Fatal(err) }
+
+	resp, err := http.Get(srv.URL + "/deploys/d1/watch")
+	if err != nil { t.Fatal(err) }
+	defer resp.Body.Close()
+	lines := make(chan string)
+	go func() {
+		sc := bufio.NewScanner(resp.Body)
+		for sc.Scan() {
+			if l := sc.Text(); strings.HasPrefix(l, "id: ") || strings.HasPrefix(l, "event: ") { lines <- l }
+		}
+		close(lines)
+	}()
+	next := func() string {
+		select {
+		case l := <-lines: return l
+		case <-time.After(5 * time.Second): t.Fatal("evento não chegou"); return ""
+		}
+	}
+	if got := next() + "|" + next(); got != "id: 1|event: status" { t.Fatalf("histórico = %s", got) }
+
+	// o approve grava os eventos novos e acorda o watcher
+	if err := s.d.Orc.Approve("d1", "alice"); err != nil { t.Fatal(err) }
+	var got []string
+	for i := 0; i < 4; i++ { got = append(got, next()) }
+	if g := strings.Join(got, "|"); g != "id: 2|event: approval|id: 3|event: status" { t.Fatalf("ao vivo = %s", g) }
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/stats"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// GET /deploys?app=&namespace=&status=&since=&limit=&cursor=
+func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
+	if !s.can(w, r, auth.ActionRead, "", "") { return }
+	q := r.URL.Query()
+	id := auth.FromContext(r.Context())
+	lq := store.ListQuery{
+		App: q.Get("app"), Namespace: q.Get("namespace"), Status: q.Get("status"), Cursor: q.Get("cursor"),
+		Filter: func(rec store.DeployRecord) bool { return s.d.Auth.Can(id, auth.ActionRead, rec.Namespace, rec.App) },
+	}
+	if v := q.Get("since"); v != "" {
+		t, err := parseSince(v, time.Now())
+		if err != nil { http.Error(w, "invalid since", http.StatusBadRequest); return }
+		lq.Since = t
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 { http.Error(w, "invalid limit", http.StatusBadRequest); return }
+		lq.Limit = n
+	}
+	page, err := s.d.Store.Query(lq)
+	if errors.Is(err, store.ErrBadCursor) { http.Error(w, err.Error(), http.StatusBadRequest); return }
+	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	writeJSON(w, http.StatusOK, page)
+}
+
+// GET /stats?window=&namespace=&app=
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if !s.can(w, r, auth.ActionRead, "", "") { return }
+	q := r.URL.Query()
+	var window time.Duration
+	if v := q.Get("window"); v != "" {
+		d, err := parseDuration(v)
+		if err != nil || d <= 0 { http.Error(w, "invalid window", http.StatusBadRequest); return }
+		window = d
+	}
+	rep, err := s.d.Orc.Stats(window)
+	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	id := auth.FromContext(r.Context())
+	apps := []stats.AppStats{}
+	for _, a := range rep.Apps {
+		if ns := q.Get("namespace"); ns != "" && a.Namespace != ns { continue }
+		if app := q.Get("app"); app != "" && a.App != app { continue }
+		if s.d.Auth.Can(id, auth.ActionRead, a.Namespace, a.App) { apps = append(apps, a) }
+	}
+	rep.Apps = apps
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// parseSince aceita RFC3339 ou uma duração relativa a now (ex: 24h, 7d).
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil { return t, nil }
+	d, err := parseDuration(v)
+	if err != nil { return time.Time{}, err }
+	return now.Add(-d), nil
+}
+
+// parseDuration é time.ParseDuration com suporte a dias ("7d").
+func parseDuration(v string) (time.Duration, error) {
+	if n, ok := strings.CutSuffix(v, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil { return 0, err }
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(v)
+}
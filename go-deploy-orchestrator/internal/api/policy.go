@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/orchestrator"
+)
+
+type overrideReq struct {
+	Namespace string   `json:"namespace"` // glob, vazio = *
+	App       string   `json:"app"`       // glob, vazio = *
+	Rules     []string `json:"rules"`     // freeze|business_hours|images, vazio = todas
+	Reason    string   `json:"reason"`
+	CreatedBy string   `json:"createdBy"`
+	Duration  string   `json:"duration"`  // ex: 2h
+}
+
+func (s *Server) handleCreateOverride(w http.ResponseWriter, r *http.Request) {
+	var req overrideReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "invalid override payload", http.StatusBadRequest); return }
+	if req.Namespace == "" { req.Namespace = "*" }
+	if req.App == "" { req.App = "*" }
+	if !s.canScope(w, r, auth.ActionAdmin, req.Namespace, req.App) { return }
+	if id := auth.FromContext(r.Context()); !id.Anonymous() { req.CreatedBy = id.Name }
+	d, err := time.ParseDuration(req.Duration)
+	if err != nil { http.Error(w, "invalid duration", http.StatusBadRequest); return }
+	ov, err := s.d.Orc.CreateOverride(orchestrator.OverrideInput{
+		Namespace: req.Namespace, App: req.App, Rules: req.Rules, Reason: req.Reason, CreatedBy: req.CreatedBy, Duration: d,
+	})
+	if err != nil { http.Error(w, err.Error(), http.StatusBadRequest); return }
+	writeJSON(w, http.StatusCreated, ov)
+}
+
+func (s *Server) handleListOverrides(w http.ResponseWriter, r *http.Request) {
+	if !s.can(w, r, auth.ActionRead, "", "") { return }
+	arr, err := s.d.Store.ListOverrides(r.URL.Query().Get("all") != "true")
+	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	writeJSON(w, http.StatusOK, arr)
+}
+
+func (s *Server) handleDeleteOverride(w http.ResponseWriter, r *http.Request) {
+	ov, err := s.d.Store.GetOverride(chi.URLParam(r, "id"))
+	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	if ov == nil { http.Error(w, "not found", http.StatusNotFound); return }
+	if !s.canScope(w, r, auth.ActionAdmin, ov.Namespace, ov.App) { return }
+	found, err := s.d.Store.DeleteOverride(ov.ID)
+	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
+	if !found { http.Error(w, "not found", http.StatusNotFound); return }
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/orchestrator"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/policy"
+)
+
+// POST /apps/{ns}/{app}/rollback?to=<deployID|revision>&strategy=fast|canary&queue=true
+// O corpo é opcional: {"params": {...}} com os mesmos params de um deploy.
+func (s *Server) handleRollback(w http.ResponseWriter, r *http.Request) {
+	ns, app := chi.URLParam(r, "ns"), chi.URLParam(r, "app")
+	if !s.can(w, r, auth.ActionRevert, ns, app) { return }
+	var body struct{ Params map[string]string `json:"params"` }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid rollback payload", http.StatusBadRequest); return
+	}
+	q := r.URL.Query()
+	rec, err := s.d.Orc.RollbackTo(r.Context(), orchestrator.RollbackInput{
+		App: app, Namespace: ns, To: q.Get("to"), Strategy: q.Get("strategy"), Params: body.Params,
+		Queue: q.Get("queue") == "true", Actor: auth.FromContext(r.Context()).Name,
+	})
+	var pe *policy.Error
+	var locked *orchestrator.LockedError
+	switch {
+	case errors.As(err, &pe):
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": pe.Error(), "policy": pe.Decision})
+	case errors.As(err, &locked):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": locked.Error(), "activeDeploy": locked.ActiveID})
+	case errors.Is(err, orchestrator.ErrNoRollbackTarget):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, orchestrator.ErrInvalidRollback), errors.Is(err, orchestrator.ErrInvalidWaves):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeJSON(w, http.StatusOK, rec)
+	}
+}
@@ -3,16 +3,19 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/go-chi/chi/v5"
 
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/orchestrator"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/policy"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/trigger"
 )
 
 type Deps struct {
@@ -20,6 +23,8 @@ type Deps struct {
 	Orc   *orchestrator.Orchestrator
 	Store *store.Store
 	Cfg   *config.Config
+	Auth  *auth.Service
+	Triggers *trigger.Matcher
 }
 type Config struct{ Addr string }
 
@@ -34,10 +39,23 @@ func (s *Server) Run(ctx context.Context) error {
 	r := chi.NewRouter()
 	r.Get("/healthz", func(w http.ResponseWriter, _ *http.Request) { _, _ = w.Write([]byte("ok")) })
 	r.Get("/metrics", func(w http.ResponseWriter, r *http.Request) { metrics.Handler().ServeHTTP(w, r) })
-	r.Post("/deploys", s.handleStart)
-	r.Get("/deploys", s.handleList)
-	r.Get("/deploys/{id}", s.handleGet)
-	r.Post("/deploys/{id}/approve", s.auth(s.handleApprove))
+	r.Post("/webhooks/registry", s.handleRegistryWebhook)
+	r.Group(func(r chi.Router) {
+		r.Use(s.authn)
+		r.Get("/whoami", s.handleWhoami)
+		r.Post("/deploys", s.handleStart)
+		r.Get("/deploys", s.handleList)
+		r.Get("/deploys/{id}", s.handleGet)
+		r.Get("/stats", s.handleStats)
+		r.Get("/deploys/{id}/events", s.handleEvents)
+		r.Get("/deploys/{id}/watch", s.handleWatch)
+		r.Post("/deploys/{id}/approve", s.handleApprove)
+		r.Post("/deploys/{id}/abort", s.handleAbort)
+		r.Post("/apps/{ns}/{app}/rollback", s.handleRollback)
+		r.Get("/policy/overrides", s.handleListOverrides)
+		r.Post("/policy/overrides", s.handleCreateOverride)
+		r.Delete("/policy/overrides/{id}", s.handleDeleteOverride)
+	})
 
 	srv := &http.Server{Addr: s.c.Addr, Handler: s.d.Log.HTTP(r)}
 	go func(){ <-ctx.Done(); _ = srv.Shutdown(context.Background()) }()
@@ -52,6 +70,7 @@ type deployReq struct {
 	Strategy  string            `json:"strategy"`
 	Params    map[string]string `json:"params"`
 	RequireApproval bool        `json:"requireApproval"`
+	Waves     []store.Wave      `json:"waves"` // deploy multi-cluster (vazio = waves do target)
 }
 
 func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
@@ -59,44 +78,72 @@ func (s *Server) handleStart(w http.ResponseWriter, r *http.Request) {
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.App=="" || req.Image=="" {
 		http.Error(w, "invalid deploy payload", http.StatusBadRequest); return
 	}
-	rec, err := s.d.Orc.StartDeploy(r.Context(), orchestrator.DeployInput{
-		App: req.App, Namespace: req.Namespace, Image: req.Image, Strategy: req.Strategy, Params: req.Params, RequireApproval: req.RequireApproval,
-	})
+	if req.Namespace == "" { req.Namespace = "default" }
+	in := orchestrator.DeployInput{
+		App: req.App, Namespace: req.Namespace, Image: req.Image, Strategy: req.Strategy, Params: req.Params, RequireApproval: req.RequireApproval, Waves: req.Waves,
+		Queue: r.URL.Query().Get("queue") == "true", Actor: auth.FromContext(r.Context()).Name,
+	}
+	if r.URL.Query().Get("dryRun") == "true" {
+		// o plano não altera nada; basta poder ler o app
+		if !s.can(w, r, auth.ActionRead, req.Namespace, req.App) { return }
+		plan, err := s.d.Orc.Plan(r.Context(), in)
+		if errors.Is(err, orchestrator.ErrInvalidWaves) { http.Error(w, err.Error(), http.StatusBadRequest); return }
+		if err != nil { http.Error(w, err.Error(), http.StatusUnprocessableEntity); return }
+		writeJSON(w, http.StatusOK, plan)
+		return
+	}
+	if !s.can(w, r, auth.ActionDeploy, req.Namespace, req.App) { return }
+	rec, err := s.d.Orc.StartDeploy(r.Context(), in)
+	var pe *policy.Error
+	if errors.As(err, &pe) {
+		writeJSON(w, http.StatusForbidden, map[string]any{"error": pe.Error(), "policy": pe.Decision})
+		return
+	}
+	var locked *orchestrator.LockedError
+	if errors.As(err, &locked) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": locked.Error(), "activeDeploy": locked.ActiveID})
+		return
+	}
+	if errors.Is(err, orchestrator.ErrInvalidWaves) { http.Error(w, err.Error(), http.StatusBadRequest); return }
 	if err != nil { http.Error(w, err.Error(), http.StatusInternalServerError); return }
 	w.Header().Set("Content-Type","application/json")
 	_ = json.NewEncoder(w).Encode(rec)
 }
 
-func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
-	arr, _ := s.d.Store.List()
-	w.Header().Set("Content-Type","application/json")
-	_ = json.NewEncoder(w).Encode(arr)
-}
 func (s *Server) handleGet(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	rec, _ := s.d.Store.Get(id)
-	if rec == nil { http.Error(w, "not found", http.StatusNotFound); return }
+	rec, ok := s.record(w, r, auth.ActionRead)
+	if !ok { return }
 	w.Header().Set("Content-Type","application/json")
 	_ = json.NewEncoder(w).Encode(rec)
 }
 
 func (s *Server) handleApprove(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	rec, _ := s.d.Store.Get(id)
-	if rec == nil { http.Error(w, "not found", http.StatusNotFound); return }
-	if rec.Status != "waiting_approval" { http.Error(w, "not waiting approval", http.StatusBadRequest); return }
-	rec.Status = "running"
-	_ = s.d.Store.Put(*rec)
+	rec, ok := s.record(w, r, auth.ActionApprove)
+	if !ok { return }
+	switch err := s.d.Orc.Approve(rec.ID, auth.FromContext(r.Context()).Name); {
+	case errors.Is(err, orchestrator.ErrNotFound): http.Error(w, "not found", http.StatusNotFound); return
+	case errors.Is(err, orchestrator.ErrNotWaitingApproval): http.Error(w, err.Error(), http.StatusBadRequest); return
+	case errors.Is(err, orchestrator.ErrSelfApproval): http.Error(w, err.Error(), http.StatusForbidden); return
+	case err != nil: http.Error(w, err.Error(), http.StatusInternalServerError); return
+	}
 	_, _ = w.Write([]byte("ok"))
 }
 
-func (s *Server) auth(next http.HandlerFunc) http.HandlerFunc {
-	if s.d.Cfg.AuthToken == "" { return next }
-	return func(w http.ResponseWriter, r *http.Request) {
-		got := r.Header.Get("Authorization")
-		if !strings.HasPrefix(got, "Bearer ") || strings.TrimPrefix(got, "Bearer ") != s.d.Cfg.AuthToken {
-			http.Error(w, "unauthorized", http.StatusUnauthorized); return
-		}
-		next(w, r)
+// abortar exige o mesmo papel que iniciar o deploy
+func (s *Server) handleAbort(w http.ResponseWriter, r *http.Request) {
+	rec, ok := s.record(w, r, auth.ActionAbort)
+	if !ok { return }
+	switch err := s.d.Orc.Abort(rec.ID, auth.FromContext(r.Context()).Name); {
+	case errors.Is(err, orchestrator.ErrNotFound): http.Error(w, "not found", http.StatusNotFound); return
+	case errors.Is(err, orchestrator.ErrAlreadyFinished), errors.Is(err, orchestrator.ErrNotLocal): http.Error(w, err.Error(), http.StatusConflict); return
+	case err != nil: http.Error(w, err.Error(), http.StatusInternalServerError); return
 	}
-}
\ No newline at end of file
+	w.WriteHeader(http.StatusAccepted)
+	_, _ = w.Write([]byte("ok"))
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type","application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/orchestrator"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/trigger"
+)
+
+type triggerResult struct {
+	Rule     string `json:"rule"`
+	Image    string `json:"image"`
+	DeployID string `json:"deployId,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// POST /webhooks/registry -> push do Docker Hub/Harbor/genérico, assinado com HMAC.
+// O HMAC substitui o RBAC aqui; policy e lock continuam valendo no StartDeploy.
+func (s *Server) handleRegistryWebhook(w http.ResponseWriter, r *http.Request) {
+	if !s.d.Triggers.Enabled() { http.Error(w, "no trigger rules configured", http.StatusNotFound); return }
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil { http.Error(w, "read body", http.StatusBadRequest); return }
+	sig := r.Header.Get("X-Signature-256")
+	if sig == "" { sig = r.Header.Get("X-Hub-Signature-256") }
+	if err := s.d.Triggers.Verify(body, sig); err != nil {
+		metrics.WebhookPushes.WithLabelValues("unknown", "rejected").Inc()
+		http.Error(w, err.Error(), http.StatusUnauthorized); return
+	}
+	pushes, err := trigger.Parse(body)
+	if err != nil {
+		metrics.WebhookPushes.WithLabelValues("unknown", "rejected").Inc()
+		http.Error(w, err.Error(), http.StatusBadRequest); return
+	}
+
+	results := []triggerResult{}
+	for _, p := range pushes {
+		rules := s.d.Triggers.Match(p)
+		if len(rules) == 0 {
+			metrics.WebhookPushes.WithLabelValues(p.Source, "ignored").Inc()
+			continue
+		}
+		metrics.WebhookPushes.WithLabelValues(p.Source, "matched").Inc()
+		for _, rule := range rules {
+			rec, err := s.d.Orc.StartDeploy(r.Context(), orchestrator.DeployInput{
+				App: rule.App, Namespace: rule.Namespace, Image: p.Image, Strategy: rule.Strategy, Params: rule.Params,
+				RequireApproval: rule.RequireApproval, Queue: rule.Queue, Actor: "webhook:" + rule.Name,
+			})
+			res := triggerResult{Rule: rule.Name, Image: p.Image}
+			if err != nil {
+				res.Error = err.Error()
+				s.d.Log.Warn().Err(err).Str("rule", rule.Name).Str("image", p.Image).Msg("webhook deploy rejected")
+			} else {
+				res.DeployID = rec.ID
+			}
+			results = append(results, res)
+		}
+	}
+	code := http.StatusAccepted
+	for _, res := range results {
+		if res.Error != "" { code = http.StatusMultiStatus; break }
+	}
+	writeJSON(w, code, results)
+}
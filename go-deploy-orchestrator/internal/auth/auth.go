@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+// Ações verificadas pelo Authorizer.
+const (
+	ActionRead    = "read"
+	ActionDeploy  = "deploy"
+	ActionApprove = "approve"
+	ActionAbort   = "abort"    // abortar um deploy em andamento
+	ActionRevert  = "rollback" // rollback manual para um deploy anterior
+	ActionAdmin   = "admin"
+)
+
+var rolePerms = map[string][]string{
+	"deployer": {ActionRead, ActionDeploy, ActionAbort, ActionRevert},
+	"approver": {ActionRead, ActionApprove},
+	"admin":    {ActionRead, ActionDeploy, ActionApprove, ActionAbort, ActionRevert, ActionAdmin},
+}
+
+const Anonymous = "anonymous"
+
+// SharedToken é a identidade do authToken do modo legado. Como qualquer
+// outra identidade, não aprova um deploy que ela mesma pediu.
+const SharedToken = "shared-token"
+
+// Identity é quem fez a requisição.
+type Identity struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+func (i Identity) Anonymous() bool { return i.Name == "" || i.Name == Anonymous }
+
+var (
+	ErrNoToken      = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+type ctxKey struct{}
+
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func FromContext(ctx context.Context) Identity {
+	if id, ok := ctx.Value(ctxKey{}).(Identity); ok { return id }
+	return Identity{Name: Anonymous}
+}
+
+type staticToken struct {
+	sum [32]byte
+	id  Identity
+}
+
+// Service autentica tokens (estáticos ou JWT) e autoriza ações por binding.
+type Service struct {
+	legacy   string // authToken do modo legado
+	tokens   []staticToken
+	jwt      *jwtVerifier
+	bindings []config.RoleBinding
+}
+
+func New(cfg config.Auth, legacyToken string) (*Service, error) {
+	s := &Service{legacy: legacyToken, bindings: cfg.Bindings}
+	for _, t := range cfg.Tokens {
+		if t.Name == "" || t.Token == "" { return nil, fmt.Errorf("auth token %q: name and token are required", t.Name) }
+		s.tokens = append(s.tokens, staticToken{sum: sha256.Sum256([]byte(t.Token)), id: Identity{Name: t.Name, Groups: t.Groups}})
+	}
+	if cfg.JWT.JWKSFile != "" {
+		v, err := newJWTVerifier(cfg.JWT)
+		if err != nil { return nil, err }
+		s.jwt = v
+	}
+	for _, b := range cfg.Bindings {
+		if _, ok := rolePerms[b.Role]; !ok { return nil, fmt.Errorf("unknown role %q", b.Role) }
+	}
+	return s, nil
+}
+
+// Enabled indica se há autenticação por identidade (tokens ou JWKS).
+func (s *Service) Enabled() bool { return len(s.tokens) > 0 || s.jwt != nil }
+
+// Authenticate resolve a identidade do bearer token. Sem token, devolve
+// anônimo (ErrNoToken); quem decide se anônimo pode algo é Can.
+func (s *Service) Authenticate(r *http.Request) (Identity, error) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") { return Identity{Name: Anonymous}, ErrNoToken }
+	tok := strings.TrimPrefix(h, "Bearer ")
+	sum := sha256.Sum256([]byte(tok))
+	if !s.Enabled() {
+		if s.legacy != "" && subtle.ConstantTimeCompare([]byte(tok), []byte(s.legacy)) == 1 { return Identity{Name: SharedToken}, nil }
+		return Identity{Name: Anonymous}, ErrInvalidToken
+	}
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(sum[:], t.sum[:]) == 1 { return t.id, nil }
+	}
+	if s.jwt != nil && strings.Count(tok, ".") == 2 {
+		id, err := s.jwt.verify(tok)
+		if err != nil { return Identity{Name: Anonymous}, fmt.Errorf("%w: %v", ErrInvalidToken, err) }
+		return id, nil
+	}
+	return Identity{Name: Anonymous}, ErrInvalidToken
+}
+
+// Can diz se a identidade pode executar a ação no namespace/app.
+// ns/app vazios (ex: listagens) só exigem o papel em algum escopo.
+func (s *Service) Can(id Identity, action, ns, app string) bool {
+	if !s.Enabled() {
+		// modo legado: sem authToken tudo é aberto; com authToken, anônimos só leem e iniciam deploys
+		if s.legacy == "" || !id.Anonymous() { return true }
+		return action == ActionRead || action == ActionDeploy
+	}
+	if id.Anonymous() { return false }
+	for _, b := range s.bindings {
+		if !allows(b.Role, action) || !subject(b.Subjects, id) { continue }
+		if ns != "" && !match(b.Namespaces, ns) { continue }
+		if app != "" && !match(b.Apps, app) { continue }
+		return true
+	}
+	return false
+}
+
+// CanScope é Can para um escopo que é ele mesmo um glob (overrides): cada
+// binding precisa cobrir o padrão literalmente, senão "team-?" liberaria
+// "team-*".
+func (s *Service) CanScope(id Identity, action, nsGlob, appGlob string) bool {
+	if !s.Enabled() { return s.Can(id, action, nsGlob, appGlob) }
+	if id.Anonymous() { return false }
+	for _, b := range s.bindings {
+		if !allows(b.Role, action) || !subject(b.Subjects, id) { continue }
+		if covers(b.Namespaces, nsGlob) && covers(b.Apps, appGlob) { return true }
+	}
+	return false
+}
+
+// covers: o glob do binding casa com tudo que p casa. Só é garantido quando
+// o binding é vazio ou "*", igual a p, ou p não tem metacaracteres.
+func covers(globs []string, p string) bool {
+	if len(globs) == 0 { return true }
+	for _, g := range globs {
+		if g == "*" || g == p { return true }
+		if !strings.ContainsAny(p, `*?[\`) {
+			if ok, _ := path.Match(g, p); ok { return true }
+		}
+	}
+	return false
+}
+
+func allows(role, action string) bool {
+	for _, a := range rolePerms[role] {
+		if a == action { return true }
+	}
+	return false
+}
+
+func subject(subjects []string, id Identity) bool {
+	for _, s := range subjects {
+		if g, ok := strings.CutPrefix(s, "group:"); ok {
+			for _, have := range id.Groups {
+				if have == g { return true }
+			}
+		} else if s == id.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func match(globs []string, v string) bool {
+	if len(globs) == 0 { return true }
+	for _, g := range globs {
+		if ok, _ := path.Match(g, v); ok { return true }
+	}
+	return false
+}
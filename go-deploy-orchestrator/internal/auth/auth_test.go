@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+var b64 = base64.RawURLEncoding
+
+func seg(v any) string { b, _ := json.Marshal(v); return b64.EncodeToString(b) }
+
+func pad32(n *big.Int) []byte { b := make([]byte, 32); n.FillBytes(b); return b }
+
+// sign monta um JWT com a chave dada; alg vai no header como está.
+func sign(t *testing.T, key crypto.Signer, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	in := seg(map[string]string{"alg": alg, "kid": kid}) + "." + seg(claims)
+	d := sha256.Sum256([]byte(in))
+	var sig []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, d[:])
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, d[:])
+		sig = append(pad32(r), pad32(s)...)
+	}
+	return in + "." + b64.EncodeToString(sig)
+}
+
+func writeJWKS(t *testing.T, rk *rsa.PrivateKey, ek *ecdsa.PrivateKey) string {
+	t.Helper()
+	set := map[string]any{"keys": []map[string]string{
+		{"kid": "rsa1", "kty": "RSA", "n": b64.EncodeToString(rk.N.Bytes()), "e": b64.EncodeToString(big.NewInt(int64(rk.E)).Bytes())},
+		{"kid": "ec1", "kty": "EC", "crv": "P-256", "x": b64.EncodeToString(pad32(ek.X)), "y": b64.EncodeToString(pad32(ek.Y))},
+	}}
+	p := filepath.Join(t.TempDir(), "jwks.json")
+	b, _ := json.Marshal(set)
+	if err := os.WriteFile(p, b, 0o600); err != nil { t.Fatal(err) }
+	return p
+}
+
+func authenticate(s *Service, tok string) (Identity, error) {
+	r := httptest.NewRequest("GET", "/", nil)
+	if tok != "" { r.Header.Set("Authorization", "Bearer "+tok) }
+	return s.Authenticate(r)
+}
+
+func TestJWKS(t *testing.T) {
+	rk, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ek, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	s, err := New(config.Auth{JWT: config.JWTAuth{JWKSFile: writeJWKS(t, rk, ek), Issuer: "https://idp", Audience: "orchestrator", UsernameClaim: "sub", GroupsClaim: "groups"}}, "")
+	if err != nil { t.Fatal(err) }
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := func(over map[string]any) map[string]any {
+		c := map[string]any{"sub": "alice", "groups": []string{"sre"}, "iss": "https://idp", "aud": "orchestrator", "exp": exp}
+		for k, v := range over { c[k] = v }
+		return c
+	}
+
+	tests := []struct {
+		name string
+		tok  string
+		ok   bool
+	}{
+		{"RS256", sign(t, rk, "RS256", "rsa1", claims(nil)), true},
+		{"ES256", sign(t, ek, "ES256", "ec1", claims(nil)), true},
+		{"aud em lista", sign(t, rk, "RS256", "rsa1", claims(map[string]any{"aud": []string{"x", "orchestrator"}})), true},
+		{"alg trocado no header", sign(t, rk, "ES256", "rsa1", claims(nil)), false},
+		{"alg none", seg(map[string]string{"alg": "none", "kid": "rsa1"}) + "." + seg(claims(nil)) + ".", false},
+		{"HS256 com a chave publica", sign(t, rk, "HS256", "rsa1", claims(nil)), false},
+		{"chave de fora", sign(t, other, "RS256", "rsa1", claims(nil)), false},
+		{"kid desconhecido", sign(t, rk, "RS256", "nope", claims(nil)), false},
+		{"expirado", sign(t, rk, "RS256", "rsa1", claims(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})), false},
+		{"sem exp", sign(t, rk, "RS256", "rsa1", claims(map[string]any{"exp": nil})), false},
+		{"issuer errado", sign(t, rk, "RS256", "rsa1", claims(map[string]any{"iss": "https://evil"})), false},
+		{"audience errada", sign(t, ek, "ES256", "ec1", claims(map[string]any{"aud": "other"})), false},
+	}
+	for _, tt := range tests {
+		id, err := authenticate(s, tt.tok)
+		if tt.ok && (err != nil || id.Name != "alice" || len(id.Groups) != 1 || id.Groups[0] != "sre") { t.Errorf("%s: id=%+v err=%v", tt.name, id, err) }
+		if !tt.ok && err == nil { t.Errorf("%s: aceito como %+v", tt.name, id) }
+	}
+}
+
+func TestBindings(t *testing.T) {
+	s, err := New(config.Auth{
+		Tokens: []config.StaticToken{{Name: "ci", Token: "t-ci", Groups: []string{"payments"}}, {Name: "bob", Token: "t-bob"}, {Name: "carol", Token: "t-carol"}},
+		Bindings: []config.RoleBinding{
+			{Role: "deployer", Subjects: []string{"group:payments"}, Namespaces: []string{"payments-*"}},
+			{Role: "approver", Subjects: []string{"bob"}},
+			{Role: "admin", Subjects: []string{"carol"}, Namespaces: []string{"team-?"}, Apps: []string{"web"}},
+		},
+	}, "")
+	if err != nil { t.Fatal(err) }
+	ci, _ := authenticate(s, "t-ci")
+	bob, _ := authenticate(s, "t-bob")
+	carol, _ := authenticate(s, "t-carol")
+	if _, err := authenticate(s, "wrong"); err != ErrInvalidToken { t.Fatalf("token errado: %v", err) }
+	if id, err := authenticate(s, ""); err != ErrNoToken || !id.Anonymous() { t.Fatalf("sem token: %+v %v", id, err) }
+
+	tests := []struct {
+		name            string
+		id              Identity
+		action, ns, app string
+		want            bool
+	}{
+		{"grupo no escopo", ci, ActionDeploy, "payments-eu", "api", true},
+		{"grupo fora do escopo", ci, ActionDeploy, "billing", "api", false},
+		{"deployer não aprova", ci, ActionApprove, "payments-eu", "api", false},
+		{"deployer aborta", ci, ActionAbort, "payments-eu", "api", true},
+		{"rollback no escopo", ci, ActionRevert, "payments-eu", "api", true},
+		{"leitura vem com o papel", ci, ActionRead, "payments-eu", "", true},
+		{"listagem sem escopo", ci, ActionRead, "", "", true},
+		{"approver em qualquer namespace", bob, ActionApprove, "billing", "x", true},
+		{"approver não faz deploy", bob, ActionDeploy, "billing", "x", false},
+		{"approver não aborta", bob, ActionAbort, "billing", "x", false},
+		{"admin no escopo", carol, ActionAdmin, "team-a", "web", true},
+		{"admin em outro app", carol, ActionAdmin, "team-a", "api", false},
+		{"anônimo", Identity{Name: Anonymous}, ActionRead, "", "", false},
+	}
+	for _, tt := range tests {
+		if got := s.Can(tt.id, tt.action, tt.ns, tt.app); got != tt.want { t.Errorf("%s: Can=%v want %v", tt.name, got, tt.want) }
+	}
+
+	// overrides: o binding precisa cobrir o glob pedido, não só casar com ele
+	for _, tt := range []struct {
+		ns, app string
+		want    bool
+	}{{"team-a", "web", true}, {"team-*", "web", false}, {"team-?", "web", true}, {"*", "web", false}, {"team-a", "*", false}} {
+		if got := s.CanScope(carol, ActionAdmin, tt.ns, tt.app); got != tt.want { t.Errorf("CanScope(%s/%s)=%v want %v", tt.ns, tt.app, got, tt.want) }
+	}
+	if _, err := New(config.Auth{Bindings: []config.RoleBinding{{Role: "root"}}}, ""); err == nil { t.Fatal("papel desconhecido aceito") }
+}
+
+func TestLegacy(t *testing.T) {
+	open, _ := New(config.Auth{}, "")
+	if open.Enabled() || !open.Can(Identity{Name: Anonymous}, ActionAdmin, "", "") { t.Fatal("sem authToken tudo é aberto") }
+
+	s, _ := New(config.Auth{}, "legacy-secret")
+	id, err := authenticate(s, "legacy-secret")
+	if err != nil || id.Name != SharedToken { t.Fatalf("authToken: %+v %v", id, err) }
+	if _, err := authenticate(s, "other"); err != ErrInvalidToken { t.Fatalf("token errado: %v", err) }
+	anon := Identity{Name: Anonymous}
+	for action, want := range map[string]bool{ActionRead: true, ActionDeploy: true, ActionApprove: false, ActionAbort: false, ActionRevert: false, ActionAdmin: false} {
+		if got := s.Can(anon, action, "ns", "app"); got != want { t.Errorf("anônimo %s: %v want %v", action, got, want) }
+		if !s.Can(id, action, "ns", "app") { t.Errorf("authToken %s negado", action) }
+	}
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+// jwtVerifier valida tokens RS256/ES256 contra um JWKS local. O arquivo é
+// relido quando aparece um kid desconhecido (rotação de chaves no IdP).
+type jwtVerifier struct {
+	cfg config.JWTAuth
+
+	mu   sync.RWMutex
+	keys map[string]crypto.PublicKey
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func newJWTVerifier(cfg config.JWTAuth) (*jwtVerifier, error) {
+	v := &jwtVerifier{cfg: cfg}
+	if err := v.load(); err != nil { return nil, fmt.Errorf("load jwks: %w", err) }
+	return v, nil
+}
+
+func (v *jwtVerifier) load() error {
+	b, err := os.ReadFile(v.cfg.JWKSFile)
+	if err != nil { return err }
+	var set struct{ Keys []jwk `json:"keys"` }
+	if err := json.Unmarshal(b, &set); err != nil { return err }
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		pub, err := k.publicKey()
+		if err != nil { return fmt.Errorf("key %q: %w", k.Kid, err) }
+		keys[k.Kid] = pub
+	}
+	v.mu.Lock()
+	v.keys = keys
+	v.mu.Unlock()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64int(k.N)
+		if err != nil { return nil, err }
+		e, err := b64int(k.E)
+		if err != nil { return nil, err }
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" { return nil, fmt.Errorf("unsupported curve %q", k.Crv) }
+		x, err := b64int(k.X)
+		if err != nil { return nil, err }
+		y, err := b64int(k.Y)
+		if err != nil { return nil, err }
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		if !pub.Curve.IsOnCurve(x, y) { return nil, errors.New("point not on curve") }
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+func (v *jwtVerifier) key(kid string) crypto.PublicKey {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if k, ok := v.keys[kid]; ok { return k }
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys { return k }
+	}
+	return nil
+}
+
+func (v *jwtVerifier) verify(tok string) (Identity, error) {
+	parts := strings.Split(tok, ".")
+	var hdr struct{ Alg, Kid string }
+	if err := decodeSegment(parts[0], &hdr); err != nil { return Identity{}, err }
+	pub := v.key(hdr.Kid)
+	if pub == nil {
+		if err := v.load(); err == nil { pub = v.key(hdr.Kid) }
+	}
+	if pub == nil { return Identity{}, fmt.Errorf("unknown kid %q", hdr.Kid) }
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil { return Identity{}, err }
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		if hdr.Alg != "RS256" { return Identity{}, fmt.Errorf("alg %q not allowed for RSA key", hdr.Alg) }
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig); err != nil { return Identity{}, errors.New("bad signature") }
+	case *ecdsa.PublicKey:
+		if hdr.Alg != "ES256" || len(sig) != 64 { return Identity{}, fmt.Errorf("alg %q not allowed for EC key", hdr.Alg) }
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest[:], r, s) { return Identity{}, errors.New("bad signature") }
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil { return Identity{}, err }
+	now := float64(time.Now().Unix())
+	exp, ok := claims["exp"].(float64)
+	if !ok || now >= exp { return Identity{}, errors.New("token expired") }
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf { return Identity{}, errors.New("token not yet valid") }
+	if v.cfg.Issuer != "" && claims["iss"] != v.cfg.Issuer { return Identity{}, errors.New("bad issuer") }
+	if v.cfg.Audience != "" && !hasAudience(claims["aud"], v.cfg.Audience) { return Identity{}, errors.New("bad audience") }
+
+	name, _ := claims[v.cfg.UsernameClaim].(string)
+	if name == "" { return Identity{}, fmt.Errorf("missing %s claim", v.cfg.UsernameClaim) }
+	id := Identity{Name: name}
+	if gs, ok := claims[v.cfg.GroupsClaim].([]any); ok {
+		for _, g := range gs {
+			if s, ok := g.(string); ok { id.Groups = append(id.Groups, s) }
+		}
+	}
+	return id, nil
+}
+
+func hasAudience(aud any, want string) bool {
+	switch a := aud.(type) {
+	case string: return a == want
+	case []any:
+		for _, x := range a {
+			if x == want { return true }
+		}
+	}
+	return false
+}
+
+func decodeSegment(seg string, out any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil { return err }
+	return json.Unmarshal(b, out)
+}
+
+func b64int(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil { return nil, err }
+	return new(big.Int).SetBytes(b), nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"time"
 	"gopkg.in/yaml.v3"
 )
@@ -12,6 +13,23 @@ type Kube struct {
 	Context    string `yaml:"context"`
 }
 
+// Cluster é um cluster nomeado, alvo das waves de um deploy.
+type Cluster struct {
+	Name       string `yaml:"name"`
+	Kubeconfig string `yaml:"kubeconfig"` // vazio = in-cluster (ou o kubeconfig padrão)
+	Context    string `yaml:"context"`
+}
+
+// Wave é uma etapa de um deploy multi-cluster: a estratégia roda em cada
+// cluster da wave, depois o deploy espera BakeTime e analisa cada cluster
+// antes da próxima wave.
+type Wave struct {
+	Name     string        `yaml:"name"`
+	Clusters []string      `yaml:"clusters"`
+	BakeTime time.Duration `yaml:"bakeTime"`
+	Analysis string        `yaml:"analysis"` // template avaliado após o bake (padrão: o do target)
+}
+
 type PromCfg struct {
 	URL     string        `yaml:"url"`     // http://prometheus:9090
 	Timeout time.Duration `yaml:"timeout"` // ex: 10s
@@ -35,14 +53,232 @@ type Defaults struct {
 	CanaryPauseSec    int `yaml:"canaryPauseSec"`    // ex: 60
 }
 
+// Target diz como um app é implantado. Sem entrada, o app é o Deployment de mesmo nome.
+type Target struct {
+	Namespace string `yaml:"namespace"` // vazio = qualquer namespace
+	App       string `yaml:"app"`
+	Type      string `yaml:"type"`      // deployment (padrão) | statefulset | daemonset | kustomize | helm
+	Path      string `yaml:"path"`      // kustomize: diretório do overlay
+	Image     string `yaml:"image"`     // kustomize: repositório cuja tag é trocada (vazio = o da imagem do deploy)
+	Release   string `yaml:"release"`   // helm: nome do release (vazio = app)
+	NodeLabel      string `yaml:"nodeLabel"`      // daemonset: label de nó que define os lotes
+	MaxUnavailable string `yaml:"maxUnavailable"` // daemonset: pods recriados por vez em cada lote (ex: 1, 25%)
+	ImageValues struct {
+		Repository string `yaml:"repository"` // padrão image.repository
+		Tag        string `yaml:"tag"`        // padrão image.tag; "-" = imagem completa em repository
+	} `yaml:"imageValues"`
+	Hooks    []Hook `yaml:"hooks"`
+	Analysis string `yaml:"analysis"` // template de analysis.templates avaliado a cada etapa
+	Waves    []Wave `yaml:"waves"`    // deploy multi-cluster padrão do app (o pedido pode trocar)
+}
+
+// Hook roda numa fase do deploy: preDeploy (antes de alterar o workload),
+// postStep (ao fim de cada etapa da estratégia) ou postPromote (depois da
+// última etapa). Se falhar, o deploy faz rollback com reason hook_failed.
+type Hook struct {
+	Name    string        `yaml:"name"`
+	Phase   string        `yaml:"phase"`
+	Timeout time.Duration `yaml:"timeout"` // job: espera total (padrão 10m); http: por tentativa (padrão 30s)
+	Job     *JobHook      `yaml:"job"`
+	HTTP    *HTTPHook     `yaml:"http"`
+}
+
+// JobHook cria um Job e espera ele terminar; falha se o Job falhar.
+type JobHook struct {
+	// Template é o manifesto batch/v1 Job. Containers com image "${IMAGE}"
+	// (ou vazia) recebem a imagem do deploy; DEPLOY_* entram no env.
+	Template map[string]any `yaml:"template"`
+}
+
+// HTTPHook é um smoke test. URL, headers e body aceitam ${APP},
+// ${NAMESPACE}, ${IMAGE}, ${DEPLOY_ID}, ${PHASE} e ${STEP}.
+type HTTPHook struct {
+	URL          string            `yaml:"url"`
+	Method       string            `yaml:"method"`       // padrão GET
+	Headers      map[string]string `yaml:"headers"`
+	Body         string            `yaml:"body"`
+	ExpectStatus int               `yaml:"expectStatus"` // padrão 200
+	BodyRegex    string            `yaml:"bodyRegex"`    // opcional: o corpo precisa casar
+	Retries      int               `yaml:"retries"`      // tentativas extras antes de falhar
+	Interval     time.Duration     `yaml:"interval"`     // entre tentativas (padrão 5s)
+}
+
+// AnalysisProvider é um backend de métricas da análise. O provider
+// "prometheus" existe sempre e usa prometheus.url.
+type AnalysisProvider struct {
+	Name     string            `yaml:"name"`
+	Type     string            `yaml:"type"`     // prometheus | http | job | graphite | influx
+	URL      string            `yaml:"url"`      // base das queries (job: não usa)
+	Headers  map[string]string `yaml:"headers"`  // aceitam ${APP}, ${NAMESPACE}...
+	Database string            `yaml:"database"` // influx: db da query
+	Timeout  time.Duration     `yaml:"timeout"`  // por métrica (padrão 10s; job 10m)
+}
+
+// Metric é uma métrica da análise, avaliada contra min/max a cada etapa.
+type Metric struct {
+	Name     string   `yaml:"name"`
+	Provider string   `yaml:"provider"` // padrão prometheus
+	// Query depende do provider: PromQL, URL (absoluta ou relativa à url do
+	// provider http), target do Graphite ou InfluxQL. Aceita ${APP}, ${NAMESPACE}...
+	Query    string   `yaml:"query"`
+	Range    string   `yaml:"range"`    // prometheus/graphite: janela (padrão 5m)
+	JSONPath string   `yaml:"jsonPath"` // http: caminho do valor (ex: $.data.conversion)
+	Job      *JobHook `yaml:"job"`      // job: o valor é o exit code do container
+	Min      *float64 `yaml:"min"`      // sem min/max a métrica só é registrada (job: max 0)
+	Max      *float64 `yaml:"max"`
+	OnError  string   `yaml:"onError"`  // fail (padrão) reprova quando a consulta falha; skip só registra o erro
+}
+
+type AnalysisTemplate struct {
+	Name    string   `yaml:"name"`
+	Metrics []Metric `yaml:"metrics"`
+}
+
+type Analysis struct {
+	Providers []AnalysisProvider `yaml:"providers"`
+	Templates []AnalysisTemplate `yaml:"templates"`
+}
+
+// Template devolve o template de análise pelo nome.
+func (a Analysis) Template(name string) (AnalysisTemplate, bool) {
+	for _, t := range a.Templates {
+		if t.Name == name { return t, true }
+	}
+	return AnalysisTemplate{}, false
+}
+
+// TargetFor devolve o target configurado para namespace/app.
+func (c *Config) TargetFor(ns, app string) (Target, bool) {
+	for _, t := range c.Targets {
+		if t.App == app && (t.Namespace == "" || t.Namespace == ns) { return t, true }
+	}
+	return Target{}, false
+}
+
+type Locks struct {
+	Backend        string        `yaml:"backend"`        // store (padrão, uma réplica) | lease (Lease do K8s, multi-réplica)
+	TTL            time.Duration `yaml:"ttl"`            // expira locks órfãos; renovado a cada ttl/3
+	LeaseNamespace string        `yaml:"leaseNamespace"` // vazio = namespace do app
+}
+
+// Stats controla as métricas DORA exportadas como gauges.
+type Stats struct {
+	Window  time.Duration `yaml:"window"`  // janela do cálculo (padrão 720h = 30 dias)
+	Refresh time.Duration `yaml:"refresh"` // intervalo de atualização dos gauges
+}
+
+// Window é uma janela de freeze: absoluta (from/to) ou recorrente (weekdays + start/end).
+type Window struct {
+	Name       string    `yaml:"name"`
+	Namespaces []string  `yaml:"namespaces"` // globs; vazio = todos
+	Apps       []string  `yaml:"apps"`       // globs; vazio = todos
+	From       time.Time `yaml:"from"`       // RFC3339
+	To         time.Time `yaml:"to"`
+	Weekdays   []string  `yaml:"weekdays"`   // mon..sun
+	Start      string    `yaml:"start"`      // HH:MM, vazio = dia todo
+	End        string    `yaml:"end"`
+}
+
+type BusinessHours struct {
+	Namespaces []string `yaml:"namespaces"` // onde fora do horário exige aprovação; vazio = desligado
+	Weekdays   []string `yaml:"weekdays"`   // padrão mon..fri
+	Start      string   `yaml:"start"`      // ex: 09:00
+	End        string   `yaml:"end"`        // ex: 18:00
+}
+
+type ImagePolicy struct {
+	AllowedRegistries []string `yaml:"allowedRegistries"` // prefixos (ex: ghcr.io/acme); vazio = qualquer
+	DenyLatest        bool     `yaml:"denyLatest"`        // rejeita :latest e imagem sem tag
+}
+
+type Policy struct {
+	Timezone      string        `yaml:"timezone"` // ex: America/Sao_Paulo (padrão UTC)
+	Freezes       []Window      `yaml:"freezes"`
+	BusinessHours BusinessHours `yaml:"businessHours"`
+	Images        ImagePolicy   `yaml:"images"`
+	MaxOverride   time.Duration `yaml:"maxOverride"` // duração máxima de um override (padrão 24h)
+}
+
+type Notifier struct {
+	Type    string            `yaml:"type"`    // slack|webhook|github
+	URL     string            `yaml:"url"`     // webhook do Slack / endpoint; github: API base (padrão https://api.github.com)
+	Headers map[string]string `yaml:"headers"` // webhook: headers extras (ex: token)
+	Token   string            `yaml:"token"`   // github: token (ou env GITHUB_TOKEN)
+	Repo    string            `yaml:"repo"`    // github: owner/repo padrão (param githubRepo sobrescreve)
+	Events  []string          `yaml:"events"`  // started|waiting_approval|step|succeeded|rolled_back; vazio = todos (github ignora)
+}
+
+type Notifications struct {
+	PublicURL string     `yaml:"publicURL"` // URL externa do orquestrador, usada nos links
+	Notifiers []Notifier `yaml:"notifiers"`
+}
+
+type StaticToken struct {
+	Name     string   `yaml:"name"`     // identidade (ex: team-payments)
+	Token    string   `yaml:"token"`
+	TokenEnv string   `yaml:"tokenEnv"` // alternativa: lê o token desta env
+	Groups   []string `yaml:"groups"`
+}
+
+type JWTAuth struct {
+	JWKSFile      string `yaml:"jwksFile"`      // JWKS local (RS256/ES256)
+	Issuer        string `yaml:"issuer"`        // opcional: valida iss
+	Audience      string `yaml:"audience"`      // opcional: valida aud
+	UsernameClaim string `yaml:"usernameClaim"` // padrão: sub
+	GroupsClaim   string `yaml:"groupsClaim"`   // padrão: groups
+}
+
+type RoleBinding struct {
+	Role       string   `yaml:"role"`       // deployer|approver|admin
+	Subjects   []string `yaml:"subjects"`   // nome da identidade ou group:<grupo>
+	Namespaces []string `yaml:"namespaces"` // globs; vazio = todos
+	Apps       []string `yaml:"apps"`       // globs; vazio = todos
+}
+
+// Auth liga autenticação por identidade. Sem tokens nem JWKS, vale o modo
+// legado: authToken protege só approve/overrides.
+type Auth struct {
+	Tokens   []StaticToken `yaml:"tokens"`
+	JWT      JWTAuth       `yaml:"jwt"`
+	Bindings []RoleBinding `yaml:"bindings"`
+}
+
+// TriggerRule liga pushes no registry a deploys automáticos.
+type TriggerRule struct {
+	Name            string            `yaml:"name"`
+	Repository      string            `yaml:"repository"` // glob do repositório (ex: ghcr.io/acme/myapp, acme/*)
+	Tag             string            `yaml:"tag"`        // regex da tag (ex: ^v\d+\.\d+\.\d+$); vazio = qualquer
+	App             string            `yaml:"app"`
+	Namespace       string            `yaml:"namespace"`
+	Strategy        string            `yaml:"strategy"`
+	Params          map[string]string `yaml:"params"`
+	RequireApproval bool              `yaml:"requireApproval"`
+	Queue           bool              `yaml:"queue"`
+}
+
+type Triggers struct {
+	Secret    string        `yaml:"secret"`    // HMAC-SHA256 do corpo (header X-Signature-256)
+	SecretEnv string        `yaml:"secretEnv"` // alternativa: lê o secret desta env
+	Rules     []TriggerRule `yaml:"rules"`
+}
+
 type Config struct {
 	Server struct {
 		HTTPAddr string `yaml:"httpAddr"`
 	} `yaml:"server"`
 	Kube       Kube      `yaml:"kube"`
+	Clusters   []Cluster `yaml:"clusters"`
 	Prometheus PromCfg   `yaml:"prometheus"`
 	Storage    Storage   `yaml:"storage"`
 	Defaults   Defaults  `yaml:"defaults"`
+	Targets    []Target  `yaml:"targets"`
+	Locks      Locks     `yaml:"locks"`
+	Stats      Stats     `yaml:"stats"`
+	Policy     Policy    `yaml:"policy"`
+	Notifications Notifications `yaml:"notifications"`
+	Auth       Auth      `yaml:"auth"`
+	Triggers   Triggers  `yaml:"triggers"`
+	Analysis   Analysis  `yaml:"analysis"`
 	AuthToken  string    `yaml:"authToken"` // opcional p/ rotas admin
 }
 
@@ -55,5 +291,57 @@ func Load(path string) (*Config, error) {
 	if c.Prometheus.Timeout == 0 { c.Prometheus.Timeout = 10 * time.Second }
 	if c.Defaults.CanaryStepPercent == 0 { c.Defaults.CanaryStepPercent = 20 }
 	if c.Defaults.CanaryPauseSec == 0 { c.Defaults.CanaryPauseSec = 60 }
+	if c.Locks.TTL == 0 { c.Locks.TTL = 5 * time.Minute }
+	for i := range c.Targets {
+		t := &c.Targets[i]
+		if t.Type == "helm" && t.Release == "" { t.Release = t.App }
+		if t.ImageValues.Repository == "" { t.ImageValues.Repository = "image.repository" }
+		switch t.ImageValues.Tag {
+		case "": t.ImageValues.Tag = "image.tag"
+		case "-": t.ImageValues.Tag = ""
+		}
+		for j := range t.Waves {
+			if t.Waves[j].Name == "" { t.Waves[j].Name = strings.Join(t.Waves[j].Clusters, "+") }
+		}
+		for j := range t.Hooks {
+			h := &t.Hooks[j]
+			if h.HTTP != nil {
+				if h.Timeout == 0 { h.Timeout = 30 * time.Second }
+				if h.HTTP.Method == "" { h.HTTP.Method = "GET" }
+				if h.HTTP.ExpectStatus == 0 { h.HTTP.ExpectStatus = 200 }
+				if h.HTTP.Interval == 0 { h.HTTP.Interval = 5 * time.Second }
+			}
+			if h.Timeout == 0 { h.Timeout = 10 * time.Minute }
+		}
+	}
+	types := map[string]string{"prometheus": "prometheus"}
+	for i := range c.Analysis.Providers {
+		p := &c.Analysis.Providers[i]
+		types[p.Name] = p.Type
+		if p.Timeout == 0 && p.Type == "job" { p.Timeout = 10 * time.Minute }
+		if p.Timeout == 0 { p.Timeout = 10 * time.Second }
+	}
+	for i := range c.Analysis.Templates {
+		for j := range c.Analysis.Templates[i].Metrics {
+			m := &c.Analysis.Templates[i].Metrics[j]
+			if m.Provider == "" { m.Provider = "prometheus" }
+			if m.Range == "" { m.Range = "5m" }
+			if types[m.Provider] == "job" && m.Min == nil && m.Max == nil { m.Max = new(float64) }
+		}
+	}
+	if c.Stats.Window == 0 { c.Stats.Window = 30 * 24 * time.Hour }
+	if c.Stats.Refresh == 0 { c.Stats.Refresh = time.Minute }
+	if c.Policy.MaxOverride == 0 { c.Policy.MaxOverride = 24 * time.Hour }
+	for i := range c.Auth.Tokens {
+		t := &c.Auth.Tokens[i]
+		if t.Token == "" && t.TokenEnv != "" { t.Token = os.Getenv(t.TokenEnv) }
+	}
+	if c.Triggers.Secret == "" && c.Triggers.SecretEnv != "" { c.Triggers.Secret = os.Getenv(c.Triggers.SecretEnv) }
+	if c.Auth.JWT.UsernameClaim == "" { c.Auth.JWT.UsernameClaim = "sub" }
+	if c.Auth.JWT.GroupsClaim == "" { c.Auth.JWT.GroupsClaim = "groups" }
+	for i := range c.Notifications.Notifiers {
+		n := &c.Notifications.Notifiers[i]
+		if n.Type == "github" && n.Token == "" { n.Token = os.Getenv("GITHUB_TOKEN") }
+	}
 	return &c, nil
 }
\ No newline at end of file
@@ -0,0 +1,240 @@
+// Package hooks executa os hooks de deploy configurados por app: Jobs do
+// Kubernetes (ex: migrations) e checagens HTTP (smoke tests).
+package hooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+// Fases em que os hooks rodam.
+const (
+	PreDeploy   = "preDeploy"
+	PostStep    = "postStep"
+	PostPromote = "postPromote"
+)
+
+// Vars identificam o deploy para o hook (env DEPLOY_* do Job e ${...} do HTTP).
+type Vars struct {
+	DeployID   string
+	Namespace  string
+	App        string
+	Image      string
+	Phase      string
+	Step       string
+	RollbackOf string
+	Cluster    string // deploys com waves: cluster da etapa
+}
+
+func (v Vars) env() map[string]string {
+	return map[string]string{
+		"DEPLOY_ID": v.DeployID, "APP": v.App, "NAMESPACE": v.Namespace, "IMAGE": v.Image,
+		"PHASE": v.Phase, "STEP": v.Step, "ROLLBACK_OF": v.RollbackOf, "CLUSTER": v.Cluster,
+	}
+}
+
+// Expand troca ${DEPLOY_ID}, ${APP}, ${NAMESPACE}, ${IMAGE}, ${PHASE}, ${STEP},
+// ${ROLLBACK_OF} e ${CLUSTER} em s; outras referências ficam como estão.
+func (v Vars) Expand(s string) string {
+	env := v.env()
+	return os.Expand(s, func(k string) string {
+		if val, ok := env[k]; ok { return val }
+		return "${" + k + "}"
+	})
+}
+
+// Error é a falha de um hook; o orquestrador a trata como hook_failed.
+type Error struct {
+	Hook  string
+	Phase string
+	Err   error
+}
+
+func (e *Error) Error() string { return "hook_failed: " + e.Hook + " (" + e.Phase + "): " + e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+type Runner struct {
+	cs   kubernetes.Interface
+	http *http.Client
+	poll time.Duration
+}
+
+func New(cs kubernetes.Interface) *Runner {
+	return &Runner{cs: cs, http: &http.Client{}, poll: 2 * time.Second}
+}
+
+// Validate confere os hooks de um target (chamado na inicialização).
+func Validate(hs []config.Hook) error {
+	for _, h := range hs {
+		if h.Name == "" { return errors.New("hook: name is required") }
+		switch h.Phase {
+		case PreDeploy, PostStep, PostPromote:
+		default: return fmt.Errorf("hook %q: unknown phase %q", h.Name, h.Phase)
+		}
+		if (h.Job == nil) == (h.HTTP == nil) { return fmt.Errorf("hook %q: set exactly one of job or http", h.Name) }
+		if h.HTTP != nil {
+			if h.HTTP.URL == "" { return fmt.Errorf("hook %q: http.url is required", h.Name) }
+			if _, err := regexp.Compile(h.HTTP.BodyRegex); err != nil { return fmt.Errorf("hook %q: bodyRegex: %w", h.Name, err) }
+		}
+		if h.Job != nil {
+			if _, err := jobFromTemplate(h.Job.Template); err != nil { return fmt.Errorf("hook %q: %w", h.Name, err) }
+		}
+	}
+	return nil
+}
+
+// Run executa o hook e devolve *Error se ele falhar. O detalhe do resultado
+// (job criado, status HTTP) vai em data para a timeline.
+func (r *Runner) Run(ctx context.Context, h config.Hook, v Vars) (data map[string]string, err error) {
+	data = map[string]string{"hook": h.Name, "phase": v.Phase}
+	if v.Step != "" { data["step"] = v.Step }
+	switch {
+	case h.Job != nil: err = r.runJob(ctx, h, v, data)
+	case h.HTTP != nil: err = r.runHTTP(ctx, h, v, data)
+	}
+	if err != nil { return data, &Error{Hook: h.Name, Phase: v.Phase, Err: err} }
+	return data, nil
+}
+
+func (r *Runner) runHTTP(ctx context.Context, h config.Hook, v Vars, data map[string]string) error {
+	var re *regexp.Regexp
+	if h.HTTP.BodyRegex != "" { re = regexp.MustCompile(h.HTTP.BodyRegex) }
+	var err error
+	for attempt := 0; attempt <= h.HTTP.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done(): return context.Cause(ctx)
+			case <-time.After(h.HTTP.Interval):
+			}
+		}
+		data["attempts"] = fmt.Sprint(attempt + 1)
+		if err = r.checkHTTP(ctx, h, v, re, data); err == nil { return nil }
+	}
+	return err
+}
+
+func (r *Runner) checkHTTP(ctx context.Context, h config.Hook, v Vars, re *regexp.Regexp, data map[string]string) error {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	var body io.Reader
+	if h.HTTP.Body != "" { body = strings.NewReader(v.Expand(h.HTTP.Body)) }
+	req, err := http.NewRequestWithContext(ctx, h.HTTP.Method, v.Expand(h.HTTP.URL), body)
+	if err != nil { return err }
+	for k, val := range h.HTTP.Headers { req.Header.Set(k, v.Expand(val)) }
+	resp, err := r.http.Do(req)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil { return err }
+	data["status"] = fmt.Sprint(resp.StatusCode)
+	if resp.StatusCode != h.HTTP.ExpectStatus {
+		return fmt.Errorf("%s %s returned %d, want %d", req.Method, req.URL.Redacted(), resp.StatusCode, h.HTTP.ExpectStatus)
+	}
+	if re != nil && !re.Match(b) {
+		return fmt.Errorf("%s %s body does not match %q", req.Method, req.URL.Redacted(), h.HTTP.BodyRegex)
+	}
+	return nil
+}
+
+// jobFromTemplate converte o template do YAML (map) num batch/v1 Job.
+func jobFromTemplate(tpl map[string]any) (*batchv1.Job, error) {
+	if len(tpl) == 0 { return nil, errors.New("job.template is required") }
+	b, err := json.Marshal(tpl)
+	if err != nil { return nil, fmt.Errorf("job.template: %w", err) }
+	var job batchv1.Job
+	if err := json.Unmarshal(b, &job); err != nil { return nil, fmt.Errorf("job.template: %w", err) }
+	if len(job.Spec.Template.Spec.Containers) == 0 { return nil, errors.New("job.template: spec.template.spec.containers is empty") }
+	return &job, nil
+}
+
+// NewJob monta o Job do template para o deploy: nome único, namespace,
+// labels, imagem e env DEPLOY_*. Também usado pelos Jobs de análise.
+func NewJob(tpl map[string]any, name string, v Vars) (*batchv1.Job, error) {
+	job, err := jobFromTemplate(tpl)
+	if err != nil { return nil, err }
+	prepareJob(job, name, v)
+	return job, nil
+}
+
+func (r *Runner) runJob(ctx context.Context, h config.Hook, v Vars, data map[string]string) error {
+	job, err := NewJob(h.Job.Template, h.Name, v)
+	if err != nil { return err }
+	data["job"] = job.Name
+
+	jobs := r.cs.BatchV1().Jobs(job.Namespace)
+	if _, err := jobs.Create(ctx, job, meta.CreateOptions{}); err != nil { return fmt.Errorf("create job: %w", err) }
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	for {
+		cur, err := jobs.Get(ctx, job.Name, meta.GetOptions{})
+		if err == nil {
+			for _, c := range cur.Status.Conditions {
+				if c.Status != corev1.ConditionTrue { continue }
+				switch c.Type {
+				case batchv1.JobComplete: return nil
+				case batchv1.JobFailed: return fmt.Errorf("job %s failed: %s", job.Name, c.Message)
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			// um Job pendurado não pode seguir rodando depois do rollback
+			bg := meta.DeletePropagationBackground
+			_ = jobs.Delete(context.WithoutCancel(ctx), job.Name, meta.DeleteOptions{PropagationPolicy: &bg})
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) { return fmt.Errorf("job %s did not finish in %s", job.Name, h.Timeout) }
+			return context.Cause(ctx)
+		case <-time.After(r.poll):
+		}
+	}
+}
+
+// prepareJob dá nome único, namespace, labels, imagem e env DEPLOY_* ao Job.
+func prepareJob(job *batchv1.Job, hook string, v Vars) {
+	base := job.Name
+	if base == "" { base = v.App + "-" + hook }
+	if len(base) > 50 { base = base[:50] }
+	b := make([]byte, 3)
+	_, _ = rand.Read(b)
+	job.Name = strings.TrimRight(base, "-") + "-" + hex.EncodeToString(b)
+	job.GenerateName = ""
+	if job.Namespace == "" { job.Namespace = v.Namespace }
+	if job.Labels == nil { job.Labels = map[string]string{} }
+	job.Labels["app.kubernetes.io/managed-by"] = "deploy-orchestrator"
+	job.Labels["deploy-orchestrator/deploy"] = v.DeployID
+	job.Labels["deploy-orchestrator/hook"] = hook
+	if job.Spec.TTLSecondsAfterFinished == nil {
+		ttl := int32(3600)
+		job.Spec.TTLSecondsAfterFinished = &ttl
+	}
+	env := []corev1.EnvVar{
+		{Name: "DEPLOY_ID", Value: v.DeployID}, {Name: "DEPLOY_APP", Value: v.App},
+		{Name: "DEPLOY_NAMESPACE", Value: v.Namespace}, {Name: "DEPLOY_IMAGE", Value: v.Image},
+		{Name: "DEPLOY_PHASE", Value: v.Phase}, {Name: "DEPLOY_STEP", Value: v.Step},
+		{Name: "DEPLOY_ROLLBACK_OF", Value: v.RollbackOf}, {Name: "DEPLOY_CLUSTER", Value: v.Cluster},
+	}
+	spec := &job.Spec.Template.Spec
+	for _, cs := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range cs {
+			if cs[i].Image == "" || cs[i].Image == "${IMAGE}" { cs[i].Image = v.Image }
+			cs[i].Env = append(cs[i].Env, env...)
+		}
+	}
+	if spec.RestartPolicy == "" { spec.RestartPolicy = corev1.RestartPolicyNever }
+}
@@ -0,0 +1,109 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+var vars = Vars{DeployID: "d1", Namespace: "prod", App: "web", Image: "repo/web:2", Phase: PostPromote}
+
+func TestHTTPHook(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		if r.URL.Query().Get("app") != "web" { http.Error(w, "bad app", http.StatusBadRequest); return }
+		if n < 3 { http.Error(w, "warming up", http.StatusServiceUnavailable); return }
+		fmt.Fprint(w, `{"status":"ok","version":"2"}`)
+	}))
+	defer srv.Close()
+
+	hook := func(regex string, retries int) config.Hook {
+		return config.Hook{Name: "smoke", Phase: PostPromote, Timeout: time.Second, HTTP: &config.HTTPHook{
+			URL: srv.URL + "/health?app=${APP}", Method: "GET", ExpectStatus: 200, BodyRegex: regex,
+			Retries: retries, Interval: time.Millisecond,
+		}}
+	}
+	cases := []struct {
+		name    string
+		hook    config.Hook
+		wantErr bool
+	}{
+		{"retries until healthy", hook(`"status":"ok"`, 5), false},
+		{"body mismatch", hook(`"version":"3"`, 0), true},
+		{"out of retries", hook("", 0), true},
+	}
+	r := New(fake.NewSimpleClientset())
+	for _, c := range cases {
+		calls.Store(0)
+		if c.name == "body mismatch" { calls.Store(10) }
+		data, err := r.Run(context.Background(), c.hook, vars)
+		var he *Error
+		if c.wantErr != (err != nil) || (err != nil && !errors.As(err, &he)) {
+			t.Errorf("%s: err=%v data=%v", c.name, err, data)
+		}
+	}
+}
+
+func TestJobHook(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	r := New(cs)
+	r.poll = time.Millisecond
+	hook := config.Hook{Name: "migrate", Phase: PreDeploy, Timeout: 5 * time.Second, Job: &config.JobHook{Template: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "migrate", "image": "${IMAGE}", "args": []any{"migrate", "up"}}},
+		}}},
+	}}}
+
+	// simula o controller de Jobs: o primeiro Job criado termina com cond
+	finish := func(cond batchv1.JobConditionType) {
+		for {
+			list, _ := cs.BatchV1().Jobs("prod").List(context.Background(), meta.ListOptions{})
+			for _, j := range list.Items {
+				if len(j.Status.Conditions) > 0 { continue }
+				j.Status.Conditions = []batchv1.JobCondition{{Type: cond, Status: corev1.ConditionTrue, Message: "exit code 1"}}
+				_, _ = cs.BatchV1().Jobs("prod").UpdateStatus(context.Background(), &j, meta.UpdateOptions{})
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	go finish(batchv1.JobComplete)
+	data, err := r.Run(context.Background(), hook, vars)
+	if err != nil { t.Fatalf("complete job: %v", err) }
+	job, err := cs.BatchV1().Jobs("prod").Get(context.Background(), data["job"], meta.GetOptions{})
+	if err != nil { t.Fatal(err) }
+	c := job.Spec.Template.Spec.Containers[0]
+	if c.Image != "repo/web:2" || job.Labels["deploy-orchestrator/deploy"] != "d1" || job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever {
+		t.Fatalf("job not prepared: %+v", job)
+	}
+	if len(c.Env) == 0 || c.Env[0].Name != "DEPLOY_ID" { t.Fatalf("env=%v", c.Env) }
+
+	go finish(batchv1.JobFailed)
+	if _, err := r.Run(context.Background(), hook, vars); err == nil { t.Fatal("failed job passed") }
+}
+
+func TestValidate(t *testing.T) {
+	job := &config.JobHook{Template: map[string]any{"spec": map[string]any{}}}
+	for _, hs := range [][]config.Hook{
+		{{Name: "x", Phase: "afterDeploy", HTTP: &config.HTTPHook{URL: "http://x"}}},
+		{{Name: "x", Phase: PreDeploy}},
+		{{Name: "x", Phase: PreDeploy, HTTP: &config.HTTPHook{URL: "http://x", BodyRegex: "("}}},
+		{{Name: "x", Phase: PreDeploy, Job: job}},
+	} {
+		if err := Validate(hs); err == nil { t.Errorf("Validate(%+v) accepted", hs[0]) }
+	}
+}
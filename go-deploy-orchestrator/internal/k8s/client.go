@@ -5,14 +5,22 @@ import (
 	"os"
 	"path/filepath"
 
+	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 func NewClient(kubeconfig, context string) (*kubernetes.Clientset, error) {
+	cfg, err := NewRESTConfig(kubeconfig, context)
+	if err != nil { return nil, err }
+	return kubernetes.NewForConfig(cfg)
+}
+
+// NewRESTConfig usa o ServiceAccount quando roda no cluster; fora dele, o kubeconfig.
+func NewRESTConfig(kubeconfig, context string) (*rest.Config, error) {
 	if cfg, err := rest.InClusterConfig(); err == nil {
-		return kubernetes.NewForConfig(cfg)
+		return cfg, nil
 	}
 	if kubeconfig == "" {
 		if home, err := os.UserHomeDir(); err == nil {
@@ -23,7 +31,15 @@ func NewClient(kubeconfig, context string) (*kubernetes.Clientset, error) {
 	loading := &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
 	over := &clientcmd.ConfigOverrides{}
 	if context != "" { over.CurrentContext = context }
-	cfg, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loading, over).ClientConfig()
-	if err != nil { return nil, err }
-	return kubernetes.NewForConfig(cfg)
-}
\ No newline at end of file
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loading, over).ClientConfig()
+}
+
+// restGetter é o equivalente de NewRESTConfig para o SDK do Helm.
+func restGetter(kubeconfig, context, namespace string) genericclioptions.RESTClientGetter {
+	flags := genericclioptions.NewConfigFlags(false)
+	flags.Namespace = &namespace
+	if _, err := rest.InClusterConfig(); err == nil { return flags }
+	if kubeconfig != "" { flags.KubeConfig = &kubeconfig }
+	if context != "" { flags.Context = &context }
+	return flags
+}
@@ -0,0 +1,239 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NodeBatch é um grupo de nós com o mesmo valor do label de batch.
+type NodeBatch struct {
+	Value string   `json:"value"` // vazio = nós sem o label
+	Nodes []string `json:"nodes"`
+}
+
+// NodeBatched é um Target atualizado por lotes de nós (DaemonSet): o
+// template muda com updateStrategy OnDelete e os pods de cada lote são
+// recriados explicitamente.
+type NodeBatched interface {
+	Target
+	PrepareImage(ctx context.Context, image string) error
+	Batches(ctx context.Context, label string) ([]NodeBatch, error)
+	// UpdateNodes recria os pods desatualizados dos nós, no máximo
+	// maxUnavailable por vez, esperando cada grupo ficar pronto.
+	UpdateNodes(ctx context.Context, nodes []string, maxUnavailable int, timeout time.Duration) error
+	// Finish devolve a updateStrategy original.
+	Finish(ctx context.Context) error
+}
+
+type DaemonSetTarget struct {
+	cs   kubernetes.Interface
+	ns   string
+	name string
+	orig *appsv1.DaemonSetUpdateStrategy // strategy antes do PrepareImage
+	restore *corev1.PodTemplateSpec      // ver Restore
+}
+
+func NewDaemonSetTarget(cs kubernetes.Interface, namespace, name string) *DaemonSetTarget {
+	return &DaemonSetTarget{cs: cs, ns: namespace, name: name}
+}
+
+func (d *DaemonSetTarget) get(ctx context.Context) (*appsv1.DaemonSet, error) {
+	return d.cs.AppsV1().DaemonSets(d.ns).Get(ctx, d.name, meta.GetOptions{})
+}
+
+func (d *DaemonSetTarget) update(ctx context.Context, fn func(*appsv1.DaemonSet)) error {
+	ds, err := d.get(ctx)
+	if err != nil { return err }
+	fn(ds)
+	_, err = d.cs.AppsV1().DaemonSets(d.ns).Update(ctx, ds, meta.UpdateOptions{})
+	return err
+}
+
+func (d *DaemonSetTarget) Snapshot(ctx context.Context) (Snapshot, error) {
+	ds, err := d.get(ctx)
+	if err != nil { return Snapshot{}, err }
+	return Snapshot{Image: templateImage(ds.Spec.Template, d.name), Template: ds.Spec.Template.DeepCopy()}, nil
+}
+
+// SetImage faz o rolling update normal do DaemonSet.
+func (d *DaemonSetTarget) SetImage(ctx context.Context, image string) error {
+	return d.update(ctx, func(ds *appsv1.DaemonSet) {
+		applyTemplate(&ds.Spec.Template, d.restore, d.name, image)
+		d.restoreStrategy(ds)
+	})
+}
+
+func (d *DaemonSetTarget) PrepareImage(ctx context.Context, image string) error {
+	return d.update(ctx, func(ds *appsv1.DaemonSet) {
+		if d.orig == nil { d.orig = ds.Spec.UpdateStrategy.DeepCopy() }
+		ds.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType}
+		applyTemplate(&ds.Spec.Template, d.restore, d.name, image)
+	})
+}
+
+func (d *DaemonSetTarget) restoring(tpl corev1.PodTemplateSpec) Target {
+	c := *d
+	c.restore = &tpl
+	return &c
+}
+
+func (d *DaemonSetTarget) restoreStrategy(ds *appsv1.DaemonSet) {
+	switch {
+	case d.orig != nil: ds.Spec.UpdateStrategy = *d.orig
+	case ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType:
+		// sem registro do original (ex: orquestrador reiniciou): volta ao padrão
+		ds.Spec.UpdateStrategy = appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType}
+	}
+}
+
+func (d *DaemonSetTarget) Finish(ctx context.Context) error {
+	return d.update(ctx, d.restoreStrategy)
+}
+
+func (d *DaemonSetTarget) pods(ctx context.Context, ds *appsv1.DaemonSet) ([]corev1.Pod, error) {
+	sel, err := meta.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil { return nil, err }
+	list, err := d.cs.CoreV1().Pods(d.ns).List(ctx, meta.ListOptions{LabelSelector: sel.String()})
+	if err != nil { return nil, err }
+	return list.Items, nil
+}
+
+func (d *DaemonSetTarget) Batches(ctx context.Context, label string) ([]NodeBatch, error) {
+	ds, err := d.get(ctx)
+	if err != nil { return nil, err }
+	pods, err := d.pods(ctx, ds)
+	if err != nil { return nil, err }
+	nodes, err := d.cs.CoreV1().Nodes().List(ctx, meta.ListOptions{})
+	if err != nil { return nil, err }
+	labels := map[string]string{}
+	for _, n := range nodes.Items { labels[n.Name] = n.Labels[label] }
+
+	byValue := map[string][]string{}
+	seen := map[string]bool{}
+	for _, p := range pods {
+		n := p.Spec.NodeName
+		if n == "" || seen[n] { continue }
+		seen[n] = true
+		byValue[labels[n]] = append(byValue[labels[n]], n)
+	}
+	var out []NodeBatch
+	for v, ns := range byValue {
+		sort.Strings(ns)
+		out = append(out, NodeBatch{Value: v, Nodes: ns})
+	}
+	// ordem estável por valor; nós sem o label por último
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].Value == "") != (out[j].Value == "") { return out[j].Value == "" }
+		return out[i].Value < out[j].Value
+	})
+	return out, nil
+}
+
+func (d *DaemonSetTarget) UpdateNodes(ctx context.Context, nodes []string, maxUnavailable int, timeout time.Duration) error {
+	if maxUnavailable < 1 { maxUnavailable = 1 }
+	for start := 0; start < len(nodes); start += maxUnavailable {
+		group := nodes[start:min(len(nodes), start+maxUnavailable)]
+		ds, err := d.get(ctx)
+		if err != nil { return err }
+		pods, err := d.pods(ctx, ds)
+		if err != nil { return err }
+		for _, p := range pods {
+			if !contains(group, p.Spec.NodeName) || podUpToDate(p, ds.Spec.Template) { continue }
+			if err := d.cs.CoreV1().Pods(d.ns).Delete(ctx, p.Name, meta.DeleteOptions{}); err != nil {
+				return fmt.Errorf("delete pod %s on %s: %w", p.Name, p.Spec.NodeName, err)
+			}
+		}
+		if err := d.waitNodes(ctx, group, timeout); err != nil { return err }
+	}
+	return nil
+}
+
+// waitNodes espera cada nó ter um pod pronto com o template atual.
+func (d *DaemonSetTarget) waitNodes(ctx context.Context, nodes []string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		ds, err := d.get(ctx)
+		if err != nil { return err }
+		pods, err := d.pods(ctx, ds)
+		if err != nil { return err }
+		ready := 0
+		for _, n := range nodes {
+			for _, p := range pods {
+				if p.Spec.NodeName == n && p.DeletionTimestamp == nil && podUpToDate(p, ds.Spec.Template) && podReady(p) { ready++; break }
+			}
+		}
+		if ready == len(nodes) { return nil }
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("rollout timeout for daemonset %s on nodes %v", d.name, nodes)
+}
+
+func (d *DaemonSetTarget) Replicas(ctx context.Context) (int32, error) {
+	ds, err := d.get(ctx)
+	if err != nil { return 0, err }
+	return ds.Status.DesiredNumberScheduled, nil
+}
+
+func (d *DaemonSetTarget) Scale(context.Context, int32) error {
+	return errors.New("daemonsets cannot be scaled; use the node-batch rollout")
+}
+
+func (d *DaemonSetTarget) WaitRollout(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		ds, err := d.get(ctx)
+		if err != nil { return err }
+		if daemonSetReady(ds) { return nil }
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("rollout timeout for daemonset %s", d.name)
+}
+
+// Rollback volta o template (ou a imagem) e a updateStrategy original; o
+// controller recria os pods já atualizados respeitando o maxUnavailable da strategy.
+func (d *DaemonSetTarget) Rollback(ctx context.Context, to Snapshot) error {
+	if to.Template == nil && to.Image == "" { return d.Finish(ctx) }
+	return d.update(ctx, func(ds *appsv1.DaemonSet) {
+		applyTemplate(&ds.Spec.Template, to.Template, d.name, to.Image)
+		d.restoreStrategy(ds)
+	})
+}
+
+func daemonSetReady(d *appsv1.DaemonSet) bool {
+	st := d.Status
+	return st.ObservedGeneration >= d.Generation && st.UpdatedNumberScheduled == st.DesiredNumberScheduled && st.NumberReady == st.DesiredNumberScheduled
+}
+
+// podUpToDate compara as imagens do pod com as do template.
+func podUpToDate(p corev1.Pod, t corev1.PodTemplateSpec) bool {
+	want := map[string]string{}
+	for _, c := range t.Spec.Containers { want[c.Name] = c.Image }
+	for _, c := range p.Spec.Containers {
+		if want[c.Name] != c.Image { return false }
+	}
+	return true
+}
+
+func podReady(p corev1.Pod) bool {
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady { return c.Status == corev1.ConditionTrue }
+	}
+	return false
+}
+
+func contains(list []string, v string) bool {
+	for _, s := range list {
+		if s == v { return true }
+	}
+	return false
+}
+
+var _ NodeBatched = (*DaemonSetTarget)(nil)
@@ -23,6 +23,28 @@ func (d *Deployer) Get(ctx context.Context, name string) (*appsv1.Deployment, er
 
 func (d *Deployer) SetImage(ctx context.Context, name, container, image string) error {
 	dep, err := d.Get(ctx, name); if err != nil { return err }
+	setImage(dep, container, image)
+	_, err = d.cs.Update(ctx, dep, meta.UpdateOptions{})
+	return err
+}
+
+// SetTemplate substitui o pod template inteiro (rollback para um snapshot).
+func (d *Deployer) SetTemplate(ctx context.Context, name string, tpl corev1.PodTemplateSpec) error {
+	dep, err := d.Get(ctx, name); if err != nil { return err }
+	dep.Spec.Template = tpl
+	_, err = d.cs.Update(ctx, dep, meta.UpdateOptions{})
+	return err
+}
+
+// DryRunSetImage envia o mesmo Update de SetImage com dryRun=All e devolve o
+// objeto como o API server o gravaria (defaults e admission aplicados).
+func (d *Deployer) DryRunSetImage(ctx context.Context, dep *appsv1.Deployment, container, image string) (*appsv1.Deployment, error) {
+	next := dep.DeepCopy()
+	setImage(next, container, image)
+	return d.cs.Update(ctx, next, meta.UpdateOptions{DryRun: []string{meta.DryRunAll}})
+}
+
+func setImage(dep *appsv1.Deployment, container, image string) {
 	found := false
 	for i := range dep.Spec.Template.Spec.Containers {
 		if dep.Spec.Template.Spec.Containers[i].Name == container {
@@ -38,8 +60,13 @@ func (d *Deployer) SetImage(ctx context.Context, name, container, image string)
 			dep.Spec.Template.Spec.Containers[0].Image = image
 		}
 	}
-	_, err = d.cs.Update(ctx, dep, meta.UpdateOptions{})
-	return err
+}
+
+// SetImageLocal aplica a troca de imagem numa cópia, sem falar com o cluster.
+func SetImageLocal(dep *appsv1.Deployment, container, image string) *appsv1.Deployment {
+	next := dep.DeepCopy()
+	setImage(next, container, image)
+	return next
 }
 
 func (d *Deployer) Scale(ctx context.Context, name string, replicas int32) error {
@@ -53,11 +80,7 @@ func (d *Deployer) WaitRollout(ctx context.Context, name string, timeout time.Du
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
 		dep, err := d.Get(ctx, name); if err != nil { return err }
-		if dep.Status.UpdatedReplicas == *dep.Spec.Replicas &&
-			dep.Status.ReadyReplicas == *dep.Spec.Replicas &&
-			dep.Status.ObservedGeneration >= dep.Generation {
-			return nil
-		}
+		if deploymentReady(dep) { return nil }
 		time.Sleep(2 * time.Second)
 	}
 	return fmt.Errorf("rollout timeout for %s", name)
@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"encoding/json"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// TemplateDiff compara dois pod templates (JSON indentado) linha a linha,
+// com 3 linhas de contexto em volta de cada mudança. Vazio se iguais.
+func TemplateDiff(from, to corev1.PodTemplateSpec) string {
+	a, _ := json.MarshalIndent(from, "", "  ")
+	b, _ := json.MarshalIndent(to, "", "  ")
+	return lineDiff(strings.Split(string(a), "\n"), strings.Split(string(b), "\n"), 3)
+}
+
+func lineDiff(a, b []string, context int) string {
+	// lcs[i][j] = maior subsequência comum entre a[i:] e b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs { lcs[i] = make([]int, len(b)+1) }
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+	var ops []line
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, line{' ', a[i]}); i++; j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, line{'-', a[i]}); i++
+		default:
+			ops = append(ops, line{'+', b[j]}); j++
+		}
+	}
+
+	keep := make([]bool, len(ops))
+	changed := false
+	for k, l := range ops {
+		if l.op == ' ' { continue }
+		changed = true
+		for c := max(0, k-context); c <= min(len(ops)-1, k+context); c++ { keep[c] = true }
+	}
+	if !changed { return "" }
+
+	var sb strings.Builder
+	skipped := false
+	for k, l := range ops {
+		if !keep[k] { skipped = true; continue }
+		if skipped { sb.WriteString("...\n"); skipped = false }
+		sb.WriteByte(l.op)
+		sb.WriteByte(' ')
+		sb.WriteString(l.text)
+		sb.WriteByte('\n')
+	}
+	if skipped { sb.WriteString("...\n") }
+	return sb.String()
+}
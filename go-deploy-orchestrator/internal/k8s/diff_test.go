@@ -0,0 +1,21 @@
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestTemplateDiff(t *testing.T) {
+	from := corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "myapp", Image: "repo/myapp:1"}}}}
+	if d := TemplateDiff(from, from); d != "" { t.Fatalf("diff of equal templates = %q", d) }
+
+	to := *from.DeepCopy()
+	to.Spec.Containers[0].Image = "repo/myapp:2"
+	d := TemplateDiff(from, to)
+	if !strings.Contains(d, `- `) || !strings.Contains(d, `"image": "repo/myapp:1"`) || !strings.Contains(d, `+ `) || !strings.Contains(d, `"image": "repo/myapp:2"`) {
+		t.Fatalf("unexpected diff:\n%s", d)
+	}
+	if n := strings.Count(d, "\n-") + strings.Count(d, "\n+"); n > 2 { t.Fatalf("diff has unrelated changes:\n%s", d) }
+}
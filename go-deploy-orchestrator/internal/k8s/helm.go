@@ -0,0 +1,104 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/client-go/kubernetes"
+)
+
+// HelmTarget faz upgrade de um release existente (mesmo chart, values
+// reaproveitados) mudando só os values de imagem; Rollback volta à revision.
+type HelmTarget struct {
+	workloadSet
+	cfg     *action.Configuration
+	release string
+	repoKey string // ex: image.repository
+	tagKey  string // ex: image.tag; vazio = imagem completa em repoKey
+}
+
+func NewHelmTarget(cs kubernetes.Interface, kubeconfig, kubeContext, namespace, release, repoKey, tagKey string, logf func(string, ...any)) (*HelmTarget, error) {
+	cfg := new(action.Configuration)
+	if err := cfg.Init(restGetter(kubeconfig, kubeContext, namespace), namespace, os.Getenv("HELM_DRIVER"), logf); err != nil {
+		return nil, fmt.Errorf("helm init: %w", err)
+	}
+	return &HelmTarget{workloadSet: workloadSet{cs: cs, ns: namespace}, cfg: cfg, release: release, repoKey: repoKey, tagKey: tagKey}, nil
+}
+
+func (h *HelmTarget) current() (*release.Release, error) {
+	rel, err := action.NewGet(h.cfg).Run(h.release)
+	if err != nil { return nil, fmt.Errorf("helm release %s: %w", h.release, err) }
+	if err := h.setManifest(rel); err != nil { return nil, err }
+	return rel, nil
+}
+
+func (h *HelmTarget) setManifest(rel *release.Release) error {
+	objs, err := parseManifests([]byte(rel.Manifest))
+	if err != nil { return fmt.Errorf("helm release %s manifest: %w", h.release, err) }
+	h.setManifests(objs)
+	return nil
+}
+
+func (h *HelmTarget) Snapshot(ctx context.Context) (Snapshot, error) {
+	rel, err := h.current()
+	if err != nil { return Snapshot{}, err }
+	snap := Snapshot{Revision: strconv.Itoa(rel.Version)}
+	vals, err := chartutil.CoalesceValues(rel.Chart, rel.Config)
+	if err != nil { return snap, err }
+	repo, _ := vals.PathValue(h.repoKey)
+	snap.Image, _ = repo.(string)
+	if h.tagKey != "" {
+		if tag, _ := vals.PathValue(h.tagKey); tag != nil && snap.Image != "" { snap.Image += ":" + fmt.Sprint(tag) }
+	}
+	return snap, nil
+}
+
+func (h *HelmTarget) SetImage(ctx context.Context, image string) error {
+	rel, err := h.current()
+	if err != nil { return err }
+	vals := map[string]any{}
+	if h.tagKey == "" {
+		setPath(vals, h.repoKey, image)
+	} else {
+		repo, tag := splitImage(image)
+		setPath(vals, h.repoKey, repo)
+		setPath(vals, h.tagKey, tag)
+	}
+	up := action.NewUpgrade(h.cfg)
+	up.Namespace = h.ns
+	up.ReuseValues = true
+	next, err := up.RunWithContext(ctx, h.release, rel.Chart, vals)
+	if err != nil { return fmt.Errorf("helm upgrade %s: %w", h.release, err) }
+	return h.setManifest(next)
+}
+
+func (h *HelmTarget) Rollback(ctx context.Context, to Snapshot) error {
+	if to.Revision == "" { return nil }
+	v, err := strconv.Atoi(to.Revision)
+	if err != nil { return fmt.Errorf("invalid helm revision %q", to.Revision) }
+	rb := action.NewRollback(h.cfg)
+	rb.Version = v
+	if err := rb.Run(h.release); err != nil { return fmt.Errorf("helm rollback %s to %d: %w", h.release, v, err) }
+	_, err = h.current()
+	return err
+}
+
+// setPath grava v em m seguindo um caminho "a.b.c" de values.
+func setPath(m map[string]any, path string, v any) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = v
+}
@@ -0,0 +1,122 @@
+package k8s
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	coordv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+var ErrLeaseLost = errors.New("deploy lease lost")
+
+// LeaseLocker guarda o lock de deploy por (namespace, app) num Lease do
+// coordination.k8s.io, compartilhado entre réplicas do orquestrador.
+type LeaseLocker struct {
+	cs        kubernetes.Interface
+	namespace string // vazio = namespace do app
+}
+
+func NewLeaseLocker(cs kubernetes.Interface, namespace string) *LeaseLocker {
+	return &LeaseLocker{cs: cs, namespace: namespace}
+}
+
+// lease devolve namespace e nome do Lease. O nome é um hash de ns/app: juntar
+// os dois com "-" e truncar fazia pares diferentes (a-b/c e a/b-c) dividirem o
+// mesmo lock. O ns/app legível fica nas annotations.
+func (l *LeaseLocker) lease(ns, app string) (string, string) {
+	leaseNS := l.namespace
+	if leaseNS == "" { leaseNS = ns }
+	sum := sha256.Sum256([]byte(ns + "/" + app))
+	return leaseNS, "deploy-lock-" + hex.EncodeToString(sum[:])[:16]
+}
+
+// Acquire cria ou assume o Lease se estiver livre/expirado. Conflito de
+// resourceVersion significa que outra réplica ganhou a corrida.
+func (l *LeaseLocker) Acquire(ctx context.Context, ns, app, holder string, ttl time.Duration) (string, bool, error) {
+	leaseNS, name := l.lease(ns, app)
+	leases := l.cs.CoordinationV1().Leases(leaseNS)
+	now := meta.NewMicroTime(time.Now())
+	secs := int32(ttl.Seconds())
+
+	cur, err := leases.Get(ctx, name, meta.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordv1.Lease{
+			ObjectMeta: meta.ObjectMeta{
+				Name:        name,
+				Labels:      map[string]string{"app.kubernetes.io/managed-by": "deploy-orchestrator"},
+				Annotations: map[string]string{"deploy-orchestrator/namespace": ns, "deploy-orchestrator/app": app},
+			},
+			Spec: coordv1.LeaseSpec{HolderIdentity: &holder, LeaseDurationSeconds: &secs, AcquireTime: &now, RenewTime: &now},
+		}, meta.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) { return l.holderOf(ctx, leaseNS, name) }
+		return holder, err == nil, err
+	}
+	if err != nil { return "", false, err }
+
+	if h := leaseHolder(cur); h != "" && h != holder && !leaseExpired(cur) { return h, false, nil }
+	if leaseHolder(cur) != holder { cur.Spec.AcquireTime = &now }
+	cur.Spec.HolderIdentity, cur.Spec.LeaseDurationSeconds, cur.Spec.RenewTime = &holder, &secs, &now
+	if _, err := leases.Update(ctx, cur, meta.UpdateOptions{}); err != nil {
+		if apierrors.IsConflict(err) { return l.holderOf(ctx, leaseNS, name) }
+		return "", false, err
+	}
+	return holder, true, nil
+}
+
+func (l *LeaseLocker) Renew(ctx context.Context, ns, app, holder string, ttl time.Duration) error {
+	leaseNS, name := l.lease(ns, app)
+	leases := l.cs.CoordinationV1().Leases(leaseNS)
+	cur, err := leases.Get(ctx, name, meta.GetOptions{})
+	if err != nil { return err }
+	if leaseHolder(cur) != holder { return ErrLeaseLost }
+	now := meta.NewMicroTime(time.Now())
+	secs := int32(ttl.Seconds())
+	cur.Spec.RenewTime, cur.Spec.LeaseDurationSeconds = &now, &secs
+	_, err = leases.Update(ctx, cur, meta.UpdateOptions{})
+	return err
+}
+
+func (l *LeaseLocker) Release(ctx context.Context, ns, app, holder string) error {
+	leaseNS, name := l.lease(ns, app)
+	leases := l.cs.CoordinationV1().Leases(leaseNS)
+	cur, err := leases.Get(ctx, name, meta.GetOptions{})
+	if apierrors.IsNotFound(err) { return nil }
+	if err != nil { return err }
+	if leaseHolder(cur) != holder { return nil }
+	rv := cur.ResourceVersion
+	err = leases.Delete(ctx, name, meta.DeleteOptions{Preconditions: &meta.Preconditions{ResourceVersion: &rv}})
+	if apierrors.IsNotFound(err) || apierrors.IsConflict(err) { return nil }
+	return err
+}
+
+// Holder devolve o holder do Lease ou "" se não existir ou estiver expirado.
+func (l *LeaseLocker) Holder(ctx context.Context, ns, app string) (string, error) {
+	leaseNS, name := l.lease(ns, app)
+	cur, err := l.cs.CoordinationV1().Leases(leaseNS).Get(ctx, name, meta.GetOptions{})
+	if apierrors.IsNotFound(err) { return "", nil }
+	if err != nil { return "", err }
+	if leaseExpired(cur) { return "", nil }
+	return leaseHolder(cur), nil
+}
+
+func (l *LeaseLocker) holderOf(ctx context.Context, ns, name string) (string, bool, error) {
+	cur, err := l.cs.CoordinationV1().Leases(ns).Get(ctx, name, meta.GetOptions{})
+	if err != nil { return "", false, err }
+	return leaseHolder(cur), false, nil
+}
+
+func leaseHolder(l *coordv1.Lease) string {
+	if l.Spec.HolderIdentity == nil { return "" }
+	return *l.Spec.HolderIdentity
+}
+
+func leaseExpired(l *coordv1.Lease) bool {
+	if l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil { return true }
+	return time.Now().After(l.Spec.RenewTime.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second))
+}
@@ -0,0 +1,30 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeaseNames(t *testing.T) {
+	l := NewLeaseLocker(fake.NewSimpleClientset(), "locks")
+	long := "a-very-long-namespace-name-that-fills-most-of-the-lease-name"
+	seen := map[string]string{}
+	for _, p := range [][2]string{{"a-b", "c"}, {"a", "b-c"}, {long, "web"}, {long, "api"}} {
+		_, name := l.lease(p[0], p[1])
+		if len(name) > 63 { t.Fatalf("%s/%s: nome %q passa de 63", p[0], p[1], name) }
+		if prev, ok := seen[name]; ok { t.Fatalf("%s/%s colide com %s em %q", p[0], p[1], prev, name) }
+		seen[name] = p[0] + "/" + p[1]
+	}
+
+	ctx := context.Background()
+	if _, ok, err := l.Acquire(ctx, "a-b", "c", "d1", time.Minute); err != nil || !ok { t.Fatalf("acquire a-b/c: %v", err) }
+	if _, ok, err := l.Acquire(ctx, "a", "b-c", "d2", time.Minute); err != nil || !ok { t.Fatalf("a/b-c ficou preso no lock de a-b/c: %v", err) }
+	_, name := l.lease("a-b", "c")
+	lease, err := l.cs.CoordinationV1().Leases("locks").Get(ctx, name, meta.GetOptions{})
+	if err != nil { t.Fatal(err) }
+	if a := lease.Annotations; a["deploy-orchestrator/namespace"] != "a-b" || a["deploy-orchestrator/app"] != "c" { t.Fatalf("annotations = %v", a) }
+}
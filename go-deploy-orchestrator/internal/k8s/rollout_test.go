@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStatefulSetPartition(t *testing.T) {
+	replicas := int32(4)
+	cs := fake.NewSimpleClientset(&appsv1.StatefulSet{
+		ObjectMeta: meta.ObjectMeta{Name: "db", Namespace: "prod"},
+		Spec: appsv1.StatefulSetSpec{Replicas: &replicas, Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "db", Image: "repo/db:1"}},
+		}}},
+	})
+	ctx := context.Background()
+	s := NewStatefulSetTarget(cs, "prod", "db")
+	if snap, _ := s.Snapshot(ctx); snap.Image != "repo/db:1" { t.Fatalf("snapshot=%+v", snap) }
+
+	partition := func() int32 {
+		sts, _ := s.get(ctx)
+		return *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	if err := s.PrepareImage(ctx, "repo/db:2"); err != nil { t.Fatal(err) }
+	if p := partition(); p != 4 { t.Fatalf("partition after prepare=%d want 4", p) }
+	if err := s.SetPartition(ctx, 2); err != nil { t.Fatal(err) }
+	if p := partition(); p != 2 { t.Fatalf("partition=%d want 2", p) }
+	if err := s.Rollback(ctx, Snapshot{Image: "repo/db:1"}); err != nil { t.Fatal(err) }
+	sts, _ := s.get(ctx)
+	if partition() != 0 || sts.Spec.Template.Spec.Containers[0].Image != "repo/db:1" { t.Fatalf("rollback left %+v", sts.Spec) }
+}
+
+func TestDaemonSetBatches(t *testing.T) {
+	node := func(name, zone string) *corev1.Node {
+		n := &corev1.Node{ObjectMeta: meta.ObjectMeta{Name: name, Labels: map[string]string{}}}
+		if zone != "" { n.Labels["zone"] = zone }
+		return n
+	}
+	pod := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: meta.ObjectMeta{Name: name, Namespace: "kube-system", Labels: map[string]string{"app": "agent"}},
+			Spec:       corev1.PodSpec{NodeName: node, Containers: []corev1.Container{{Name: "agent", Image: "repo/agent:1"}}},
+		}
+	}
+	cs := fake.NewSimpleClientset(
+		&appsv1.DaemonSet{
+			ObjectMeta: meta.ObjectMeta{Name: "agent", Namespace: "kube-system"},
+			Spec: appsv1.DaemonSetSpec{
+				Selector: &meta.LabelSelector{MatchLabels: map[string]string{"app": "agent"}},
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "agent", Image: "repo/agent:1"}}}},
+			},
+		},
+		node("n1", "b"), node("n2", "a"), node("n3", "b"), node("n4", ""), node("n5", "a"),
+		pod("p1", "n1"), pod("p2", "n2"), pod("p3", "n3"), pod("p4", "n4"),
+	)
+	d := NewDaemonSetTarget(cs, "kube-system", "agent")
+	got, err := d.Batches(context.Background(), "zone")
+	if err != nil { t.Fatal(err) }
+	want := []NodeBatch{{"a", []string{"n2"}}, {"b", []string{"n1", "n3"}}, {"", []string{"n4"}}}
+	if !reflect.DeepEqual(got, want) { t.Fatalf("batches=%+v want %+v", got, want) }
+
+	if err := d.PrepareImage(context.Background(), "repo/agent:2"); err != nil { t.Fatal(err) }
+	ds, _ := d.get(context.Background())
+	if ds.Spec.UpdateStrategy.Type != appsv1.OnDeleteDaemonSetStrategyType { t.Fatalf("strategy=%v", ds.Spec.UpdateStrategy.Type) }
+	if err := d.Finish(context.Background()); err != nil { t.Fatal(err) }
+	ds, _ = d.get(context.Background())
+	if ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType { t.Fatal("Finish did not restore the update strategy") }
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	tpl := func(image, env string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "web", Image: image, Env: []corev1.EnvVar{{Name: "MODE", Value: env}},
+		}}}}
+	}
+	replicas := int32(3)
+	cs := fake.NewSimpleClientset(
+		&appsv1.Deployment{
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "prod", Annotations: map[string]string{revisionAnnotation: "7"}},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Template: tpl("repo/web:1", "old")},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas, Template: tpl("repo/web:1", "old")},
+		},
+	)
+	ctx := context.Background()
+
+	dt := NewDeploymentTarget(NewDeployer(cs.AppsV1().Deployments("prod")), "web")
+	snap, err := dt.Snapshot(ctx)
+	if err != nil { t.Fatal(err) }
+	if snap.Image != "repo/web:1" || snap.Revision != "7" || snap.Template == nil { t.Fatalf("snapshot=%+v", snap) }
+	if err := dt.SetImage(ctx, "repo/web:2"); err != nil { t.Fatal(err) }
+
+	// o SetImage da estratégia aplica o template inteiro, não só a imagem
+	want := tpl("repo/web:0", "restored")
+	if err := Restore(dt, Snapshot{Image: "repo/web:0", Template: &want}).SetImage(ctx, "ignored"); err != nil { t.Fatal(err) }
+	dep, _ := cs.AppsV1().Deployments("prod").Get(ctx, "web", meta.GetOptions{})
+	if !reflect.DeepEqual(dep.Spec.Template, want) { t.Fatalf("deployment template=%+v", dep.Spec.Template) }
+
+	// StatefulSet restaurado continua Partitioned e respeita a partição
+	st := Restore(NewStatefulSetTarget(cs, "prod", "web"), Snapshot{Template: &want})
+	p, ok := st.(Partitioned)
+	if !ok { t.Fatalf("restored statefulset target is %T", st) }
+	if err := p.PrepareImage(ctx, "ignored"); err != nil { t.Fatal(err) }
+	sts, _ := cs.AppsV1().StatefulSets("prod").Get(ctx, "web", meta.GetOptions{})
+	if !reflect.DeepEqual(sts.Spec.Template, want) || *sts.Spec.UpdateStrategy.RollingUpdate.Partition != 3 {
+		t.Fatalf("statefulset spec=%+v", sts.Spec)
+	}
+
+	// sem template (Kustomize/Helm sem snapshot) cai na troca de imagem
+	if err := Restore(dt, Snapshot{Image: "repo/web:1"}).SetImage(ctx, "ignored"); err != nil { t.Fatal(err) }
+	dep, _ = cs.AppsV1().Deployments("prod").Get(ctx, "web", meta.GetOptions{})
+	if img := dep.Spec.Template.Spec.Containers[0].Image; img != "repo/web:1" { t.Fatalf("image=%s", img) }
+}
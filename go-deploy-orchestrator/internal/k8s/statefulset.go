@@ -0,0 +1,164 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Partitioned é um Target que atualiza pods por partição (StatefulSet):
+// só os ordinais >= partition recebem o template novo.
+type Partitioned interface {
+	Target
+	// PrepareImage troca a imagem com partition = replicas (nenhum pod muda ainda).
+	PrepareImage(ctx context.Context, image string) error
+	SetPartition(ctx context.Context, partition int32) error
+}
+
+type StatefulSetTarget struct {
+	cs      kubernetes.Interface
+	ns      string
+	name    string
+	restore *corev1.PodTemplateSpec // ver Restore
+}
+
+func NewStatefulSetTarget(cs kubernetes.Interface, namespace, name string) *StatefulSetTarget {
+	return &StatefulSetTarget{cs: cs, ns: namespace, name: name}
+}
+
+func (s *StatefulSetTarget) get(ctx context.Context) (*appsv1.StatefulSet, error) {
+	return s.cs.AppsV1().StatefulSets(s.ns).Get(ctx, s.name, meta.GetOptions{})
+}
+
+// update aplica fn ao StatefulSet atual e grava.
+func (s *StatefulSetTarget) update(ctx context.Context, fn func(*appsv1.StatefulSet) error) error {
+	sts, err := s.get(ctx)
+	if err != nil { return err }
+	if err := fn(sts); err != nil { return err }
+	_, err = s.cs.AppsV1().StatefulSets(s.ns).Update(ctx, sts, meta.UpdateOptions{})
+	return err
+}
+
+func (s *StatefulSetTarget) Snapshot(ctx context.Context) (Snapshot, error) {
+	sts, err := s.get(ctx)
+	if err != nil { return Snapshot{}, err }
+	return Snapshot{
+		Image:    templateImage(sts.Spec.Template, s.name),
+		Revision: sts.Status.UpdateRevision,
+		Template: sts.Spec.Template.DeepCopy(),
+	}, nil
+}
+
+// SetImage faz o rolling update completo (partition 0).
+func (s *StatefulSetTarget) SetImage(ctx context.Context, image string) error {
+	return s.update(ctx, func(sts *appsv1.StatefulSet) error {
+		applyTemplate(&sts.Spec.Template, s.restore, s.name, image)
+		return setPartition(sts, 0)
+	})
+}
+
+func (s *StatefulSetTarget) PrepareImage(ctx context.Context, image string) error {
+	return s.update(ctx, func(sts *appsv1.StatefulSet) error {
+		if err := setPartition(sts, replicasOf(sts.Spec.Replicas)); err != nil { return err }
+		applyTemplate(&sts.Spec.Template, s.restore, s.name, image)
+		return nil
+	})
+}
+
+func (s *StatefulSetTarget) restoring(tpl corev1.PodTemplateSpec) Target {
+	c := *s
+	c.restore = &tpl
+	return &c
+}
+
+func (s *StatefulSetTarget) SetPartition(ctx context.Context, partition int32) error {
+	return s.update(ctx, func(sts *appsv1.StatefulSet) error { return setPartition(sts, partition) })
+}
+
+func (s *StatefulSetTarget) Replicas(ctx context.Context) (int32, error) {
+	sts, err := s.get(ctx)
+	if err != nil { return 0, err }
+	return replicasOf(sts.Spec.Replicas), nil
+}
+
+func (s *StatefulSetTarget) Scale(ctx context.Context, replicas int32) error {
+	return s.update(ctx, func(sts *appsv1.StatefulSet) error { sts.Spec.Replicas = &replicas; return nil })
+}
+
+// WaitRollout espera os pods acima da partição atualizados e todos prontos.
+func (s *StatefulSetTarget) WaitRollout(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		sts, err := s.get(ctx)
+		if err != nil { return err }
+		if statefulSetReady(sts) { return nil }
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("rollout timeout for statefulset %s", s.name)
+}
+
+// Rollback volta a imagem e zera a partição: o controller recria os pods já
+// atualizados com o template anterior.
+func (s *StatefulSetTarget) Rollback(ctx context.Context, to Snapshot) error {
+	if to.Template == nil && to.Image == "" { return nil }
+	return s.update(ctx, func(sts *appsv1.StatefulSet) error {
+		applyTemplate(&sts.Spec.Template, to.Template, s.name, to.Image)
+		return setPartition(sts, 0)
+	})
+}
+
+func setPartition(sts *appsv1.StatefulSet, partition int32) error {
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return errors.New("statefulset uses OnDelete update strategy; partitioned rollout needs RollingUpdate")
+	}
+	sts.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+	if sts.Spec.UpdateStrategy.RollingUpdate == nil {
+		sts.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{}
+	}
+	sts.Spec.UpdateStrategy.RollingUpdate.Partition = &partition
+	return nil
+}
+
+func statefulSetReady(s *appsv1.StatefulSet) bool {
+	want := replicasOf(s.Spec.Replicas)
+	var partition int32
+	if ru := s.Spec.UpdateStrategy.RollingUpdate; ru != nil && ru.Partition != nil { partition = *ru.Partition }
+	return s.Status.ObservedGeneration >= s.Generation &&
+		s.Status.UpdatedReplicas >= max(0, want-partition) &&
+		s.Status.ReadyReplicas == want
+}
+
+func replicasOf(r *int32) int32 {
+	if r == nil { return 1 }
+	return *r
+}
+
+// templateImage devolve a imagem do container com o nome dado (ou do primeiro).
+func templateImage(t corev1.PodTemplateSpec, container string) string {
+	for _, c := range t.Spec.Containers {
+		if c.Name == container { return c.Image }
+	}
+	if len(t.Spec.Containers) > 0 { return t.Spec.Containers[0].Image }
+	return ""
+}
+
+// applyTemplate copia tpl (quando não nil) ou troca só a imagem do container.
+func applyTemplate(t *corev1.PodTemplateSpec, tpl *corev1.PodTemplateSpec, container, image string) {
+	if tpl != nil { *t = *tpl.DeepCopy(); return }
+	setTemplateImage(t, container, image)
+}
+
+func setTemplateImage(t *corev1.PodTemplateSpec, container, image string) {
+	for i := range t.Spec.Containers {
+		if t.Spec.Containers[i].Name == container { t.Spec.Containers[i].Image = image; return }
+	}
+	if len(t.Spec.Containers) > 0 { t.Spec.Containers[0].Image = image }
+}
+
+var _ Partitioned = (*StatefulSetTarget)(nil)
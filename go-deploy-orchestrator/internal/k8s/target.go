@@ -0,0 +1,119 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Target é o que as estratégias alteram: um Deployment, ou o conjunto de
+// workloads renderizado por um overlay Kustomize ou release Helm.
+type Target interface {
+	// Snapshot captura o estado atual para um Rollback posterior.
+	Snapshot(ctx context.Context) (Snapshot, error)
+	SetImage(ctx context.Context, image string) error
+	// Replicas soma as réplicas desejadas dos workloads escaláveis.
+	Replicas(ctx context.Context) (int32, error)
+	// Scale distribui replicas entre os workloads, proporcional ao desejado.
+	Scale(ctx context.Context, replicas int32) error
+	WaitRollout(ctx context.Context, timeout time.Duration) error
+	Rollback(ctx context.Context, to Snapshot) error
+}
+
+// Snapshot é o ponto de retorno de um Target.
+type Snapshot struct {
+	Image    string `json:"image"`
+	Revision string `json:"revision,omitempty"` // revision do release Helm, do Deployment ou do StatefulSet
+	// Template é o pod template completo (Deployment, StatefulSet, DaemonSet).
+	Template *corev1.PodTemplateSpec `json:"template,omitempty"`
+}
+
+// restorer é implementado pelos Targets que sabem aplicar um pod template
+// inteiro onde SetImage/PrepareImage trocariam só a imagem.
+type restorer interface {
+	restoring(tpl corev1.PodTemplateSpec) Target
+}
+
+// Restore devolve um Target que leva o workload de volta a snap quando a
+// estratégia chama SetImage (ou PrepareImage), de modo que canary e
+// análises rodam inalterados num rollback manual. Sem template no snapshot
+// (Kustomize, Helm), SetImage vira Rollback(snap) ou a troca da imagem.
+func Restore(t Target, snap Snapshot) Target {
+	if r, ok := t.(restorer); ok && snap.Template != nil { return r.restoring(*snap.Template) }
+	return &restoreTarget{Target: t, snap: snap}
+}
+
+type restoreTarget struct {
+	Target
+	snap Snapshot
+}
+
+// SetImage volta a revision (Helm) ou reaplica a imagem do snapshot.
+func (r *restoreTarget) SetImage(ctx context.Context, _ string) error {
+	if r.snap.Revision == "" { return r.Target.SetImage(ctx, r.snap.Image) }
+	return r.Rollback(ctx, r.snap)
+}
+
+// deploymentTarget adapta o Deployer a um único Deployment com o nome do app.
+type deploymentTarget struct {
+	d       *Deployer
+	name    string
+	restore *corev1.PodTemplateSpec // ver Restore
+}
+
+func NewDeploymentTarget(d *Deployer, name string) Target { return &deploymentTarget{d: d, name: name} }
+
+// revisionAnnotation é gravada pelo controller de Deployment a cada rollout.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+func (t *deploymentTarget) Snapshot(ctx context.Context) (Snapshot, error) {
+	dep, err := t.d.Get(ctx, t.name)
+	if err != nil { return Snapshot{}, err }
+	return Snapshot{
+		Image:    templateImage(dep.Spec.Template, t.name),
+		Revision: dep.Annotations[revisionAnnotation],
+		Template: dep.Spec.Template.DeepCopy(),
+	}, nil
+}
+
+func (t *deploymentTarget) SetImage(ctx context.Context, image string) error {
+	if t.restore != nil { return t.d.SetTemplate(ctx, t.name, *t.restore) }
+	return t.d.SetImage(ctx, t.name, t.name, image)
+}
+
+func (t *deploymentTarget) restoring(tpl corev1.PodTemplateSpec) Target {
+	c := *t
+	c.restore = &tpl
+	return &c
+}
+
+func (t *deploymentTarget) Replicas(ctx context.Context) (int32, error) {
+	dep, err := t.d.Get(ctx, t.name)
+	if err != nil { return 0, err }
+	if dep.Spec.Replicas == nil { return 1, nil }
+	return *dep.Spec.Replicas, nil
+}
+
+func (t *deploymentTarget) Scale(ctx context.Context, replicas int32) error {
+	return t.d.Scale(ctx, t.name, replicas)
+}
+
+func (t *deploymentTarget) WaitRollout(ctx context.Context, timeout time.Duration) error {
+	return t.d.WaitRollout(ctx, t.name, timeout)
+}
+
+func (t *deploymentTarget) Rollback(ctx context.Context, to Snapshot) error {
+	if to.Template != nil { return t.d.SetTemplate(ctx, t.name, *to.Template) }
+	if to.Image == "" { return nil }
+	return t.d.SetImage(ctx, t.name, t.name, to.Image)
+}
+
+// splitImage separa "registry/repo:tag" em repo e tag (a porta do registry não é tag).
+func splitImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 { return image[:i], "" }
+	slash := strings.LastIndex(image, "/")
+	if i := strings.LastIndex(image, ":"); i > slash { return image[:i], image[i+1:] }
+	return image, ""
+}
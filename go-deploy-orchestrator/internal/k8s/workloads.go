@@ -0,0 +1,190 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+)
+
+// workloadRef é um workload de um conjunto renderizado (Kustomize/Helm).
+type workloadRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Replicas  int32 // desejadas no manifesto; 0 para DaemonSet
+}
+
+// workloadSet trata os Deployments, StatefulSets e DaemonSets de um
+// manifesto como um único Target para as estratégias.
+type workloadSet struct {
+	cs   kubernetes.Interface
+	ns   string
+	refs []workloadRef
+}
+
+// setManifests atualiza os workloads a partir dos objetos renderizados.
+func (w *workloadSet) setManifests(objs []*unstructured.Unstructured) {
+	w.refs = nil
+	for _, o := range objs {
+		ref := workloadRef{Kind: o.GetKind(), Namespace: o.GetNamespace(), Name: o.GetName()}
+		if ref.Namespace == "" { ref.Namespace = w.ns }
+		switch ref.Kind {
+		case "Deployment", "StatefulSet":
+			ref.Replicas = 1
+			// o decoder YAML produz float64; manifests já tipados trazem int64
+			v, _, _ := unstructured.NestedFieldNoCopy(o.Object, "spec", "replicas")
+			switch n := v.(type) {
+			case float64: ref.Replicas = int32(n)
+			case int64: ref.Replicas = int32(n)
+			}
+		case "DaemonSet":
+		default:
+			continue
+		}
+		w.refs = append(w.refs, ref)
+	}
+}
+
+func (w *workloadSet) Replicas(context.Context) (int32, error) {
+	var total int32
+	for _, r := range w.refs { total += r.Replicas }
+	if total == 0 { return 0, errors.New("target has no scalable workloads") }
+	return total, nil
+}
+
+func (w *workloadSet) Scale(ctx context.Context, replicas int32) error {
+	total, err := w.Replicas(ctx)
+	if err != nil { return err }
+	for _, r := range w.refs {
+		if r.Replicas == 0 { continue }
+		// arredonda para cima: cada workload fica com ao menos 1 réplica
+		n := max(1, (r.Replicas*replicas+total-1)/total)
+		patch := []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, n))
+		switch r.Kind {
+		case "Deployment": _, err = w.cs.AppsV1().Deployments(r.Namespace).Patch(ctx, r.Name, types.MergePatchType, patch, meta.PatchOptions{})
+		case "StatefulSet": _, err = w.cs.AppsV1().StatefulSets(r.Namespace).Patch(ctx, r.Name, types.MergePatchType, patch, meta.PatchOptions{})
+		}
+		if err != nil { return fmt.Errorf("scale %s/%s: %w", r.Kind, r.Name, err) }
+	}
+	return nil
+}
+
+func (w *workloadSet) WaitRollout(ctx context.Context, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, r := range w.refs {
+		for {
+			ok, err := w.ready(ctx, r)
+			if err != nil { return err }
+			if ok { break }
+			if time.Now().After(deadline) { return fmt.Errorf("rollout timeout for %s/%s", r.Kind, r.Name) }
+			time.Sleep(2 * time.Second)
+		}
+	}
+	return nil
+}
+
+func (w *workloadSet) ready(ctx context.Context, r workloadRef) (bool, error) {
+	apps := w.cs.AppsV1()
+	switch r.Kind {
+	case "Deployment":
+		d, err := apps.Deployments(r.Namespace).Get(ctx, r.Name, meta.GetOptions{})
+		if err != nil { return false, err }
+		return deploymentReady(d), nil
+	case "StatefulSet":
+		s, err := apps.StatefulSets(r.Namespace).Get(ctx, r.Name, meta.GetOptions{})
+		if err != nil { return false, err }
+		return statefulSetReady(s), nil
+	case "DaemonSet":
+		d, err := apps.DaemonSets(r.Namespace).Get(ctx, r.Name, meta.GetOptions{})
+		if err != nil { return false, err }
+		return daemonSetReady(d), nil
+	}
+	return true, nil
+}
+
+// currentImage devolve a imagem em uso do primeiro container cujo repositório
+// é repo (ou do primeiro container, se repo for vazio).
+func (w *workloadSet) currentImage(ctx context.Context, repo string) (string, error) {
+	apps := w.cs.AppsV1()
+	for _, r := range w.refs {
+		var tpl corev1.PodTemplateSpec
+		switch r.Kind {
+		case "Deployment":
+			d, err := apps.Deployments(r.Namespace).Get(ctx, r.Name, meta.GetOptions{})
+			if err != nil { return "", err }
+			tpl = d.Spec.Template
+		case "StatefulSet":
+			s, err := apps.StatefulSets(r.Namespace).Get(ctx, r.Name, meta.GetOptions{})
+			if err != nil { return "", err }
+			tpl = s.Spec.Template
+		case "DaemonSet":
+			d, err := apps.DaemonSets(r.Namespace).Get(ctx, r.Name, meta.GetOptions{})
+			if err != nil { return "", err }
+			tpl = d.Spec.Template
+		}
+		for _, c := range tpl.Spec.Containers {
+			if cr, _ := splitImage(c.Image); repo == "" || cr == repo { return c.Image, nil }
+		}
+	}
+	return "", nil
+}
+
+func deploymentReady(d *appsv1.Deployment) bool {
+	want := int32(1)
+	if d.Spec.Replicas != nil { want = *d.Spec.Replicas }
+	return d.Status.UpdatedReplicas == want && d.Status.ReadyReplicas == want && d.Status.ObservedGeneration >= d.Generation
+}
+
+// parseManifests decodifica um stream YAML multi-documento.
+func parseManifests(b []byte) ([]*unstructured.Unstructured, error) {
+	dec := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096)
+	var out []*unstructured.Unstructured
+	for {
+		var obj map[string]any
+		if err := dec.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) { return out, nil }
+			return nil, err
+		}
+		if len(obj) == 0 { continue }
+		out = append(out, &unstructured.Unstructured{Object: obj})
+	}
+}
+
+// replaceImages troca a imagem dos containers cujo repositório é repo.
+func replaceImages(obj *unstructured.Unstructured, repo, image string) {
+	var spec []string
+	switch obj.GetKind() {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job":
+		spec = []string{"spec", "template", "spec"}
+	case "CronJob":
+		spec = []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	case "Pod":
+		spec = []string{"spec"}
+	default:
+		return
+	}
+	for _, field := range []string{"initContainers", "containers"} {
+		path := append(append([]string(nil), spec...), field)
+		cs, ok, _ := unstructured.NestedSlice(obj.Object, path...)
+		if !ok { continue }
+		for i := range cs {
+			c, ok := cs[i].(map[string]any)
+			if !ok { continue }
+			if img, _ := c["image"].(string); img != "" {
+				if r, _ := splitImage(img); r == repo { c["image"] = image }
+			}
+		}
+		_ = unstructured.SetNestedSlice(obj.Object, cs, path...)
+	}
+}
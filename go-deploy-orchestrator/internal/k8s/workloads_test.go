@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const manifest = `
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: web-config}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: web}
+spec:
+  replicas: 4
+  template:
+    spec:
+      initContainers: [{name: migrate, image: ghcr.io/acme/web:v1}]
+      containers: [{name: web, image: ghcr.io/acme/web:v1}, {name: proxy, image: envoyproxy/envoy:v1.30}]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata: {name: worker, namespace: jobs}
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers: [{name: worker, image: "registry:5000/acme/web:v1"}]
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata: {name: agent}
+spec:
+  template:
+    spec:
+      containers: [{name: agent, image: ghcr.io/acme/agent:v1}]
+`
+
+func TestWorkloadSetScale(t *testing.T) {
+	objs, err := parseManifests([]byte(manifest))
+	if err != nil || len(objs) != 4 { t.Fatalf("objs=%d err=%v", len(objs), err) }
+
+	cs := fake.NewSimpleClientset(
+		&appsv1.Deployment{ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "prod"}},
+		&appsv1.Deployment{ObjectMeta: meta.ObjectMeta{Name: "worker", Namespace: "jobs"}},
+	)
+	w := &workloadSet{cs: cs, ns: "prod"}
+	w.setManifests(objs)
+	if len(w.refs) != 3 { t.Fatalf("refs=%+v", w.refs) }
+
+	ctx := context.Background()
+	if n, err := w.Replicas(ctx); err != nil || n != 6 { t.Fatalf("replicas=%d err=%v", n, err) }
+	if err := w.Scale(ctx, 3); err != nil { t.Fatal(err) }
+	for _, tt := range []struct{ ns, name string; want int32 }{{"prod", "web", 2}, {"jobs", "worker", 1}} {
+		d, err := cs.AppsV1().Deployments(tt.ns).Get(ctx, tt.name, meta.GetOptions{})
+		if err != nil { t.Fatal(err) }
+		if d.Spec.Replicas == nil || *d.Spec.Replicas != tt.want { t.Fatalf("%s replicas=%v want %d", tt.name, d.Spec.Replicas, tt.want) }
+	}
+}
+
+func TestReplaceImages(t *testing.T) {
+	objs, _ := parseManifests([]byte(manifest))
+	for _, o := range objs { replaceImages(o, "ghcr.io/acme/web", "ghcr.io/acme/web:v2") }
+
+	images := func(o *unstructured.Unstructured, field string) []string {
+		cs, _, _ := unstructured.NestedSlice(o.Object, "spec", "template", "spec", field)
+		var out []string
+		for _, c := range cs { out = append(out, c.(map[string]any)["image"].(string)) }
+		return out
+	}
+	if got := images(objs[1], "containers"); got[0] != "ghcr.io/acme/web:v2" || got[1] != "envoyproxy/envoy:v1.30" { t.Fatalf("containers=%v", got) }
+	if got := images(objs[1], "initContainers"); got[0] != "ghcr.io/acme/web:v2" { t.Fatalf("initContainers=%v", got) }
+	if got := images(objs[2], "containers"); got[0] != "registry:5000/acme/web:v1" { t.Fatalf("other repo changed: %v", got) }
+}
@@ -23,9 +23,26 @@ var (
 		prometheus.HistogramOpts{Name:"do_step_duration_seconds",Help:"Duração por etapa do deploy"},
 		[]string{"app","strategy","step"},
 	)
+	WebhookPushes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name:"do_webhook_pushes_total",Help:"Pushes recebidos via webhook de registry"},
+		[]string{"source","result"}, // result: matched|ignored|rejected
+	)
+	DoraDeployFrequency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name:"do_dora_deploys_per_day",Help:"Deploys com sucesso por dia na janela de stats"},
+		[]string{"namespace","app"},
+	)
+	DoraChangeFailureRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name:"do_dora_change_failure_rate",Help:"Fração de deploys que terminaram em rollback/falha"},
+		[]string{"namespace","app"},
+	)
+	DoraMTTR = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name:"do_dora_mttr_seconds",Help:"Tempo médio entre um deploy com falha e o próximo sucesso"},
+		[]string{"namespace","app"},
+	)
 )
 
 func MustRegister() {
-	prometheus.MustRegister(DeploysStarted, DeploysSucceeded, DeploysFailed, StepDuration)
+	prometheus.MustRegister(DeploysStarted, DeploysSucceeded, DeploysFailed, StepDuration, WebhookPushes,
+		DoraDeployFrequency, DoraChangeFailureRate, DoraMTTR)
 }
 func Handler() http.Handler { return promhttp.Handler() }
\ No newline at end of file
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// GitHub publica o andamento como statuses da Deployments API.
+// Usa o deployment do param githubDeploymentId ou cria um no evento started
+// quando houver githubRef (sha/branch/tag). Repo vem de githubRepo ou da config.
+type GitHub struct {
+	cl    *http.Client
+	api   string
+	token string
+	repo  string
+
+	mu  sync.Mutex
+	ids map[string]int64 // deploy ID -> GitHub deployment ID
+}
+
+func NewGitHub(cl *http.Client, api, token, repo string) *GitHub {
+	if api == "" { api = "https://api.github.com" }
+	return &GitHub{cl: cl, api: strings.TrimSuffix(api, "/"), token: token, repo: repo, ids: map[string]int64{}}
+}
+
+func (g *GitHub) Name() string { return "github" }
+
+var githubStates = map[string]string{
+	Started: "in_progress", WaitingApproval: "queued", Step: "in_progress",
+	Succeeded: "success", RolledBack: "failure",
+}
+
+func (g *GitHub) Notify(ctx context.Context, ev Event) error {
+	d := ev.Deploy
+	repo := d.Params["githubRepo"]
+	if repo == "" { repo = g.repo }
+	if repo == "" { return nil }
+
+	id, err := g.deploymentID(ctx, repo, ev)
+	if err != nil || id == 0 { return err }
+
+	body := map[string]any{
+		"state":       githubStates[ev.Type],
+		"description": truncate(ev.Message, 140),
+		"environment": environment(d),
+	}
+	if ev.URL != "" { body["log_url"] = ev.URL }
+	if ev.Type == Succeeded || ev.Type == RolledBack { g.forget(d.ID) }
+	return g.post(ctx, fmt.Sprintf("/repos/%s/deployments/%d/statuses", repo, id), body, nil)
+}
+
+func (g *GitHub) deploymentID(ctx context.Context, repo string, ev Event) (int64, error) {
+	d := ev.Deploy
+	if v := d.Params["githubDeploymentId"]; v != "" { return strconv.ParseInt(v, 10, 64) }
+	g.mu.Lock()
+	id := g.ids[d.ID]
+	g.mu.Unlock()
+	if id != 0 || ev.Type != Started || d.Params["githubRef"] == "" { return id, nil }
+
+	var out struct{ ID int64 `json:"id"` }
+	err := g.post(ctx, "/repos/"+repo+"/deployments", map[string]any{
+		"ref":               d.Params["githubRef"],
+		"environment":       environment(d),
+		"description":       fmt.Sprintf("%s %s via %s", d.App, d.ImageNew, d.Strategy),
+		"auto_merge":        false,
+		"required_contexts": []string{},
+		"payload":           map[string]string{"deployId": d.ID, "image": d.ImageNew},
+	}, &out)
+	if err != nil { return 0, err }
+	g.mu.Lock()
+	g.ids[d.ID] = out.ID
+	g.mu.Unlock()
+	return out.ID, nil
+}
+
+func (g *GitHub) forget(id string) {
+	g.mu.Lock()
+	delete(g.ids, id)
+	g.mu.Unlock()
+}
+
+func (g *GitHub) post(ctx context.Context, path string, body, out any) error {
+	b, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.api+path, bytes.NewReader(b))
+	if err != nil { return err }
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	if g.token != "" { req.Header.Set("Authorization", "Bearer "+g.token) }
+	resp, err := g.cl.Do(req)
+	if err != nil { return err }
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 { return errHTTP("github", resp.Status) }
+	if out != nil { return json.NewDecoder(resp.Body).Decode(out) }
+	return nil
+}
+
+func environment(d store.DeployRecord) string {
+	if e := d.Params["githubEnvironment"]; e != "" { return e }
+	return d.Namespace
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n { return s }
+	return s[:n-3] + "..."
+}
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/util"
+)
+
+// Tipos de evento de ciclo de vida enviados aos notifiers.
+const (
+	Started         = "started"
+	WaitingApproval = "waiting_approval"
+	Step            = "step"
+	Succeeded       = "succeeded"
+	RolledBack      = "rolled_back"
+)
+
+type Event struct {
+	Type    string             `json:"type"`
+	Step    string             `json:"step,omitempty"`
+	Message string             `json:"message"`
+	Deploy  store.DeployRecord `json:"deploy"`
+	URL     string             `json:"url,omitempty"` // link para a timeline do deploy
+	Time    time.Time          `json:"time"`
+}
+
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, ev Event) error
+}
+
+type route struct {
+	n      Notifier
+	events map[string]bool // vazio = todos
+}
+
+// Dispatcher entrega eventos a todos os notifiers em um worker único,
+// preservando a ordem (importante para os statuses do GitHub).
+type Dispatcher struct {
+	log       *logger.Logger
+	publicURL string
+	routes    []route
+	ch        chan Event
+}
+
+func New(log *logger.Logger, cfg config.Notifications) (*Dispatcher, error) {
+	d := &Dispatcher{log: log, publicURL: cfg.PublicURL, ch: make(chan Event, 256)}
+	cl := &http.Client{Timeout: 10 * time.Second}
+	for _, nc := range cfg.Notifiers {
+		var n Notifier
+		switch nc.Type {
+		case "slack": n = NewSlack(cl, nc.URL)
+		case "webhook": n = NewWebhook(cl, nc.URL, nc.Headers)
+		case "github": n = NewGitHub(cl, nc.URL, nc.Token, nc.Repo)
+		default: return nil, fmt.Errorf("unknown notifier type %q", nc.Type)
+		}
+		r := route{n: n, events: map[string]bool{}}
+		// o GitHub precisa de todos os eventos: sem o succeeded/rolled_back o
+		// deployment fica in_progress para sempre
+		if nc.Type == "github" && len(nc.Events) > 0 {
+			log.Warn().Str("notifier", "github").Msg("events filter ignored: github receives every lifecycle event")
+			nc.Events = nil
+		}
+		for _, e := range nc.Events { r.events[e] = true }
+		d.routes = append(d.routes, r)
+	}
+	return d, nil
+}
+
+// Run consome a fila até o ctx ser cancelado.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done(): return
+		case ev := <-d.ch: d.deliver(ctx, ev)
+		}
+	}
+}
+
+// Publish enfileira o evento sem bloquear o deploy; descarta se a fila estiver cheia.
+func (d *Dispatcher) Publish(ev Event) {
+	if d == nil || len(d.routes) == 0 { return }
+	if ev.Time.IsZero() { ev.Time = time.Now() }
+	if ev.URL == "" && d.publicURL != "" { ev.URL = d.publicURL + "/deploys/" + ev.Deploy.ID + "/events" }
+	select {
+	case d.ch <- ev:
+	default: d.log.Warn().Str("deploy", ev.Deploy.ID).Str("type", ev.Type).Msg("notification queue full, dropping")
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, ev Event) {
+	for _, r := range d.routes {
+		if len(r.events) > 0 && !r.events[ev.Type] { continue }
+		if err := notify(ctx, r.n, ev); err != nil {
+			d.log.Error().Err(err).Str("notifier", r.n.Name()).Str("deploy", ev.Deploy.ID).Str("type", ev.Type).Msg("notify failed")
+		}
+	}
+}
+
+// notify tenta até 3 vezes com backoff; o shutdown (ctx) interrompe a espera.
+func notify(ctx context.Context, n Notifier, ev Event) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done(): return err
+			case <-time.After(util.Backoff(attempt - 1)):
+			}
+		}
+		if err = n.Notify(ctx, ev); err == nil { return nil }
+	}
+	return err
+}
+
+type httpErr string
+
+func (e httpErr) Error() string { return string(e) }
+func errHTTP(who, st string) error { return httpErr(who + " http status " + st) }
@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+type hit struct {
+	path string
+	body map[string]any
+}
+
+func stub(t *testing.T, reply string) (*httptest.Server, func() []hit) {
+	var mu sync.Mutex
+	var hits []hit
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&b)
+		mu.Lock()
+		hits = append(hits, hit{path: r.URL.Path, body: b})
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []hit { mu.Lock(); defer mu.Unlock(); return append([]hit(nil), hits...) }
+}
+
+func TestSlack(t *testing.T) {
+	srv, hits := stub(t, "ok")
+	s := NewSlack(srv.Client(), srv.URL)
+	ev := Event{Type: RolledBack, Message: "deploy rolled_back", Deploy: store.DeployRecord{
+		ID: "abc", App: "myapp", Namespace: "prod", ImageNew: "repo/myapp:1.2.3", Strategy: "canary", Reason: "SLO breach",
+	}}
+	if err := s.Notify(context.Background(), ev); err != nil { t.Fatal(err) }
+	h := hits()
+	if len(h) != 1 { t.Fatalf("hits=%d want 1", len(h)) }
+	text, _ := h[0].body["text"].(string)
+	for _, want := range []string{"prod/myapp", "repo/myapp:1.2.3", "SLO breach"} {
+		if !strings.Contains(text, want) { t.Fatalf("text=%q missing %q", text, want) }
+	}
+}
+
+func TestGitHubDeploymentStatuses(t *testing.T) {
+	srv, hits := stub(t, `{"id":42}`)
+	g := NewGitHub(srv.Client(), srv.URL, "tok", "acme/myapp")
+	d := store.DeployRecord{ID: "abc", App: "myapp", Namespace: "prod", ImageNew: "repo/myapp:1.2.3",
+		Params: map[string]string{"githubRef": "deadbeef"}}
+
+	for _, typ := range []string{Started, Step, Succeeded} {
+		if err := g.Notify(context.Background(), Event{Type: typ, Message: typ, Deploy: d}); err != nil { t.Fatal(err) }
+	}
+	tests := []struct {
+		path, state string
+	}{
+		{"/repos/acme/myapp/deployments", ""},
+		{"/repos/acme/myapp/deployments/42/statuses", "in_progress"},
+		{"/repos/acme/myapp/deployments/42/statuses", "in_progress"},
+		{"/repos/acme/myapp/deployments/42/statuses", "success"},
+	}
+	h := hits()
+	if len(h) != len(tests) { t.Fatalf("hits=%d want %d", len(h), len(tests)) }
+	for i, tt := range tests {
+		if h[i].path != tt.path { t.Fatalf("hit %d path=%q want %q", i, h[i].path, tt.path) }
+		if tt.state != "" && h[i].body["state"] != tt.state { t.Fatalf("hit %d state=%v want %q", i, h[i].body["state"], tt.state) }
+	}
+	if h[0].body["ref"] != "deadbeef" || h[0].body["environment"] != "prod" {
+		t.Fatalf("unexpected deployment payload %v", h[0].body)
+	}
+}
+
+func TestGitHubSkipsWithoutRef(t *testing.T) {
+	srv, hits := stub(t, `{"id":1}`)
+	g := NewGitHub(srv.Client(), srv.URL, "", "acme/myapp")
+	ev := Event{Type: Started, Deploy: store.DeployRecord{ID: "x", Namespace: "prod"}}
+	if err := g.Notify(context.Background(), ev); err != nil { t.Fatal(err) }
+	if n := len(hits()); n != 0 { t.Fatalf("hits=%d want 0", n) }
+}
+
+type failing struct{ calls int }
+
+func (f *failing) Name() string { return "failing" }
+func (f *failing) Notify(context.Context, Event) error { f.calls++; return errors.New("down") }
+
+func TestDispatcherRoutes(t *testing.T) {
+	d, err := New(logger.New("error"), config.Notifications{Notifiers: []config.Notifier{
+		{Type: "slack", URL: "http://slack", Events: []string{Succeeded}},
+		{Type: "github", URL: "http://github", Events: []string{Succeeded}},
+	}})
+	if err != nil { t.Fatal(err) }
+	if r := d.routes[0]; len(r.events) != 1 || !r.events[Succeeded] { t.Fatalf("slack events = %v", r.events) }
+	if r := d.routes[1]; len(r.events) != 0 { t.Fatalf("github filtrado: %v", r.events) }
+}
+
+func TestNotifyStopsOnShutdown(t *testing.T) {
+	f := &failing{}
+	if err := notify(context.Background(), f, Event{}); err == nil || f.calls != 3 { t.Fatalf("err=%v calls=%d", err, f.calls) }
+
+	f = &failing{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := notify(ctx, f, Event{}); err == nil || f.calls != 1 { t.Fatalf("err=%v calls=%d", err, f.calls) }
+	if d := time.Since(start); d > 100*time.Millisecond { t.Fatalf("esperou o backoff com o ctx cancelado: %v", d) }
+}
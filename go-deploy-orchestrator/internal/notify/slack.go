@@ -0,0 +1,61 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type Slack struct {
+	cl  *http.Client
+	url string
+}
+
+func NewSlack(cl *http.Client, webhook string) *Slack { return &Slack{cl: cl, url: webhook} }
+
+func (s *Slack) Name() string { return "slack" }
+
+var slackIcons = map[string]string{
+	Started: ":rocket:", WaitingApproval: ":raised_hand:", Step: ":arrow_forward:",
+	Succeeded: ":white_check_mark:", RolledBack: ":rewind:",
+}
+
+func (s *Slack) Notify(ctx context.Context, ev Event) error {
+	d := ev.Deploy
+	text := fmt.Sprintf("%s *%s/%s* `%s` (%s) — %s", slackIcons[ev.Type], d.Namespace, d.App, d.ImageNew, d.Strategy, ev.Message)
+	if ev.Type == RolledBack && d.Reason != "" { text += "\n> " + d.Reason }
+	if ev.URL != "" { text += fmt.Sprintf(" <%s|timeline>", ev.URL) }
+	return postJSON(ctx, s.cl, s.url, nil, map[string]string{"text": text}, "slack")
+}
+
+// Webhook envia o Event inteiro como JSON para um endpoint genérico.
+type Webhook struct {
+	cl      *http.Client
+	url     string
+	headers map[string]string
+}
+
+func NewWebhook(cl *http.Client, url string, headers map[string]string) *Webhook {
+	return &Webhook{cl: cl, url: url, headers: headers}
+}
+
+func (w *Webhook) Name() string { return "webhook" }
+
+func (w *Webhook) Notify(ctx context.Context, ev Event) error {
+	return postJSON(ctx, w.cl, w.url, w.headers, ev, "webhook")
+}
+
+func postJSON(ctx context.Context, cl *http.Client, url string, headers map[string]string, body any, who string) error {
+	b, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil { return err }
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers { req.Header.Set(k, v) }
+	resp, err := cl.Do(req)
+	if err != nil { return err }
+	_ = resp.Body.Close()
+	if resp.StatusCode/100 != 2 { return errHTTP(who, resp.Status) }
+	return nil
+}
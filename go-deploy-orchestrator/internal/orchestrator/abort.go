@@ -0,0 +1,52 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// AbortedError é a causa do cancelamento de um deploy abortado via API.
+type AbortedError struct{ Actor string }
+
+func (e *AbortedError) Error() string { return "aborted by " + e.Actor }
+
+var (
+	ErrAlreadyFinished = errors.New("deploy already finished")
+	ErrNotLocal        = errors.New("deploy is not running on this replica")
+)
+
+// Abort interrompe um deploy: enfileirado ou aguardando aprovação termina
+// como aborted; em execução, a estratégia para e o deploy faz rollback.
+func (o *Orchestrator) Abort(id, actor string) error {
+	rec, err := o.db.Get(id)
+	if err != nil { return err }
+	if rec == nil { return ErrNotFound }
+	if rec.Finished() { return ErrAlreadyFinished }
+	o.emit(id, "abort", "", "abort requested by "+actor, map[string]string{"abortedBy": actor})
+	if o.runs.cancel(id, &AbortedError{Actor: actor}) { return nil }
+	// o loop de aprovação de outra réplica acompanha o status pelo store
+	if rec.Status == "waiting_approval" {
+		o.finishAborted(rec, actor)
+		return nil
+	}
+	return ErrNotLocal
+}
+
+// finishAborted encerra um deploy que ainda não tocou no cluster.
+func (o *Orchestrator) finishAborted(rec *store.DeployRecord, actor string) {
+	now := time.Now()
+	rec.FinishedAt = &now
+	metrics.DeploysFailed.WithLabelValues(rec.App, rec.Strategy, "aborted").Inc()
+	o.setStatus(rec, "aborted", "aborted by "+actor)
+}
+
+// abortedBy devolve quem abortou o deploy do ctx, se foi o caso.
+func abortedBy(ctx context.Context) (string, bool) {
+	var ae *AbortedError
+	if errors.As(context.Cause(ctx), &ae) { return ae.Actor, true }
+	return "", false
+}
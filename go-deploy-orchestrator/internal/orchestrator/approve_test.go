@@ -0,0 +1,21 @@
+package orchestrator
+
+import (
+	"testing"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/auth"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+func TestApproveSelf(t *testing.T) {
+	o := newLockOrchestrator(t)
+	// anônimo e o authToken do modo legado também não aprovam o próprio deploy
+	for _, who := range []string{"alice", auth.Anonymous, auth.SharedToken} {
+		id := "d-" + who
+		if err := o.db.Put(store.DeployRecord{ID: id, App: "web", Namespace: "prod", Status: "waiting_approval", RequestedBy: who}); err != nil { t.Fatal(err) }
+		if err := o.Approve(id, who); err != ErrSelfApproval { t.Fatalf("%s aprovou o próprio deploy: %v", who, err) }
+	}
+	if err := o.Approve("d-alice", "bob"); err != nil { t.Fatal(err) }
+	rec, _ := o.db.Get("d-alice")
+	if rec.Status != "running" || rec.ApprovedBy != "bob" { t.Fatalf("record = %+v", rec) }
+}
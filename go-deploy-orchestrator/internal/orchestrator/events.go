@@ -0,0 +1,80 @@
+package orchestrator
+
+import (
+	"sync"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/notify"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// notifier acorda os watchers (SSE) de um deploy quando há evento novo.
+// O conteúdo é sempre relido do store, então um sinal perdido não perde evento.
+type notifier struct {
+	mu   sync.Mutex
+	subs map[string]map[chan struct{}]struct{}
+}
+
+func newNotifier() *notifier { return &notifier{subs: map[string]map[chan struct{}]struct{}{}} }
+
+func (n *notifier) subscribe(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	n.mu.Lock()
+	if n.subs[id] == nil { n.subs[id] = map[chan struct{}]struct{}{} }
+	n.subs[id][ch] = struct{}{}
+	n.mu.Unlock()
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subs[id], ch)
+		if len(n.subs[id]) == 0 { delete(n.subs, id) }
+		n.mu.Unlock()
+	}
+}
+
+func (n *notifier) notify(id string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for ch := range n.subs[id] {
+		select { case ch <- struct{}{}: default: }
+	}
+}
+
+// Subscribe devolve um canal sinalizado a cada novo evento do deploy id.
+// A função retornada cancela a inscrição.
+func (o *Orchestrator) Subscribe(id string) (<-chan struct{}, func()) { return o.events.subscribe(id) }
+
+// emit grava o evento na timeline do deploy e acorda os watchers.
+func (o *Orchestrator) emit(id, typ, step, msg string, data map[string]string) {
+	if _, err := o.db.AppendEvent(id, store.Event{Type: typ, Step: step, Message: msg, Data: data}); err != nil {
+		o.log.Error().Err(err).Str("deploy", id).Msg("append event")
+		return
+	}
+	o.log.Info().Str("deploy", id).Str("type", typ).Str("step", step).Msg(msg)
+	o.events.notify(id)
+	o.publish(id, typ, step, msg, data)
+}
+
+// publish traduz eventos da timeline para o ciclo de vida enviado aos notifiers.
+func (o *Orchestrator) publish(id, typ, step, msg string, data map[string]string) {
+	kind := ""
+	switch {
+	case typ == "status" && data["status"] == "started": kind = notify.Started
+	case typ == "status" && data["status"] == "waiting_approval": kind = notify.WaitingApproval
+	case typ == "status" && data["status"] == "succeeded": kind = notify.Succeeded
+	case typ == "status" && data["status"] == "rolled_back": kind = notify.RolledBack
+	case typ == "step_finished": kind = notify.Step
+	}
+	if kind == "" { return }
+	rec, _ := o.db.Get(id)
+	if rec == nil { return }
+	o.dispatch.Publish(notify.Event{Type: kind, Step: step, Message: msg, Deploy: *rec})
+}
+
+// setStatus persiste o novo status do deploy e registra o evento correspondente.
+func (o *Orchestrator) setStatus(rec *store.DeployRecord, status, reason string) {
+	rec.Status = status
+	rec.Reason = reason
+	_ = o.db.Put(*rec)
+	data := map[string]string{"status": status}
+	if reason != "" { data["reason"] = reason }
+	o.emit(rec.ID, "status", "", "deploy "+status, data)
+}
@@ -0,0 +1,29 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// runHooks executa, em ordem, os hooks do app para a fase; para no primeiro
+// que falhar (*hooks.Error). cluster só vem preenchido nos postStep de
+// deploys com waves, e os Jobs desses hooks rodam nele.
+func (o *Orchestrator) runHooks(ctx context.Context, rec store.DeployRecord, phase, cluster, step string) error {
+	tc, _ := o.cfg.TargetFor(rec.Namespace, rec.App)
+	step, plain := clusterStep(cluster, step), step
+	for _, h := range tc.Hooks {
+		if h.Phase != phase { continue }
+		v := hooks.Vars{DeployID: rec.ID, Namespace: rec.Namespace, App: rec.App, Image: rec.ImageNew, Phase: phase, Step: plain, RollbackOf: rec.RollbackOf, Cluster: cluster}
+		o.emit(rec.ID, "hook", step, "hook "+h.Name+" started ("+phase+")", map[string]string{"hook": h.Name, "phase": phase})
+		data, err := o.clusters[cluster].hooks.Run(ctx, h, v)
+		if err != nil {
+			data["error"] = err.Error()
+			o.emit(rec.ID, "hook", step, "hook "+h.Name+" failed", data)
+			return err
+		}
+		o.emit(rec.ID, "hook", step, "hook "+h.Name+" passed", data)
+	}
+	return nil
+}
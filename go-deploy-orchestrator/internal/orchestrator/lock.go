@@ -0,0 +1,190 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// ErrLockLost é a causa do cancelamento de um deploy que não conseguiu renovar o lock.
+var ErrLockLost = errors.New("deploy lock lost")
+
+// Locker serializa deploys por (namespace, app). O store atende uma réplica;
+// k8s.LeaseLocker atende várias réplicas do orquestrador.
+type Locker interface {
+	// Acquire devolve (holder atual, false) quando outro deploy segura o lock.
+	Acquire(ctx context.Context, ns, app, holder string, ttl time.Duration) (string, bool, error)
+	Renew(ctx context.Context, ns, app, holder string, ttl time.Duration) error
+	Release(ctx context.Context, ns, app, holder string) error
+	// Holder devolve o deploy que segura o lock agora ("" = livre ou expirado).
+	Holder(ctx context.Context, ns, app string) (string, error)
+}
+
+// LockedError indica que já existe deploy ativo para o app.
+type LockedError struct {
+	Namespace, App, ActiveID string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("deploy %s already in progress for %s/%s", e.ActiveID, e.Namespace, e.App)
+}
+
+type storeLocker struct{ db *store.Store }
+
+func (l storeLocker) Acquire(_ context.Context, ns, app, holder string, ttl time.Duration) (string, bool, error) {
+	return l.db.AcquireLock(ns, app, holder, ttl)
+}
+func (l storeLocker) Renew(_ context.Context, ns, app, holder string, ttl time.Duration) error {
+	return l.db.RenewLock(ns, app, holder, ttl)
+}
+func (l storeLocker) Release(_ context.Context, ns, app, holder string) error {
+	return l.db.ReleaseLock(ns, app, holder)
+}
+func (l storeLocker) Holder(_ context.Context, ns, app string) (string, error) {
+	return l.db.LockHolder(ns, app)
+}
+
+// runs guarda o cancel de cada deploy em andamento nesta réplica.
+type runs struct {
+	mu sync.Mutex
+	m  map[string]context.CancelCauseFunc
+}
+
+func (r *runs) add(id string, cancel context.CancelCauseFunc) {
+	r.mu.Lock(); defer r.mu.Unlock()
+	if r.m == nil { r.m = map[string]context.CancelCauseFunc{} }
+	r.m[id] = cancel
+}
+
+func (r *runs) done(id string) {
+	r.mu.Lock(); defer r.mu.Unlock()
+	if cancel := r.m[id]; cancel != nil { cancel(nil) }
+	delete(r.m, id)
+}
+
+func (r *runs) cancel(id string, cause error) bool {
+	r.mu.Lock(); defer r.mu.Unlock()
+	cancel := r.m[id]
+	if cancel == nil { return false }
+	cancel(cause)
+	return true
+}
+
+// deployQueue mantém a ordem FIFO dos deploys com queue=true por app nesta réplica.
+type deployQueue struct {
+	mu sync.Mutex
+	q  map[string][]string
+}
+
+func (q *deployQueue) push(key, id string) {
+	q.mu.Lock(); defer q.mu.Unlock()
+	if q.q == nil { q.q = map[string][]string{} }
+	q.q[key] = append(q.q[key], id)
+}
+
+func (q *deployQueue) head(key string) string {
+	q.mu.Lock(); defer q.mu.Unlock()
+	if len(q.q[key]) == 0 { return "" }
+	return q.q[key][0]
+}
+
+func (q *deployQueue) remove(key, id string) {
+	q.mu.Lock(); defer q.mu.Unlock()
+	arr := q.q[key]
+	for i := range arr {
+		if arr[i] == id { arr = append(arr[:i], arr[i+1:]...); break }
+	}
+	if len(arr) == 0 { delete(q.q, key) } else { q.q[key] = arr }
+}
+
+func lockID(ns, app string) string { return ns + "/" + app }
+
+// waitLock bloqueia um deploy enfileirado até ele ser o primeiro da fila e obter o lock.
+func (o *Orchestrator) waitLock(ctx context.Context, rec *store.DeployRecord) bool {
+	key := lockID(rec.Namespace, rec.App)
+	defer o.queue.remove(key, rec.ID)
+	for {
+		if o.queue.head(key) == rec.ID {
+			_, ok, err := o.locks.Acquire(ctx, rec.Namespace, rec.App, rec.ID, o.cfg.Locks.TTL)
+			if err != nil { o.log.Error().Err(err).Str("deploy", rec.ID).Msg("acquire deploy lock") }
+			if ok {
+				o.emit(rec.ID, "lock", "", "deploy lock acquired", nil)
+				o.setStatus(rec, "started", "")
+				return true
+			}
+		}
+		select {
+		case <-ctx.Done(): return false
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// holdLock renova o lock enquanto o deploy roda; a função retornada para a
+// renovação e libera o lock.
+func (o *Orchestrator) holdLock(ctx context.Context, rec store.DeployRecord) func() {
+	ttl := o.cfg.Locks.TTL
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		t := time.NewTicker(ttl / 3)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop: return
+			case <-ctx.Done(): return
+			case <-t.C:
+				if err := o.locks.Renew(ctx, rec.Namespace, rec.App, rec.ID, ttl); err != nil {
+					// sem o lock outro deploy pode assumir o app: a estratégia para e faz rollback
+					o.emit(rec.ID, "lock", "", "deploy lock renew failed: "+err.Error(), nil)
+					o.runs.cancel(rec.ID, fmt.Errorf("%w: %v", ErrLockLost, err))
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-done
+		if err := o.locks.Release(context.Background(), rec.Namespace, rec.App, rec.ID); err != nil {
+			o.log.Error().Err(err).Str("deploy", rec.ID).Msg("release deploy lock")
+		}
+	}
+}
+
+// finishLockLost encerra como failed um deploy que perdeu o lock antes de tocar no cluster.
+func (o *Orchestrator) finishLockLost(rec *store.DeployRecord, cause error) {
+	now := time.Now()
+	rec.FinishedAt = &now
+	metrics.DeploysFailed.WithLabelValues(rec.App, rec.Strategy, "lock_lost").Inc()
+	o.setStatus(rec, "failed", cause.Error())
+}
+
+// Resume refaz a fila com os deploys que ficaram queued no store quando a
+// réplica parou, na ordem em que foram pedidos. Deve rodar antes da API
+// aceitar deploys novos, que senão passariam à frente da fila.
+func (o *Orchestrator) Resume(ctx context.Context) error {
+	recs, err := o.db.List()
+	if err != nil { return err }
+	var queued []store.DeployRecord
+	for _, r := range recs {
+		if r.Status == "queued" { queued = append(queued, r) }
+	}
+	sort.Slice(queued, func(i, j int) bool { return queued[i].StartedAt.Before(queued[j].StartedAt) })
+	for _, rec := range queued {
+		o.queue.push(lockID(rec.Namespace, rec.App), rec.ID)
+		o.emit(rec.ID, "status", "", "deploy requeued after restart", nil)
+		rctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+		o.runs.add(rec.ID, cancel)
+		go o.run(rctx, rec, rec.RequireApproval, true)
+	}
+	if len(queued) > 0 { o.log.Info().Int("count", len(queued)).Msg("queued deploys resumed") }
+	return nil
+}
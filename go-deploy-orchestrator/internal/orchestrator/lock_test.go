@@ -0,0 +1,120 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/policy"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// newLockOrchestrator monta um Orchestrator sem cluster: os testes daqui só
+// usam deploys que ficam na fila.
+func newLockOrchestrator(t *testing.T) *Orchestrator {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "deploys.db"))
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { _ = db.Close() })
+	cfg := &config.Config{}
+	cfg.Locks.TTL = time.Minute
+	pol, err := policy.New(config.Policy{})
+	if err != nil { t.Fatal(err) }
+	return &Orchestrator{log: logger.New("error"), cfg: cfg, db: db, events: newNotifier(), locks: storeLocker{db}, policy: pol}
+}
+
+// waitStatus espera o deploy chegar ao status (os deploys rodam em goroutine).
+func waitStatus(t *testing.T, o *Orchestrator, id, status string) store.DeployRecord {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rec, err := o.db.Get(id)
+		if err != nil { t.Fatal(err) }
+		if rec != nil && rec.Status == status { return *rec }
+		if time.Now().After(deadline) { t.Fatalf("%s: status = %+v, want %s", id, rec, status) }
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDeployQueue(t *testing.T) {
+	var q deployQueue
+	q.push("prod/web", "a")
+	q.push("prod/web", "b")
+	q.push("prod/api", "c")
+	if h := q.head("prod/web"); h != "a" { t.Fatalf("head = %q", h) }
+	q.remove("prod/web", "b")
+	q.remove("prod/web", "a")
+	if h := q.head("prod/web"); h != "" { t.Fatalf("fila vazia, head = %q", h) }
+	if h := q.head("prod/api"); h != "c" { t.Fatalf("head de outro app = %q", h) }
+}
+
+func TestStartDeployQueue(t *testing.T) {
+	o := newLockOrchestrator(t)
+	ctx := context.Background()
+	if _, ok, err := o.locks.Acquire(ctx, "prod", "web", "d1", o.cfg.Locks.TTL); err != nil || !ok { t.Fatalf("lock: %v", err) }
+	in := DeployInput{App: "web", Namespace: "prod", Image: "repo/web:2", Strategy: "fast"}
+
+	var le *LockedError
+	if _, err := o.StartDeploy(ctx, in); !errors.As(err, &le) || le.ActiveID != "d1" { t.Fatalf("sem fila: %v", err) }
+
+	in.Queue = true
+	q, err := o.StartDeploy(ctx, in)
+	if err != nil || q.Status != "queued" { t.Fatalf("enfileirado: %+v %v", q, err) }
+	if h := o.queue.head("prod/web"); h != q.ID { t.Fatalf("head = %q", h) }
+
+	// com fila, o LockedError aponta quem segura o lock, não o primeiro da fila
+	in.Queue = false
+	if _, err := o.StartDeploy(ctx, in); !errors.As(err, &le) || le.ActiveID != "d1" { t.Fatalf("com fila: %v", err) }
+
+	if err := o.Abort(q.ID, "alice"); err != nil { t.Fatal(err) }
+	waitStatus(t, o, q.ID, "aborted")
+	if h := o.queue.head("prod/web"); h != "" { t.Fatalf("abortado continua na fila: %q", h) }
+}
+
+func TestResume(t *testing.T) {
+	o := newLockOrchestrator(t)
+	ctx := context.Background()
+	if _, ok, err := o.locks.Acquire(ctx, "prod", "web", "d1", o.cfg.Locks.TTL); err != nil || !ok { t.Fatalf("lock: %v", err) }
+	now := time.Now()
+	for i, id := range []string{"q2", "q1", "done"} {
+		rec := store.DeployRecord{ID: id, App: "web", Namespace: "prod", ImageNew: "repo/web:2", Strategy: "fast", Status: "queued", StartedAt: now.Add(-time.Duration(i) * time.Minute)}
+		if id == "done" { rec.Status = "succeeded" }
+		if err := o.db.Put(rec); err != nil { t.Fatal(err) }
+	}
+	if err := o.Resume(ctx); err != nil { t.Fatal(err) }
+	if h := o.queue.head("prod/web"); h != "q1" { t.Fatalf("head = %q, want o mais antigo", h) }
+	var le *LockedError
+	if _, err := o.StartDeploy(ctx, DeployInput{App: "web", Namespace: "prod", Image: "repo/web:3", Strategy: "fast"}); !errors.As(err, &le) { t.Fatalf("deploy novo furou a fila: %v", err) }
+
+	for _, id := range []string{"q1", "q2"} {
+		if err := o.Abort(id, "alice"); err != nil { t.Fatalf("%s: %v", id, err) }
+		waitStatus(t, o, id, "aborted")
+	}
+}
+
+func TestHoldLockLost(t *testing.T) {
+	o := newLockOrchestrator(t)
+	o.cfg.Locks.TTL = 60 * time.Millisecond
+	ctx := context.Background()
+	rec := store.DeployRecord{ID: "d1", App: "web", Namespace: "prod", Strategy: "fast"}
+	if _, ok, err := o.locks.Acquire(ctx, "prod", "web", rec.ID, time.Minute); err != nil || !ok { t.Fatalf("lock: %v", err) }
+	rctx, cancel := context.WithCancelCause(ctx)
+	o.runs.add(rec.ID, cancel)
+	defer o.runs.done(rec.ID)
+	release := o.holdLock(rctx, rec)
+	defer release()
+
+	// outra réplica assume o lock; a renovação seguinte cancela o deploy
+	if err := o.locks.Release(ctx, "prod", "web", rec.ID); err != nil { t.Fatal(err) }
+	if _, ok, err := o.locks.Acquire(ctx, "prod", "web", "d2", time.Minute); err != nil || !ok { t.Fatalf("lock: %v", err) }
+	select {
+	case <-rctx.Done():
+	case <-time.After(2 * time.Second): t.Fatal("deploy não foi cancelado")
+	}
+	if err := context.Cause(rctx); !errors.Is(err, ErrLockLost) { t.Fatalf("cause = %v", err) }
+	if h, _ := o.locks.Holder(ctx, "prod", "web"); h != "d2" { t.Fatalf("holder = %q", h) }
+}
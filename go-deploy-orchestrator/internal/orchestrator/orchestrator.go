@@ -4,32 +4,64 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/notify"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/policy"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/strategies"
-	"k8s.io/client-go/kubernetes"
 )
 
 type Orchestrator struct {
 	log  *logger.Logger
 	cfg  *config.Config
 	db   *store.Store
-	prom *prometheus.Evaluator
-	kcs  *kubernetes.Clientset
+	clusters map[string]*cluster
+	events *notifier
+	locks  Locker
+	queue  deployQueue
+	runs   runs
+	policy *policy.Evaluator
+	dispatch *notify.Dispatcher
 }
 
 func New(log *logger.Logger, cfg *config.Config, db *store.Store, prom *prometheus.Evaluator) (*Orchestrator, error) {
-	cs, err := k8s.NewClient(cfg.Kube.Kubeconfig, cfg.Kube.Context)
+	clusters, err := newClusters(cfg)
 	if err != nil { return nil, err }
-	return &Orchestrator{log: log, cfg: cfg, db: db, prom: prom, kcs: cs}, nil
+	cs := clusters[""].cs
+	if err := validateTargets(cfg); err != nil { return nil, err }
+	for _, cl := range clusters {
+		if err := cl.bind(cfg.Analysis.Providers, prom); err != nil { return nil, err }
+	}
+	if err := clusters[""].analysis.Validate(cfg.Analysis.Templates); err != nil { return nil, err }
+	var locks Locker = storeLocker{db}
+	switch cfg.Locks.Backend {
+	case "", "store":
+	case "lease": locks = k8s.NewLeaseLocker(cs, cfg.Locks.LeaseNamespace)
+	default: return nil, fmt.Errorf("unknown locks backend %q", cfg.Locks.Backend)
+	}
+	pol, err := policy.New(cfg.Policy)
+	if err != nil { return nil, err }
+	nd, err := notify.New(log, cfg.Notifications)
+	if err != nil { return nil, err }
+	return &Orchestrator{log: log, cfg: cfg, db: db, clusters: clusters, events: newNotifier(), locks: locks, policy: pol, dispatch: nd}, nil
+}
+
+// Run mantém os workers de fundo (notificações, gauges DORA) até o ctx ser cancelado.
+func (o *Orchestrator) Run(ctx context.Context) {
+	go o.refreshStats(ctx)
+	o.dispatch.Run(ctx)
 }
 
 type DeployInput struct {
@@ -39,104 +71,322 @@ type DeployInput struct {
 	Strategy  string
 	Params    map[string]string
 	RequireApproval bool
+	Queue     bool // se o app já tem deploy ativo, enfileira em vez de falhar com LockedError
+	Actor     string // identidade de quem pediu o deploy
+	RollbackOf string // ver RollbackTo
+	Waves      []store.Wave // deploy multi-cluster; vazio = waves do target (se houver)
 }
 
 func (o *Orchestrator) StartDeploy(ctx context.Context, in DeployInput) (*store.DeployRecord, error) {
+	if in.Namespace == "" { in.Namespace = "default" }
+
+	// policy é avaliada antes de qualquer lock ou chamada ao cluster
+	overrides, err := o.db.ListOverrides(true)
+	if err != nil { return nil, err }
+	dec := o.policy.Evaluate(policy.Input{Namespace: in.Namespace, App: in.App, Image: in.Image}, time.Now(), overrides)
+	if !dec.Allowed {
+		metrics.DeploysFailed.WithLabelValues(in.App, in.Strategy, "policy").Inc()
+		o.log.Warn().Str("app", in.App).Str("ns", in.Namespace).Str("rule", dec.Rule).Msg(dec.Reason)
+		return nil, &policy.Error{Decision: dec}
+	}
+	if dec.RequireApproval { in.RequireApproval = true }
+	waves, err := o.resolveWaves(in.Namespace, in.App, in.Waves)
+	if err != nil { return nil, err }
+
 	id := randID()
+	key := lockID(in.Namespace, in.App)
+
+	// deploys já enfileirados têm prioridade sobre o lock recém-liberado
+	var active string
+	ok := false
+	if head := o.queue.head(key); head != "" {
+		active, err = o.locks.Holder(ctx, in.Namespace, in.App)
+		if err != nil { return nil, fmt.Errorf("read deploy lock: %w", err) }
+		if active == "" { active = head } // lock livre: o primeiro da fila assume em seguida
+	} else {
+		active, ok, err = o.locks.Acquire(ctx, in.Namespace, in.App, id, o.cfg.Locks.TTL)
+		if err != nil { return nil, fmt.Errorf("acquire deploy lock: %w", err) }
+	}
+	if !ok && !in.Queue { return nil, &LockedError{Namespace: in.Namespace, App: in.App, ActiveID: active} }
+
+	status := "started"
+	if !ok { status = "queued" }
 	rec := store.DeployRecord{
 		ID: id, App: in.App, Namespace: in.Namespace, ImageNew: in.Image, Strategy: in.Strategy,
-		Status: "started", StartedAt: time.Now(), Params: in.Params,
+		Status: status, StartedAt: time.Now(), Params: in.Params, RequestedBy: in.Actor, RollbackOf: in.RollbackOf, Waves: waves,
+		RequireApproval: in.RequireApproval,
+	}
+	if err := o.db.Put(rec); err != nil {
+		if ok { _ = o.locks.Release(ctx, in.Namespace, in.App, id) }
+		return nil, err
+	}
+	data := map[string]string{"status": rec.Status, "image": rec.ImageNew, "strategy": rec.Strategy, "requestedBy": rec.RequestedBy}
+	if !ok { data["behind"] = active }
+	if in.RollbackOf != "" { data["rollbackOf"] = in.RollbackOf }
+	if len(waves) > 0 {
+		var names []string
+		for _, w := range waves { names = append(names, w.Name) }
+		data["waves"] = strings.Join(names, " -> ")
 	}
-	if err := o.db.Put(rec); err != nil { return nil, err }
+	o.emit(rec.ID, "status", "", "deploy "+status, data)
+	if dec.RequireApproval {
+		o.emit(rec.ID, "policy", "", dec.Reason, map[string]string{"rule": dec.Rule})
+	}
+	if dec.Override != "" {
+		o.emit(rec.ID, "policy", "", "policy override applied", map[string]string{"override": dec.Override})
+	}
+	if !ok { o.queue.push(key, id) }
 
 	metrics.DeploysStarted.WithLabelValues(in.App, in.Strategy).Inc()
 
-	go o.run(ctx, rec, in.RequireApproval)
+	// o deploy continua depois que a requisição HTTP termina; Abort e a perda do lock cancelam rctx
+	rctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	o.runs.add(id, cancel)
+	go o.run(rctx, rec, in.RequireApproval, !ok)
 	return &rec, nil
 }
 
-func (o *Orchestrator) run(ctx context.Context, rec store.DeployRecord, requireApproval bool) {
-	ns := rec.Namespace
-	if ns == "" { ns = "default" }
-
-	deployIf := o.kcs.AppsV1().Deployments(ns)
-	dep := k8s.NewDeployer(deployIf)
-
-	// snapshot do image atual
-	orig, err := dep.Get(ctx, rec.App)
-	if err == nil && len(orig.Spec.Template.Spec.Containers) > 0 {
-		rec.ImageOld = orig.Spec.Template.Spec.Containers[0].Image
-		_ = o.db.Put(rec)
+func (o *Orchestrator) run(ctx context.Context, rec store.DeployRecord, requireApproval, queued bool) {
+	defer o.runs.done(rec.ID)
+	if queued && !o.waitLock(ctx, &rec) {
+		actor, _ := abortedBy(ctx)
+		o.finishAborted(&rec, actor)
+		return
+	}
+	// o lock segue renovado durante o rollback de um deploy abortado
+	defer o.holdLock(context.WithoutCancel(ctx), rec)()
+	if len(rec.Waves) > 0 {
+		o.runWaves(ctx, rec, requireApproval)
+		return
+	}
+	t, err := o.targetFor(rec, "")
+	if err != nil {
+		now := time.Now()
+		rec.FinishedAt = &now
+		metrics.DeploysFailed.WithLabelValues(rec.App, rec.Strategy, "target").Inc()
+		o.setStatus(&rec, "failed", err.Error())
+		return
 	}
 
-	if requireApproval {
-		rec.Status = "waiting_approval"
+	// snapshot do estado atual (imagem/revision/template) para o rollback
+	start, err := t.Snapshot(ctx)
+	if err == nil {
+		rec.ImageOld, rec.RevisionOld = start.Image, start.Revision
 		_ = o.db.Put(rec)
-		// aguarda sinal externo via API /approve (para simplificar, só muda status)
-		for {
-			time.Sleep(1 * time.Second)
-			cur, _ := o.db.Get(rec.ID)
-			if cur != nil && cur.Status == "running" { break }
-			select { case <-ctx.Done(): return default: }
-		}
+	} else {
+		o.log.Warn().Err(err).Str("deploy", rec.ID).Msg("target snapshot")
 	}
+	if !o.awaitApproval(ctx, &rec, requireApproval) { return }
 
-	// executa a estratégia
-	err = o.applyStrategy(ctx, dep, rec)
+	// executa a estratégia entre os hooks preDeploy e postPromote
+	err = o.runHooks(ctx, rec, hooks.PreDeploy, "", "")
+	if err == nil { err = o.applyStrategy(ctx, t, rec, "") }
+	if err == nil { err = o.runHooks(ctx, rec, hooks.PostPromote, "", "") }
 	if err != nil {
-		metrics.DeploysFailed.WithLabelValues(rec.App, rec.Strategy, "strategy_error").Inc()
-		o.rollback(ctx, dep, rec, err)
+		err = o.failure(ctx, rec, err)
+		o.rollback(context.WithoutCancel(ctx), t, rec, start, err)
 		return
 	}
 
+	// o estado final vira ponto de retorno para rollbacks manuais
+	rec.Revision = o.saveSnapshot(ctx, t, rec.ID, "")
 	now := time.Now()
-	rec.Status = "succeeded"
 	rec.FinishedAt = &now
-	_ = o.db.Put(rec)
+	o.setStatus(&rec, "succeeded", "")
 	metrics.DeploysSucceeded.WithLabelValues(rec.App, rec.Strategy).Inc()
 }
 
-func (o *Orchestrator) applyStrategy(ctx context.Context, dep *k8s.Deployer, rec store.DeployRecord) error {
+// targetFor monta o target do app no cluster; num rollback manual ele
+// restaura o snapshot do deploy de origem.
+func (o *Orchestrator) targetFor(rec store.DeployRecord, cluster string) (k8s.Target, error) {
+	t, err := o.target(cluster, rec.Namespace, rec.App)
+	if err != nil || rec.RollbackOf == "" { return t, err }
+	snap, err := o.restoreSnapshot(rec.RollbackOf, cluster)
+	if err != nil { return nil, err }
+	return k8s.Restore(t, snap), nil
+}
+
+// awaitApproval deixa o deploy running, esperando antes a aprovação se
+// necessário; false = o deploy foi abortado enquanto esperava.
+func (o *Orchestrator) awaitApproval(ctx context.Context, rec *store.DeployRecord, requireApproval bool) bool {
+	if !requireApproval {
+		o.setStatus(rec, "running", "")
+		return true
+	}
+	o.setStatus(rec, "waiting_approval", "")
+	// aguarda sinal externo via API /approve (para simplificar, só muda status)
+	for {
+		select {
+		case <-ctx.Done():
+			if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) {
+				o.finishLockLost(rec, cause)
+				return false
+			}
+			actor, _ := abortedBy(ctx)
+			o.finishAborted(rec, actor)
+			return false
+		case <-time.After(1 * time.Second):
+		}
+		cur, _ := o.db.Get(rec.ID)
+		if cur != nil && cur.Status == "aborted" { return false }
+		if cur != nil && cur.Status == "running" {
+			rec.Status, rec.ApprovedBy = "running", cur.ApprovedBy
+			return true
+		}
+	}
+}
+
+// failure conta a falha na métrica com o motivo (hook_failed, aborted ou
+// strategy_error) e devolve o erro que vai para o rollback.
+func (o *Orchestrator) failure(ctx context.Context, rec store.DeployRecord, err error) error {
+	reason := "strategy_error"
+	var he *hooks.Error
+	if errors.As(err, &he) { reason = "hook_failed" }
+	if actor, ok := abortedBy(ctx); ok {
+		// a estratégia falha com o erro do cliente k8s; o motivo real é o abort
+		reason, err = "aborted", &AbortedError{Actor: actor}
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrLockLost) { reason, err = "lock_lost", cause }
+	metrics.DeploysFailed.WithLabelValues(rec.App, rec.Strategy, reason).Inc()
+	return err
+}
+
+// applyStrategy roda a estratégia do deploy em t; em deploys com waves, os
+// steps da timeline levam o cluster como prefixo.
+func (o *Orchestrator) applyStrategy(ctx context.Context, t k8s.Target, rec store.DeployRecord, cluster string) error {
+	ev := strategies.Emitter(func(typ, step, msg string, data map[string]string) {
+		if cluster != "" {
+			if data == nil { data = map[string]string{} }
+			data["cluster"] = cluster
+		}
+		o.emit(rec.ID, typ, clusterStep(cluster, step), msg, data)
+	})
+	an := o.analysisFor(rec, cluster, "")
+	after := strategies.StepHook(func(ctx context.Context, step string) error { return o.runHooks(ctx, rec, hooks.PostStep, cluster, step) })
 	switch rec.Strategy {
 	case "canary":
-		step, _ := atoi(rec.Params["canaryStep"])
-		if step == 0 { step = o.cfg.Defaults.CanaryStepPercent }
-		pause, _ := atoi(rec.Params["canaryPause"])
-		if pause == 0 { pause = o.cfg.Defaults.CanaryPauseSec }
-		maxError, _ := atof(rec.Params["maxError"])
-		if maxError == 0 { maxError = o.cfg.Prometheus.Thresholds.MaxError }
-		maxP95, _ := atof(rec.Params["maxP95"])
-		if maxP95 == 0 { maxP95 = o.cfg.Prometheus.Thresholds.MaxP95 }
-		return strategies.RunCanary(ctx, dep, o.prom, rec.App, rec.ImageNew, strategies.CanaryParams{
-			StepPercent: step, PauseSec: pause, MaxError: maxError, MaxP95: maxP95,
-		})
+		switch tt := t.(type) {
+		case k8s.Partitioned:
+			return strategies.RunPartitionedCanary(ctx, tt, an, rec.App, rec.ImageNew, o.canaryParams(rec.Params), ev, after)
+		case k8s.NodeBatched:
+			return strategies.RunNodeBatches(ctx, tt, an, rec.App, rec.ImageNew, o.nodeBatchParams(rec.Namespace, rec.App, rec.Params), ev, after)
+		}
+		return strategies.RunCanary(ctx, t, an, rec.App, rec.ImageNew, o.canaryParams(rec.Params), ev, after)
 	case "bluegreen":
-		wait, _ := atoi(rec.Params["probeWait"])
-		maxError, _ := atof(rec.Params["maxError"])
-		if maxError == 0 { maxError = o.cfg.Prometheus.Thresholds.MaxError }
-		maxP95, _ := atof(rec.Params["maxP95"])
-		if maxP95 == 0 { maxP95 = o.cfg.Prometheus.Thresholds.MaxP95 }
-		return strategies.RunBlueGreen(ctx, dep, o.prom, rec.App, rec.ImageNew, strategies.BlueGreenParams{
-			ProbeWaitSec: wait, MaxError: maxError, MaxP95: maxP95,
-		})
+		return strategies.RunBlueGreen(ctx, t, an, rec.App, rec.ImageNew, o.blueGreenParams(rec.Params), ev, after)
+	case "fast":
+		return strategies.RunFast(ctx, t, an, rec.App, rec.ImageNew, o.fastParams(rec.Params), ev, after)
 	default:
 		return fmt.Errorf("unknown strategy %q", rec.Strategy)
 	}
 }
 
-func (o *Orchestrator) rollback(ctx context.Context, dep *k8s.Deployer, rec store.DeployRecord, err error) {
-	o.log.Error().Err(err).Str("app", rec.App).Msg("deploy failed, rolling back")
-	if rec.ImageOld != "" {
-		_ = dep.SetImage(ctx, rec.App, rec.App, rec.ImageOld)
-		_ = dep.WaitRollout(ctx, rec.App, 5*time.Minute)
+// analysisFor prepara a análise do deploy no cluster com o template
+// informado ou, vazio, o do target (se houver).
+func (o *Orchestrator) analysisFor(rec store.DeployRecord, cluster, template string) *analysis.Analysis {
+	if template == "" {
+		tc, _ := o.cfg.TargetFor(rec.Namespace, rec.App)
+		template = tc.Analysis
 	}
+	tpl, _ := o.cfg.Analysis.Template(template)
+	return o.clusters[cluster].analysis.For(tpl.Metrics, hooks.Vars{DeployID: rec.ID, Namespace: rec.Namespace, App: rec.App, Image: rec.ImageNew, RollbackOf: rec.RollbackOf, Cluster: cluster})
+}
+
+// canaryParams resolve os parâmetros do canary a partir de Params e Defaults.
+func (o *Orchestrator) canaryParams(params map[string]string) strategies.CanaryParams {
+	step, _ := atoi(params["canaryStep"])
+	if step == 0 { step = o.cfg.Defaults.CanaryStepPercent }
+	pause, _ := atoi(params["canaryPause"])
+	if pause == 0 { pause = o.cfg.Defaults.CanaryPauseSec }
+	maxError, maxP95 := o.thresholds(params)
+	return strategies.CanaryParams{StepPercent: step, PauseSec: pause, MaxError: maxError, MaxP95: maxP95}
+}
+
+func (o *Orchestrator) blueGreenParams(params map[string]string) strategies.BlueGreenParams {
+	wait, _ := atoi(params["probeWait"])
+	maxError, maxP95 := o.thresholds(params)
+	return strategies.BlueGreenParams{ProbeWaitSec: wait, MaxError: maxError, MaxP95: maxP95}
+}
+
+func (o *Orchestrator) fastParams(params map[string]string) strategies.FastParams {
+	wait, _ := atoi(params["probeWait"])
+	maxError, maxP95 := o.thresholds(params)
+	return strategies.FastParams{ProbeWaitSec: wait, MaxError: maxError, MaxP95: maxP95}
+}
+
+// nodeBatchParams usa nodeLabel/maxUnavailable dos params ou, na falta, do target.
+func (o *Orchestrator) nodeBatchParams(ns, app string, params map[string]string) strategies.NodeBatchParams {
+	tc, _ := o.cfg.TargetFor(ns, app)
+	p := strategies.NodeBatchParams{Label: params["nodeLabel"], MaxUnavailable: params["maxUnavailable"]}
+	if p.Label == "" { p.Label = tc.NodeLabel }
+	if p.MaxUnavailable == "" { p.MaxUnavailable = tc.MaxUnavailable }
+	p.PauseSec, _ = atoi(params["canaryPause"])
+	if p.PauseSec == 0 { p.PauseSec = o.cfg.Defaults.CanaryPauseSec }
+	p.MaxError, p.MaxP95 = o.thresholds(params)
+	return p
+}
+
+func (o *Orchestrator) thresholds(params map[string]string) (float64, float64) {
+	maxError, _ := atof(params["maxError"])
+	if maxError == 0 { maxError = o.cfg.Prometheus.Thresholds.MaxError }
+	maxP95, _ := atof(params["maxP95"])
+	if maxP95 == 0 { maxP95 = o.cfg.Prometheus.Thresholds.MaxP95 }
+	return maxError, maxP95
+}
+
+// rollback volta o target ao snapshot tirado no início do deploy.
+func (o *Orchestrator) rollback(ctx context.Context, t k8s.Target, rec store.DeployRecord, to k8s.Snapshot, err error) {
+	o.log.Error().Err(err).Str("app", rec.App).Msg("deploy failed, rolling back")
+	o.emit(rec.ID, "rollback", "", "rollback started: "+err.Error(), map[string]string{"imageOld": rec.ImageOld, "revisionOld": rec.RevisionOld})
+	_ = o.restore(ctx, rec.ID, "", t, to)
 	now := time.Now()
-	rec.Status = "rolled_back"
-	rec.Reason = err.Error()
 	rec.FinishedAt = &now
-	_ = o.db.Put(rec)
+	o.setStatus(&rec, "rolled_back", err.Error())
 }
 
+// restore aplica o snapshot to em t e registra o resultado na timeline (step
+// = cluster nos deploys com waves). Sem snapshot não há o que restaurar.
+func (o *Orchestrator) restore(ctx context.Context, id, cluster string, t k8s.Target, to k8s.Snapshot) error {
+	if to.Image == "" && to.Revision == "" && to.Template == nil { return nil }
+	var data map[string]string
+	if cluster != "" { data = map[string]string{"cluster": cluster} }
+	if e := t.Rollback(ctx, to); e != nil {
+		o.emit(id, "rollback", cluster, "rollback set image failed: "+e.Error(), data)
+		return e
+	}
+	if e := t.WaitRollout(ctx, 5*time.Minute); e != nil {
+		o.emit(id, "rollback", cluster, "rollback rollout failed: "+e.Error(), data)
+		return e
+	}
+	done := map[string]string{"image": to.Image}
+	if cluster != "" { done["cluster"] = cluster }
+	o.emit(id, "rollback", cluster, "rolled back to "+to.Image, done)
+	return nil
+}
+
+// Approve libera um deploy em waiting_approval; o loop de run() percebe a mudança de status.
+// Quem pediu o deploy não pode aprová-lo, seja qual for a identidade
+// (anônimo e o authToken do modo legado inclusive).
+func (o *Orchestrator) Approve(id, actor string) error {
+	rec, err := o.db.Get(id)
+	if err != nil { return err }
+	if rec == nil { return ErrNotFound }
+	if rec.Status != "waiting_approval" { return ErrNotWaitingApproval }
+	if actor == rec.RequestedBy { return ErrSelfApproval }
+	o.emit(id, "approval", "", "deploy approved by "+actor, map[string]string{"approvedBy": actor})
+	rec.Status = "running"
+	rec.ApprovedBy = actor
+	if err := o.db.Put(*rec); err != nil { return err }
+	o.emit(id, "status", "", "deploy running", map[string]string{"status": "running"})
+	return nil
+}
+
+var (
+	ErrNotFound           = errors.New("not found")
+	ErrNotWaitingApproval = errors.New("not waiting approval")
+	ErrSelfApproval       = errors.New("approval must come from a different identity than the requester")
+)
+
 func randID() string {
 	b := make([]byte, 6)
 	_, _ = rand.Read(b)
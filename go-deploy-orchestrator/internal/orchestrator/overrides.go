@@ -0,0 +1,43 @@
+package orchestrator
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/policy"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// OverrideInput cria uma liberação temporária das regras de policy.
+type OverrideInput struct {
+	Namespace string
+	App       string
+	Rules     []string
+	Reason    string
+	CreatedBy string
+	Duration  time.Duration
+}
+
+func (o *Orchestrator) CreateOverride(in OverrideInput) (*store.Override, error) {
+	if in.Reason == "" { return nil, fmt.Errorf("reason is required") }
+	if in.Duration <= 0 { return nil, fmt.Errorf("duration must be positive") }
+	if in.Duration > o.cfg.Policy.MaxOverride {
+		return nil, fmt.Errorf("duration exceeds policy.maxOverride (%s)", o.cfg.Policy.MaxOverride)
+	}
+	for _, r := range in.Rules {
+		if r != policy.RuleFreeze && r != policy.RuleBusinessHours && r != policy.RuleImages {
+			return nil, fmt.Errorf("unknown policy rule %q", r)
+		}
+	}
+	if in.Namespace == "" { in.Namespace = "*" }
+	if in.App == "" { in.App = "*" }
+	now := time.Now()
+	ov := store.Override{
+		ID: randID(), Namespace: in.Namespace, App: in.App, Rules: in.Rules, Reason: in.Reason,
+		CreatedBy: in.CreatedBy, CreatedAt: now, ExpiresAt: now.Add(in.Duration),
+	}
+	if err := o.db.PutOverride(ov); err != nil { return nil, err }
+	o.log.Warn().Str("override", ov.ID).Str("ns", ov.Namespace).Str("app", ov.App).Strs("rules", ov.Rules).
+		Time("expiresAt", ov.ExpiresAt).Msg("policy override created: " + ov.Reason)
+	return &ov, nil
+}
@@ -0,0 +1,101 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/policy"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/strategies"
+)
+
+// Plan é o que StartDeploy faria com o mesmo input, sem alterar nada.
+type Plan struct {
+	DryRun       bool                  `json:"dryRun"`
+	App          string                `json:"app"`
+	Namespace    string                `json:"namespace"`
+	Strategy     string                `json:"strategy"`
+	ImageOld     string                `json:"imageOld"`
+	ImageNew     string                `json:"imageNew"`
+	Replicas     int32                 `json:"replicas"`
+	Params       any                   `json:"params"` // parâmetros resolvidos (Params + Defaults)
+	Policy       policy.Decision       `json:"policy"`
+	Steps        []strategies.PlanStep `json:"steps"`
+	TemplateDiff string                `json:"templateDiff"`
+	ServerDryRun bool                  `json:"serverDryRun"` // diff validado pelo API server (dryRun=All)
+	Warnings     []string              `json:"warnings,omitempty"`
+}
+
+// Plan resolve parâmetros, lê o Deployment atual e devolve as etapas e o diff
+// do pod template. O Update é enviado com dryRun=All; se o cluster recusar, o
+// diff é calculado localmente.
+func (o *Orchestrator) Plan(ctx context.Context, in DeployInput) (*Plan, error) {
+	if in.Namespace == "" { in.Namespace = "default" }
+	p := &Plan{DryRun: true, App: in.App, Namespace: in.Namespace, Strategy: in.Strategy, ImageNew: in.Image}
+
+	overrides, err := o.db.ListOverrides(true)
+	if err != nil { return nil, err }
+	p.Policy = o.policy.Evaluate(policy.Input{Namespace: in.Namespace, App: in.App, Image: in.Image}, time.Now(), overrides)
+	if !p.Policy.Allowed { p.Warnings = append(p.Warnings, "policy would reject this deploy: "+p.Policy.Reason) }
+	if p.Policy.RequireApproval || in.RequireApproval { p.Warnings = append(p.Warnings, "deploy will wait for manual approval") }
+
+	tc, _ := o.cfg.TargetFor(in.Namespace, in.App)
+	if tc.Type != "" && tc.Type != "deployment" {
+		return nil, fmt.Errorf("dry-run plan is only available for deployment targets (%s/%s is %s)", in.Namespace, in.App, tc.Type)
+	}
+	waves, err := o.resolveWaves(in.Namespace, in.App, in.Waves)
+	if err != nil { return nil, err }
+	cl := o.clusters[""]
+	if len(waves) > 0 {
+		cl = o.clusters[waves[0].Clusters[0]]
+		p.Warnings = append(p.Warnings, "plan computed against cluster "+waves[0].Clusters[0]+" (first wave); other clusters are not checked")
+	}
+	dep := k8s.NewDeployer(cl.cs.AppsV1().Deployments(in.Namespace))
+	cur, err := dep.Get(ctx, in.App)
+	if err != nil { return nil, fmt.Errorf("get deployment %s/%s: %w", in.Namespace, in.App, err) }
+	if cur.Spec.Replicas != nil { p.Replicas = *cur.Spec.Replicas } else { p.Replicas = 1 }
+	if len(cur.Spec.Template.Spec.Containers) > 0 { p.ImageOld = cur.Spec.Template.Spec.Containers[0].Image }
+
+	switch in.Strategy {
+	case "canary":
+		cp := o.canaryParams(in.Params)
+		p.Params, p.Steps = cp, strategies.PlanCanary(cur, in.Image, cp)
+	case "bluegreen":
+		bp := o.blueGreenParams(in.Params)
+		p.Params, p.Steps = bp, strategies.PlanBlueGreen(cur, in.Image, bp)
+	default:
+		return nil, fmt.Errorf("unknown strategy %q", in.Strategy)
+	}
+
+	if tpl, ok := o.cfg.Analysis.Template(tc.Analysis); ok {
+		for i := range p.Steps {
+			if len(p.Steps[i].Analysis) > 0 { p.Steps[i].Analysis = append(p.Steps[i].Analysis, planMetrics(tpl.Metrics)...) }
+		}
+	}
+
+	next, err := dep.DryRunSetImage(ctx, cur, in.App, in.Image)
+	if err == nil {
+		p.ServerDryRun = true
+	} else {
+		p.Warnings = append(p.Warnings, "server-side dry-run failed, diff computed locally: "+err.Error())
+		next = k8s.SetImageLocal(cur, in.App, in.Image)
+	}
+	p.TemplateDiff = k8s.TemplateDiff(cur.Spec.Template, next.Spec.Template)
+	return p, nil
+}
+
+// planMetrics descreve as métricas do template de análise no plano.
+func planMetrics(ms []config.Metric) []strategies.AnalysisQuery {
+	var out []strategies.AnalysisQuery
+	for _, m := range ms {
+		q := strategies.AnalysisQuery{Metric: m.Name, Provider: m.Provider, Query: m.Query, Range: m.Range}
+		if m.Job != nil { q.Query = "job (exit code)" }
+		if m.JSONPath != "" { q.Query += " " + m.JSONPath }
+		if m.Min != nil { q.Min = *m.Min }
+		if m.Max != nil { q.Max = *m.Max }
+		out = append(out, q)
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+var (
+	ErrNoRollbackTarget = errors.New("no succeeded deploy to roll back to")
+	ErrInvalidRollback  = errors.New("invalid rollback")
+)
+
+// RollbackInput pede a volta de um app ao estado deixado por um deploy
+// anterior com sucesso.
+type RollbackInput struct {
+	App       string
+	Namespace string
+	// To é o ID do deploy ou a revision gravada nele; vazio = o sucesso mais
+	// recente com imagem diferente da atual.
+	To       string
+	Strategy string // fast|canary
+	Params   map[string]string
+	Queue    bool
+	Actor    string
+}
+
+// RollbackTo cria um novo deploy (RollbackOf = deploy de origem) que restaura
+// o snapshot do target gravado quando o deploy de origem terminou. Ele passa
+// por policy, lock, estratégia e análise como qualquer deploy.
+func (o *Orchestrator) RollbackTo(ctx context.Context, in RollbackInput) (*store.DeployRecord, error) {
+	if in.Namespace == "" { in.Namespace = "default" }
+	if in.Strategy == "" { in.Strategy = "fast" }
+	if in.Strategy != "fast" && in.Strategy != "canary" {
+		return nil, fmt.Errorf("%w: strategy must be fast or canary, got %q", ErrInvalidRollback, in.Strategy)
+	}
+	src, err := o.rollbackSource(in.Namespace, in.App, in.To)
+	if err != nil { return nil, err }
+	return o.StartDeploy(ctx, DeployInput{
+		App: in.App, Namespace: in.Namespace, Image: src.ImageNew, Strategy: in.Strategy, Params: in.Params,
+		Queue: in.Queue, Actor: in.Actor, RollbackOf: src.ID, Waves: src.Waves,
+	})
+}
+
+// rollbackSource acha o deploy de origem: pelo ID, pela revision ou, sem to,
+// o sucesso mais recente cuja imagem difere da do último sucesso.
+func (o *Orchestrator) rollbackSource(ns, app, to string) (*store.DeployRecord, error) {
+	if to != "" {
+		rec, err := o.db.Get(to)
+		if err != nil { return nil, err }
+		if rec != nil {
+			if rec.Namespace != ns || rec.App != app {
+				return nil, fmt.Errorf("%w: deploy %s belongs to %s/%s", ErrInvalidRollback, to, rec.Namespace, rec.App)
+			}
+			if rec.Status != "succeeded" {
+				return nil, fmt.Errorf("%w: deploy %s is %s, only succeeded deploys can be restored", ErrInvalidRollback, to, rec.Status)
+			}
+			return rec, nil
+		}
+	}
+	var cur *store.DeployRecord
+	q := store.ListQuery{App: app, Namespace: ns, Status: "succeeded", Limit: store.MaxListLimit}
+	for {
+		page, err := o.db.Query(q)
+		if err != nil { return nil, err }
+		for i := range page.Items {
+			r := &page.Items[i]
+			switch {
+			case to != "":
+				if r.Revision == to { return r, nil }
+			case cur == nil:
+				cur = r
+			case r.ImageNew != cur.ImageNew:
+				return r, nil
+			}
+		}
+		if page.NextCursor == "" { break }
+		q.Cursor = page.NextCursor
+	}
+	if to != "" { return nil, fmt.Errorf("%w: no succeeded deploy %s or revision %s for %s/%s", ErrNoRollbackTarget, to, to, ns, app) }
+	return nil, fmt.Errorf("%w: %s/%s has no earlier succeeded deploy with a different image", ErrNoRollbackTarget, ns, app)
+}
+
+// saveSnapshot grava o estado deixado por um deploy com sucesso no cluster
+// e devolve a revision do target.
+func (o *Orchestrator) saveSnapshot(ctx context.Context, t k8s.Target, id, cluster string) string {
+	snap, err := t.Snapshot(ctx)
+	if err != nil {
+		o.log.Warn().Err(err).Str("deploy", id).Str("cluster", cluster).Msg("target snapshot after deploy")
+		return ""
+	}
+	b, _ := json.Marshal(snap)
+	if err := o.db.PutSnapshot(snapshotKey(id, cluster), b); err != nil {
+		o.log.Error().Err(err).Str("deploy", id).Str("cluster", cluster).Msg("save target snapshot")
+	}
+	return snap.Revision
+}
+
+// restoreSnapshot devolve o snapshot do deploy id no cluster; deploys
+// anteriores aos snapshots voltam só pela imagem.
+func (o *Orchestrator) restoreSnapshot(id, cluster string) (k8s.Snapshot, error) {
+	src, err := o.db.Get(id)
+	if err != nil { return k8s.Snapshot{}, err }
+	if src == nil { return k8s.Snapshot{}, fmt.Errorf("%w: deploy %s not found", ErrNoRollbackTarget, id) }
+	snap := k8s.Snapshot{Image: src.ImageNew, Revision: src.Revision}
+	for _, cs := range src.Clusters {
+		if cs.Cluster == cluster && cluster != "" { snap.Revision = cs.Revision }
+	}
+	raw, err := o.db.Snapshot(snapshotKey(id, cluster))
+	if err != nil { return snap, err }
+	if raw != nil {
+		if err := json.Unmarshal(raw, &snap); err != nil { return snap, fmt.Errorf("snapshot of %s: %w", id, err) }
+	}
+	return snap, nil
+}
+
+// snapshotKey: deploys com waves gravam um snapshot por cluster.
+func snapshotKey(id, cluster string) string {
+	if cluster == "" { return id }
+	return id + "@" + cluster
+}
@@ -0,0 +1,71 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// seedHistory grava o histórico de prod/web (do mais antigo ao mais novo) e
+// um deploy de prod/api.
+func seedHistory(t *testing.T, o *Orchestrator) {
+	t.Helper()
+	now := time.Now()
+	for i, r := range []store.DeployRecord{
+		{ID: "r1", App: "web", ImageNew: "repo/web:1", Revision: "rev-1", Status: "succeeded"},
+		{ID: "r2", App: "web", ImageNew: "repo/web:2", Revision: "rev-2", Status: "succeeded"},
+		{ID: "r3", App: "web", ImageNew: "repo/web:3", Status: "rolled_back"},
+		{ID: "r4", App: "web", ImageNew: "repo/web:2", Revision: "rev-4", Status: "succeeded"}, // redeploy da mesma imagem
+		{ID: "a1", App: "api", ImageNew: "repo/api:1", Revision: "rev-1", Status: "succeeded"},
+	} {
+		r.Namespace, r.Strategy, r.StartedAt = "prod", "fast", now.Add(time.Duration(i-5)*time.Hour)
+		if err := o.db.Put(r); err != nil { t.Fatal(err) }
+	}
+}
+
+func TestRollbackSource(t *testing.T) {
+	o := newLockOrchestrator(t)
+	seedHistory(t, o)
+	tests := []struct {
+		name, app, to string
+		want          string
+		err           error
+	}{
+		{"último sucesso com outra imagem", "web", "", "r1", nil},
+		{"pelo ID", "web", "r2", "r2", nil},
+		{"pela revision", "web", "rev-1", "r1", nil},
+		{"revision de outro app não conta", "api", "rev-4", "", ErrNoRollbackTarget},
+		{"deploy sem sucesso", "web", "r3", "", ErrInvalidRollback},
+		{"deploy de outro app", "web", "a1", "", ErrInvalidRollback},
+		{"inexistente", "web", "nope", "", ErrNoRollbackTarget},
+		{"sem imagem anterior", "api", "", "", ErrNoRollbackTarget},
+	}
+	for _, tt := range tests {
+		rec, err := o.rollbackSource("prod", tt.app, tt.to)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) { t.Errorf("%s: err = %v, want %v", tt.name, err, tt.err) }
+			continue
+		}
+		if err != nil || rec.ID != tt.want { t.Errorf("%s: %+v %v, want %s", tt.name, rec, err, tt.want) }
+	}
+}
+
+func TestRollbackTo(t *testing.T) {
+	o := newLockOrchestrator(t)
+	seedHistory(t, o)
+	ctx := context.Background()
+
+	if _, err := o.RollbackTo(ctx, RollbackInput{App: "web", Namespace: "prod", Strategy: "bluegreen"}); !errors.Is(err, ErrInvalidRollback) { t.Fatalf("bluegreen: %v", err) }
+	if _, err := o.RollbackTo(ctx, RollbackInput{App: "api", Namespace: "prod", To: "r1"}); !errors.Is(err, ErrInvalidRollback) { t.Fatalf("alvo de outro app: %v", err) }
+
+	// com o lock ocupado o rollback fica na fila e não toca no cluster
+	if _, ok, err := o.locks.Acquire(ctx, "prod", "web", "d0", o.cfg.Locks.TTL); err != nil || !ok { t.Fatalf("lock: %v", err) }
+	rec, err := o.RollbackTo(ctx, RollbackInput{App: "web", Namespace: "prod", To: "rev-1", Queue: true, Actor: "alice"})
+	if err != nil { t.Fatal(err) }
+	if rec.RollbackOf != "r1" || rec.ImageNew != "repo/web:1" || rec.Strategy != "fast" || rec.Status != "queued" || rec.RequestedBy != "alice" { t.Fatalf("rollback = %+v", rec) }
+	if err := o.Abort(rec.ID, "alice"); err != nil { t.Fatal(err) }
+	waitStatus(t, o, rec.ID, "aborted")
+}
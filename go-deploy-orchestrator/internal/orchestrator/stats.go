@@ -0,0 +1,43 @@
+package orchestrator
+
+import (
+	"context"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/stats"
+)
+
+// Stats calcula as métricas DORA dos deploys iniciados na janela.
+func (o *Orchestrator) Stats(window time.Duration) (stats.Report, error) {
+	if window <= 0 { window = o.cfg.Stats.Window }
+	now := time.Now()
+	recs, err := o.db.Since(now.Add(-window))
+	if err != nil { return stats.Report{}, err }
+	return stats.Compute(recs, window, now), nil
+}
+
+// refreshStats atualiza os gauges do_dora_* a cada cfg.Stats.Refresh.
+func (o *Orchestrator) refreshStats(ctx context.Context) {
+	t := time.NewTicker(o.cfg.Stats.Refresh)
+	defer t.Stop()
+	for {
+		rep, err := o.Stats(0)
+		if err != nil {
+			o.log.Error().Err(err).Msg("dora stats")
+		} else {
+			metrics.DoraDeployFrequency.Reset()
+			metrics.DoraChangeFailureRate.Reset()
+			metrics.DoraMTTR.Reset()
+			for _, a := range rep.Apps {
+				metrics.DoraDeployFrequency.WithLabelValues(a.Namespace, a.App).Set(a.DeploysPerDay)
+				metrics.DoraChangeFailureRate.WithLabelValues(a.Namespace, a.App).Set(a.ChangeFailureRate)
+				metrics.DoraMTTR.WithLabelValues(a.Namespace, a.App).Set(a.MTTRSeconds)
+			}
+		}
+		select {
+		case <-ctx.Done(): return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package orchestrator
+
+import (
+	"fmt"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+)
+
+// target monta o k8s.Target do app no cluster ("" = cfg.Kube) conforme
+// cfg.Targets; sem entrada, é o Deployment com o nome do app.
+func (o *Orchestrator) target(cluster, ns, app string) (k8s.Target, error) {
+	cl, ok := o.clusters[cluster]
+	if !ok { return nil, fmt.Errorf("unknown cluster %q", cluster) }
+	tc, _ := o.cfg.TargetFor(ns, app)
+	switch tc.Type {
+	case "", "deployment":
+		return k8s.NewDeploymentTarget(k8s.NewDeployer(cl.cs.AppsV1().Deployments(ns)), app), nil
+	case "statefulset":
+		return k8s.NewStatefulSetTarget(cl.cs, ns, app), nil
+	case "daemonset":
+		return k8s.NewDaemonSetTarget(cl.cs, ns, app), nil
+	case "kustomize":
+		return k8s.NewKustomizeTarget(cl.cs, cl.dyn, ns, tc.Path, tc.Image), nil
+	case "helm":
+		logf := func(format string, v ...any) { o.log.Debug().Str("release", tc.Release).Msgf(format, v...) }
+		return k8s.NewHelmTarget(cl.cs, cl.kubeconfig, cl.context, ns, tc.Release, tc.ImageValues.Repository, tc.ImageValues.Tag, logf)
+	}
+	return nil, fmt.Errorf("unknown target type %q", tc.Type)
+}
+
+func validateTargets(cfg *config.Config) error {
+	clusters := map[string]bool{}
+	for _, c := range cfg.Clusters { clusters[c.Name] = true }
+	for _, t := range cfg.Targets {
+		if t.App == "" { return fmt.Errorf("target: app is required") }
+		switch t.Type {
+		case "", "deployment", "statefulset", "daemonset", "helm":
+		case "kustomize":
+			if t.Path == "" { return fmt.Errorf("target %q: kustomize requires path", t.App) }
+		default:
+			return fmt.Errorf("target %q: unknown type %q", t.App, t.Type)
+		}
+		if err := hooks.Validate(t.Hooks); err != nil { return fmt.Errorf("target %q: %w", t.App, err) }
+		if _, ok := cfg.Analysis.Template(t.Analysis); t.Analysis != "" && !ok { return fmt.Errorf("target %q: unknown analysis template %q", t.App, t.Analysis) }
+		for _, w := range t.Waves {
+			if len(w.Clusters) == 0 { return fmt.Errorf("target %q: wave %q has no clusters", t.App, w.Name) }
+			for _, c := range w.Clusters {
+				if !clusters[c] { return fmt.Errorf("target %q: wave %q uses unknown cluster %q", t.App, w.Name, c) }
+			}
+			if _, ok := cfg.Analysis.Template(w.Analysis); w.Analysis != "" && !ok { return fmt.Errorf("target %q: wave %q: unknown analysis template %q", t.App, w.Name, w.Analysis) }
+		}
+	}
+	return nil
+}
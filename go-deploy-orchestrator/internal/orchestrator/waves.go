@@ -0,0 +1,249 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+var ErrInvalidWaves = errors.New("invalid waves")
+
+// cluster guarda os clientes de um cluster; "" é o de cfg.Kube e os demais
+// vêm de cfg.Clusters. Hooks e providers "job" rodam no cluster do deploy.
+type cluster struct {
+	cs         kubernetes.Interface
+	dyn        dynamic.Interface
+	kubeconfig string
+	context    string
+	hooks      *hooks.Runner
+	analysis   analysis.Providers
+}
+
+func newCluster(kubeconfig, kctx string) (*cluster, error) {
+	rc, err := k8s.NewRESTConfig(kubeconfig, kctx)
+	if err != nil { return nil, err }
+	cs, err := kubernetes.NewForConfig(rc)
+	if err != nil { return nil, err }
+	dyn, err := dynamic.NewForConfig(rc)
+	if err != nil { return nil, err }
+	return &cluster{cs: cs, dyn: dyn, kubeconfig: kubeconfig, context: kctx}, nil
+}
+
+func newClusters(cfg *config.Config) (map[string]*cluster, error) {
+	def, err := newCluster(cfg.Kube.Kubeconfig, cfg.Kube.Context)
+	if err != nil { return nil, err }
+	out := map[string]*cluster{"": def}
+	for _, c := range cfg.Clusters {
+		if c.Name == "" { return nil, errors.New("cluster: name is required") }
+		if _, dup := out[c.Name]; dup { return nil, fmt.Errorf("cluster %q: duplicated name", c.Name) }
+		if out[c.Name], err = newCluster(c.Kubeconfig, c.Context); err != nil { return nil, fmt.Errorf("cluster %q: %w", c.Name, err) }
+	}
+	return out, nil
+}
+
+// bind monta o runner de hooks e os providers de análise com o client do cluster.
+func (c *cluster) bind(cfg []config.AnalysisProvider, prom *prometheus.Evaluator) error {
+	var err error
+	c.analysis, err = analysis.NewProviders(cfg, prom, c.cs)
+	c.hooks = hooks.New(c.cs)
+	return err
+}
+
+// resolveWaves valida as waves do pedido ou, sem elas, devolve as do target.
+// Cada cluster aparece em uma única wave.
+func (o *Orchestrator) resolveWaves(ns, app string, in []store.Wave) ([]store.Wave, error) {
+	if len(in) == 0 {
+		tc, _ := o.cfg.TargetFor(ns, app)
+		for _, w := range tc.Waves {
+			bake := ""
+			if w.BakeTime > 0 { bake = w.BakeTime.String() }
+			in = append(in, store.Wave{Name: w.Name, Clusters: w.Clusters, BakeTime: bake, Analysis: w.Analysis})
+		}
+	}
+	seen := map[string]bool{}
+	for i := range in {
+		w := &in[i]
+		if len(w.Clusters) == 0 { return nil, fmt.Errorf("%w: wave %d has no clusters", ErrInvalidWaves, i+1) }
+		if w.Name == "" { w.Name = strings.Join(w.Clusters, "+") }
+		for _, c := range w.Clusters {
+			if _, ok := o.clusters[c]; !ok || c == "" { return nil, fmt.Errorf("%w: unknown cluster %q in wave %s", ErrInvalidWaves, c, w.Name) }
+			if seen[c] { return nil, fmt.Errorf("%w: cluster %q is in more than one wave", ErrInvalidWaves, c) }
+			seen[c] = true
+		}
+		if w.BakeTime != "" {
+			if d, err := time.ParseDuration(w.BakeTime); err != nil || d < 0 { return nil, fmt.Errorf("%w: wave %s: invalid bakeTime %q", ErrInvalidWaves, w.Name, w.BakeTime) }
+		}
+		if _, ok := o.cfg.Analysis.Template(w.Analysis); w.Analysis != "" && !ok {
+			return nil, fmt.Errorf("%w: wave %s: unknown analysis template %q", ErrInvalidWaves, w.Name, w.Analysis)
+		}
+	}
+	return in, nil
+}
+
+// touched é um cluster já alterado pelo deploy e o estado para o rollback.
+type touched struct {
+	cluster string
+	t       k8s.Target
+	start   k8s.Snapshot
+}
+
+// runWaves executa um deploy multi-cluster: as waves em ordem, a estratégia
+// em cada cluster da wave e, antes da próxima, bake e análise de cada
+// cluster. Uma falha volta todos os clusters já alterados, do último para o
+// primeiro.
+func (o *Orchestrator) runWaves(ctx context.Context, rec store.DeployRecord, requireApproval bool) {
+	rec.Clusters = nil
+	for _, w := range rec.Waves {
+		for _, c := range w.Clusters {
+			rec.Clusters = append(rec.Clusters, store.ClusterStatus{Cluster: c, Wave: w.Name, Status: "pending"})
+		}
+	}
+	_ = o.db.Put(rec)
+	if !o.awaitApproval(ctx, &rec, requireApproval) { return }
+
+	var done []touched
+	err := o.runHooks(ctx, rec, hooks.PreDeploy, "", "")
+	for _, w := range rec.Waves {
+		if err != nil { break }
+		err = o.runWave(ctx, &rec, w, &done)
+	}
+	if err == nil { err = o.runHooks(ctx, rec, hooks.PostPromote, "", "") }
+	if err != nil {
+		err = o.failure(ctx, rec, err)
+		o.rollbackClusters(context.WithoutCancel(ctx), rec, done, err)
+		return
+	}
+
+	for _, d := range done {
+		o.clusterStatus(&rec, d.cluster).Revision = o.saveSnapshot(ctx, d.t, rec.ID, d.cluster)
+	}
+	now := time.Now()
+	rec.FinishedAt = &now
+	o.setStatus(&rec, "succeeded", "")
+	metrics.DeploysSucceeded.WithLabelValues(rec.App, rec.Strategy).Inc()
+}
+
+func (o *Orchestrator) runWave(ctx context.Context, rec *store.DeployRecord, w store.Wave, done *[]touched) error {
+	o.emit(rec.ID, "wave", w.Name, "wave "+w.Name+" started", map[string]string{"wave": w.Name, "clusters": strings.Join(w.Clusters, ",")})
+	for _, c := range w.Clusters {
+		now := time.Now()
+		o.clusterStatus(rec, c).StartedAt = &now
+		t, err := o.targetFor(*rec, c)
+		if err != nil {
+			o.setClusterStatus(rec, c, "failed", err.Error())
+			return fmt.Errorf("cluster %s: %w", c, err)
+		}
+		start, err := t.Snapshot(ctx)
+		if err == nil {
+			cs := o.clusterStatus(rec, c)
+			cs.ImageOld, cs.RevisionOld = start.Image, start.Revision
+			if rec.ImageOld == "" { rec.ImageOld, rec.RevisionOld = start.Image, start.Revision }
+		} else {
+			o.log.Warn().Err(err).Str("deploy", rec.ID).Str("cluster", c).Msg("target snapshot")
+		}
+		*done = append(*done, touched{cluster: c, t: t, start: start})
+		o.setClusterStatus(rec, c, "running", "")
+		if err := o.applyStrategy(ctx, t, *rec, c); err != nil {
+			o.setClusterStatus(rec, c, "failed", err.Error())
+			return fmt.Errorf("cluster %s: %w", c, err)
+		}
+	}
+
+	if bake, _ := time.ParseDuration(w.BakeTime); bake > 0 {
+		for _, c := range w.Clusters { o.clusterStatus(rec, c).Status = "baking" }
+		_ = o.db.Put(*rec)
+		o.emit(rec.ID, "wave", w.Name, "wave "+w.Name+" baking for "+w.BakeTime, map[string]string{"wave": w.Name, "bakeTime": w.BakeTime})
+		if err := sleep(ctx, bake); err != nil { return err }
+	}
+
+	// análise entre waves: o template da wave (ou o do target) em cada cluster
+	for _, c := range w.Clusters {
+		step := clusterStep(c, "bake")
+		r := o.analysisFor(*rec, c, w.Analysis).Run(ctx, step)
+		if len(r.Measurements) == 0 { continue }
+		data := r.Data()
+		data["cluster"], data["wave"] = c, w.Name
+		msg := "analysis passed"
+		if !r.OK() { msg = "analysis failed" }
+		o.emit(rec.ID, "analysis", step, msg, data)
+		if !r.OK() {
+			o.setClusterStatus(rec, c, "failed", r.Summary())
+			return fmt.Errorf("analysis failed after wave %s in cluster %s (%s)", w.Name, c, r.Summary())
+		}
+	}
+	for _, c := range w.Clusters { o.setClusterStatus(rec, c, "succeeded", "") }
+	o.emit(rec.ID, "wave", w.Name, "wave "+w.Name+" finished", map[string]string{"wave": w.Name})
+	return nil
+}
+
+// rollbackClusters volta os clusters alterados, na ordem inversa do deploy.
+func (o *Orchestrator) rollbackClusters(ctx context.Context, rec store.DeployRecord, done []touched, err error) {
+	o.log.Error().Err(err).Str("app", rec.App).Msg("multi-cluster deploy failed, rolling back")
+	var names []string
+	for i := len(done) - 1; i >= 0; i-- { names = append(names, done[i].cluster) }
+	o.emit(rec.ID, "rollback", "", "rollback started: "+err.Error(), map[string]string{"clusters": strings.Join(names, ","), "imageOld": rec.ImageOld})
+	for i := len(done) - 1; i >= 0; i-- {
+		d := done[i]
+		if e := o.restore(ctx, rec.ID, d.cluster, d.t, d.start); e != nil {
+			o.setClusterStatus(&rec, d.cluster, "failed", "rollback failed: "+e.Error())
+			continue
+		}
+		o.setClusterStatus(&rec, d.cluster, "rolled_back", err.Error())
+	}
+	now := time.Now()
+	rec.FinishedAt = &now
+	o.setStatus(&rec, "rolled_back", err.Error())
+}
+
+func (o *Orchestrator) clusterStatus(rec *store.DeployRecord, c string) *store.ClusterStatus {
+	for i := range rec.Clusters {
+		if rec.Clusters[i].Cluster == c { return &rec.Clusters[i] }
+	}
+	rec.Clusters = append(rec.Clusters, store.ClusterStatus{Cluster: c})
+	return &rec.Clusters[len(rec.Clusters)-1]
+}
+
+// setClusterStatus grava o status do cluster no registro e na timeline.
+func (o *Orchestrator) setClusterStatus(rec *store.DeployRecord, c, status, reason string) {
+	cs := o.clusterStatus(rec, c)
+	cs.Status, cs.Reason = status, reason
+	switch status {
+	case "succeeded", "failed", "rolled_back":
+		now := time.Now()
+		cs.FinishedAt = &now
+	}
+	_ = o.db.Put(*rec)
+	data := map[string]string{"cluster": c, "wave": cs.Wave, "status": status}
+	if reason != "" { data["reason"] = reason }
+	o.emit(rec.ID, "cluster", c, "cluster "+c+" "+status, data)
+}
+
+// clusterStep prefixa o step dos eventos com o cluster (deploys com waves).
+func clusterStep(cluster, step string) string {
+	if cluster == "" { return step }
+	if step == "" { return cluster }
+	return cluster + "/" + step
+}
+
+// sleep espera d ou até o deploy ser abortado.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done(): return context.Cause(ctx)
+	case <-t.C: return nil
+	}
+}
@@ -0,0 +1,173 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/hooks"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/logger"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/notify"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// readyDeployment é um Deployment "web" já pronto (o fake não tem controller).
+func readyDeployment(image string) *appsv1.Deployment {
+	replicas := int32(2)
+	return &appsv1.Deployment{
+		ObjectMeta: meta.ObjectMeta{Name: "web", Namespace: "prod"},
+		Spec: appsv1.DeploymentSpec{Replicas: &replicas, Template: corev1.PodTemplateSpec{
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: image}}},
+		}},
+		Status: appsv1.DeploymentStatus{Replicas: 2, UpdatedReplicas: 2, ReadyReplicas: 2, AvailableReplicas: 2},
+	}
+}
+
+// newWavesOrchestrator monta um Orchestrator com os clusters staging, eu e us
+// (fake) e o template "health", que reprova os clusters em unhealthy.
+func newWavesOrchestrator(t *testing.T, unhealthy ...string) *Orchestrator {
+	t.Helper()
+	bad := map[string]bool{}
+	for _, c := range unhealthy { bad[c] = true }
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/query", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+	})
+	mux.HandleFunc("GET /health/{cluster}", func(w http.ResponseWriter, r *http.Request) {
+		ok := 1
+		if bad[r.PathValue("cluster")] { ok = 0 }
+		fmt.Fprintf(w, `{"ok":%d}`, ok)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	min := 1.0
+	cfg := &config.Config{
+		Clusters: []config.Cluster{{Name: "staging"}, {Name: "eu"}, {Name: "us"}},
+		Analysis: config.Analysis{
+			Providers: []config.AnalysisProvider{{Name: "health", Type: "http", URL: srv.URL, Timeout: time.Second}},
+			Templates: []config.AnalysisTemplate{{Name: "health", Metrics: []config.Metric{
+				{Name: "healthy", Provider: "health", Query: "/health/${CLUSTER}", JSONPath: "$.ok", Range: "5m", Min: &min},
+			}}},
+		},
+	}
+	cfg.Locks.TTL = time.Minute
+	db, err := store.Open(filepath.Join(t.TempDir(), "deploys.db"))
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { _ = db.Close() })
+	log := logger.New("error")
+	nd, _ := notify.New(log, config.Notifications{})
+	clusters := map[string]*cluster{"": {cs: fake.NewSimpleClientset()}}
+	for _, c := range cfg.Clusters {
+		clusters[c.Name] = &cluster{cs: fake.NewSimpleClientset(readyDeployment("repo/web:1"))}
+	}
+	for _, cl := range clusters {
+		if err := cl.bind(cfg.Analysis.Providers, prometheus.NewEvaluator(srv.URL, time.Second)); err != nil { t.Fatal(err) }
+	}
+	return &Orchestrator{log: log, cfg: cfg, db: db, clusters: clusters, events: newNotifier(), locks: storeLocker{db}, dispatch: nd}
+}
+
+// deployWaves roda o deploy de repo/web:2 de forma síncrona.
+func deployWaves(t *testing.T, o *Orchestrator, waves []store.Wave) store.DeployRecord {
+	t.Helper()
+	waves, err := o.resolveWaves("prod", "web", waves)
+	if err != nil { t.Fatal(err) }
+	rec := store.DeployRecord{ID: "d1", App: "web", Namespace: "prod", ImageNew: "repo/web:2", Strategy: "fast",
+		Status: "started", StartedAt: time.Now(), Params: map[string]string{"probeWait": "1"}, Waves: waves}
+	if err := o.db.Put(rec); err != nil { t.Fatal(err) }
+	if _, ok, err := o.locks.Acquire(context.Background(), "prod", "web", rec.ID, o.cfg.Locks.TTL); err != nil || !ok { t.Fatalf("lock: %v", err) }
+	o.run(context.Background(), rec, false, false)
+	cur, err := o.db.Get(rec.ID)
+	if err != nil { t.Fatal(err) }
+	return *cur
+}
+
+func image(t *testing.T, o *Orchestrator, c string) string {
+	t.Helper()
+	dep, err := o.clusters[c].cs.AppsV1().Deployments("prod").Get(context.Background(), "web", meta.GetOptions{})
+	if err != nil { t.Fatal(err) }
+	return dep.Spec.Template.Spec.Containers[0].Image
+}
+
+func TestWavesSucceed(t *testing.T) {
+	o := newWavesOrchestrator(t)
+	rec := deployWaves(t, o, []store.Wave{
+		{Clusters: []string{"staging"}, Analysis: "health"},
+		{Clusters: []string{"eu", "us"}, Analysis: "health"},
+	})
+	if rec.Status != "succeeded" { t.Fatalf("status = %s (%s)", rec.Status, rec.Reason) }
+	if len(rec.Clusters) != 3 { t.Fatalf("clusters = %+v", rec.Clusters) }
+	for _, cs := range rec.Clusters {
+		if cs.Status != "succeeded" || cs.ImageOld != "repo/web:1" { t.Errorf("%s: %+v", cs.Cluster, cs) }
+		if snap, _ := o.db.Snapshot(snapshotKey(rec.ID, cs.Cluster)); snap == nil { t.Errorf("%s: no snapshot saved", cs.Cluster) }
+		if img := image(t, o, cs.Cluster); img != "repo/web:2" { t.Errorf("%s image = %s", cs.Cluster, img) }
+	}
+	if rec.Waves[1].Name != "eu+us" { t.Errorf("wave name = %q", rec.Waves[1].Name) }
+}
+
+func TestWavesRollbackAllClusters(t *testing.T) {
+	o := newWavesOrchestrator(t, "us")
+	rec := deployWaves(t, o, []store.Wave{
+		{Name: "canary", Clusters: []string{"staging"}, Analysis: "health"},
+		{Name: "prod", Clusters: []string{"eu", "us"}, Analysis: "health"},
+	})
+	if rec.Status != "rolled_back" { t.Fatalf("status = %s (%s)", rec.Status, rec.Reason) }
+	for _, cs := range rec.Clusters {
+		if cs.Status != "rolled_back" { t.Errorf("%s: %+v", cs.Cluster, cs) }
+		if img := image(t, o, cs.Cluster); img != "repo/web:1" { t.Errorf("%s image = %s", cs.Cluster, img) }
+	}
+
+	// o rollback segue a ordem inversa do deploy
+	evs, err := o.db.Events(rec.ID, 0)
+	if err != nil { t.Fatal(err) }
+	var order []string
+	for _, e := range evs {
+		if e.Type == "rollback" && e.Data["image"] != "" { order = append(order, e.Data["cluster"]) }
+	}
+	if fmt.Sprint(order) != "[us eu staging]" { t.Errorf("rollback order = %v", order) }
+}
+
+func TestResolveWaves(t *testing.T) {
+	o := newWavesOrchestrator(t)
+	for _, ws := range [][]store.Wave{
+		{{Clusters: []string{"mars"}}},
+		{{Clusters: []string{"eu"}}, {Clusters: []string{"eu", "us"}}},
+		{{Clusters: nil}},
+		{{Clusters: []string{"eu"}, BakeTime: "soon"}},
+		{{Clusters: []string{"eu"}, Analysis: "nope"}},
+	} {
+		if _, err := o.resolveWaves("prod", "web", ws); !errors.Is(err, ErrInvalidWaves) { t.Errorf("resolveWaves(%+v) = %v", ws, err) }
+	}
+}
+
+func TestWavesHooksRunInCluster(t *testing.T) {
+	o := newWavesOrchestrator(t)
+	o.cfg.Targets = []config.Target{{App: "web", Namespace: "prod", Hooks: []config.Hook{{Name: "smoke", Phase: hooks.PostStep, Timeout: 50 * time.Millisecond, Job: &config.JobHook{Template: map[string]any{
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{"containers": []any{map[string]any{"name": "smoke", "image": "${IMAGE}"}}}}},
+	}}}}}}
+	created := map[string]int{}
+	for name, cl := range o.clusters {
+		cl.cs.(*fake.Clientset).PrependReactor("create", "jobs", func(k8stesting.Action) (bool, runtime.Object, error) {
+			created[name]++
+			return false, nil, nil
+		})
+	}
+	// sem controller o Job não termina: o hook falha no primeiro step da wave
+	rec := deployWaves(t, o, []store.Wave{{Clusters: []string{"eu"}}})
+	if rec.Status != "rolled_back" { t.Fatalf("status = %s (%s)", rec.Status, rec.Reason) }
+	if created["eu"] != 1 || len(created) != 1 { t.Fatalf("jobs criados por cluster = %v", created) }
+}
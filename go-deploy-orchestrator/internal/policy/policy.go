@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+	_ "time/tzdata" // imagem distroless não traz zoneinfo
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+const (
+	RuleFreeze        = "freeze"
+	RuleBusinessHours = "business_hours"
+	RuleImages        = "images"
+)
+
+// Input é o que a policy precisa saber do deploy antes de tocar no cluster.
+type Input struct {
+	Namespace string
+	App       string
+	Image     string
+}
+
+// Decision é o resultado da avaliação. Rejeições trazem Rule/Reason.
+type Decision struct {
+	Allowed         bool   `json:"allowed"`
+	RequireApproval bool   `json:"requireApproval,omitempty"`
+	Rule            string `json:"rule,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	Override        string `json:"override,omitempty"` // ID do override que liberou o deploy
+}
+
+// Error é a rejeição estruturada devolvida pelo orquestrador.
+type Error struct{ Decision }
+
+func (e *Error) Error() string { return fmt.Sprintf("policy %s: %s", e.Rule, e.Reason) }
+
+type Evaluator struct {
+	cfg config.Policy
+	loc *time.Location
+}
+
+func New(cfg config.Policy) (*Evaluator, error) {
+	loc := time.UTC
+	if cfg.Timezone != "" {
+		l, err := time.LoadLocation(cfg.Timezone)
+		if err != nil { return nil, fmt.Errorf("policy timezone: %w", err) }
+		loc = l
+	}
+	for _, w := range cfg.Freezes {
+		if err := checkWindow(w); err != nil { return nil, fmt.Errorf("freeze %q: %w", w.Name, err) }
+	}
+	bh := cfg.BusinessHours
+	if len(bh.Namespaces) > 0 {
+		if _, err := clock(bh.Start); err != nil { return nil, fmt.Errorf("businessHours.start: %w", err) }
+		if _, err := clock(bh.End); err != nil { return nil, fmt.Errorf("businessHours.end: %w", err) }
+	}
+	return &Evaluator{cfg: cfg, loc: loc}, nil
+}
+
+// Evaluate aplica imagens, freezes e horário comercial, nessa ordem.
+// Overrides ativos que casam com namespace/app pulam as regras que cobrem.
+func (e *Evaluator) Evaluate(in Input, now time.Time, overrides []store.Override) Decision {
+	now = now.In(e.loc)
+	d := Decision{Allowed: true}
+	skip := func(rule string) bool {
+		for _, o := range overrides {
+			if !o.Active(now) || !glob(o.Namespace, in.Namespace) || !glob(o.App, in.App) { continue }
+			if len(o.Rules) == 0 || contains(o.Rules, rule) { d.Override = o.ID; return true }
+		}
+		return false
+	}
+
+	if reason := e.checkImage(in.Image); reason != "" && !skip(RuleImages) {
+		return Decision{Rule: RuleImages, Reason: reason}
+	}
+	for _, w := range e.cfg.Freezes {
+		if !scoped(w.Namespaces, in.Namespace) || !scoped(w.Apps, in.App) || !inWindow(w, now) { continue }
+		if skip(RuleFreeze) { break }
+		return Decision{Rule: RuleFreeze, Reason: fmt.Sprintf("deploys frozen by window %q", w.Name)}
+	}
+	bh := e.cfg.BusinessHours
+	if len(bh.Namespaces) > 0 && scoped(bh.Namespaces, in.Namespace) && !inBusinessHours(bh, now) && !skip(RuleBusinessHours) {
+		d.RequireApproval = true
+		d.Rule, d.Reason = RuleBusinessHours, "outside business hours, approval required"
+	}
+	return d
+}
+
+func (e *Evaluator) checkImage(image string) string {
+	ip := e.cfg.Images
+	if len(ip.AllowedRegistries) > 0 {
+		ok := false
+		for _, r := range ip.AllowedRegistries {
+			if strings.HasPrefix(image, strings.TrimSuffix(r, "/")+"/") { ok = true; break }
+		}
+		if !ok { return fmt.Sprintf("image %q is not from an allowed registry", image) }
+	}
+	if ip.DenyLatest {
+		if tag := imageTag(image); tag == "" || tag == "latest" {
+			return fmt.Sprintf("image %q must be pinned to a tag other than latest", image)
+		}
+	}
+	return ""
+}
+
+// imageTag devolve a tag da imagem ("" se não tiver); digest conta como fixado.
+func imageTag(image string) string {
+	if i := strings.Index(image, "@"); i >= 0 { return "@" + image[i+1:] }
+	last := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(last, ":"); i >= 0 { return last[i+1:] }
+	return ""
+}
+
+func checkWindow(w config.Window) error {
+	if w.From.IsZero() != w.To.IsZero() { return fmt.Errorf("from and to must be set together") }
+	if w.From.IsZero() && len(w.Weekdays) == 0 { return fmt.Errorf("set from/to or weekdays") }
+	for _, d := range w.Weekdays {
+		if _, ok := weekdays[strings.ToLower(d)]; !ok { return fmt.Errorf("invalid weekday %q", d) }
+	}
+	if w.Start != "" || w.End != "" {
+		if _, err := clock(w.Start); err != nil { return err }
+		if _, err := clock(w.End); err != nil { return err }
+	}
+	return nil
+}
+
+func inWindow(w config.Window, now time.Time) bool {
+	if !w.From.IsZero() && (now.Before(w.From) || !now.Before(w.To)) { return false }
+	if len(w.Weekdays) > 0 && !onWeekday(w.Weekdays, now) { return false }
+	if w.Start != "" { return inClock(w.Start, w.End, now) }
+	return true
+}
+
+func inBusinessHours(bh config.BusinessHours, now time.Time) bool {
+	days := bh.Weekdays
+	if len(days) == 0 { days = []string{"mon", "tue", "wed", "thu", "fri"} }
+	return onWeekday(days, now) && inClock(bh.Start, bh.End, now)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func onWeekday(days []string, now time.Time) bool {
+	for _, d := range days {
+		if weekdays[strings.ToLower(d)] == now.Weekday() { return true }
+	}
+	return false
+}
+
+// inClock aceita intervalos que cruzam a meia-noite (ex: 22:00-06:00).
+func inClock(start, end string, now time.Time) bool {
+	s, _ := clock(start)
+	e, _ := clock(end)
+	m := now.Hour()*60 + now.Minute()
+	if s <= e { return m >= s && m < e }
+	return m >= s || m < e
+}
+
+func clock(hhmm string) (int, error) {
+	t, err := time.Parse("15:04", hhmm)
+	if err != nil { return 0, fmt.Errorf("invalid time %q (want HH:MM)", hhmm) }
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func scoped(globs []string, v string) bool {
+	if len(globs) == 0 { return true }
+	for _, g := range globs {
+		if glob(g, v) { return true }
+	}
+	return false
+}
+
+func glob(pattern, v string) bool {
+	if pattern == "" || pattern == "*" { return true }
+	ok, _ := path.Match(pattern, v)
+	return ok
+}
+
+func contains(arr []string, v string) bool {
+	for _, a := range arr {
+		if a == v { return true }
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+func at(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil { panic(err) }
+	return t
+}
+
+func TestInClock(t *testing.T) {
+	tests := []struct {
+		start, end, now string
+		want            bool
+	}{
+		{"09:00", "18:00", "09:00", true},
+		{"09:00", "18:00", "17:59", true},
+		{"09:00", "18:00", "18:00", false},
+		{"09:00", "18:00", "08:59", false},
+		{"22:00", "06:00", "22:00", true}, // cruza a meia-noite
+		{"22:00", "06:00", "23:30", true},
+		{"22:00", "06:00", "00:00", true},
+		{"22:00", "06:00", "05:59", true},
+		{"22:00", "06:00", "06:00", false},
+		{"22:00", "06:00", "12:00", false},
+	}
+	for _, tt := range tests {
+		now := at("2024-05-03T" + tt.now + ":00Z")
+		if got := inClock(tt.start, tt.end, now); got != tt.want { t.Errorf("inClock(%s-%s, %s)=%v want %v", tt.start, tt.end, tt.now, got, tt.want) }
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	release := config.Window{Name: "release", From: at("2024-12-20T00:00:00Z"), To: at("2025-01-02T00:00:00Z")}
+	nights := config.Window{Name: "nights", Start: "22:00", End: "06:00", Weekdays: []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}}
+	weekend := config.Window{Name: "weekend", Weekdays: []string{"Sat", "sun"}}
+	tests := []struct {
+		name string
+		w    config.Window
+		now  string
+		want bool
+	}{
+		{"antes do período", release, "2024-12-19T23:59:00Z", false},
+		{"dentro do período", release, "2024-12-25T12:00:00Z", true},
+		{"fim é exclusivo", release, "2025-01-02T00:00:00Z", false},
+		{"noite antes da meia-noite", nights, "2024-05-03T23:00:00Z", true},
+		{"noite depois da meia-noite", nights, "2024-05-04T03:00:00Z", true},
+		{"de dia", nights, "2024-05-04T12:00:00Z", false},
+		{"sábado", weekend, "2024-05-04T12:00:00Z", true},
+		{"sexta", weekend, "2024-05-03T12:00:00Z", false},
+	}
+	for _, tt := range tests {
+		if got := inWindow(tt.w, at(tt.now)); got != tt.want { t.Errorf("%s: inWindow=%v want %v", tt.name, got, tt.want) }
+	}
+}
+
+func TestImageTag(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                          "",
+		"nginx:1.25":                     "1.25",
+		"nginx:latest":                   "latest",
+		"registry:5000/acme/web":         "",
+		"registry:5000/acme/web:v2":      "v2",
+		"ghcr.io/acme/web@sha256:abc":    "@sha256:abc",
+		"ghcr.io/acme/web:v1@sha256:abc": "@sha256:abc",
+	}
+	for image, want := range tests {
+		if got := imageTag(image); got != want { t.Errorf("imageTag(%q)=%q want %q", image, got, want) }
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	e, err := New(config.Policy{
+		Timezone: "America/Sao_Paulo",
+		Freezes: []config.Window{
+			{Name: "black-friday", Namespaces: []string{"shop-*"}, From: at("2024-11-29T00:00:00-03:00"), To: at("2024-11-30T00:00:00-03:00")},
+		},
+		BusinessHours: config.BusinessHours{Namespaces: []string{"prod"}, Start: "09:00", End: "18:00"},
+		Images:        config.ImagePolicy{AllowedRegistries: []string{"ghcr.io/acme/"}, DenyLatest: true},
+	})
+	if err != nil { t.Fatal(err) }
+	bf := at("2024-11-29T12:00:00-03:00") // sexta, dentro do freeze e do horário comercial
+	night := at("2024-05-03T23:00:00Z")   // sexta, 20:00 em São Paulo
+	expired := store.Override{ID: "old", Namespace: "*", App: "*", ExpiresAt: bf.Add(-time.Minute)}
+	freezeOnly := store.Override{ID: "ov-freeze", Namespace: "shop-*", App: "web", Rules: []string{RuleFreeze}, ExpiresAt: bf.Add(time.Hour)}
+	all := store.Override{ID: "ov-all", Namespace: "*", App: "*", ExpiresAt: night.Add(time.Hour)}
+
+	tests := []struct {
+		name      string
+		in        Input
+		now       time.Time
+		overrides []store.Override
+		want      Decision
+	}{
+		{"liberado", Input{"dev", "web", "ghcr.io/acme/web:v1"}, bf, nil, Decision{Allowed: true}},
+		{"registry fora da lista", Input{"dev", "web", "docker.io/acme/web:v1"}, bf, nil, Decision{Rule: RuleImages}},
+		{"latest", Input{"dev", "web", "ghcr.io/acme/web:latest"}, bf, nil, Decision{Rule: RuleImages}},
+		{"sem tag", Input{"dev", "web", "ghcr.io/acme/web"}, bf, nil, Decision{Rule: RuleImages}},
+		{"digest conta como fixado", Input{"dev", "web", "ghcr.io/acme/web@sha256:abc"}, bf, nil, Decision{Allowed: true}},
+		{"freeze", Input{"shop-eu", "web", "ghcr.io/acme/web:v1"}, bf, nil, Decision{Rule: RuleFreeze}},
+		{"freeze em outro namespace", Input{"billing", "web", "ghcr.io/acme/web:v1"}, bf, nil, Decision{Allowed: true}},
+		{"override do freeze", Input{"shop-eu", "web", "ghcr.io/acme/web:v1"}, bf, []store.Override{freezeOnly}, Decision{Allowed: true, Override: "ov-freeze"}},
+		{"override do freeze não cobre imagem", Input{"shop-eu", "web", "ghcr.io/acme/web:latest"}, bf, []store.Override{freezeOnly}, Decision{Rule: RuleImages}},
+		{"override de outro app", Input{"shop-eu", "api", "ghcr.io/acme/api:v1"}, bf, []store.Override{freezeOnly}, Decision{Rule: RuleFreeze}},
+		{"override expirado", Input{"shop-eu", "web", "ghcr.io/acme/web:v1"}, bf, []store.Override{expired}, Decision{Rule: RuleFreeze}},
+		{"horário comercial (fuso da policy)", Input{"prod", "web", "ghcr.io/acme/web:v1"}, night, nil, Decision{Allowed: true, RequireApproval: true, Rule: RuleBusinessHours}},
+		{"dentro do horário", Input{"prod", "web", "ghcr.io/acme/web:v1"}, bf, nil, Decision{Allowed: true}},
+		{"override sem regras cobre todas", Input{"prod", "web", "docker.io/x:latest"}, night, []store.Override{all}, Decision{Allowed: true, Override: "ov-all"}},
+	}
+	for _, tt := range tests {
+		got := e.Evaluate(tt.in, tt.now, tt.overrides)
+		got.Reason = ""
+		if got != tt.want { t.Errorf("%s: %+v want %+v", tt.name, got, tt.want) }
+	}
+
+	if _, err := New(config.Policy{Freezes: []config.Window{{Name: "x", Weekdays: []string{"someday"}}}}); err == nil { t.Error("weekday inválido aceito") }
+	if _, err := New(config.Policy{Freezes: []config.Window{{Name: "x", From: bf}}}); err == nil { t.Error("from sem to aceito") }
+	if _, err := New(config.Policy{BusinessHours: config.BusinessHours{Namespaces: []string{"prod"}, Start: "9h"}}); err == nil { t.Error("start inválido aceito") }
+}
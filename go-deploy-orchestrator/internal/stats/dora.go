@@ -0,0 +1,81 @@
+package stats
+
+import (
+	"sort"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+// AppStats são as métricas DORA de um app numa janela de tempo.
+//   - DeploysPerDay: deploys com sucesso por dia (deployment frequency)
+//   - ChangeFailureRate: rolled_back+failed / deploys finalizados
+//   - MTTRSeconds: média entre o início de um deploy que falhou e o fim do
+//     próximo deploy com sucesso do mesmo app (mean time to recovery)
+type AppStats struct {
+	Namespace         string  `json:"namespace"`
+	App               string  `json:"app"`
+	Deploys           int     `json:"deploys"`
+	Succeeded         int     `json:"succeeded"`
+	Failed            int     `json:"failed"`
+	DeploysPerDay     float64 `json:"deploysPerDay"`
+	ChangeFailureRate float64 `json:"changeFailureRate"`
+	Recoveries        int     `json:"recoveries"`
+	MTTRSeconds       float64 `json:"mttrSeconds"`
+}
+
+type Report struct {
+	Window string     `json:"window"`
+	Since  time.Time  `json:"since"`
+	Apps   []AppStats `json:"apps"`
+}
+
+// Compute agrupa os registros por namespace/app. recs deve estar em ordem de
+// StartedAt (como devolvido por store.Since); deploys ainda em andamento só
+// contam em Deploys.
+func Compute(recs []store.DeployRecord, window time.Duration, now time.Time) Report {
+	type acc struct {
+		AppStats
+		failedAt *time.Time
+		recovery time.Duration
+	}
+	byApp := map[string]*acc{}
+	var keys []string
+	for _, r := range recs {
+		key := r.Namespace + "/" + r.App
+		a := byApp[key]
+		if a == nil {
+			a = &acc{AppStats: AppStats{Namespace: r.Namespace, App: r.App}}
+			byApp[key] = a
+			keys = append(keys, key)
+		}
+		a.Deploys++
+		switch r.Status {
+		case "succeeded":
+			a.Succeeded++
+			if a.failedAt != nil && r.FinishedAt != nil {
+				a.recovery += r.FinishedAt.Sub(*a.failedAt)
+				a.Recoveries++
+				a.failedAt = nil
+			}
+		case "rolled_back", "failed":
+			a.Failed++
+			if a.failedAt == nil {
+				t := r.StartedAt
+				a.failedAt = &t
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	rep := Report{Window: window.String(), Since: now.Add(-window), Apps: []AppStats{}}
+	days := window.Hours() / 24
+	for _, k := range keys {
+		a := byApp[k]
+		if days > 0 { a.DeploysPerDay = float64(a.Succeeded) / days }
+		if n := a.Succeeded + a.Failed; n > 0 { a.ChangeFailureRate = float64(a.Failed) / float64(n) }
+		if a.Recoveries > 0 { a.MTTRSeconds = a.recovery.Seconds() / float64(a.Recoveries) }
+		rep.Apps = append(rep.Apps, a.AppStats)
+	}
+	return rep
+}
@@ -0,0 +1,34 @@
+package stats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/store"
+)
+
+func TestCompute(t *testing.T) {
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rec := func(app, status string, start, dur time.Duration) store.DeployRecord {
+		fin := t0.Add(start + dur)
+		return store.DeployRecord{App: app, Namespace: "prod", Status: status, StartedAt: t0.Add(start), FinishedAt: &fin}
+	}
+	recs := []store.DeployRecord{
+		rec("api", "succeeded", 0, 5*time.Minute),
+		rec("api", "rolled_back", time.Hour, 5*time.Minute),
+		rec("api", "failed", 2*time.Hour, 5*time.Minute),
+		rec("api", "succeeded", 3*time.Hour, 10*time.Minute), // recupera 2h10m após a 1ª falha
+		rec("web", "succeeded", 0, time.Minute),
+		{App: "web", Namespace: "prod", Status: "running", StartedAt: t0.Add(time.Hour)},
+	}
+	rep := Compute(recs, 2*24*time.Hour, t0.Add(4*time.Hour))
+	if len(rep.Apps) != 2 { t.Fatalf("apps=%d want 2", len(rep.Apps)) }
+
+	api, web := rep.Apps[0], rep.Apps[1]
+	if api.App != "api" || api.Deploys != 4 || api.Succeeded != 2 || api.Failed != 2 { t.Fatalf("api=%+v", api) }
+	if api.DeploysPerDay != 1 { t.Fatalf("api deploysPerDay=%v want 1", api.DeploysPerDay) }
+	if api.ChangeFailureRate != 0.5 { t.Fatalf("api cfr=%v want 0.5", api.ChangeFailureRate) }
+	if api.Recoveries != 1 || api.MTTRSeconds != (2*time.Hour+10*time.Minute).Seconds() { t.Fatalf("api mttr=%v recoveries=%d", api.MTTRSeconds, api.Recoveries) }
+
+	if web.Deploys != 2 || web.Succeeded != 1 || web.ChangeFailureRate != 0 || web.MTTRSeconds != 0 { t.Fatalf("web=%+v", web) }
+}
@@ -1,7 +1,9 @@
 package store
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -9,15 +11,22 @@ import (
 
 var (
 	bDeploys = []byte("deploys") // id -> DeployRecord
+	bEvents  = []byte("events")  // id -> (seq -> Event)
+	bSnapshots = []byte("snapshots") // id -> snapshot do target após o deploy (JSON)
 )
 
+var ErrLockLost = errors.New("deploy lock lost")
+
 type Store struct{ db *bolt.DB }
 
 func Open(path string) (*Store, error) {
 	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
 	if err != nil { return nil, err }
 	err = db.Update(func(tx *bolt.Tx) error {
-		_, e := tx.CreateBucketIfNotExists(bDeploys); return e
+		for _, b := range [][]byte{bDeploys, bEvents, bSnapshots, bLocks, bOverrides, bIdxTime, bIdxApp, bIdxNS} {
+			if _, e := tx.CreateBucketIfNotExists(b); e != nil { return e }
+		}
+		return reindex(tx)
 	})
 	if err != nil { _ = db.Close(); return nil, err }
 	return &Store{db: db}, nil
@@ -30,18 +39,69 @@ type DeployRecord struct {
 	Namespace string            `json:"namespace"`
 	ImageNew  string            `json:"imageNew"`
 	ImageOld  string            `json:"imageOld"`
+	RevisionOld string          `json:"revisionOld,omitempty"` // revision do target (release Helm, Deployment...) antes do deploy
+	Revision    string          `json:"revision,omitempty"`    // revision do target após o deploy com sucesso
+	RollbackOf  string          `json:"rollbackOf,omitempty"`  // deploy cujo snapshot este rollback restaura
 	Strategy  string            `json:"strategy"`
-	Status    string            `json:"status"` // started|waiting_approval|running|succeeded|failed|rolled_back
+	Status    string            `json:"status"` // queued|started|waiting_approval|running|succeeded|failed|rolled_back|aborted
 	Reason    string            `json:"reason,omitempty"`
 	StartedAt time.Time         `json:"startedAt"`
 	FinishedAt *time.Time       `json:"finishedAt,omitempty"`
 	Params    map[string]string `json:"params"`
+	RequestedBy string          `json:"requestedBy,omitempty"`
+	RequireApproval bool        `json:"requireApproval,omitempty"` // o deploy espera /approve antes de rodar (ver Resume)
+	ApprovedBy  string          `json:"approvedBy,omitempty"`
+	Waves       []Wave          `json:"waves,omitempty"`    // deploy multi-cluster: waves na ordem
+	Clusters    []ClusterStatus `json:"clusters,omitempty"` // andamento por cluster (deploys com waves)
+}
+
+// Wave é uma etapa de um deploy multi-cluster (ver config.Wave).
+type Wave struct {
+	Name     string   `json:"name"`
+	Clusters []string `json:"clusters"`
+	BakeTime string   `json:"bakeTime,omitempty"` // duração Go (ex: 30m)
+	Analysis string   `json:"analysis,omitempty"`
+}
+
+// ClusterStatus é o andamento de um deploy multi-cluster num cluster.
+type ClusterStatus struct {
+	Cluster     string     `json:"cluster"`
+	Wave        string     `json:"wave"`
+	Status      string     `json:"status"` // pending|running|baking|succeeded|failed|rolled_back
+	Reason      string     `json:"reason,omitempty"`
+	ImageOld    string     `json:"imageOld,omitempty"`
+	RevisionOld string     `json:"revisionOld,omitempty"`
+	Revision    string     `json:"revision,omitempty"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Finished indica se o deploy chegou a um status final.
+func (r DeployRecord) Finished() bool {
+	return r.Status == "succeeded" || r.Status == "failed" || r.Status == "rolled_back" || r.Status == "aborted"
+}
+
+// Event é uma entrada da timeline (append-only) de um deploy.
+type Event struct {
+	Seq     uint64            `json:"seq"`
+	Time    time.Time         `json:"time"`
+	Type    string            `json:"type"` // status|lock|policy|image|wave|cluster|step_started|step_finished|scale|partition|nodes|analysis|hook|approval|abort|rollback
+	Step    string            `json:"step,omitempty"`
+	Message string            `json:"message"`
+	Data    map[string]string `json:"data,omitempty"`
 }
 
 func (s *Store) Put(rec DeployRecord) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
+		bk := tx.Bucket(bDeploys)
+		var old *DeployRecord
+		if v := bk.Get([]byte(rec.ID)); v != nil {
+			var o DeployRecord
+			if json.Unmarshal(v, &o) == nil { old = &o }
+		}
 		b, _ := json.Marshal(rec)
-		return tx.Bucket(bDeploys).Put([]byte(rec.ID), b)
+		if err := bk.Put([]byte(rec.ID), b); err != nil { return err }
+		return putIndexes(tx, old, rec)
 	})
 }
 
@@ -68,4 +128,57 @@ func (s *Store) List() ([]DeployRecord, error) {
 		return nil
 	})
 	return arr, err
-}
\ No newline at end of file
+}
+
+// AppendEvent grava o evento no fim da timeline do deploy e devolve com Seq/Time preenchidos.
+func (s *Store) AppendEvent(id string, ev Event) (Event, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(bEvents).CreateBucketIfNotExists([]byte(id))
+		if err != nil { return err }
+		seq, err := b.NextSequence()
+		if err != nil { return err }
+		ev.Seq = seq
+		if ev.Time.IsZero() { ev.Time = time.Now() }
+		v, _ := json.Marshal(ev)
+		return b.Put(seqKey(seq), v)
+	})
+	return ev, err
+}
+
+// Events devolve os eventos do deploy com Seq > after, em ordem.
+func (s *Store) Events(id string, after uint64) ([]Event, error) {
+	arr := []Event{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bEvents).Bucket([]byte(id))
+		if b == nil { return nil }
+		c := b.Cursor()
+		for k, v := c.Seek(seqKey(after + 1)); k != nil; k, v = c.Next() {
+			var ev Event
+			if json.Unmarshal(v, &ev) == nil { arr = append(arr, ev) }
+		}
+		return nil
+	})
+	return arr, err
+}
+
+// PutSnapshot guarda o estado do target deixado pelo deploy id, ponto de
+// retorno de rollbacks manuais.
+func (s *Store) PutSnapshot(id string, snap json.RawMessage) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return tx.Bucket(bSnapshots).Put([]byte(id), snap) })
+}
+
+// Snapshot devolve o snapshot gravado por PutSnapshot (nil se não houver).
+func (s *Store) Snapshot(id string) (json.RawMessage, error) {
+	var out json.RawMessage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bSnapshots).Get([]byte(id)); v != nil { out = append(out, v...) }
+		return nil
+	})
+	return out, err
+}
+
+func seqKey(seq uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, seq)
+	return k
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEventsRoundTrip(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil { t.Fatal(err) }
+	defer s.Close()
+
+	// mais de 255 eventos: a chave big-endian mantém a ordem numérica no cursor
+	for i := 0; i < 300; i++ {
+		ev, err := s.AppendEvent("d1", Event{Type: "scale", Step: "scale_1", Message: "scaled", Data: map[string]string{"replicas": "1"}})
+		if err != nil { t.Fatal(err) }
+		if ev.Seq != uint64(i+1) || ev.Time.IsZero() { t.Fatalf("append %d = %+v", i, ev) }
+	}
+	if _, err := s.AppendEvent("d2", Event{Type: "status", Message: "deploy started"}); err != nil { t.Fatal(err) }
+
+	evs, err := s.Events("d1", 0)
+	if err != nil { t.Fatal(err) }
+	if len(evs) != 300 { t.Fatalf("len = %d", len(evs)) }
+	for i, ev := range evs {
+		if ev.Seq != uint64(i+1) || ev.Type != "scale" || ev.Step != "scale_1" || ev.Data["replicas"] != "1" { t.Fatalf("event %d = %+v", i, ev) }
+	}
+
+	tests := []struct {
+		id    string
+		after uint64
+		first uint64
+		n     int
+	}{
+		{"d1", 255, 256, 45},
+		{"d1", 300, 0, 0},
+		{"d2", 0, 1, 1}, // sequência própria por deploy
+		{"nope", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		evs, err := s.Events(tt.id, tt.after)
+		if err != nil { t.Fatal(err) }
+		if evs == nil { t.Fatalf("%s: nil em vez de lista vazia (vira null no JSON)", tt.id) }
+		if len(evs) != tt.n || (tt.n > 0 && evs[0].Seq != tt.first) { t.Errorf("Events(%s, %d) = %d eventos, primeiro %+v", tt.id, tt.after, len(evs), evs) }
+	}
+}
@@ -0,0 +1,157 @@
+package store
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Índices secundários: a chave termina em startedAt(8 bytes, big-endian) + id,
+// então o cursor do bolt percorre cada prefixo em ordem cronológica.
+var (
+	bIdxTime = []byte("idx_time") // ts|id
+	bIdxApp  = []byte("idx_app")  // app\0ts|id
+	bIdxNS   = []byte("idx_ns")   // namespace\0ts|id
+)
+
+var ErrBadCursor = errors.New("invalid cursor")
+
+const (
+	DefaultListLimit = 50
+	MaxListLimit     = 500
+)
+
+// ListQuery filtra o histórico. Resultados vêm do mais novo para o mais antigo.
+type ListQuery struct {
+	App       string
+	Namespace string
+	Status    string
+	Since     time.Time
+	Limit     int    // 0 = DefaultListLimit
+	Cursor    string // NextCursor da página anterior
+	// Filter é aplicado antes da paginação (ex: autorização por app).
+	Filter func(DeployRecord) bool
+}
+
+type ListPage struct {
+	Items      []DeployRecord `json:"items"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+func indexKeys(rec DeployRecord) map[string][]byte {
+	suffix := make([]byte, 8, 8+len(rec.ID))
+	binary.BigEndian.PutUint64(suffix, uint64(rec.StartedAt.UnixNano()))
+	suffix = append(suffix, rec.ID...)
+	return map[string][]byte{
+		string(bIdxTime): suffix,
+		string(bIdxApp):  prefixed(rec.App, suffix),
+		string(bIdxNS):   prefixed(rec.Namespace, suffix),
+	}
+}
+
+func prefixed(p string, suffix []byte) []byte {
+	k := make([]byte, 0, len(p)+1+len(suffix))
+	k = append(k, p...)
+	k = append(k, 0)
+	return append(k, suffix...)
+}
+
+// putIndexes troca as entradas de índice de old (se houver) pelas de rec.
+func putIndexes(tx *bolt.Tx, old *DeployRecord, rec DeployRecord) error {
+	if old != nil {
+		for b, k := range indexKeys(*old) {
+			if err := tx.Bucket([]byte(b)).Delete(k); err != nil { return err }
+		}
+	}
+	for b, k := range indexKeys(rec) {
+		if err := tx.Bucket([]byte(b)).Put(k, []byte(rec.ID)); err != nil { return err }
+	}
+	return nil
+}
+
+// reindex reconstrói os índices a partir de bDeploys (bancos anteriores aos índices).
+func reindex(tx *bolt.Tx) error {
+	if k, _ := tx.Bucket(bIdxTime).Cursor().First(); k != nil { return nil }
+	return tx.Bucket(bDeploys).ForEach(func(_, v []byte) error {
+		var r DeployRecord
+		if json.Unmarshal(v, &r) != nil { return nil }
+		return putIndexes(tx, nil, r)
+	})
+}
+
+// Query devolve uma página do histórico usando o índice mais seletivo.
+func (s *Store) Query(q ListQuery) (ListPage, error) {
+	page := ListPage{Items: []DeployRecord{}}
+	if q.Limit <= 0 { q.Limit = DefaultListLimit }
+	if q.Limit > MaxListLimit { q.Limit = MaxListLimit }
+	var after []byte
+	if q.Cursor != "" {
+		c, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+		if err != nil { return page, ErrBadCursor }
+		after = c
+	}
+
+	bucket, prefix := bIdxTime, []byte(nil)
+	switch {
+	case q.App != "": bucket, prefix = bIdxApp, prefixed(q.App, nil)
+	case q.Namespace != "": bucket, prefix = bIdxNS, prefixed(q.Namespace, nil)
+	}
+	if after != nil && !bytes.HasPrefix(after, prefix) { return page, ErrBadCursor }
+
+	var last []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		deploys := tx.Bucket(bDeploys)
+		c := tx.Bucket(bucket).Cursor()
+		for k, v := seekLast(c, prefix, after); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+			ts := int64(binary.BigEndian.Uint64(k[len(prefix):]))
+			if !q.Since.IsZero() && ts < q.Since.UnixNano() { break }
+			var r DeployRecord
+			if raw := deploys.Get(v); raw == nil || json.Unmarshal(raw, &r) != nil { continue }
+			if q.App != "" && r.App != q.App { continue }
+			if q.Namespace != "" && r.Namespace != q.Namespace { continue }
+			if q.Status != "" && r.Status != q.Status { continue }
+			if q.Filter != nil && !q.Filter(r) { continue }
+			if len(page.Items) == q.Limit {
+				page.NextCursor = base64.RawURLEncoding.EncodeToString(last)
+				break
+			}
+			page.Items = append(page.Items, r)
+			last = append(last[:0], k...)
+		}
+		return nil
+	})
+	return page, err
+}
+
+// seekLast posiciona o cursor na maior chave com o prefixo e estritamente
+// menor que after (quando informado).
+func seekLast(c *bolt.Cursor, prefix, after []byte) ([]byte, []byte) {
+	upper := after
+	if upper == nil { upper = append(append([]byte(nil), prefix...), bytes.Repeat([]byte{0xff}, 9)...) }
+	k, v := c.Seek(upper)
+	if k == nil { return c.Last() }
+	if bytes.Compare(k, upper) >= 0 { return c.Prev() }
+	return k, v
+}
+
+// Since devolve todos os deploys iniciados a partir de t, do mais antigo ao mais novo.
+func (s *Store) Since(t time.Time) ([]DeployRecord, error) {
+	arr := []DeployRecord{}
+	start := make([]byte, 8)
+	binary.BigEndian.PutUint64(start, uint64(t.UnixNano()))
+	err := s.db.View(func(tx *bolt.Tx) error {
+		deploys := tx.Bucket(bDeploys)
+		c := tx.Bucket(bIdxTime).Cursor()
+		for k, v := c.Seek(start); k != nil; k, v = c.Next() {
+			var r DeployRecord
+			if raw := deploys.Get(v); raw != nil && json.Unmarshal(raw, &r) == nil { arr = append(arr, r) }
+		}
+		return nil
+	})
+	return arr, err
+}
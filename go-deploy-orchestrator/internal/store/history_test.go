@@ -0,0 +1,56 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQueryPagination(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil { t.Fatal(err) }
+	defer s.Close()
+
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, app := range []string{"api", "web", "api", "api", "web", "api"} {
+		status := "succeeded"
+		if i == 3 { status = "rolled_back" }
+		rec := DeployRecord{ID: string(rune('a' + i)), App: app, Namespace: "prod", Status: status, StartedAt: t0.Add(time.Duration(i) * time.Minute)}
+		if err := s.Put(rec); err != nil { t.Fatal(err) }
+	}
+	// atualizar um registro não duplica entradas de índice
+	if err := s.Put(DeployRecord{ID: "f", App: "api", Namespace: "prod", Status: "succeeded", StartedAt: t0.Add(5 * time.Minute)}); err != nil { t.Fatal(err) }
+
+	ids := func(p ListPage) string {
+		out := ""
+		for _, r := range p.Items { out += r.ID }
+		return out
+	}
+	tests := []struct {
+		name string
+		q    ListQuery
+		want []string // ids de cada página
+	}{
+		{"all", ListQuery{Limit: 4}, []string{"fedc", "ba"}},
+		{"app", ListQuery{App: "api", Limit: 2}, []string{"fd", "ca"}},
+		{"status", ListQuery{Status: "rolled_back"}, []string{"d"}},
+		{"since", ListQuery{Namespace: "prod", Since: t0.Add(4 * time.Minute)}, []string{"fe"}},
+		{"filter", ListQuery{Limit: 1, Filter: func(r DeployRecord) bool { return r.App == "web" }}, []string{"e", "b"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		q := tt.q
+		for {
+			p, err := s.Query(q)
+			if err != nil { t.Fatalf("%s: %v", tt.name, err) }
+			got = append(got, ids(p))
+			if p.NextCursor == "" { break }
+			q.Cursor = p.NextCursor
+		}
+		if len(got) != len(tt.want) { t.Fatalf("%s: pages=%v want %v", tt.name, got, tt.want) }
+		for i := range got {
+			if got[i] != tt.want[i] { t.Fatalf("%s: pages=%v want %v", tt.name, got, tt.want) }
+		}
+	}
+	if _, err := s.Query(ListQuery{Cursor: "!!"}); err != ErrBadCursor { t.Fatalf("err=%v want ErrBadCursor", err) }
+}
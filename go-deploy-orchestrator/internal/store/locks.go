@@ -0,0 +1,80 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bLocks = []byte("locks") // ns/app -> Lock
+
+// Lock é o lock de deploy de um (namespace, app); Holder é o ID do deploy dono.
+type Lock struct {
+	Holder     string    `json:"holder"`
+	AcquiredAt time.Time `json:"acquiredAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+func lockKey(ns, app string) []byte { return []byte(ns + "/" + app) }
+
+// AcquireLock obtém o lock se estiver livre, expirado ou já for do holder.
+// Se outro deploy segura o lock, devolve (holderAtual, false).
+func (s *Store) AcquireLock(ns, app, holder string, ttl time.Duration) (string, bool, error) {
+	current, ok := holder, false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bLocks)
+		now := time.Now()
+		var l Lock
+		if v := b.Get(lockKey(ns, app)); v != nil && json.Unmarshal(v, &l) == nil &&
+			l.Holder != holder && now.Before(l.ExpiresAt) {
+			current = l.Holder
+			return nil
+		}
+		if l.Holder != holder { l.AcquiredAt = now }
+		l.Holder, l.ExpiresAt = holder, now.Add(ttl)
+		v, _ := json.Marshal(l)
+		ok = true
+		return b.Put(lockKey(ns, app), v)
+	})
+	return current, ok, err
+}
+
+// LockHolder devolve o holder do lock ou "" se estiver livre/expirado.
+func (s *Store) LockHolder(ns, app string) (string, error) {
+	var holder string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var l Lock
+		if v := tx.Bucket(bLocks).Get(lockKey(ns, app)); v != nil && json.Unmarshal(v, &l) == nil && time.Now().Before(l.ExpiresAt) {
+			holder = l.Holder
+		}
+		return nil
+	})
+	return holder, err
+}
+
+// RenewLock estende o TTL do lock; falha se o holder perdeu o lock.
+func (s *Store) RenewLock(ns, app, holder string, ttl time.Duration) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bLocks)
+		var l Lock
+		if v := b.Get(lockKey(ns, app)); v == nil || json.Unmarshal(v, &l) != nil || l.Holder != holder {
+			return ErrLockLost
+		}
+		l.ExpiresAt = time.Now().Add(ttl)
+		v, _ := json.Marshal(l)
+		return b.Put(lockKey(ns, app), v)
+	})
+}
+
+// ReleaseLock libera o lock se ainda pertencer ao holder.
+func (s *Store) ReleaseLock(ns, app, holder string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bLocks)
+		var l Lock
+		if v := b.Get(lockKey(ns, app)); v == nil || json.Unmarshal(v, &l) != nil || l.Holder != holder {
+			return nil
+		}
+		return b.Delete(lockKey(ns, app))
+	})
+}
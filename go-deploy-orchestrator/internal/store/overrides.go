@@ -0,0 +1,67 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var bOverrides = []byte("overrides") // id -> Override
+
+// Override libera temporariamente regras de policy para namespace/app (globs).
+type Override struct {
+	ID        string    `json:"id"`
+	Namespace string    `json:"namespace"`
+	App       string    `json:"app"`
+	Rules     []string  `json:"rules,omitempty"` // freeze|business_hours|images; vazio = todas
+	Reason    string    `json:"reason"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (o Override) Active(now time.Time) bool { return now.Before(o.ExpiresAt) }
+
+func (s *Store) PutOverride(o Override) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, _ := json.Marshal(o)
+		return tx.Bucket(bOverrides).Put([]byte(o.ID), b)
+	})
+}
+
+// GetOverride devolve nil quando o id não existe.
+func (s *Store) GetOverride(id string) (*Override, error) {
+	var o *Override
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(bOverrides).Get([]byte(id))
+		if v == nil { return nil }
+		o = &Override{}
+		return json.Unmarshal(v, o)
+	})
+	return o, err
+}
+
+func (s *Store) DeleteOverride(id string) (bool, error) {
+	found := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bOverrides)
+		found = b.Get([]byte(id)) != nil
+		return b.Delete([]byte(id))
+	})
+	return found, err
+}
+
+// ListOverrides devolve os overrides; com activeOnly, só os não expirados.
+func (s *Store) ListOverrides(activeOnly bool) ([]Override, error) {
+	arr := []Override{}
+	now := time.Now()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bOverrides).ForEach(func(_, v []byte) error {
+			var o Override
+			if json.Unmarshal(v, &o) == nil && (!activeOnly || o.Active(now)) { arr = append(arr, o) }
+			return nil
+		})
+	})
+	return arr, err
+}
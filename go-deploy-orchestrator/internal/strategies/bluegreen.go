@@ -6,29 +6,45 @@ import (
 	"time"
 
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
-	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
 )
 
 type BlueGreenParams struct {
-	ProbeWaitSec int
-	MaxError     float64
-	MaxP95       float64
+	ProbeWaitSec int     `json:"probeWaitSec"`
+	MaxError     float64 `json:"maxError"`
+	MaxP95       float64 `json:"maxP95"`
 }
 
-func RunBlueGreen(ctx context.Context, dep *k8s.Deployer, prom *prometheus.Evaluator, app, image string, p BlueGreenParams) error {
+func (p *BlueGreenParams) defaults() {
 	if p.ProbeWaitSec == 0 { p.ProbeWaitSec = 30 }
+}
+
+const (
+	blueGreenErrorQuery = `vector(0)`
+	blueGreenP95Query   = `vector(0)`
+)
+
+func RunBlueGreen(ctx context.Context, t k8s.Target, an *analysis.Analysis, app, image string, p BlueGreenParams, ev Emitter, after StepHook) error {
+	p.defaults()
 	// update image & rollout all replicas (blue->green swap simplificada: troca de template)
-	if err := dep.SetImage(ctx, app, app, image); err != nil { return err }
-	if err := dep.WaitRollout(ctx, app, 5*time.Minute); err != nil { return err }
+	ev.emit("step_started", "rollout", "blue-green rollout started", nil)
+	if err := t.SetImage(ctx, image); err != nil { return err }
+	ev.emit("image", "rollout", "image set to "+image, map[string]string{"image": image})
+	if err := t.WaitRollout(ctx, 5*time.Minute); err != nil { return err }
+	if err := after.run(ctx, "rollout"); err != nil { return err }
+	ev.emit("step_finished", "rollout", "blue-green rollout finished", nil)
 
-	time.Sleep(time.Duration(p.ProbeWaitSec) * time.Second)
+	ev.emit("step_started", "probe", "waiting "+itoa(p.ProbeWaitSec)+"s before analysis", nil)
+	if err := pause(ctx, p.ProbeWaitSec); err != nil { return err }
 
-	// checa SLOs (placeholder seguro)
-	er, _ := prom.QueryRange(ctx, `vector(0)`, "5m")
-	p95, _ := prom.QueryRange(ctx, `vector(0)`, "5m")
-	if (p.MaxError > 0 && er > p.MaxError) || (p.MaxP95 > 0 && p95 > p.MaxP95) {
-		return fmt.Errorf("SLO breach after blue-green (error=%.4f p95=%.3fs)", er, p95)
+	// checa SLOs (placeholder seguro) e o template de análise
+	r, data := analyze(ctx, an, "probe", blueGreenErrorQuery, blueGreenP95Query, p.MaxError, p.MaxP95)
+	ev.emit("analysis", "probe", analysisMsg(r.OK()), data)
+	if !r.OK() {
+		return fmt.Errorf("SLO breach after blue-green (%s)", r.Summary())
 	}
+	if err := after.run(ctx, "probe"); err != nil { return err }
+	ev.emit("step_finished", "probe", "blue-green probe finished", nil)
 	return nil
 }
 // RunBlueGreen executes a blue-green deployment strategy.
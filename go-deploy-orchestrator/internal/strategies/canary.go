@@ -8,54 +8,106 @@ import (
 
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
 	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
-	"github.com/viniciushammett/go-deploy-orchestrator/internal/prometheus"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
 )
 
 type CanaryParams struct {
-	StepPercent int
-	PauseSec    int
-	MaxError    float64
-	MaxP95      float64
+	StepPercent int     `json:"stepPercent"`
+	PauseSec    int     `json:"pauseSec"`
+	MaxError    float64 `json:"maxError"`
+	MaxP95      float64 `json:"maxP95"`
 }
 
-func RunCanary(ctx context.Context, dep *k8s.Deployer, prom *prometheus.Evaluator, app string, image string, params CanaryParams) error {
-	if params.StepPercent <= 0 { params.StepPercent = 20 }
-	if params.PauseSec <= 0 { params.PauseSec = 60 }
+func (p *CanaryParams) defaults() {
+	if p.StepPercent <= 0 { p.StepPercent = 20 }
+	if p.PauseSec <= 0 { p.PauseSec = 60 }
+}
+
+// Consultas de análise (placeholder seguro até as queries por app existirem).
+const (
+	canaryErrorQuery = `vector(0) + on() group_left() 0`
+	canaryP95Query   = `vector(0) + on() group_left() 0`
+	analysisRange    = "5m"
+)
+
+func RunCanary(ctx context.Context, t k8s.Target, an *analysis.Analysis, app string, image string, params CanaryParams, ev Emitter, after StepHook) error {
+	params.defaults()
 
 	// set image
-	if err := dep.SetImage(ctx, app, app, image); err != nil { return err }
+	if err := t.SetImage(ctx, image); err != nil { return err }
+	ev.emit("image", "", "image set to "+image, map[string]string{"image": image})
 
 	// discover replicas
-	d, err := dep.Get(ctx, app); if err != nil { return err }
-	replicas := int32(1)
-	if d.Spec.Replicas != nil { replicas = *d.Spec.Replicas }
+	replicas, err := t.Replicas(ctx); if err != nil { return err }
 
 	// progressive traffic: emulate by scaling up in steps (simple approach)
-	step := int32(max(1, (int(replicas) * params.StepPercent / 100)))
-	for cur := step; cur <= replicas; cur += step {
-		if cur > replicas { cur = replicas }
-		if err := dep.Scale(ctx, app, cur); err != nil { return err }
-		if err := dep.WaitRollout(ctx, app, 5*time.Minute); err != nil { return err }
-		metrics.StepDuration.WithLabelValues(app, "canary", "scale_"+itoa(int(cur))).Observe(float64(params.PauseSec))
+	for _, cur := range canarySteps(replicas, params.StepPercent) {
+		name := "scale_"+itoa(int(cur))
+		ev.emit("step_started", name, "canary step started", map[string]string{"replicas": itoa(int(cur)), "total": itoa(int(replicas))})
+		if err := t.Scale(ctx, cur); err != nil { return err }
+		ev.emit("scale", name, "scaled to "+itoa(int(cur))+" replicas", map[string]string{"replicas": itoa(int(cur))})
+		if err := t.WaitRollout(ctx, 5*time.Minute); err != nil { return err }
+		metrics.StepDuration.WithLabelValues(app, "canary", name).Observe(float64(params.PauseSec))
 
 		// check SLOs (if configured)
-		ok, er, p95 := passSLOs(ctx, prom, params)
-		if !ok {
-			return fmt.Errorf("SLO breach during canary (error=%.4f p95=%.3fs)", er, p95)
+		r, data := analyze(ctx, an, name, canaryErrorQuery, canaryP95Query, params.MaxError, params.MaxP95)
+		ev.emit("analysis", name, analysisMsg(r.OK()), data)
+		if !r.OK() {
+			return fmt.Errorf("SLO breach during canary (%s)", r.Summary())
 		}
-		time.Sleep(time.Duration(params.PauseSec) * time.Second)
+		if err := pause(ctx, params.PauseSec); err != nil { return err }
+		if err := after.run(ctx, name); err != nil { return err }
+		ev.emit("step_finished", name, "canary step finished", nil)
 	}
 	return nil
 }
 
-func passSLOs(ctx context.Context, prom *prometheus.Evaluator, p CanaryParams) (bool, float64, float64) {
-	er, _ := prom.QueryRange(ctx, `vector(0) + on() group_left() 0`, "5m") // placeholder safe
-	p95, _ := prom.QueryRange(ctx, `vector(0) + on() group_left() 0`, "5m")
-	// se tiver thresholds > 0, valida
-	if p.MaxError > 0 && er > p.MaxError { return false, er, p95 }
-	if p.MaxP95 > 0 && p95 > p.MaxP95 { return false, er, p95 }
-	return true, er, p95
+// canarySteps devolve as réplicas de cada etapa do canary.
+func canarySteps(replicas int32, stepPercent int) []int32 {
+	step := int32(max(1, (int(replicas) * stepPercent / 100)))
+	var out []int32
+	for cur := step; cur <= replicas; cur += step {
+		out = append(out, cur)
+	}
+	// replicas fora do múltiplo do passo: o último step sobe para a escala cheia
+	if n := len(out); n > 0 && out[n-1] < replicas { out = append(out, replicas) }
+	return out
+}
+
+// analyze avalia as SLOs da estratégia (errorRate/p95 no Prometheus; limite
+// 0 só registra) junto com as métricas do template de análise do app. Como
+// antes dos templates, um Prometheus fora do ar não reprova as SLOs da
+// estratégia: o erro fica no evento e a etapa segue.
+func analyze(ctx context.Context, an *analysis.Analysis, step, errQuery, p95Query string, maxError, maxP95 float64) (analysis.Result, map[string]string) {
+	r := an.Run(ctx, step,
+		config.Metric{Name: "errorRate", Provider: "prometheus", Query: errQuery, Range: analysisRange, Max: limit(maxError), OnError: analysis.OnErrorSkip},
+		config.Metric{Name: "p95", Provider: "prometheus", Query: p95Query, Range: analysisRange, Max: limit(maxP95), OnError: analysis.OnErrorSkip},
+	)
+	data := r.Data()
+	data["maxError"], data["maxP95"] = ftoa(maxError), ftoa(maxP95)
+	return r, data
+}
+
+func limit(v float64) *float64 {
+	if v <= 0 { return nil }
+	return &v
+}
+
+func analysisMsg(ok bool) string {
+	if ok { return "analysis passed" }
+	return "analysis failed"
 }
 
 func max(a,b int) int { if a>b {return a}; return b }
-func itoa(v int) string { return strconv.Itoa(v) }
\ No newline at end of file
+func itoa(v int) string { return strconv.Itoa(v) }
+func ftoa(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+// pause espera sec segundos ou até o deploy ser abortado.
+func pause(ctx context.Context, sec int) error {
+	t := time.NewTimer(time.Duration(sec) * time.Second)
+	defer t.Stop()
+	select {
+	case <-ctx.Done(): return context.Cause(ctx)
+	case <-t.C: return nil
+	}
+}
@@ -0,0 +1,19 @@
+package strategies
+
+import "context"
+
+// Emitter recebe os eventos de progresso das estratégias (ver store.Event).
+type Emitter func(typ, step, msg string, data map[string]string)
+
+func (e Emitter) emit(typ, step, msg string, data map[string]string) {
+	if e != nil { e(typ, step, msg, data) }
+}
+
+// StepHook roda ao fim de cada etapa, antes do step_finished (hooks
+// postStep); um erro interrompe a estratégia e o deploy faz rollback.
+type StepHook func(ctx context.Context, step string) error
+
+func (h StepHook) run(ctx context.Context, step string) error {
+	if h == nil { return nil }
+	return h(ctx, step)
+}
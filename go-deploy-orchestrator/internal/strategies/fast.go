@@ -0,0 +1,45 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
+)
+
+// FastParams: troca tudo de uma vez e analisa após ProbeWaitSec.
+type FastParams struct {
+	ProbeWaitSec int     `json:"probeWaitSec"`
+	MaxError     float64 `json:"maxError"`
+	MaxP95       float64 `json:"maxP95"`
+}
+
+func (p *FastParams) defaults() {
+	if p.ProbeWaitSec <= 0 { p.ProbeWaitSec = 30 }
+}
+
+// RunFast aplica a imagem (ou o snapshot, num rollback) em todas as réplicas,
+// espera o rollout e faz uma única análise. Usada nos rollbacks manuais
+// quando a prioridade é voltar rápido.
+func RunFast(ctx context.Context, t k8s.Target, an *analysis.Analysis, app, image string, p FastParams, ev Emitter, after StepHook) error {
+	p.defaults()
+	ev.emit("step_started", "rollout", "fast rollout started", nil)
+	if err := t.SetImage(ctx, image); err != nil { return err }
+	ev.emit("image", "rollout", "image set to "+image, map[string]string{"image": image})
+	if err := t.WaitRollout(ctx, 5*time.Minute); err != nil { return err }
+	if err := after.run(ctx, "rollout"); err != nil { return err }
+	ev.emit("step_finished", "rollout", "fast rollout finished", nil)
+
+	ev.emit("step_started", "probe", "waiting "+itoa(p.ProbeWaitSec)+"s before analysis", nil)
+	if err := pause(ctx, p.ProbeWaitSec); err != nil { return err }
+	r, data := analyze(ctx, an, "probe", canaryErrorQuery, canaryP95Query, p.MaxError, p.MaxP95)
+	ev.emit("analysis", "probe", analysisMsg(r.OK()), data)
+	if !r.OK() {
+		return fmt.Errorf("SLO breach after fast rollout (%s)", r.Summary())
+	}
+	if err := after.run(ctx, "probe"); err != nil { return err }
+	ev.emit("step_finished", "probe", "fast probe finished", nil)
+	return nil
+}
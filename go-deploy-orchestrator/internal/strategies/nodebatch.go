@@ -0,0 +1,69 @@
+package strategies
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
+)
+
+type NodeBatchParams struct {
+	Label          string  `json:"label"`          // label de nó que define os lotes (ex: topology.kubernetes.io/zone)
+	MaxUnavailable string  `json:"maxUnavailable"` // por lote: número ou porcentagem dos nós do lote
+	PauseSec       int     `json:"pauseSec"`
+	MaxError       float64 `json:"maxError"`
+	MaxP95         float64 `json:"maxP95"`
+}
+
+func (p *NodeBatchParams) defaults() {
+	if p.MaxUnavailable == "" { p.MaxUnavailable = "1" }
+	if p.PauseSec <= 0 { p.PauseSec = 60 }
+}
+
+// RunNodeBatches atualiza um DaemonSet um lote de nós por vez (nós agrupados
+// pelo valor de Label), com no máximo MaxUnavailable pods recriados ao mesmo
+// tempo e análise entre os lotes.
+func RunNodeBatches(ctx context.Context, t k8s.NodeBatched, an *analysis.Analysis, app, image string, p NodeBatchParams, ev Emitter, after StepHook) error {
+	p.defaults()
+	if p.Label == "" { return errors.New("node batch rollout requires the nodeLabel param") }
+	batches, err := t.Batches(ctx, p.Label)
+	if err != nil { return err }
+
+	if err := t.PrepareImage(ctx, image); err != nil { return err }
+	ev.emit("image", "", "image set to "+image+" (OnDelete, "+itoa(len(batches))+" node batches by "+p.Label+")", map[string]string{"image": image, "batches": itoa(len(batches))})
+
+	for _, b := range batches {
+		name := "nodes_" + b.Value
+		if b.Value == "" { name = "nodes_unlabeled" }
+		maxUnavailable, err := scaledMaxUnavailable(p.MaxUnavailable, len(b.Nodes))
+		if err != nil { return err }
+		ev.emit("step_started", name, "node batch started", map[string]string{"label": p.Label, "value": b.Value, "nodes": itoa(len(b.Nodes)), "maxUnavailable": itoa(maxUnavailable)})
+		if err := t.UpdateNodes(ctx, b.Nodes, maxUnavailable, 10*time.Minute); err != nil { return err }
+		ev.emit("nodes", name, "updated "+itoa(len(b.Nodes))+" nodes", map[string]string{"nodes": itoa(len(b.Nodes))})
+		metrics.StepDuration.WithLabelValues(app, "canary", name).Observe(float64(p.PauseSec))
+
+		r, data := analyze(ctx, an, name, canaryErrorQuery, canaryP95Query, p.MaxError, p.MaxP95)
+		ev.emit("analysis", name, analysisMsg(r.OK()), data)
+		if !r.OK() {
+			return fmt.Errorf("SLO breach during node batch %s (%s)", name, r.Summary())
+		}
+		if err := pause(ctx, p.PauseSec); err != nil { return err }
+		if err := after.run(ctx, name); err != nil { return err }
+		ev.emit("step_finished", name, "node batch finished", nil)
+	}
+	return t.Finish(ctx)
+}
+
+// scaledMaxUnavailable resolve "2" ou "25%" contra o tamanho do lote (mínimo 1).
+func scaledMaxUnavailable(v string, nodes int) (int, error) {
+	iv := intstr.Parse(v)
+	n, err := intstr.GetScaledValueFromIntOrPercent(&iv, nodes, false)
+	if err != nil { return 0, fmt.Errorf("invalid maxUnavailable %q: %w", v, err) }
+	return max(1, n), nil
+}
@@ -0,0 +1,50 @@
+package strategies
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/k8s"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/metrics"
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/analysis"
+)
+
+// RunPartitionedCanary é o canary de StatefulSet: o template novo entra com
+// partition = replicas e a partição desce a cada etapa (ordinais mais altos
+// primeiro), com análise e pausa entre etapas.
+func RunPartitionedCanary(ctx context.Context, t k8s.Partitioned, an *analysis.Analysis, app, image string, params CanaryParams, ev Emitter, after StepHook) error {
+	params.defaults()
+	replicas, err := t.Replicas(ctx); if err != nil { return err }
+
+	if err := t.PrepareImage(ctx, image); err != nil { return err }
+	ev.emit("image", "", "image set to "+image+" with partition "+itoa(int(replicas)), map[string]string{"image": image, "partition": itoa(int(replicas))})
+
+	for _, cur := range partitionSteps(replicas, params.StepPercent) {
+		partition := replicas - cur
+		name := "partition_" + itoa(int(partition))
+		ev.emit("step_started", name, "partition step started", map[string]string{"partition": itoa(int(partition)), "updated": itoa(int(cur)), "total": itoa(int(replicas))})
+		if err := t.SetPartition(ctx, partition); err != nil { return err }
+		ev.emit("partition", name, "partition lowered to "+itoa(int(partition))+" ("+itoa(int(cur))+"/"+itoa(int(replicas))+" pods updated)", map[string]string{"partition": itoa(int(partition))})
+		if err := t.WaitRollout(ctx, 5*time.Minute); err != nil { return err }
+		metrics.StepDuration.WithLabelValues(app, "canary", name).Observe(float64(params.PauseSec))
+
+		r, data := analyze(ctx, an, name, canaryErrorQuery, canaryP95Query, params.MaxError, params.MaxP95)
+		ev.emit("analysis", name, analysisMsg(r.OK()), data)
+		if !r.OK() {
+			return fmt.Errorf("SLO breach during partitioned canary (%s)", r.Summary())
+		}
+		if err := pause(ctx, params.PauseSec); err != nil { return err }
+		if err := after.run(ctx, name); err != nil { return err }
+		ev.emit("step_finished", name, "partition step finished", nil)
+	}
+	return nil
+}
+
+// partitionSteps são as etapas do canary; sem réplicas ainda há uma etapa
+// (partição 0), que troca o template.
+func partitionSteps(replicas int32, stepPercent int) []int32 {
+	steps := canarySteps(replicas, stepPercent)
+	if len(steps) == 0 { steps = []int32{replicas} }
+	return steps
+}
@@ -0,0 +1,64 @@
+package strategies
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+// PlanStep descreve uma etapa que Run* executaria, sem tocar no cluster.
+// Name é o mesmo "step" dos eventos da timeline.
+type PlanStep struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Image       string          `json:"image,omitempty"`
+	Replicas    int32           `json:"replicas,omitempty"`
+	Total       int32           `json:"total,omitempty"`
+	PauseSec    int             `json:"pauseSec,omitempty"`
+	Analysis    []AnalysisQuery `json:"analysis,omitempty"`
+}
+
+// AnalysisQuery é uma consulta avaliada contra um limite. Provider vazio =
+// Prometheus (as SLOs da estratégia); as demais vêm do template de análise.
+type AnalysisQuery struct {
+	Metric   string  `json:"metric"`
+	Provider string  `json:"provider,omitempty"`
+	Query    string  `json:"query"`
+	Range    string  `json:"range"`
+	Min      float64 `json:"min,omitempty"`
+	Max      float64 `json:"max"` // 0 = só registra, não reprova
+}
+
+// PlanCanary monta as etapas de RunCanary para o Deployment atual.
+func PlanCanary(d *appsv1.Deployment, image string, params CanaryParams) []PlanStep {
+	params.defaults()
+	replicas := int32(1)
+	if d.Spec.Replicas != nil { replicas = *d.Spec.Replicas }
+	steps := []PlanStep{{Name: "image", Description: "set image " + image, Image: image}}
+	for _, cur := range canarySteps(replicas, params.StepPercent) {
+		steps = append(steps, PlanStep{
+			Name:        "scale_" + itoa(int(cur)),
+			Description: "scale to " + itoa(int(cur)) + "/" + itoa(int(replicas)) + " replicas, wait rollout, analyze and pause",
+			Replicas:    cur,
+			Total:       replicas,
+			PauseSec:    params.PauseSec,
+			Analysis: []AnalysisQuery{
+				{Metric: "errorRate", Query: canaryErrorQuery, Range: analysisRange, Max: params.MaxError},
+				{Metric: "p95", Query: canaryP95Query, Range: analysisRange, Max: params.MaxP95},
+			},
+		})
+	}
+	return steps
+}
+
+// PlanBlueGreen monta as etapas de RunBlueGreen.
+func PlanBlueGreen(d *appsv1.Deployment, image string, p BlueGreenParams) []PlanStep {
+	p.defaults()
+	replicas := int32(1)
+	if d.Spec.Replicas != nil { replicas = *d.Spec.Replicas }
+	return []PlanStep{
+		{Name: "rollout", Description: "set image " + image + " and roll out all replicas", Image: image, Replicas: replicas, Total: replicas},
+		{Name: "probe", Description: "wait " + itoa(p.ProbeWaitSec) + "s and analyze", PauseSec: p.ProbeWaitSec, Analysis: []AnalysisQuery{
+			{Metric: "errorRate", Query: blueGreenErrorQuery, Range: analysisRange, Max: p.MaxError},
+			{Metric: "p95", Query: blueGreenP95Query, Range: analysisRange, Max: p.MaxP95},
+		}},
+	}
+}
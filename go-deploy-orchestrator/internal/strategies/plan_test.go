@@ -0,0 +1,25 @@
+package strategies
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+func TestPlanCanary(t *testing.T) {
+	replicas := int32(5)
+	d := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+	steps := PlanCanary(d, "repo/myapp:2", CanaryParams{StepPercent: 40, MaxError: 0.02})
+
+	want := []struct {
+		name     string
+		replicas int32
+	}{{"image", 0}, {"scale_2", 2}, {"scale_4", 4}, {"scale_5", 5}}
+	if len(steps) != len(want) { t.Fatalf("steps=%+v", steps) }
+	for i, w := range want {
+		if steps[i].Name != w.name || steps[i].Replicas != w.replicas { t.Fatalf("step %d = %+v, want %s/%d", i, steps[i], w.name, w.replicas) }
+	}
+	s := steps[1]
+	if s.PauseSec != 60 || s.Total != 5 { t.Fatalf("defaults not applied: %+v", s) }
+	if len(s.Analysis) != 2 || s.Analysis[0].Query != canaryErrorQuery || s.Analysis[0].Max != 0.02 { t.Fatalf("analysis=%+v", s.Analysis) }
+}
@@ -0,0 +1,36 @@
+package strategies
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPartitionSteps(t *testing.T) {
+	tests := []struct {
+		replicas int32
+		percent  int
+		want     []int32
+	}{
+		{5, 40, []int32{2, 4, 5}},
+		{4, 25, []int32{1, 2, 3, 4}},
+		{1, 20, []int32{1}},
+	}
+	for _, tt := range tests {
+		if got := partitionSteps(tt.replicas, tt.percent); !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("partitionSteps(%d, %d)=%v want %v", tt.replicas, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestScaledMaxUnavailable(t *testing.T) {
+	tests := []struct {
+		v     string
+		nodes int
+		want  int
+	}{{"1", 10, 1}, {"3", 10, 3}, {"25%", 10, 2}, {"10%", 3, 1}}
+	for _, tt := range tests {
+		got, err := scaledMaxUnavailable(tt.v, tt.nodes)
+		if err != nil || got != tt.want { t.Fatalf("scaledMaxUnavailable(%q, %d)=%d,%v want %d", tt.v, tt.nodes, got, err, tt.want) }
+	}
+	if _, err := scaledMaxUnavailable("abc", 3); err == nil { t.Fatal("expected error for invalid value") }
+}
@@ -0,0 +1,142 @@
+package trigger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+// Push é uma imagem publicada no registry, normalizada entre formatos.
+type Push struct {
+	Source     string `json:"source"`     // dockerhub|harbor|generic
+	Repository string `json:"repository"` // referência sem tag
+	Tag        string `json:"tag"`
+	Image      string `json:"image"`      // repository:tag (ou @digest)
+}
+
+var (
+	ErrBadSignature = errors.New("invalid webhook signature")
+	ErrUnknown      = errors.New("unrecognized registry payload")
+)
+
+type rule struct {
+	config.TriggerRule
+	tag *regexp.Regexp
+}
+
+type Matcher struct {
+	secret []byte
+	rules  []rule
+}
+
+func New(cfg config.Triggers) (*Matcher, error) {
+	m := &Matcher{secret: []byte(cfg.Secret)}
+	if len(cfg.Rules) > 0 && cfg.Secret == "" { return nil, errors.New("triggers: secret is required when rules are configured") }
+	for _, r := range cfg.Rules {
+		if r.Name == "" || r.Repository == "" || r.App == "" { return nil, fmt.Errorf("trigger rule %q: name, repository and app are required", r.Name) }
+		if _, err := path.Match(r.Repository, ""); err != nil { return nil, fmt.Errorf("trigger rule %q: repository: %w", r.Name, err) }
+		cr := rule{TriggerRule: r}
+		if r.Tag != "" {
+			re, err := regexp.Compile(r.Tag)
+			if err != nil { return nil, fmt.Errorf("trigger rule %q: tag: %w", r.Name, err) }
+			cr.tag = re
+		}
+		m.rules = append(m.rules, cr)
+	}
+	return m, nil
+}
+
+// Enabled indica se há regras configuradas.
+func (m *Matcher) Enabled() bool { return len(m.rules) > 0 }
+
+// Verify confere o HMAC-SHA256 do corpo; aceita "sha256=<hex>" ou só o hex.
+func (m *Matcher) Verify(body []byte, signature string) error {
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(got) == 0 { return ErrBadSignature }
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) { return ErrBadSignature }
+	return nil
+}
+
+// Match devolve as regras que casam com o push.
+func (m *Matcher) Match(p Push) []config.TriggerRule {
+	var out []config.TriggerRule
+	for _, r := range m.rules {
+		if ok, _ := path.Match(r.Repository, p.Repository); !ok { continue }
+		if r.tag != nil && !r.tag.MatchString(p.Tag) { continue }
+		out = append(out, r.TriggerRule)
+	}
+	return out
+}
+
+// Parse reconhece payloads do Docker Hub, Harbor ou JSON genérico
+// ({"repository","tag"} ou {"image"}).
+func Parse(body []byte) ([]Push, error) {
+	var raw struct {
+		// Docker Hub
+		PushData *struct{ Tag string `json:"tag"` } `json:"push_data"`
+		// Harbor
+		Type      string `json:"type"`
+		EventData *struct {
+			Resources []struct {
+				Tag         string `json:"tag"`
+				Digest      string `json:"digest"`
+				ResourceURL string `json:"resource_url"`
+			} `json:"resources"`
+		} `json:"event_data"`
+		// Docker Hub (objeto) ou genérico (string)
+		Repository json.RawMessage `json:"repository"`
+		Tag        string          `json:"tag"`
+		Image      string          `json:"image"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil { return nil, err }
+
+	switch {
+	case raw.PushData != nil:
+		var repo struct{ RepoName string `json:"repo_name"` }
+		if err := json.Unmarshal(raw.Repository, &repo); err != nil || repo.RepoName == "" || raw.PushData.Tag == "" { return nil, ErrUnknown }
+		return []Push{newPush("dockerhub", repo.RepoName, raw.PushData.Tag)}, nil
+
+	case raw.EventData != nil:
+		if raw.Type != "" && raw.Type != "PUSH_ARTIFACT" && raw.Type != "pushImage" { return nil, nil }
+		var out []Push
+		for _, r := range raw.EventData.Resources {
+			if r.Tag == "" || r.ResourceURL == "" { continue }
+			repo, _ := splitImage(r.ResourceURL)
+			out = append(out, newPush("harbor", repo, r.Tag))
+		}
+		if len(out) == 0 { return nil, ErrUnknown }
+		return out, nil
+
+	case raw.Image != "":
+		repo, tag := splitImage(raw.Image)
+		if tag == "" { return nil, ErrUnknown }
+		return []Push{newPush("generic", repo, tag)}, nil
+
+	default:
+		var repo string
+		if json.Unmarshal(raw.Repository, &repo) != nil || repo == "" || raw.Tag == "" { return nil, ErrUnknown }
+		return []Push{newPush("generic", repo, raw.Tag)}, nil
+	}
+}
+
+func newPush(source, repo, tag string) Push {
+	return Push{Source: source, Repository: repo, Tag: tag, Image: repo + ":" + tag}
+}
+
+// splitImage separa "registry/repo:tag" em repo e tag (a porta do registry não é tag).
+func splitImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 { image = image[:i] }
+	slash := strings.LastIndex(image, "/")
+	if i := strings.LastIndex(image, ":"); i > slash { return image[:i], image[i+1:] }
+	return image, ""
+}
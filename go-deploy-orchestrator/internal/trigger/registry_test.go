@@ -0,0 +1,49 @@
+package trigger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/viniciushammett/go-deploy-orchestrator/internal/config"
+)
+
+func TestParseAndMatch(t *testing.T) {
+	m, err := New(config.Triggers{Secret: "s3cret", Rules: []config.TriggerRule{
+		{Name: "myapp-release", Repository: "*/acme/myapp", Tag: `^v\d+\.\d+\.\d+$`, App: "myapp"},
+		{Name: "hub", Repository: "acme/*", App: "hubapp"},
+	}})
+	if err != nil { t.Fatal(err) }
+
+	tests := []struct {
+		name, body, image, rule string
+	}{
+		{"dockerhub", `{"push_data":{"tag":"latest"},"repository":{"repo_name":"acme/web"}}`, "acme/web:latest", "hub"},
+		{"harbor", `{"type":"PUSH_ARTIFACT","event_data":{"resources":[{"tag":"v1.2.3","resource_url":"harbor.acme.io:443/acme/myapp:v1.2.3"}]}}`, "harbor.acme.io:443/acme/myapp:v1.2.3", "myapp-release"},
+		{"generic", `{"repository":"ghcr.io/acme/myapp","tag":"v2.0.0"}`, "ghcr.io/acme/myapp:v2.0.0", "myapp-release"},
+		{"generic-image", `{"image":"ghcr.io/acme/myapp:v2.0.0-rc1"}`, "ghcr.io/acme/myapp:v2.0.0-rc1", ""},
+	}
+	for _, tt := range tests {
+		pushes, err := Parse([]byte(tt.body))
+		if err != nil || len(pushes) != 1 { t.Fatalf("%s: pushes=%v err=%v", tt.name, pushes, err) }
+		if pushes[0].Image != tt.image { t.Fatalf("%s: image=%q want %q", tt.name, pushes[0].Image, tt.image) }
+		rules := m.Match(pushes[0])
+		got := ""
+		if len(rules) > 0 { got = rules[0].Name }
+		if got != tt.rule { t.Fatalf("%s: rule=%q want %q", tt.name, got, tt.rule) }
+	}
+	if _, err := Parse([]byte(`{"foo":"bar"}`)); err != ErrUnknown { t.Fatalf("err=%v want ErrUnknown", err) }
+}
+
+func TestVerify(t *testing.T) {
+	m, _ := New(config.Triggers{Secret: "s3cret"})
+	body := []byte(`{"image":"repo/app:v1"}`)
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+	if err := m.Verify(body, "sha256="+sig); err != nil { t.Fatal(err) }
+	if err := m.Verify(body, sig); err != nil { t.Fatal(err) }
+	if err := m.Verify([]byte(`{"image":"repo/app:v2"}`), "sha256="+sig); err != ErrBadSignature { t.Fatalf("err=%v want ErrBadSignature", err) }
+	if err := m.Verify(body, ""); err != ErrBadSignature { t.Fatalf("err=%v want ErrBadSignature", err) }
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/dynamic"
+	"go-secret-vault/internal/policy"
+)
+
+// databasePrefix: policy paths of dynamic credentials are
+// "database/<name>"; read issues credentials, update renews their leases and
+// delete revokes them.
+const databasePrefix = "database/"
+
+type credsResp struct {
+	dynamic.Creds
+	LeaseDuration int `json:"lease_duration"` // seconds
+}
+
+// POST /database/creds/{name} {"ttl": "30m"} (body optional)
+func (a *api) databaseCreds(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !a.allow(w, r, policy.Read, databasePrefix+name, "") { return }
+	var body struct{ TTL string `json:"ttl"` }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && r.ContentLength != 0 { http.Error(w, "bad json", 400); return }
+	var ttl time.Duration
+	if body.TTL != "" { d, err := time.ParseDuration(body.TTL); if err != nil { http.Error(w, "bad ttl", 400); return }; ttl = d }
+	c, err := a.dbs.Creds(r.Context(), name, ttl)
+	if err != nil {
+		a.log.Log(audit.Entry{Actor: who(r), Action: "database.creds", Outcome: "error", Target: databasePrefix + name, Meta: map[string]string{"error": err.Error()}})
+		leaseError(w, err)
+		return
+	}
+	a.log.Log(audit.Entry{Actor: who(r), Action: "database.creds", Outcome: "ok", Target: c.ID, Meta: map[string]string{"database": name, "username": c.Username, "expires_at": c.ExpiresAt.Format(time.RFC3339)}})
+	json.NewEncoder(w).Encode(credsResp{Creds: c, LeaseDuration: int(c.ExpiresAt.Sub(c.IssuedAt).Seconds())})
+}
+
+// POST /leases/{id}/renew {"increment": "1h"} (body optional)
+func (a *api) renewLease(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !a.allowLease(w, r, policy.Update, id) { return }
+	var body struct{ Increment string `json:"increment"` }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && r.ContentLength != 0 { http.Error(w, "bad json", 400); return }
+	var inc time.Duration
+	if body.Increment != "" { d, err := time.ParseDuration(body.Increment); if err != nil { http.Error(w, "bad increment", 400); return }; inc = d }
+	l, err := a.dbs.Renew(r.Context(), id, inc)
+	if err != nil { leaseError(w, err); return }
+	a.log.Log(audit.Entry{Actor: who(r), Action: "lease.renew", Outcome: "ok", Target: id, Meta: map[string]string{"database": l.Database, "expires_at": l.ExpiresAt.Format(time.RFC3339)}})
+	json.NewEncoder(w).Encode(l)
+}
+
+// POST /leases/{id}/revoke: drops the user now.
+func (a *api) revokeLease(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !a.allowLease(w, r, policy.Delete, id) { return }
+	l, err := a.dbs.Revoke(r.Context(), id)
+	if err != nil { leaseError(w, err); return }
+	a.log.Log(audit.Entry{Actor: who(r), Action: "lease.revoke", Outcome: "ok", Target: id, Meta: map[string]string{"database": l.Database, "username": l.Username}})
+	w.WriteHeader(204)
+}
+
+// allowLease is allow for the /leases/{id} routes, on the lease's database.
+func (a *api) allowLease(w http.ResponseWriter, r *http.Request, c policy.Capability, id string) bool {
+	l, err := a.dbs.Lease(id)
+	if err != nil { leaseError(w, err); return false }
+	return a.allow(w, r, c, databasePrefix+l.Database, id)
+}
+
+// reapLeases revokes the expired leases (driven by the reaper in main).
+func (a *api) reapLeases() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	done, err := a.dbs.RevokeExpired(ctx)
+	for _, l := range done {
+		a.log.Log(audit.Entry{Actor: "system", Action: "lease.expire", Outcome: "ok", Target: l.ID, Meta: map[string]string{"database": l.Database, "username": l.Username}})
+	}
+	if err != nil { log.Printf("lease reaper: %v", err) }
+}
+
+func leaseError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, dynamic.ErrUnknownDatabase), errors.Is(err, dynamic.ErrLeaseNotFound):
+		http.Error(w, err.Error(), 404)
+	case errors.Is(err, dynamic.ErrLeaseExpired):
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, err.Error(), 502) // the database refused or is down
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/auth"
+	"go-secret-vault/internal/policy"
+)
+
+// Policy paths of the approles are "auth/approle/<name>"; read returns the
+// role_id and create issues secret_ids.
+const (
+	authPrefix    = "auth/"
+	approlePrefix = authPrefix + "approle/"
+)
+
+// POST /login/approle {"role_id": "...", "secret_id": "..."}
+func (a *api) loginAppRole(w http.ResponseWriter, r *http.Request) {
+	var req struct{ RoleID string `json:"role_id"`; SecretID string `json:"secret_id"` }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
+	u, ttl, accessor, err := a.approle.Login(req.RoleID, req.SecretID)
+	if err != nil {
+		a.log.Log(audit.Entry{Actor: "approle", Action: "login", Outcome: "denied", Meta: map[string]string{"method": "approle"}})
+		loginError(w, err)
+		return
+	}
+	if c := a.issueToken(w, u, ttl); c != nil {
+		a.log.Log(audit.Entry{Actor: u.Username, Action: "login", Outcome: "ok", Meta: map[string]string{"method": "approle", "secret_id_accessor": accessor, "jti": c.ID}})
+	}
+}
+
+// POST /login/kubernetes {"role": "...", "jwt": "<ServiceAccount token>"}
+func (a *api) loginKubernetes(w http.ResponseWriter, r *http.Request) {
+	var req struct{ Role string `json:"role"`; JWT string `json:"jwt"` }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
+	u, ttl, err := a.kube.Login(r.Context(), req.Role, req.JWT)
+	if err != nil {
+		a.log.Log(audit.Entry{Actor: "kubernetes", Action: "login", Outcome: "denied", Meta: map[string]string{"method": "kubernetes", "role": req.Role}})
+		loginError(w, err)
+		return
+	}
+	if c := a.issueToken(w, u, ttl); c != nil {
+		a.log.Log(audit.Entry{Actor: u.Username, Action: "login", Outcome: "ok", Meta: map[string]string{"method": "kubernetes", "role": req.Role, "jti": c.ID}})
+	}
+}
+
+// GET /auth/approle/{name}/role-id
+func (a *api) appRoleID(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !a.allow(w, r, policy.Read, approlePrefix+name, "") { return }
+	id, err := a.approle.RoleID(name)
+	if err != nil { http.Error(w, err.Error(), 404); return }
+	json.NewEncoder(w).Encode(map[string]string{"role_id": id})
+}
+
+// POST /auth/approle/{name}/secret-id
+func (a *api) newSecretID(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !a.allow(w, r, policy.Create, approlePrefix+name, "") { return }
+	s, err := a.approle.NewSecretID(name)
+	if errors.Is(err, auth.ErrUnknownAppRole) { http.Error(w, err.Error(), 404); return }
+	if err != nil { http.Error(w, err.Error(), 500); return }
+	a.log.Log(audit.Entry{Actor: who(r), Action: "approle.secret-id", Outcome: "ok", Target: approlePrefix + name, Meta: map[string]string{"secret_id_accessor": s.Accessor, "num_uses": strconv.Itoa(s.NumUses)}})
+	json.NewEncoder(w).Encode(s)
+}
+
+// tidySecretIDs drops the expired secret_ids (driven by the reaper in main).
+func (a *api) tidySecretIDs() {
+	if n, err := a.approle.Tidy(); err != nil {
+		log.Printf("approle tidy: %v", err)
+	} else if n > 0 {
+		a.log.Log(audit.Entry{Actor: "system", Action: "approle.tidy", Outcome: "ok", Meta: map[string]string{"count": strconv.Itoa(n)}})
+	}
+}
+
+func loginError(w http.ResponseWriter, err error) {
+	if errors.Is(err, auth.ErrInvalidLogin) { http.Error(w, "unauthorized", 401); return }
+	log.Printf("login: %v", err)
+	http.Error(w, "login check failed", 502) // e.g. the TokenReview API is down
+}
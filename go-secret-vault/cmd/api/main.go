@@ -5,6 +5,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -14,15 +17,34 @@ import (
 	"go-secret-vault/internal/auth"
 	"go-secret-vault/internal/config"
 	"go-secret-vault/internal/crypto"
+	"go-secret-vault/internal/dynamic"
+	"go-secret-vault/internal/k8ssync"
+	"go-secret-vault/internal/policy"
+	"go-secret-vault/internal/seal"
+	"go-secret-vault/internal/transit"
 	"go-secret-vault/internal/vault"
 )
 
 type api struct {
-	vault *vault.Service
-	store *vault.BoltStore
-	jwt   *auth.JWT
-	users *auth.UserStore
-	log   *audit.Logger
+	// sealMu guards vault, keys and transit: nil while sealed. Requests that
+	// use them hold the read lock; sealing takes the write lock.
+	sealMu  sync.RWMutex
+	seal    *seal.Seal          // nil unless seal.shamir
+	master  *crypto.AESEncryptor // config master key, for the keyring move at init
+	vault   *vault.Service
+	store   *vault.BoltStore
+	keys    *crypto.Envelope
+	rotated chan struct{} // wakes the rewrap job
+	transit *transit.Engine
+	dbs     *dynamic.Manager
+	sync    *k8ssync.Syncer // nil unless k8s_sync.enabled
+	syncNow chan struct{}
+	jwt     *auth.JWT
+	users   *auth.UserStore
+	approle *auth.AppRole
+	kube    *auth.KubeAuth // nil unless auth.kubernetes.enabled
+	log     *audit.Logger
+	pol     *policy.Engine
 }
 
 func main() {
@@ -31,26 +53,66 @@ func main() {
 	cfg, err := config.Load(cfgPath)
 	if err != nil { log.Fatal(err) }
 
-	enc, err := crypto.NewAESEncryptor(cfg.Security.MasterKeyB64)
-	if err != nil { log.Fatal(err) }
-	st, err := vault.NewBolt(cfg.Storage.BoltPath)
+	st, err := vault.NewBolt(cfg.Storage.BoltPath, cfg.Storage.MaxVersions)
 	if err != nil { log.Fatal(err) }
 	defer st.Close()
-	v := vault.NewService(st, enc)
-	jwt, err := auth.NewJWT(cfg.Security.JWTSecretB64)
+	jwt, err := auth.NewJWT(cfg.Security.JWTSecretB64, cfg.Auth.Token, st)
 	if err != nil { log.Fatal(err) }
 	users := auth.NewUserStore(convertUsers(cfg))
-	alog, err := audit.New(cfg.Audit.File)
+	if len(cfg.Policies) == 0 {
+		log.Printf("warning: no policies configured: role admin gets every capability, other roles are denied")
+		cfg.Policies = policy.Default()
+	}
+	pol, err := policy.New(cfg.Policies)
+	if err != nil { log.Fatal(err) }
+	akey, err := audit.Key(cfg.Audit)
+	if err != nil { log.Fatal(err) }
+	alog, err := audit.New(cfg.Audit, akey)
 	if err != nil { log.Fatal(err) }
 	defer alog.Close()
 
-	api := &api{vault: v, store: st, jwt: jwt, users: users, log: alog}
+	dbs, err := dynamic.New(cfg.Databases, st)
+	if err != nil { log.Fatal(err) }
+
+	approle, err := auth.NewAppRole(cfg.Auth.AppRoles, st)
+	if err != nil { log.Fatal(err) }
+
+	api := &api{store: st, rotated: make(chan struct{}, 1), jwt: jwt, users: users, approle: approle, log: alog, pol: pol, dbs: dbs}
+	var master *crypto.AESEncryptor
+	if cfg.Security.MasterKeyB64 != "" {
+		// with Shamir it is optional: only init uses it, to move an old keyring
+		if master, err = crypto.NewAESEncryptor(cfg.Security.MasterKeyB64); err != nil && !cfg.Seal.Shamir { log.Fatal(err) }
+	}
+	if cfg.Seal.Shamir {
+		if api.seal, err = seal.New(st); err != nil { log.Fatal(err) }
+		api.master = master
+		if !api.seal.Status().Initialized { log.Printf("vault is not initialized: run gsv operator init") } else { log.Printf("vault is sealed: submit the unseal keys with gsv operator unseal") }
+	} else if err := api.unlock(master); err != nil {
+		log.Fatal(err)
+	}
+	go api.rewrapLoop()
+	if cfg.K8sSync.Enabled {
+		cs, err := k8ssync.NewClientset(cfg.K8sSync.Kubeconfig)
+		if err != nil { log.Fatal(err) }
+		if api.sync, err = k8ssync.New(cs, cfg.K8sSync.Mappings); err != nil { log.Fatal(err) }
+		api.syncNow = make(chan struct{}, 1)
+		go api.syncLoop(cfg.K8sSync.Interval)
+	}
+	if cfg.Auth.Kubernetes.Enabled {
+		cs, err := k8ssync.NewClientset(cfg.Auth.Kubernetes.Kubeconfig)
+		if err != nil { log.Fatal(err) }
+		if api.kube, err = auth.NewKubeAuth(cs, cfg.Auth.Kubernetes); err != nil { log.Fatal(err) }
+	}
 
-	go func() { // TTL reaper
+	go func() { // TTL, lease, secret_id and revoked token reaper
 		for {
 			if n, err := st.ReapExpired(); err == nil && n > 0 {
-				alog.Log(audit.Entry{Actor: "system", Action: "reap", Outcome: "ok", Meta: map[string]string{"count": string(rune(n))}})
+				api.changed()
+				alog.Log(audit.Entry{Actor: "system", Action: "reap", Outcome: "ok", Meta: map[string]string{"count": strconv.Itoa(n)}})
 			}
+			api.reapLeases()
+			api.tidySecretIDs()
+			api.reapRevokedTokens()
 			time.Sleep(time.Minute)
 		}
 	}()
@@ -61,13 +123,40 @@ func main() {
 
 	r.Get("/healthz", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
 	r.Post("/login", api.login)
+	r.Post("/login/approle", api.loginAppRole)
+	if api.kube != nil { r.Post("/login/kubernetes", api.loginKubernetes) }
+	r.Get("/auth/approle/{name}/role-id", api.appRoleID)
+	r.Post("/auth/approle/{name}/secret-id", api.newSecretID)
+	r.Get("/auth/token/self", api.tokenSelf)
+	r.Post("/auth/token/refresh", api.refreshToken)
+	r.Post("/auth/token/revoke", api.revokeToken)
+
+	r.Post("/sys/init", api.initVault)
+	r.Get("/sys/seal-status", api.sealStatus)
+	r.Post("/sys/unseal", api.unseal)
+	r.Post("/sys/seal", api.sealVault)
+	r.Get("/sys/audit", api.queryAudit)
+	r.Get("/sys/audit/verify", api.verifyAudit)
+	r.With(api.unsealed).Post("/sys/rotate", api.rotate)
+	r.With(api.unsealed).Get("/sys/keys", api.keyStatus)
+
+	if cfg.Transit.BaseURL != "" || cfg.Transit.Token != "" { log.Printf("warning: transit.base_url and transit.token are ignored: transit is served by this server (transit.enabled)") }
+	if cfg.Transit.Enabled { r.With(api.unsealed).Route("/transit", api.transitRoutes) }
 
-	r.Route("/secrets", func(sr chi.Router) {
+	r.With(api.unsealed).Post("/database/creds/{name}", api.databaseCreds)
+	r.With(api.unsealed).Post("/leases/{id}/renew", api.renewLease)
+	r.With(api.unsealed).Post("/leases/{id}/revoke", api.revokeLease)
+
+	r.With(api.unsealed).Route("/secrets", func(sr chi.Router) {
 		sr.Post("/", api.createSecret)
 		sr.Get("/", api.listSecrets)
 		sr.Get("/{id}", api.getSecret)
 		sr.Put("/{id}", api.updateSecret)
 		sr.Delete("/{id}", api.deleteSecret)
+		sr.Get("/{id}/versions", api.listVersions)
+		sr.Post("/{id}/rollback", api.rollbackSecret)
+		sr.Post("/{id}/undelete", api.undeleteSecret)
+		sr.Post("/{id}/destroy", api.destroySecret)
 		sr.Post("/{id}/export/k8s", api.exportK8s)
 	})
 
@@ -87,53 +176,72 @@ func convertUsers(cfg *config.Config) []struct{ Username, PasswordBcrypt string;
 
 type loginReq struct { Username, Password string }
 
-type loginResp struct { Token string `json:"token"` }
-
 func (a *api) login(w http.ResponseWriter, r *http.Request) {
 	var req loginReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
 	u, err := a.users.Verify(req.Username, req.Password)
 	if err != nil { a.log.Log(audit.Entry{Actor: req.Username, Action: "login", Outcome: "denied"}); http.Error(w, "unauthorized", 401); return }
-	tok, _ := a.jwt.Issue(auth.User{Username: u.Username, Roles: u.Roles}, 8*time.Hour)
-	a.log.Log(audit.Entry{Actor: u.Username, Action: "login", Outcome: "ok"})
-	json.NewEncoder(w).Encode(loginResp{Token: tok})
+	if c := a.issueToken(w, auth.User{Username: u.Username, Roles: u.Roles}, a.jwt.TTL(u.Roles)); c != nil {
+		a.log.Log(audit.Entry{Actor: u.Username, Action: "login", Outcome: "ok", Meta: map[string]string{"jti": c.ID}})
+	}
 }
 
 type createReq struct { Name string `json:"name"`; Value string `json:"value"`; TTL string `json:"ttl,omitempty"`; Meta map[string]string `json:"meta"` }
 
-type secretMeta struct { ID, Name string; CreatedAt, UpdatedAt time.Time; ExpiresAt *time.Time; Meta map[string]string }
+type secretMeta struct { ID, Name string; Version int; CreatedAt, UpdatedAt time.Time; ExpiresAt, DeletedAt *time.Time; Meta map[string]string }
+
+func metaOf(s vault.Secret) secretMeta {
+	return secretMeta{ID: s.ID, Name: s.Name, Version: s.Version, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt, ExpiresAt: s.ExpiresAt, DeletedAt: s.DeletedAt, Meta: s.Meta}
+}
 
 func (a *api) createSecret(w http.ResponseWriter, r *http.Request) {
 	var req createReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
 	var ttl time.Duration
 	if req.TTL != "" { d, err := time.ParseDuration(req.TTL); if err != nil { http.Error(w, "bad ttl", 400); return }; ttl = d }
+	for _, p := range []string{sysPrefix, transitPrefix, databasePrefix, authPrefix} {
+		if strings.HasPrefix(req.Name, p) { http.Error(w, "names under "+p+" are reserved", 400); return }
+	}
+	if !a.allow(w, r, policy.Create, req.Name, "") { return }
 	sec, err := a.vault.Create(req.Name, []byte(req.Value), ttl, req.Meta)
 	if err != nil { http.Error(w, err.Error(), 400); return }
+	a.changed()
 	a.log.Log(audit.Entry{Actor: who(r), Action: "create", Outcome: "ok", Target: sec.ID, Meta: map[string]string{"name": sec.Name}})
-	json.NewEncoder(w).Encode(secretMeta{ID: sec.ID, Name: sec.Name, CreatedAt: sec.CreatedAt, UpdatedAt: sec.UpdatedAt, ExpiresAt: sec.ExpiresAt, Meta: sec.Meta})
+	json.NewEncoder(w).Encode(metaOf(sec))
 }
 
 func (a *api) listSecrets(w http.ResponseWriter, r *http.Request) {
-	secs, err := a.store.List(false)
+	u, _ := auth.FromCtx(r.Context())
+	if !a.pol.Any(u.Roles, policy.List) { a.deny(w, r, policy.List, "", ""); return }
+	secs, err := a.store.List(false, r.URL.Query().Get("deleted") == "true")
 	if err != nil { http.Error(w, err.Error(), 500); return }
 	out := make([]secretMeta, 0, len(secs))
-	for _, s := range secs { out = append(out, secretMeta{ID: s.ID, Name: s.Name, CreatedAt: s.CreatedAt, UpdatedAt: s.UpdatedAt, ExpiresAt: s.ExpiresAt, Meta: s.Meta}) }
+	for _, s := range secs {
+		if !a.pol.Allowed(u.Roles, policy.List, s.Name) { continue }
+		out = append(out, metaOf(s))
+	}
 	json.NewEncoder(w).Encode(out)
 }
 
 func (a *api) getSecret(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
-	sec, pt, err := a.vault.GetDecrypted(id)
-	if err != nil { http.Error(w, err.Error(), 404); return }
-	a.log.Log(audit.Entry{Actor: who(r), Action: "read", Outcome: "ok", Target: id})
-	json.NewEncoder(w).Encode(struct { ID, Name, Value string }{ID: sec.ID, Name: sec.Name, Value: string(pt)})
+	if !a.allowID(w, r, policy.Read, id) { return }
+	n := 0
+	if q := r.URL.Query().Get("version"); q != "" {
+		var err error
+		if n, err = strconv.Atoi(q); err != nil || n < 1 { http.Error(w, "bad version", 400); return }
+	}
+	sec, v, pt, err := a.vault.GetDecrypted(id, n)
+	if err != nil { versionError(w, err); return }
+	a.log.Log(audit.Entry{Actor: who(r), Action: "read", Outcome: "ok", Target: id, Meta: map[string]string{"version": strconv.Itoa(v.Version)}})
+	json.NewEncoder(w).Encode(struct { ID, Name, Value string; Version int }{ID: sec.ID, Name: sec.Name, Value: string(pt), Version: v.Version})
 }
 
 type updateReq struct { Value *string `json:"value"`; TTL *string `json:"ttl"`; Meta map[string]string `json:"meta"` }
 
 func (a *api) updateSecret(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Update, id) { return }
 	var req updateReq
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
 	var ttl *time.Duration
@@ -142,19 +250,23 @@ func (a *api) updateSecret(w http.ResponseWriter, r *http.Request) {
 	if req.Value != nil { plain = []byte(*req.Value) }
 	sec, err := a.vault.Update(id, plain, ttl, req.Meta)
 	if err != nil { http.Error(w, err.Error(), 400); return }
-	a.log.Log(audit.Entry{Actor: who(r), Action: "update", Outcome: "ok", Target: id})
-	json.NewEncoder(w).Encode(secretMeta{ID: sec.ID, Name: sec.Name, CreatedAt: sec.CreatedAt, UpdatedAt: sec.UpdatedAt, ExpiresAt: sec.ExpiresAt, Meta: sec.Meta})
+	a.changed()
+	a.log.Log(audit.Entry{Actor: who(r), Action: "update", Outcome: "ok", Target: id, Meta: map[string]string{"version": strconv.Itoa(sec.Version)}})
+	json.NewEncoder(w).Encode(metaOf(sec))
 }
 
 func (a *api) deleteSecret(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Delete, id) { return }
 	if err := a.store.Delete(id); err != nil { http.Error(w, err.Error(), 404); return }
+	a.changed()
 	a.log.Log(audit.Entry{Actor: who(r), Action: "delete", Outcome: "ok", Target: id})
 	w.WriteHeader(204)
 }
 
 func (a *api) exportK8s(w http.ResponseWriter, r *http.Request) {
 	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Export, id) { return }
 	var body struct { Namespace, Key string }
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil { body.Namespace = "default" }
 	b, err := a.vault.ExportK8sYAML(id, body.Namespace, body.Key)
@@ -163,6 +275,31 @@ func (a *api) exportK8s(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+// allow checks the caller's roles against the policies for c on the secret
+// name; denials get a 403 and an audit entry with outcome "denied".
+func (a *api) allow(w http.ResponseWriter, r *http.Request, c policy.Capability, name, id string) bool {
+	u, _ := auth.FromCtx(r.Context())
+	if a.pol.Allowed(u.Roles, c, name) { return true }
+	a.deny(w, r, c, name, id)
+	return false
+}
+
+// allowID is allow for the /secrets/{id} routes, which need the name first.
+func (a *api) allowID(w http.ResponseWriter, r *http.Request, c policy.Capability, id string) bool {
+	sec, err := a.store.Get(id)
+	if err != nil { http.Error(w, err.Error(), 404); return false }
+	return a.allow(w, r, c, sec.Name, id)
+}
+
+func (a *api) deny(w http.ResponseWriter, r *http.Request, c policy.Capability, name, id string) {
+	target := id
+	if target == "" { target = name }
+	meta := map[string]string{"capability": string(c)}
+	if name != "" { meta["name"] = name }
+	a.log.Log(audit.Entry{Actor: who(r), Action: string(c), Outcome: "denied", Target: target, Meta: meta})
+	http.Error(w, "permission denied", http.StatusForbidden)
+}
+
 func who(r *http.Request) string {
 	if u, ok := auth.FromCtx(r.Context()); ok { return u.Username }
 	return "?"
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/crypto"
+	"go-secret-vault/internal/policy"
+	"go-secret-vault/internal/seal"
+	"go-secret-vault/internal/transit"
+	"go-secret-vault/internal/vault"
+)
+
+// unlock builds the keyring and the services on top of the root key; the
+// caller holds sealMu (or is still starting up).
+func (a *api) unlock(root *crypto.AESEncryptor) error {
+	keys, err := crypto.NewEnvelope(root, a.store)
+	if err != nil { return err }
+	a.keys, a.vault, a.transit = keys, vault.NewService(a.store, keys), transit.New(a.store, keys)
+	return nil
+}
+
+// unsealed answers 503 while the vault is sealed and keeps it unsealed
+// until the request is done.
+func (a *api) unsealed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.sealMu.RLock()
+		defer a.sealMu.RUnlock()
+		if a.keys == nil { http.Error(w, "vault is sealed", http.StatusServiceUnavailable); return }
+		next.ServeHTTP(w, r)
+	})
+}
+
+// POST /sys/init {"shares": 5, "threshold": 3} → {"keys": [...]}: creates
+// the root key and returns its unseal keys once. An existing keyring
+// (encrypted with master_key_b64) is moved to the new root key.
+func (a *api) initVault(w http.ResponseWriter, r *http.Request) {
+	if a.seal == nil { http.Error(w, "seal.shamir is not enabled", 400); return }
+	req := struct{ Shares, Threshold int }{Shares: 5, Threshold: 3}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && r.ContentLength != 0 { http.Error(w, "bad json", 400); return }
+	var old *crypto.Envelope
+	keys, err := a.seal.Init(req.Shares, req.Threshold, func(root *crypto.AESEncryptor) (keyring string, err error) {
+		old, keyring, err = a.moveKeyring(root)
+		return keyring, err
+	})
+	switch {
+	case errors.Is(err, seal.ErrInitialized): http.Error(w, err.Error(), 409); return
+	case err != nil: http.Error(w, err.Error(), 400); return
+	}
+	if old != nil {
+		// the seal config and the rekeyed keyring are stored: only now the
+		// master key (old's root) can go
+		old.Wipe()
+		a.master = nil
+		log.Printf("keyring moved to the root key: master_key_b64 can be removed from the config")
+	}
+	a.log.Log(audit.Entry{Actor: "operator", Action: "init", Outcome: "ok", Target: "seal", Meta: map[string]string{"shares": strconv.Itoa(req.Shares), "threshold": strconv.Itoa(req.Threshold)}})
+	json.NewEncoder(w).Encode(struct {
+		Keys      []string `json:"keys"`
+		Shares    int      `json:"shares"`
+		Threshold int      `json:"threshold"`
+	}{keys, req.Shares, req.Threshold})
+}
+
+// moveKeyring re-encrypts a keyring created with the config master key
+// under the new root key and returns it for Init to save with the seal
+// config. Values from before the keyring are read with the master key, so
+// they are rewrapped first; without a keyring one is created under the
+// master key just for that. The returned envelope still holds the master
+// key and is wiped once Init has saved.
+func (a *api) moveKeyring(root *crypto.AESEncryptor) (*crypto.Envelope, string, error) {
+	c, err := a.store.LoadKeyring()
+	if err != nil { return nil, "", err }
+	if a.master == nil {
+		if c != "" { return nil, "", errors.New("existing keyring: set master_key_b64 once so init can move it to the root key") }
+		secs, err := a.store.List(true, true)
+		if err != nil { return nil, "", err }
+		if len(secs) > 0 { return nil, "", errors.New("existing secrets: set master_key_b64 once so init can move them to the root key") }
+		return nil, "", nil
+	}
+	old, err := crypto.NewEnvelope(a.master, a.store)
+	if err != nil { return nil, "", err }
+	if _, err := vault.NewService(a.store, old).RewrapAll(); err != nil { return nil, "", fmt.Errorf("rewrap before init: %w", err) }
+	keyring, err := old.Rekey(root)
+	if err != nil { return nil, "", err }
+	return old, keyring, nil
+}
+
+// GET /sys/seal-status
+func (a *api) sealStatus(w http.ResponseWriter, r *http.Request) {
+	st := seal.Status{Initialized: true}
+	if a.seal != nil { st = a.seal.Status() }
+	json.NewEncoder(w).Encode(st)
+}
+
+// POST /sys/unseal {"key": "<share>"}: once threshold keys are in, the
+// root key is rebuilt and the vault serves requests again.
+func (a *api) unseal(w http.ResponseWriter, r *http.Request) {
+	if a.seal == nil { http.Error(w, "seal.shamir is not enabled", 400); return }
+	var req struct{ Key string }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
+	root, err := a.seal.Unseal(req.Key)
+	if errors.Is(err, seal.ErrBadShare) { a.log.Log(audit.Entry{Actor: "operator", Action: "unseal", Outcome: "denied", Target: "seal"}) }
+	if err != nil { http.Error(w, err.Error(), 400); return }
+	if root != nil {
+		a.sealMu.Lock()
+		err = a.unlock(root)
+		a.sealMu.Unlock()
+		if err != nil { root.Wipe(); a.seal.Seal(); http.Error(w, err.Error(), 500); return }
+		a.log.Log(audit.Entry{Actor: "operator", Action: "unseal", Outcome: "ok", Target: "seal"})
+		select {
+		case a.rotated <- struct{}{}:
+		default:
+		}
+	}
+	json.NewEncoder(w).Encode(a.seal.Status())
+}
+
+// POST /sys/seal: wipes the root key and KEKs from memory; needs update on
+// "sys/seal". In-flight requests finish first.
+func (a *api) sealVault(w http.ResponseWriter, r *http.Request) {
+	if a.seal == nil { http.Error(w, "seal.shamir is not enabled", 400); return }
+	if !a.allow(w, r, policy.Update, sysPrefix+"seal", "") { return }
+	a.sealMu.Lock()
+	if a.keys != nil { a.keys.Wipe() }
+	a.keys, a.vault, a.transit = nil, nil, nil
+	a.seal.Seal()
+	a.sealMu.Unlock()
+	a.log.Log(audit.Entry{Actor: who(r), Action: "seal", Outcome: "ok", Target: "seal"})
+	json.NewEncoder(w).Encode(a.seal.Status())
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-secret-vault/internal/audit"
+)
+
+// syncLoop mirrors the mapped secrets into Kubernetes every interval and
+// right after a secret changes. Sealed vaults are skipped.
+func (a *api) syncLoop(interval time.Duration) {
+	for {
+		a.syncK8s()
+		select {
+		case <-a.syncNow:
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (a *api) syncK8s() {
+	a.sealMu.RLock()
+	defer a.sealMu.RUnlock()
+	if a.vault == nil { return }
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	res, err := a.sync.Sync(ctx, a.vault)
+	if err != nil { res.Errors = append(res.Errors, err.Error()) }
+	for _, e := range res.Errors { log.Printf("k8s sync: %s", e) }
+	if !res.Changed() && len(res.Errors) == 0 { return }
+	e := audit.Entry{Actor: "system", Action: "k8s.sync", Outcome: "ok", Target: "k8s", Meta: map[string]string{
+		"created": strconv.Itoa(res.Created), "updated": strconv.Itoa(res.Updated), "deleted": strconv.Itoa(res.Deleted)}}
+	if len(res.Errors) > 0 { e.Outcome, e.Meta["error"] = "error", strings.Join(res.Errors, "; ") }
+	a.log.Log(e)
+}
+
+// changed wakes the sync after a write (no-op when sync is off).
+func (a *api) changed() {
+	select {
+	case a.syncNow <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/crypto"
+	"go-secret-vault/internal/policy"
+)
+
+// sysPrefix: policy paths for the admin endpoints ("sys/rotate" needs
+// update, "sys/keys" needs read). Secret names can't use it.
+const sysPrefix = "sys/"
+
+// POST /sys/rotate: new KEK for new values; the rewrap job moves the old
+// ones in the background.
+func (a *api) rotate(w http.ResponseWriter, r *http.Request) {
+	if !a.allow(w, r, policy.Update, sysPrefix+"rotate", "") { return }
+	v, err := a.keys.Rotate()
+	if err != nil { http.Error(w, err.Error(), 500); return }
+	a.log.Log(audit.Entry{Actor: who(r), Action: "rotate", Outcome: "ok", Target: "keyring", Meta: map[string]string{"version": strconv.Itoa(v)}})
+	select {
+	case a.rotated <- struct{}{}:
+	default:
+	}
+	json.NewEncoder(w).Encode(map[string]int{"version": v})
+}
+
+// GET /sys/keys: KEK versions and the active one.
+func (a *api) keyStatus(w http.ResponseWriter, r *http.Request) {
+	if !a.allow(w, r, policy.Read, sysPrefix+"keys", "") { return }
+	json.NewEncoder(w).Encode(struct {
+		Active int
+		Keys   []crypto.KEK
+	}{a.keys.Active(), a.keys.Keys()})
+}
+
+// rewrapLoop re-encrypts the secrets still on old KEKs: at startup (resumes
+// an interrupted rewrap), after each rotation and hourly.
+// Sealed vaults are skipped; unsealing wakes the loop.
+func (a *api) rewrapLoop() {
+	for {
+		a.rewrap()
+		select {
+		case <-a.rotated:
+		case <-time.After(time.Hour):
+		}
+	}
+}
+
+func (a *api) rewrap() {
+	a.sealMu.RLock()
+	defer a.sealMu.RUnlock()
+	if a.vault == nil { return }
+	n, err := a.vault.RewrapAll()
+	if err != nil { log.Printf("rewrap: %v", err) }
+	if n > 0 || err != nil {
+		e := audit.Entry{Actor: "system", Action: "rewrap", Outcome: "ok", Target: "keyring", Meta: map[string]string{"count": strconv.Itoa(n), "version": strconv.Itoa(a.keys.Active())}}
+		if err != nil { e.Outcome, e.Meta["error"] = "error", err.Error() }
+		a.log.Log(e)
+	}
+}
+
+// GET /sys/audit?actor=&action=&target=&since=&limit=: newest first; since
+// is RFC3339 or a duration back from now (168h). Needs read on "sys/audit".
+func (a *api) queryAudit(w http.ResponseWriter, r *http.Request) {
+	if !a.allow(w, r, policy.Read, sysPrefix+"audit", "") { return }
+	v := r.URL.Query()
+	q := audit.Query{Actor: v.Get("actor"), Action: v.Get("action"), Target: v.Get("target")}
+	if s := v.Get("since"); s != "" {
+		if d, err := time.ParseDuration(s); err == nil { q.Since = time.Now().Add(-d) } else if t, err := time.Parse(time.RFC3339, s); err == nil { q.Since = t } else { http.Error(w, "bad since", 400); return }
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 { http.Error(w, "bad limit (1-1000)", 400); return }
+		q.Limit = n
+	}
+	out, err := a.log.Query(q)
+	if err != nil { http.Error(w, err.Error(), 500); return }
+	json.NewEncoder(w).Encode(out)
+}
+
+// GET /sys/audit/verify: checks the hash chain of the server's log.
+func (a *api) verifyAudit(w http.ResponseWriter, r *http.Request) {
+	if !a.allow(w, r, policy.Read, sysPrefix+"audit", "") { return }
+	rep, err := a.log.Verify()
+	out := struct {
+		OK bool `json:"ok"`
+		audit.Report
+		Error string `json:"error,omitempty"`
+	}{OK: err == nil, Report: rep}
+	if err != nil { out.Error = err.Error() }
+	json.NewEncoder(w).Encode(out)
+}
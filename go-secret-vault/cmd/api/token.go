@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/auth"
+	"go-secret-vault/internal/policy"
+)
+
+// tokenPath: revoking someone else's token needs delete on it in the
+// policies; every token can refresh and revoke itself.
+const tokenPath = authPrefix + "token"
+
+// tokenResp is what every login and refresh returns.
+type tokenResp struct {
+	Token     string   `json:"token"`
+	Policies  []string `json:"policies"`
+	ExpiresIn int      `json:"expires_in"` // seconds
+}
+
+// issueToken answers a login with a new token and returns its claims for
+// the audit entry (nil when signing failed and the error was sent).
+func (a *api) issueToken(w http.ResponseWriter, u auth.User, ttl time.Duration) *auth.Claims {
+	tok, c, err := a.jwt.Issue(u, ttl)
+	if err != nil { http.Error(w, err.Error(), 500); return nil }
+	writeToken(w, tok, c)
+	return c
+}
+
+func writeToken(w http.ResponseWriter, tok string, c *auth.Claims) {
+	json.NewEncoder(w).Encode(tokenResp{Token: tok, Policies: c.Roles, ExpiresIn: int(c.ExpiresAt.Sub(c.IssuedAt.Time).Seconds())})
+}
+
+type tokenInfo struct {
+	Username     string    `json:"username"`
+	Policies     []string  `json:"policies"`
+	JTI          string    `json:"jti"`
+	IssuedAt     time.Time `json:"issued_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	TTL          int       `json:"ttl"` // seconds left
+	OrigIssuedAt time.Time `json:"orig_issued_at,omitempty"`
+}
+
+// GET /auth/token/self
+func (a *api) tokenSelf(w http.ResponseWriter, r *http.Request) {
+	c, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok { http.Error(w, "missing bearer token", 401); return }
+	info := tokenInfo{Username: c.Username, Policies: c.Roles, JTI: c.ID, IssuedAt: c.IssuedAt.Time, ExpiresAt: c.ExpiresAt.Time, TTL: int(time.Until(c.ExpiresAt.Time).Seconds())}
+	if c.OrigIssuedAt != nil { info.OrigIssuedAt = c.OrigIssuedAt.Time }
+	json.NewEncoder(w).Encode(info)
+}
+
+// POST /auth/token/refresh: a new token for the caller; the old one stops
+// working.
+func (a *api) refreshToken(w http.ResponseWriter, r *http.Request) {
+	c, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok { http.Error(w, "missing bearer token", 401); return }
+	tok, next, err := a.jwt.Refresh(c)
+	if err != nil {
+		a.log.Log(audit.Entry{Actor: c.Username, Action: "token.refresh", Outcome: "denied", Target: c.ID, Meta: map[string]string{"error": err.Error()}})
+		tokenError(w, err)
+		return
+	}
+	a.log.Log(audit.Entry{Actor: c.Username, Action: "token.refresh", Outcome: "ok", Target: c.ID, Meta: map[string]string{"jti": next.ID, "expires_at": next.ExpiresAt.Time.Format(time.RFC3339)}})
+	writeToken(w, tok, next)
+}
+
+// POST /auth/token/revoke: no body revokes the caller's token;
+// {"token": "..."} or {"jti": "..."} (from the login audit entries)
+// revokes another one.
+func (a *api) revokeToken(w http.ResponseWriter, r *http.Request) {
+	c, ok := auth.ClaimsFromCtx(r.Context())
+	if !ok { http.Error(w, "missing bearer token", 401); return }
+	var body struct{ Token, JTI string }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && r.ContentLength != 0 { http.Error(w, "bad json", 400); return }
+	jti, until := c.ID, c.ExpiresAt.Time
+	switch {
+	case body.Token != "":
+		other, err := a.jwt.Parse(body.Token)
+		if err != nil { tokenError(w, err); return }
+		jti, until = other.ID, other.ExpiresAt.Time
+	case body.JTI != "":
+		jti, until = body.JTI, time.Time{}
+	}
+	if jti != c.ID && !a.allow(w, r, policy.Delete, tokenPath, jti) { return }
+	if err := a.jwt.Revoke(jti, until); err != nil { tokenError(w, err); return }
+	a.log.Log(audit.Entry{Actor: c.Username, Action: "token.revoke", Outcome: "ok", Target: jti})
+	w.WriteHeader(204)
+}
+
+// reapRevokedTokens forgets revocations of expired tokens (driven by the
+// reaper in main).
+func (a *api) reapRevokedTokens() {
+	if n, err := a.store.PruneRevokedTokens(time.Now()); err != nil {
+		log.Printf("token reaper: %v", err)
+	} else if n > 0 {
+		a.log.Log(audit.Entry{Actor: "system", Action: "token.prune", Outcome: "ok", Meta: map[string]string{"count": strconv.Itoa(n)}})
+	}
+}
+
+func tokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, auth.ErrTokenRevoked): http.Error(w, err.Error(), 409)
+	case errors.Is(err, auth.ErrRefreshLimit): http.Error(w, err.Error(), 403)
+	default: http.Error(w, err.Error(), 400)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/policy"
+	"go-secret-vault/internal/transit"
+)
+
+// transitPrefix: policy paths of transit keys are "transit/<name>"; create,
+// read and update manage the key, encrypt/decrypt use it.
+const transitPrefix = "transit/"
+
+func (a *api) transitRoutes(r chi.Router) {
+	r.Post("/keys/{name}", a.transitCreate)
+	r.Get("/keys/{name}", a.transitKey)
+	r.Post("/keys/{name}/rotate", a.transitRotate)
+	r.Post("/keys/{name}/config", a.transitConfig)
+	r.Post("/encrypt/{name}", a.transitEncrypt)
+	r.Post("/decrypt/{name}", a.transitDecrypt)
+	r.Post("/rewrap/{name}", a.transitRewrap)
+}
+
+type transitKeyResp struct {
+	Name                 string    `json:"name"`
+	LatestVersion        int       `json:"latest_version"`
+	MinDecryptionVersion int       `json:"min_decryption_version"`
+	Versions             []int     `json:"versions"`
+	CreatedAt            time.Time `json:"created_at"`
+	RotatedAt            time.Time `json:"rotated_at"`
+}
+
+func keyResp(k transit.Key) transitKeyResp {
+	out := transitKeyResp{Name: k.Name, LatestVersion: k.LatestVersion, MinDecryptionVersion: k.MinDecryptionVersion, CreatedAt: k.CreatedAt, RotatedAt: k.RotatedAt}
+	for v := range k.Versions { out.Versions = append(out.Versions, v) }
+	sort.Ints(out.Versions)
+	return out
+}
+
+// transitOp checks c on the key and logs the outcome of fn.
+func (a *api) transitOp(w http.ResponseWriter, r *http.Request, c policy.Capability, action string, fn func(name string) (any, map[string]string, error)) {
+	name := chi.URLParam(r, "name")
+	if !a.allow(w, r, c, transitPrefix+name, "") { return }
+	out, meta, err := fn(name)
+	if err != nil {
+		a.log.Log(audit.Entry{Actor: who(r), Action: action, Outcome: "error", Target: transitPrefix + name, Meta: map[string]string{"error": err.Error()}})
+		transitError(w, err)
+		return
+	}
+	a.log.Log(audit.Entry{Actor: who(r), Action: action, Outcome: "ok", Target: transitPrefix + name, Meta: meta})
+	json.NewEncoder(w).Encode(out)
+}
+
+func (a *api) transitCreate(w http.ResponseWriter, r *http.Request) {
+	a.transitOp(w, r, policy.Create, "transit.create", func(name string) (any, map[string]string, error) {
+		k, err := a.transit.CreateKey(name)
+		return keyResp(k), nil, err
+	})
+}
+
+func (a *api) transitKey(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !a.allow(w, r, policy.Read, transitPrefix+name, "") { return }
+	k, err := a.transit.Key(name)
+	if err != nil { transitError(w, err); return }
+	json.NewEncoder(w).Encode(keyResp(k))
+}
+
+func (a *api) transitRotate(w http.ResponseWriter, r *http.Request) {
+	a.transitOp(w, r, policy.Update, "transit.rotate", func(name string) (any, map[string]string, error) {
+		k, err := a.transit.Rotate(name)
+		return keyResp(k), map[string]string{"version": strconv.Itoa(k.LatestVersion)}, err
+	})
+}
+
+func (a *api) transitConfig(w http.ResponseWriter, r *http.Request) {
+	a.transitOp(w, r, policy.Update, "transit.config", func(name string) (any, map[string]string, error) {
+		var body struct{ MinDecryptionVersion int `json:"min_decryption_version"` }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil { return nil, nil, transit.ErrBadInput }
+		k, err := a.transit.SetMinDecryptionVersion(name, body.MinDecryptionVersion)
+		return keyResp(k), map[string]string{"min_decryption_version": strconv.Itoa(body.MinDecryptionVersion)}, err
+	})
+}
+
+// POST /transit/encrypt/{name} {"plaintext": "<base64>"} → {"ciphertext": "vault:v1:..."}
+func (a *api) transitEncrypt(w http.ResponseWriter, r *http.Request) {
+	a.transitOp(w, r, policy.Encrypt, "transit.encrypt", func(name string) (any, map[string]string, error) {
+		var body struct{ Plaintext string `json:"plaintext"` }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil { return nil, nil, transit.ErrBadInput }
+		pt, err := base64.StdEncoding.DecodeString(body.Plaintext)
+		if err != nil { return nil, nil, fmt.Errorf("%w: plaintext must be base64", transit.ErrBadInput) }
+		c, err := a.transit.Encrypt(name, pt)
+		return map[string]string{"ciphertext": c}, nil, err
+	})
+}
+
+// POST /transit/decrypt/{name} {"ciphertext": "vault:v1:..."} → {"plaintext": "<base64>"}
+func (a *api) transitDecrypt(w http.ResponseWriter, r *http.Request) {
+	a.transitOp(w, r, policy.Decrypt, "transit.decrypt", func(name string) (any, map[string]string, error) {
+		var body struct{ Ciphertext string `json:"ciphertext"` }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil { return nil, nil, transit.ErrBadInput }
+		pt, err := a.transit.Decrypt(name, body.Ciphertext)
+		return map[string]string{"plaintext": base64.StdEncoding.EncodeToString(pt)}, nil, err
+	})
+}
+
+// POST /transit/rewrap/{name} {"ciphertext": "vault:v1:..."} → {"ciphertext": "vault:v2:..."}
+func (a *api) transitRewrap(w http.ResponseWriter, r *http.Request) {
+	a.transitOp(w, r, policy.Encrypt, "transit.rewrap", func(name string) (any, map[string]string, error) {
+		var body struct{ Ciphertext string `json:"ciphertext"` }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil { return nil, nil, transit.ErrBadInput }
+		c, err := a.transit.Rewrap(name, body.Ciphertext)
+		return map[string]string{"ciphertext": c}, nil, err
+	})
+}
+
+func transitError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, transit.ErrNotFound):
+		http.Error(w, err.Error(), 404)
+	case errors.Is(err, transit.ErrExists):
+		http.Error(w, err.Error(), 409)
+	case errors.Is(err, transit.ErrBadInput):
+		http.Error(w, err.Error(), 400)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/policy"
+	"go-secret-vault/internal/vault"
+)
+
+type versionMeta struct { Version int; CreatedAt time.Time; DestroyedAt *time.Time; Current bool }
+
+// GET /secrets/{id}/versions: version metadata, never the values.
+func (a *api) listVersions(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Read, id) { return }
+	sec, err := a.store.Get(id)
+	if err != nil { versionError(w, err); return }
+	out := make([]versionMeta, 0, len(sec.Versions))
+	for _, v := range sec.Versions {
+		out = append(out, versionMeta{Version: v.Version, CreatedAt: v.CreatedAt, DestroyedAt: v.DestroyedAt, Current: v.Version == sec.Version})
+	}
+	json.NewEncoder(w).Encode(struct {
+		secretMeta
+		Versions []versionMeta
+	}{metaOf(sec), out})
+}
+
+// POST /secrets/{id}/rollback {"version": N}: version N becomes current again,
+// as a new version.
+func (a *api) rollbackSecret(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Update, id) { return }
+	var body struct{ Version int `json:"version"` }
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Version < 1 { http.Error(w, "bad version", 400); return }
+	sec, err := a.vault.Rollback(id, body.Version)
+	if err != nil { versionError(w, err); return }
+	a.changed()
+	a.log.Log(audit.Entry{Actor: who(r), Action: "rollback", Outcome: "ok", Target: id, Meta: map[string]string{"from": strconv.Itoa(body.Version), "version": strconv.Itoa(sec.Version)}})
+	json.NewEncoder(w).Encode(metaOf(sec))
+}
+
+func (a *api) undeleteSecret(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Delete, id) { return }
+	sec, err := a.store.Undelete(id)
+	if err != nil { http.Error(w, err.Error(), 400); return }
+	a.changed()
+	a.log.Log(audit.Entry{Actor: who(r), Action: "undelete", Outcome: "ok", Target: id})
+	json.NewEncoder(w).Encode(metaOf(sec))
+}
+
+// POST /secrets/{id}/destroy {"versions": [1, 2]}: wipes those versions for
+// good; without versions, purges the whole secret.
+func (a *api) destroySecret(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if !a.allowID(w, r, policy.Delete, id) { return }
+	var body struct{ Versions []int `json:"versions"` }
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	if len(body.Versions) == 0 {
+		if err := a.store.Destroy(id); err != nil { versionError(w, err); return }
+		a.changed()
+		a.log.Log(audit.Entry{Actor: who(r), Action: "destroy", Outcome: "ok", Target: id})
+		w.WriteHeader(204)
+		return
+	}
+	sec, err := a.store.DestroyVersions(id, body.Versions)
+	if err != nil { versionError(w, err); return }
+	a.changed()
+	vs, _ := json.Marshal(body.Versions)
+	a.log.Log(audit.Entry{Actor: who(r), Action: "destroy", Outcome: "ok", Target: id, Meta: map[string]string{"versions": string(vs)}})
+	json.NewEncoder(w).Encode(metaOf(sec))
+}
+
+func versionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, vault.ErrNotFound), errors.Is(err, vault.ErrDeleted):
+		http.Error(w, err.Error(), 404)
+	case errors.Is(err, vault.ErrDestroyed):
+		http.Error(w, err.Error(), 410)
+	default:
+		http.Error(w, err.Error(), 500)
+	}
+}
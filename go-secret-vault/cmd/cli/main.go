@@ -1,15 +1,20 @@
 package main
 
 import (
+	"encoding/base64"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+
+	"go-secret-vault/internal/audit"
+	"go-secret-vault/internal/config"
 )
 
 var base = env("GSV_ADDR", "http://localhost:8080")
@@ -22,20 +27,51 @@ func main() {
 	case "ls": ls()
 	case "get": get()
 	case "del": del()
+	case "versions": versions()
+	case "rollback": rollback()
+	case "undelete": undelete()
+	case "destroy": destroy()
 	case "export-k8s": exportK8s()
+	case "creds": creds()
+	case "lease": lease()
+	case "audit": auditCmd()
+	case "operator": operator()
+	case "approle": approle()
+	case "token": token()
 	default: usage()
 	}
 }
 
 func usage() {
-	fmt.Println(`gsv CLI
+	fmt.Print(`gsv CLI
 Usage:
   gsv login -u USER -p PASS
+  gsv login --approle ROLE_ID --secret-id SECRET_ID
+  gsv login --k8s ROLE [--jwt-file PATH]  (default: the pod's ServiceAccount token)
   gsv put NAME -v VALUE [--ttl 1h]
   gsv ls
-  gsv get ID
-  gsv del ID
+  gsv get ID [--version N]
+  gsv del ID                      (soft delete)
+  gsv versions ID
+  gsv rollback ID VERSION
+  gsv undelete ID
+  gsv destroy ID [--versions 1,2] (permanent; no --versions = whole secret)
   gsv export-k8s ID [--namespace default] [--key VALUE]
+  gsv creds DATABASE [--ttl 30m]  (dynamic credentials)
+  gsv lease renew LEASE_ID [--increment 1h]
+  gsv lease revoke LEASE_ID
+  gsv audit query [--actor A] [--action X] [--target T] [--since 168h|RFC3339] [--limit N]
+  gsv audit verify                (the server checks its log)
+  gsv audit verify --file PATH [--config PATH] [--index PATH]  (offline; HMAC key from the config, end checked against PATH.idx)
+  gsv operator init [--shares 5] [--threshold 3]
+  gsv operator unseal KEY
+  gsv operator seal
+  gsv operator status
+  gsv token self
+  gsv token refresh               (also done on its own when a token nears its expiry)
+  gsv token revoke [--jti ID]     (no --jti = log out)
+  gsv approle role-id NAME
+  gsv approle secret-id NAME
 `)
 }
 
@@ -43,10 +79,27 @@ func tokenPath() string { home, _ := os.UserHomeDir(); return filepath.Join(home
 func readToken() string { b, _ := os.ReadFile(tokenPath()); return strings.TrimSpace(string(b)) }
 func saveToken(tok string) { home, _ := os.UserHomeDir(); os.MkdirAll(filepath.Join(home, ".gsv"), 0o700); _ = os.WriteFile(tokenPath(), []byte(tok), 0o600) }
 
+const saTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
 func login() {
-	u, p := flag("-u"), flag("-p")
-	if u == "" || p == "" { fmt.Println("login requires -u and -p"); return }
-	resp, err := http.Post(base+"/login", "application/json", strings.NewReader(fmt.Sprintf(`{"username":"%s","password":"%s"}`, esc(u), esc(p))))
+	path, body := "/login", ""
+	switch {
+	case flag("--approle") != "":
+		sid := flag("--secret-id")
+		if sid == "" { sid = os.Getenv("GSV_SECRET_ID") }
+		if sid == "" { fmt.Println("login --approle requires --secret-id (or GSV_SECRET_ID)"); return }
+		path, body = "/login/approle", fmt.Sprintf(`{"role_id":"%s","secret_id":"%s"}`, esc(flag("--approle")), esc(sid))
+	case flag("--k8s") != "":
+		file := flag("--jwt-file"); if file == "" { file = saTokenFile }
+		jwt, err := os.ReadFile(file)
+		check(err)
+		path, body = "/login/kubernetes", fmt.Sprintf(`{"role":"%s","jwt":"%s"}`, esc(flag("--k8s")), esc(strings.TrimSpace(string(jwt))))
+	default:
+		u, p := flag("-u"), flag("-p")
+		if u == "" || p == "" { fmt.Println("login requires -u and -p"); return }
+		body = fmt.Sprintf(`{"username":"%s","password":"%s"}`, esc(u), esc(p))
+	}
+	resp, err := http.Post(base+path, "application/json", strings.NewReader(body))
 	check(err)
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 { fmt.Println("login failed:", resp.Status); return }
@@ -66,9 +119,45 @@ func put() {
 
 func ls() { req("GET", "/secrets/", "") }
 
-func get() { id := os.Args[2]; req("GET", "/secrets/"+url.PathEscape(id), "") }
+func get() {
+	id := os.Args[2]
+	path := "/secrets/" + url.PathEscape(id)
+	if v := flag("--version"); v != "" { path += "?version=" + url.QueryEscape(v) }
+	req("GET", path, "")
+}
+
+func del() { id := os.Args[2]; r := reqRaw("DELETE", "/secrets/"+url.PathEscape(id), ""); if r.StatusCode == 204 { fmt.Println("deleted (gsv undelete " + id + " to restore)") } else { fmt.Println("error:", r.Status) } }
 
-func del() { id := os.Args[2]; r := reqRaw("DELETE", "/secrets/"+url.PathEscape(id), ""); if r.StatusCode == 204 { fmt.Println("deleted") } else { fmt.Println("error:", r.Status) } }
+func versions() { id := os.Args[2]; req("GET", "/secrets/"+url.PathEscape(id)+"/versions", "") }
+
+func rollback() {
+	if len(os.Args) < 4 { usage(); return }
+	id, v := os.Args[2], os.Args[3]
+	if _, err := strconv.Atoi(v); err != nil { fmt.Println("rollback requires a version number"); return }
+	req("POST", "/secrets/"+url.PathEscape(id)+"/rollback", `{"version":`+v+`}`)
+}
+
+func undelete() { id := os.Args[2]; req("POST", "/secrets/"+url.PathEscape(id)+"/undelete", "") }
+
+func destroy() {
+	id := os.Args[2]
+	body := ""
+	if vs := flag("--versions"); vs != "" {
+		var n []int
+		for _, v := range strings.Split(vs, ",") {
+			i, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil { fmt.Println("bad --versions:", vs); return }
+			n = append(n, i)
+		}
+		b, _ := json.Marshal(map[string][]int{"versions": n})
+		body = string(b)
+	}
+	r := reqRaw("POST", "/secrets/"+url.PathEscape(id)+"/destroy", body)
+	defer r.Body.Close()
+	if r.StatusCode == 204 { fmt.Println("destroyed"); return }
+	b, _ := ioReadAll(r.Body)
+	fmt.Println(strings.TrimSpace(string(b)))
+}
 
 func exportK8s() {
 	id := os.Args[2]
@@ -80,6 +169,140 @@ func exportK8s() {
 	fmt.Print(string(b))
 }
 
+func creds() {
+	if len(os.Args) < 3 { usage(); return }
+	req("POST", "/database/creds/"+url.PathEscape(os.Args[2]), fmt.Sprintf(`{"ttl":"%s"}`, esc(flag("--ttl"))))
+}
+
+func lease() {
+	if len(os.Args) < 4 { usage(); return }
+	id := url.PathEscape(os.Args[3])
+	switch os.Args[2] {
+	case "renew": req("POST", "/leases/"+id+"/renew", fmt.Sprintf(`{"increment":"%s"}`, esc(flag("--increment"))))
+	case "revoke":
+		r := reqRaw("POST", "/leases/"+id+"/revoke", "")
+		defer r.Body.Close()
+		if r.StatusCode == 204 { fmt.Println("revoked"); return }
+		b, _ := ioReadAll(r.Body)
+		fmt.Println(strings.TrimSpace(string(b)))
+	default: usage()
+	}
+}
+
+func auditCmd() {
+	if len(os.Args) < 3 { usage(); return }
+	switch os.Args[2] {
+	case "query":
+		q := url.Values{}
+		for _, k := range []string{"actor", "action", "target", "since", "limit"} {
+			if v := flag("--" + k); v != "" { q.Set(k, v) }
+		}
+		req("GET", "/sys/audit?"+q.Encode(), "")
+	case "verify":
+		file := flag("--file")
+		if file == "" { req("GET", "/sys/audit/verify", ""); return }
+		cfgPath := flag("--config")
+		if cfgPath == "" { cfgPath = env("GSV_CONFIG", "./configs/config.yaml") }
+		cfg, err := config.Load(cfgPath)
+		check(err)
+		key, err := audit.Key(cfg.Audit)
+		check(err)
+		f, err := os.Open(file)
+		check(err)
+		defer f.Close()
+		// without the index only the chain is checked: lines cut from the end
+		// of the log leave a valid, shorter chain
+		idx := flag("--index")
+		if idx == "" { idx = file + ".idx" }
+		var rep audit.Report
+		if _, serr := os.Stat(idx); serr == nil {
+			rep, err = audit.VerifyIndexed(f, key, idx)
+		} else {
+			fmt.Printf("no index at %s: truncation at the end of the log is not detected\n", idx)
+			rep, err = audit.Verify(f, key)
+		}
+		if err != nil { fmt.Printf("TAMPERED after %d good entries: %v\n", rep.Entries, err); os.Exit(1) }
+		fmt.Printf("ok: %d entries (last seq %d), %d legacy lines\n", rep.Entries, rep.LastSeq, rep.Legacy)
+	default: usage()
+	}
+}
+
+func operator() {
+	if len(os.Args) < 3 { usage(); return }
+	switch os.Args[2] {
+	case "init":
+		n, k := flag("--shares"), flag("--threshold")
+		if n == "" { n = "5" }
+		if k == "" { k = "3" }
+		if _, err := strconv.Atoi(n); err != nil { fmt.Println("bad --shares:", n); return }
+		if _, err := strconv.Atoi(k); err != nil { fmt.Println("bad --threshold:", k); return }
+		r := reqRaw("POST", "/sys/init", `{"shares":`+n+`,"threshold":`+k+`}`)
+		defer r.Body.Close()
+		if r.StatusCode != 200 { b, _ := ioReadAll(r.Body); fmt.Println(strings.TrimSpace(string(b))); return }
+		var out struct{ Keys []string; Threshold int }
+		json.NewDecoder(r.Body).Decode(&out)
+		for i, k := range out.Keys { fmt.Printf("Unseal key %d: %s\n", i+1, k) }
+		fmt.Printf("\nThe vault is sealed. Any %d of these keys unseal it (gsv operator unseal KEY).\nStore them apart; they are not shown again.\n", out.Threshold)
+	case "unseal":
+		if len(os.Args) < 4 { usage(); return }
+		req("POST", "/sys/unseal", `{"key":"`+esc(os.Args[3])+`"}`)
+	case "seal": req("POST", "/sys/seal", "")
+	case "status": req("GET", "/sys/seal-status", "")
+	default: usage()
+	}
+}
+
+func approle() {
+	if len(os.Args) < 4 { usage(); return }
+	name := url.PathEscape(os.Args[3])
+	switch os.Args[2] {
+	case "role-id": req("GET", "/auth/approle/"+name+"/role-id", "")
+	case "secret-id": req("POST", "/auth/approle/"+name+"/secret-id", "")
+	default: usage()
+	}
+}
+
+func token() {
+	if len(os.Args) < 3 { usage(); return }
+	switch os.Args[2] {
+	case "self": req("GET", "/auth/token/self", "")
+	case "refresh": if !refresh() { fmt.Println("refresh failed") } else { fmt.Println("ok") }
+	case "revoke":
+		body := ""
+		if jti := flag("--jti"); jti != "" { body = `{"jti":"` + esc(jti) + `"}` }
+		r := reqRaw("POST", "/auth/token/revoke", body)
+		defer r.Body.Close()
+		if r.StatusCode != 204 { b, _ := ioReadAll(r.Body); fmt.Println(strings.TrimSpace(string(b))); return }
+		if body == "" { _ = os.Remove(tokenPath()) }
+		fmt.Println("revoked")
+	default: usage()
+	}
+}
+
+// refresh swaps the saved token for a new one.
+func refresh() bool {
+	r := send("POST", "/auth/token/refresh", "", readToken())
+	defer r.Body.Close()
+	if r.StatusCode != 200 { return false }
+	var out struct{ Token string `json:"token"` }
+	if json.NewDecoder(r.Body).Decode(&out) != nil || out.Token == "" { return false }
+	saveToken(out.Token)
+	return true
+}
+
+// nearExpiry: less than a third of the token's life is left. The claims
+// are read without checking the signature; the server does that.
+func nearExpiry(tok string) bool {
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 { return false }
+	b, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil { return false }
+	var c struct{ Exp, Iat int64 }
+	if json.Unmarshal(b, &c) != nil || c.Exp == 0 { return false }
+	now := time.Now().Unix()
+	return now < c.Exp && c.Exp-now < (c.Exp-c.Iat)/3
+}
+
 // helpers
 
 func req(method, path, body string) {
@@ -90,7 +313,11 @@ func req(method, path, body string) {
 }
 
 func reqRaw(method, path, body string) *http.Response {
-	tok := readToken()
+	if tok := readToken(); tok != "" && nearExpiry(tok) { refresh() }
+	return send(method, path, body, readToken())
+}
+
+func send(method, path, body, tok string) *http.Response {
 	req, _ := http.NewRequest(method, base+path, strings.NewReader(body))
 	if tok != "" { req.Header.Set("Authorization", "Bearer "+tok) }
 	if body != "" { req.Header.Set("Content-Type", "application/json") }
@@ -106,7 +333,4 @@ func esc(s string) string { return strings.ReplaceAll(s, "\"", "\\\"") }
 func check(err error) { if err != nil { panic(err) } }
 
 // tiny io util to avoid extra imports
-func ioReadAll(r io.Reader) ([]byte, error) { var b strings.Builder; _, err := io.Copy(&b, r); return []byte(b.String()), err }
-
-// imports we used implicitly
-import "io"
\ No newline at end of file
+func ioReadAll(r io.Reader) ([]byte, error) { var b strings.Builder; _, err := io.Copy(&b, r); return []byte(b.String()), err }
\ No newline at end of file
@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"log/syslog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go-secret-vault/internal/config"
+)
+
+// forwarder ships each line to syslog and/or a webhook from a goroutine,
+// so a slow receiver never blocks a request. Lines that don't fit in the
+// queue are dropped (the file is still the source of truth).
+type forwarder struct {
+	queue   chan []byte
+	done    chan struct{}
+	syslog  *syslog.Writer
+	webhook string
+	client  *http.Client
+}
+
+// newForwarder returns nil when forwarding is off. audit.syslog is "local"
+// or "udp://host:514" / "tcp://host:514".
+func newForwarder(cfg config.AuditCfg) (*forwarder, error) {
+	if cfg.Syslog == "" && cfg.Webhook == "" { return nil, nil }
+	f := &forwarder{queue: make(chan []byte, 1024), done: make(chan struct{}), webhook: cfg.Webhook, client: &http.Client{Timeout: 5 * time.Second}}
+	if cfg.Syslog != "" {
+		network, addr := "", ""
+		if cfg.Syslog != "local" {
+			u, err := url.Parse(cfg.Syslog)
+			if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") { return nil, fmt.Errorf("audit.syslog: want local, udp://host:port or tcp://host:port, got %q", cfg.Syslog) }
+			network, addr = u.Scheme, u.Host
+		}
+		w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_AUTH, "gsv")
+		if err != nil { return nil, fmt.Errorf("audit.syslog: %w", err) }
+		f.syslog = w
+	}
+	go f.run()
+	return f, nil
+}
+
+func (f *forwarder) send(line []byte) {
+	select {
+	case f.queue <- line:
+	default:
+		log.Printf("audit: forward queue full, entry not forwarded")
+	}
+}
+
+func (f *forwarder) run() {
+	defer close(f.done)
+	for line := range f.queue {
+		if f.syslog != nil {
+			if err := f.syslog.Info(string(bytes.TrimSpace(line))); err != nil { log.Printf("audit syslog: %v", err) }
+		}
+		if f.webhook != "" {
+			if err := f.post(line); err != nil { log.Printf("audit webhook: %v", err) }
+		}
+	}
+}
+
+func (f *forwarder) post(line []byte) error {
+	resp, err := f.client.Post(f.webhook, "application/json", bytes.NewReader(line))
+	if err != nil { return err }
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 { return fmt.Errorf("%s: %s", f.webhook, resp.Status) }
+	return nil
+}
+
+// close sends what is queued and stops.
+func (f *forwarder) close() {
+	close(f.queue)
+	<-f.done
+	if f.syslog != nil { f.syslog.Close() }
+}
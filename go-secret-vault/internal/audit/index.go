@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// index points actor, action and target values at the offsets of their
+// lines in the log; keys are "<value>\x00<offset>" so a prefix scan walks
+// one value oldest to newest. It can always be rebuilt from the log, so it
+// skips fsync.
+type index struct {
+	db *bolt.DB
+}
+
+var (
+	idxAll    = []byte("all")
+	idxActor  = []byte("actor")
+	idxAction = []byte("action")
+	idxTarget = []byte("target")
+	idxMeta   = []byte("meta")
+)
+
+func openIndex(path string) (*index, error) {
+	opts := &bolt.Options{Timeout: time.Second, NoSync: true}
+	db, err := bolt.Open(path, 0o600, opts)
+	if err != nil && !errors.Is(err, bolt.ErrTimeout) { // damaged by a crash: start over
+		_ = os.Remove(path)
+		db, err = bolt.Open(path, 0o600, opts)
+	}
+	if err != nil { return nil, err }
+	x := &index{db: db}
+	if err := db.Update(func(tx *bolt.Tx) error { return createBuckets(tx) }); err != nil { db.Close(); return nil, err }
+	return x, nil
+}
+
+var buckets = [][]byte{idxAll, idxActor, idxAction, idxTarget, idxMeta}
+
+func createBuckets(tx *bolt.Tx) error {
+	for _, b := range buckets {
+		if _, err := tx.CreateBucketIfNotExists(b); err != nil { return err }
+	}
+	return nil
+}
+
+func (x *index) close() { x.db.Close() }
+
+// head is where the index stops: the end offset of the last indexed line
+// and the seq and hash of the last chained one.
+func (x *index) head() (off, seq int64, hash string, err error) {
+	err = x.db.View(func(tx *bolt.Tx) error {
+		m := tx.Bucket(idxMeta)
+		if m == nil { return nil } // empty index opened read-only
+		off, _ = strconv.ParseInt(string(m.Get([]byte("offset"))), 10, 64)
+		seq, _ = strconv.ParseInt(string(m.Get([]byte("seq"))), 10, 64)
+		hash = string(m.Get([]byte("hash")))
+		return nil
+	})
+	return
+}
+
+func (x *index) add(e Entry, off, end int64) error {
+	return x.db.Update(func(tx *bolt.Tx) error {
+		o := binary.BigEndian.AppendUint64(nil, uint64(off))
+		if err := tx.Bucket(idxAll).Put(o, nil); err != nil { return err }
+		for b, v := range map[string]string{"actor": e.Actor, "action": e.Action, "target": e.Target} {
+			if v == "" { continue }
+			if err := tx.Bucket([]byte(b)).Put(key(v, off), nil); err != nil { return err }
+		}
+		m := tx.Bucket(idxMeta)
+		if err := m.Put([]byte("offset"), []byte(strconv.FormatInt(end, 10))); err != nil { return err }
+		if e.Hash == "" { return nil }
+		if err := m.Put([]byte("seq"), []byte(strconv.FormatInt(e.Seq, 10))); err != nil { return err }
+		return m.Put([]byte("hash"), []byte(e.Hash))
+	})
+}
+
+func key(v string, off int64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(v), 0), uint64(off))
+}
+
+// offsets calls fn with the offsets in bucket b under value v (all lines
+// when b is idxAll), newest first, until fn returns false.
+func (x *index) offsets(b []byte, v string, fn func(off int64) bool) error {
+	return x.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(b).Cursor()
+		prefix := []byte(nil)
+		if !bytes.Equal(b, idxAll) { prefix = append([]byte(v), 0) }
+		k, _ := c.Seek(append(append([]byte(nil), prefix...), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff))
+		if k == nil { k, _ = c.Last() } else { k, _ = c.Prev() }
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Prev() {
+			if !fn(int64(binary.BigEndian.Uint64(k[len(prefix):]))) { return nil }
+		}
+		return nil
+	})
+}
+
+// Query filters GET /sys/audit; empty fields match everything.
+type Query struct {
+	Actor, Action, Target string
+	Since                 time.Time
+	Limit                 int // default 100
+}
+
+// Query returns the matching entries, newest first. It walks the index of
+// the most selective filter and checks the others on each entry.
+func (l *Logger) Query(q Query) ([]Entry, error) {
+	if q.Limit <= 0 { q.Limit = 100 }
+	b, v := idxAll, ""
+	switch {
+	case q.Target != "": b, v = idxTarget, q.Target
+	case q.Actor != "": b, v = idxActor, q.Actor
+	case q.Action != "": b, v = idxAction, q.Action
+	}
+	out := []Entry{}
+	var err error
+	ierr := l.index.offsets(b, v, func(off int64) bool {
+		var e Entry
+		if e, err = l.entryAt(off); err != nil { return false }
+		if e.Time.Before(q.Since) { return false } // offsets are in time order
+		if (q.Actor == "" || e.Actor == q.Actor) && (q.Action == "" || e.Action == q.Action) && (q.Target == "" || e.Target == q.Target) {
+			out = append(out, e)
+		}
+		return len(out) < q.Limit
+	})
+	if ierr != nil { return nil, ierr }
+	return out, err
+}
@@ -1,38 +1,167 @@
 package audit
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"os"
+	"path/filepath"
 	"sync"
 	"time"
+
+	"go-secret-vault/internal/config"
 )
 
+// Logger appends one JSON line per entry. Lines are hash-chained: each one
+// carries the hash of the previous line and an HMAC of its own hash, so
+// editing, inserting or deleting lines breaks Verify.
 type Logger struct {
-	mu   sync.Mutex
-	file *os.File
+	mu    sync.Mutex
+	file  *os.File
+	size  int64
+	key   []byte
+	seq   int64
+	prev  string // hash of the last line
+	index *index
+	fwd   *forwarder
 }
 
+// ErrTruncated means the log no longer reaches the head recorded in the
+// index: lines were cut from its end or the file was replaced.
+var ErrTruncated = errors.New("audit log truncated")
+
 type Entry struct {
+	Seq     int64             `json:"seq,omitempty"`
 	Time    time.Time         `json:"time"`
 	Actor   string            `json:"actor"`
 	Action  string            `json:"action"`
 	Target  string            `json:"target"`
 	Outcome string            `json:"outcome"`
 	Meta    map[string]string `json:"meta,omitempty"`
+	Prev    string            `json:"prev,omitempty"`
+	Hash    string            `json:"hash,omitempty"`
+	HMAC    string            `json:"hmac,omitempty"`
+}
+
+// ErrNoKey: the chain needs its own key; deriving it from the JWT secret
+// let anyone holding that secret rewrite the log.
+var ErrNoKey = errors.New("audit.hmac_key_b64 (or GSV_AUDIT_KEY) is required: 32+ random bytes, base64")
+
+// Key decodes audit.hmac_key_b64.
+func Key(cfg config.AuditCfg) ([]byte, error) {
+	if cfg.HMACKeyB64 == "" { return nil, ErrNoKey }
+	k, err := base64.StdEncoding.DecodeString(cfg.HMACKeyB64)
+	if err != nil { return nil, fmt.Errorf("audit.hmac_key_b64: %w", err) }
+	if len(k) < 32 { return nil, ErrNoKey }
+	return k, nil
 }
 
-func New(path string) (*Logger, error) {
-	if err := os.MkdirAll("./data", 0o755); err != nil { /* best-effort */ }
-	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+// New opens the log, continues its chain and brings the index up to date.
+func New(cfg config.AuditCfg, key []byte) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0o755); err != nil { return nil, err }
+	f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
 	if err != nil { return nil, err }
-	return &Logger{file: f}, nil
+	st, err := f.Stat()
+	if err != nil { f.Close(); return nil, err }
+	idx, err := openIndex(cfg.Index)
+	if err != nil { f.Close(); return nil, err }
+	l := &Logger{file: f, size: st.Size(), key: key, index: idx}
+	if err := l.catchUp(); err != nil { l.Close(); return nil, err }
+	if l.fwd, err = newForwarder(cfg); err != nil { l.Close(); return nil, err }
+	return l, nil
 }
 
-func (l *Logger) Close() error { return l.file.Close() }
+func (l *Logger) Close() error {
+	if l.fwd != nil { l.fwd.close() }
+	l.index.close()
+	return l.file.Close()
+}
 
 func (l *Logger) Log(e Entry) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	e.Time = time.Now().UTC()
-	_ = json.NewEncoder(l.file).Encode(e)
-}
\ No newline at end of file
+	e.Seq, e.Prev = l.seq+1, l.prev
+	line := l.seal(&e)
+	if _, err := l.file.Write(line); err != nil { log.Printf("audit: %v", err); return }
+	off := l.size
+	l.size += int64(len(line))
+	l.seq, l.prev = e.Seq, e.Hash
+	if err := l.index.add(e, off, l.size); err != nil { log.Printf("audit index: %v", err) }
+	if l.fwd != nil { l.fwd.send(line) }
+}
+
+// seal fills Hash (sha256 of the line without hash and hmac) and HMAC and
+// returns the line to write.
+func (l *Logger) seal(e *Entry) []byte {
+	e.Hash, e.HMAC = "", ""
+	body, _ := json.Marshal(e)
+	sum := sha256.Sum256(body)
+	e.Hash = hex.EncodeToString(sum[:])
+	e.HMAC = sign(l.key, e.Hash)
+	return append(append(body[:len(body)-1], `,"hash":"`+e.Hash+`","hmac":"`+e.HMAC+`"}`...), '\n')
+}
+
+func sign(key []byte, hash string) string {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(hash))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// catchUp indexes the lines written after the index was last updated (or
+// all of them when the index is new) and loads the head of the chain. A log
+// shorter than the index is refused instead of starting a new chain; after
+// investigating, removing the index rebuilds it from the file.
+func (l *Logger) catchUp() error {
+	from, seq, prev, err := l.index.head()
+	if err != nil { return err }
+	if from > l.size {
+		return fmt.Errorf("%w: %s is %d bytes but the index reaches offset %d (seq %d)", ErrTruncated, l.file.Name(), l.size, from, seq)
+	}
+	l.seq, l.prev = seq, prev
+	r := bufio.NewReader(io.NewSectionReader(l.file, from, l.size-from))
+	off := from
+	for {
+		line, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) { break } // a partial last line is left out
+		if err != nil { return err }
+		next := off + int64(len(line))
+		var e Entry
+		if json.Unmarshal(bytes.TrimSpace(line), &e) == nil {
+			if e.Hash != "" { l.seq, l.prev = e.Seq, e.Hash }
+			if err := l.index.add(e, off, next); err != nil { return err }
+		}
+		off = next
+	}
+	return nil
+}
+
+// entryAt reads the entry of the line starting at off.
+func (l *Logger) entryAt(off int64) (Entry, error) {
+	line, err := bufio.NewReader(io.NewSectionReader(l.file, off, 1<<20)).ReadBytes('\n')
+	if err != nil && !errors.Is(err, io.EOF) { return Entry{}, err }
+	var e Entry
+	return e, json.Unmarshal(line, &e)
+}
+
+// Verify checks the chain of the server's own log (up to the last complete
+// line written so far) and that it still ends at the head of the index, so
+// lines cut from the end are reported too.
+func (l *Logger) Verify() (Report, error) {
+	l.mu.Lock()
+	size := l.size
+	_, seq, hash, err := l.index.head()
+	l.mu.Unlock()
+	if err != nil { return Report{}, err }
+	rep, err := Verify(io.NewSectionReader(l.file, 0, size), l.key)
+	if err == nil { err = rep.reaches(seq, hash) }
+	return rep, err
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go-secret-vault/internal/config"
+)
+
+func TestChainAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.AuditCfg{File: filepath.Join(dir, "audit.log"), Index: filepath.Join(dir, "audit.idx")}
+	key := []byte("k")
+	// a line from before the chain stays readable
+	os.WriteFile(cfg.File, []byte(`{"time":"2024-01-01T00:00:00Z","actor":"old","action":"login","target":"","outcome":"ok"}`+"\n"), 0o600)
+	l, err := New(cfg, key)
+	if err != nil { t.Fatal(err) }
+	l.Log(Entry{Actor: "alice", Action: "read", Target: "s1", Outcome: "ok"})
+	l.Log(Entry{Actor: "bob", Action: "read", Target: "s2", Outcome: "ok"})
+	l.Close()
+	// reopening continues the chain
+	if l, err = New(cfg, key); err != nil { t.Fatal(err) }
+	l.Log(Entry{Actor: "alice", Action: "update", Target: "s1", Outcome: "ok"})
+	rep, err := l.Verify()
+	if err != nil || rep.Entries != 3 || rep.Legacy != 1 || rep.LastSeq != 3 { t.Fatalf("verify = %+v, %v", rep, err) }
+	l.Close()
+
+	orig, _ := os.ReadFile(cfg.File)
+	lines := strings.SplitAfter(string(orig), "\n")
+	// lines: legacy, alice read, bob read, alice update, ""
+	forged := strings.Replace(lines[2], `"actor":"bob"`, `"actor":"eve"`, 1)
+	for name, c := range map[string]struct{ log, err string }{
+		"edited":   {lines[0] + lines[1] + forged + lines[3], "line 3: hash mismatch"},
+		"rehashed": {lines[0] + lines[1] + rehash(forged) + lines[3], "line 3: bad hmac"},
+		"removed":  {lines[0] + lines[1] + lines[3], "line 3: chain broken"},
+		"injected": {lines[0] + lines[1] + lines[0] + lines[2], "line 3: not chained"},
+	} {
+		if _, err := Verify(strings.NewReader(c.log), key); err == nil || !strings.HasPrefix(err.Error(), c.err) { t.Errorf("%s: err = %v, want %s", name, err, c.err) }
+	}
+	if _, err := Verify(bytes.NewReader(orig), []byte("other key")); err == nil { t.Error("wrong key accepted") }
+}
+
+// rehash recomputes the hash of a forged line, as someone without the key would.
+func rehash(line string) string {
+	i := strings.Index(line, `,"hash":"`)
+	body := line[:i] + "}"
+	sum := sha256.Sum256([]byte(body))
+	h := hex.EncodeToString(sum[:])
+	return line[:i] + `,"hash":"` + h + line[i+len(`,"hash":"`)+64:]
+}
+
+func TestQuery(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.AuditCfg{File: filepath.Join(dir, "audit.log"), Index: filepath.Join(dir, "audit.idx")}
+	l, err := New(cfg, []byte("k"))
+	if err != nil { t.Fatal(err) }
+	for _, e := range []Entry{
+		{Actor: "alice", Action: "read", Target: "prod/db"},
+		{Actor: "bob", Action: "read", Target: "prod/db"},
+		{Actor: "alice", Action: "update", Target: "prod/db"},
+		{Actor: "alice", Action: "read", Target: "team-a/x"},
+	} { l.Log(e) }
+
+	check := func(q Query, want ...int64) {
+		t.Helper()
+		got, err := l.Query(q)
+		if err != nil { t.Fatal(err) }
+		var seqs []int64
+		for _, e := range got { seqs = append(seqs, e.Seq) }
+		if fmt.Sprint(seqs) != fmt.Sprint(want) { t.Errorf("query %+v = %v, want %v", q, seqs, want) }
+	}
+	check(Query{Target: "prod/db", Action: "read"}, 2, 1)
+	check(Query{Actor: "alice"}, 4, 3, 1)
+	check(Query{Action: "read", Limit: 2}, 4, 2)
+	check(Query{}, 4, 3, 2, 1)
+	check(Query{Since: time.Now().Add(time.Hour)})
+	l.Close()
+
+	// a lost index is rebuilt from the log
+	os.Remove(cfg.Index)
+	if l, err = New(cfg, []byte("k")); err != nil { t.Fatal(err) }
+	defer l.Close()
+	check(Query{Actor: "bob"}, 2)
+	l.Log(Entry{Actor: "bob", Action: "delete", Target: "prod/db"})
+	check(Query{Actor: "bob"}, 5, 2)
+}
+
+func TestTruncatedTail(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.AuditCfg{File: filepath.Join(dir, "audit.log"), Index: filepath.Join(dir, "audit.idx")}
+	key := []byte("k")
+	l, err := New(cfg, key)
+	if err != nil { t.Fatal(err) }
+	for _, a := range []string{"alice", "bob", "carol"} { l.Log(Entry{Actor: a, Action: "read", Target: "s1", Outcome: "ok"}) }
+	orig, _ := os.ReadFile(cfg.File)
+	lines := strings.SplitAfter(string(orig), "\n")
+
+	// cutting the last line keeps the remaining chain valid; only the index head catches it
+	if err := os.WriteFile(cfg.File, []byte(lines[0]+lines[1]), 0o600); err != nil { t.Fatal(err) }
+	if rep, err := l.Verify(); !errors.Is(err, ErrTruncated) { t.Fatalf("running verify = %+v, %v", rep, err) }
+	l.Close()
+	if _, err := VerifyIndexed(strings.NewReader(lines[0]+lines[1]), key, cfg.Index); !errors.Is(err, ErrTruncated) { t.Fatalf("offline verify with index: %v", err) }
+	if _, err := New(cfg, key); !errors.Is(err, ErrTruncated) { t.Fatalf("reopen truncated log: %v", err) }
+
+	// removing the index is the explicit way to accept the file as it is
+	os.Remove(cfg.Index)
+	l, err = New(cfg, key)
+	if err != nil { t.Fatal(err) }
+	defer l.Close()
+	if rep, err := l.Verify(); err != nil || rep.LastSeq != 2 { t.Fatalf("rebuilt index: %+v, %v", rep, err) }
+}
+
+func TestKey(t *testing.T) {
+	if _, err := Key(config.AuditCfg{}); !errors.Is(err, ErrNoKey) { t.Fatalf("no key: %v", err) }
+	if _, err := Key(config.AuditCfg{HMACKeyB64: "c2hvcnQ="}); !errors.Is(err, ErrNoKey) { t.Fatalf("short key: %v", err) }
+	k, err := Key(config.AuditCfg{HMACKeyB64: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="})
+	if err != nil || len(k) != 32 { t.Fatalf("key = %d bytes, %v", len(k), err) }
+}
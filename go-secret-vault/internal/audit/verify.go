@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Report is the result of Verify. Legacy counts the lines written before
+// the chain existed; they can only appear at the start of the file.
+type Report struct {
+	Entries  int    `json:"entries"`
+	Legacy   int    `json:"legacy"`
+	LastSeq  int64  `json:"last_seq"`
+	LastHash string `json:"last_hash"`
+}
+
+// suffix is what seal appends to the hashed body of a line.
+var suffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})","hmac":"([0-9a-f]{64})"}$`)
+
+// Verify walks the log and fails at the first line that was changed,
+// inserted, removed or reordered, or whose HMAC does not match key.
+func Verify(r io.Reader, key []byte) (Report, error) {
+	var rep Report
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 { return rep, nil }
+		if err != nil && err != io.EOF { return rep, err }
+		line = bytes.TrimRight(line, "\n")
+		m := suffix.FindSubmatchIndex(line)
+		if m == nil {
+			if rep.Entries > 0 { return rep, fmt.Errorf("line %d: not chained", n) }
+			rep.Legacy++
+			continue
+		}
+		hash, mac := string(line[m[2]:m[3]]), string(line[m[4]:m[5]])
+		body := append(append([]byte(nil), line[:m[0]]...), '}')
+		sum := sha256.Sum256(body)
+		if hex.EncodeToString(sum[:]) != hash { return rep, fmt.Errorf("line %d: hash mismatch (entry was modified)", n) }
+		if !hmac.Equal([]byte(sign(key, hash)), []byte(mac)) { return rep, fmt.Errorf("line %d: bad hmac", n) }
+		var e Entry
+		if err := json.Unmarshal(body, &e); err != nil { return rep, fmt.Errorf("line %d: %w", n, err) }
+		if e.Prev != rep.LastHash || e.Seq != rep.LastSeq+1 {
+			return rep, fmt.Errorf("line %d: chain broken at seq %d (entries removed, inserted or reordered)", n, e.Seq)
+		}
+		rep.Entries++
+		rep.LastSeq, rep.LastHash = e.Seq, hash
+	}
+}
+
+// VerifyIndexed is Verify plus the check the server makes: the log must end
+// at the head recorded in the index, so lines cut from its end are caught
+// too. The index is opened read-only, which waits for the server to stop.
+func VerifyIndexed(r io.Reader, key []byte, indexPath string) (Report, error) {
+	db, err := bolt.Open(indexPath, 0o600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil { return Report{}, fmt.Errorf("index %s: %w", indexPath, err) }
+	defer db.Close()
+	_, seq, hash, err := (&index{db: db}).head()
+	if err != nil { return Report{}, err }
+	rep, err := Verify(r, key)
+	if err == nil { err = rep.reaches(seq, hash) }
+	return rep, err
+}
+
+// reaches fails with ErrTruncated when the verified log stops before the
+// index head.
+func (rep Report) reaches(seq int64, hash string) error {
+	if rep.LastSeq == seq && rep.LastHash == hash { return nil }
+	return fmt.Errorf("%w: log ends at seq %d but the index head is seq %d", ErrTruncated, rep.LastSeq, seq)
+}
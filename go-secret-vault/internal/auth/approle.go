@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-secret-vault/internal/config"
+)
+
+// SecretIDStore keeps the issued secret_ids by hash (vault.BoltStore); the
+// secret_id itself is only returned once, when it is issued.
+type SecretIDStore interface {
+	PutSecretID(hash string, b []byte) error
+	UpdateSecretID(hash string, fn func(b []byte) ([]byte, error)) error
+	PruneSecretIDs(drop func(b []byte) bool) (int, error)
+}
+
+var (
+	ErrUnknownAppRole = errors.New("unknown approle")
+	// ErrInvalidLogin covers every failed machine login, so a caller can't
+	// tell a wrong role_id from a used-up secret_id.
+	ErrInvalidLogin = errors.New("invalid credentials")
+)
+
+// SecretID is what POST /auth/approle/{name}/secret-id returns.
+type SecretID struct {
+	SecretID  string    `json:"secret_id"`
+	Accessor  string    `json:"secret_id_accessor"` // logged in place of the secret_id
+	ExpiresAt time.Time `json:"expires_at"`
+	NumUses   int       `json:"num_uses"` // 0 = unlimited
+}
+
+type secretIDRecord struct {
+	Role      string    `json:"role"`
+	Accessor  string    `json:"accessor"`
+	ExpiresAt time.Time `json:"expires_at"`
+	UsesLeft  int       `json:"uses_left"` // 0 = unlimited
+}
+
+// AppRole logs machines in with a role_id (from the config, like a
+// username) and a secret_id (issued by the API, like a one-off password).
+type AppRole struct {
+	roles    map[string]config.AppRoleCfg
+	byRoleID map[string]string
+	store    SecretIDStore
+	now      func() time.Time
+}
+
+func NewAppRole(cfgs []config.AppRoleCfg, store SecretIDStore) (*AppRole, error) {
+	a := &AppRole{roles: map[string]config.AppRoleCfg{}, byRoleID: map[string]string{}, store: store, now: time.Now}
+	for _, c := range cfgs {
+		if c.Name == "" { return nil, errors.New("approle: name is required") }
+		if len(c.RoleID) < 16 { return nil, fmt.Errorf("approle %q: role_id must be at least 16 characters", c.Name) }
+		if _, dup := a.roles[c.Name]; dup { return nil, fmt.Errorf("approle %q: duplicated name", c.Name) }
+		if _, dup := a.byRoleID[c.RoleID]; dup { return nil, fmt.Errorf("approle %q: role_id already used", c.Name) }
+		a.roles[c.Name] = c
+		a.byRoleID[c.RoleID] = c.Name
+	}
+	return a, nil
+}
+
+// RoleID returns the role_id of an approle, for deploy tooling.
+func (a *AppRole) RoleID(name string) (string, error) {
+	c, ok := a.roles[name]
+	if !ok { return "", fmt.Errorf("%w: %s", ErrUnknownAppRole, name) }
+	return c.RoleID, nil
+}
+
+// NewSecretID issues a secret_id for the approle, valid for its
+// secret_id_ttl and secret_id_num_uses.
+func (a *AppRole) NewSecretID(name string) (SecretID, error) {
+	c, ok := a.roles[name]
+	if !ok { return SecretID{}, fmt.Errorf("%w: %s", ErrUnknownAppRole, name) }
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil { return SecretID{}, err }
+	s := SecretID{SecretID: hex.EncodeToString(raw), Accessor: uuid.NewString(), ExpiresAt: a.now().UTC().Add(c.SecretIDTTL), NumUses: c.SecretIDUses}
+	b, _ := json.Marshal(secretIDRecord{Role: name, Accessor: s.Accessor, ExpiresAt: s.ExpiresAt, UsesLeft: c.SecretIDUses})
+	if err := a.store.PutSecretID(hashSecretID(s.SecretID), b); err != nil { return SecretID{}, err }
+	return s, nil
+}
+
+// Login checks the pair and spends one use of the secret_id. It returns
+// the user to issue a token for, the token TTL and the accessor of the
+// secret_id (for the audit log).
+func (a *AppRole) Login(roleID, secretID string) (User, time.Duration, string, error) {
+	name, ok := a.byRoleID[roleID]
+	if !ok || secretID == "" { return User{}, 0, "", ErrInvalidLogin }
+	c := a.roles[name]
+	var accessor string
+	err := a.store.UpdateSecretID(hashSecretID(secretID), func(b []byte) ([]byte, error) {
+		if b == nil { return nil, ErrInvalidLogin }
+		var rec secretIDRecord
+		if err := json.Unmarshal(b, &rec); err != nil { return b, err }
+		if subtle.ConstantTimeCompare([]byte(rec.Role), []byte(name)) != 1 { return b, ErrInvalidLogin }
+		if !a.now().Before(rec.ExpiresAt) { return nil, ErrInvalidLogin }
+		accessor = rec.Accessor
+		switch rec.UsesLeft {
+		case 0: return b, nil
+		case 1: return nil, nil // last use
+		}
+		rec.UsesLeft--
+		return json.Marshal(rec)
+	})
+	if err != nil { return User{}, 0, "", err }
+	return User{Username: "approle:" + name, Roles: c.Roles}, c.TokenTTL, accessor, nil
+}
+
+// Tidy drops the expired secret_ids; used-up ones are removed at login.
+func (a *AppRole) Tidy() (int, error) {
+	now := a.now()
+	return a.store.PruneSecretIDs(func(b []byte) bool {
+		var rec secretIDRecord
+		return json.Unmarshal(b, &rec) == nil && !now.Before(rec.ExpiresAt)
+	})
+}
+
+func hashSecretID(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,27 @@
+package auth
+
+import "context"
+
+type ctxKey struct{}
+
+// WithUser attaches the authenticated user (from the JWT claims) to ctx.
+func WithUser(ctx context.Context, username string, roles []string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, User{Username: username, Roles: roles})
+}
+
+func FromCtx(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(ctxKey{}).(User)
+	return u, ok
+}
+
+type claimsKey struct{}
+
+// WithClaims keeps the whole token for the /auth/token routes.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+func ClaimsFromCtx(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(*Claims)
+	return c, ok
+}
@@ -3,50 +3,136 @@ package auth
 import (
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+
+	"go-secret-vault/internal/config"
 )
 
-type JWT struct { key []byte }
+// Revocations keeps the revoked jtis until the tokens would have expired
+// anyway (vault.BoltStore). RevokeToken reports false when jti was already
+// revoked.
+type Revocations interface {
+	RevokeToken(jti string, until time.Time) (bool, error)
+	TokenRevoked(jti string) (bool, error)
+}
+
+var (
+	ErrTokenRevoked = errors.New("token revoked")
+	// ErrRefreshLimit: the token is max_ttl past its login; log in again.
+	ErrRefreshLimit = errors.New("token reached its max ttl, log in again")
+)
+
+type JWT struct {
+	key     []byte
+	cfg     config.TokenCfg
+	revoked Revocations // nil = tokens can't be revoked (tests)
+	now     func() time.Time
+}
 
 type Claims struct {
 	Username string   `json:"sub"`
 	Roles    []string `json:"roles"`
+	// OrigIssuedAt is the login time, kept across refreshes for max_ttl.
+	OrigIssuedAt *jwt.NumericDate `json:"orig_iat"`
 	jwt.RegisteredClaims
 }
 
-func NewJWT(secretB64 string) (*JWT, error) {
+func NewJWT(secretB64 string, cfg config.TokenCfg, revoked Revocations) (*JWT, error) {
 	k, err := base64.StdEncoding.DecodeString(secretB64)
 	if err != nil { return nil, err }
 	if len(k) < 32 { return nil, errors.New("jwt secret must be >=32 bytes") }
-	return &JWT{key: k}, nil
+	return &JWT{key: k, cfg: cfg, revoked: revoked, now: time.Now}, nil
+}
+
+// TTL is the token lifetime for a user with roles: the shortest role_ttls
+// entry among them, or default_ttl.
+func (j *JWT) TTL(roles []string) time.Duration {
+	ttl := time.Duration(0)
+	for _, r := range roles {
+		if d, ok := j.cfg.RoleTTLs[r]; ok && (ttl == 0 || d < ttl) { ttl = d }
+	}
+	if ttl == 0 { ttl = j.cfg.DefaultTTL }
+	return ttl
 }
 
-func (j *JWT) Issue(u User, ttl time.Duration) (string, error) {
+// Issue signs a new token for u valid for ttl, capped at max_ttl.
+func (j *JWT) Issue(u User, ttl time.Duration) (string, *Claims, error) {
+	now := j.now()
+	return j.sign(u.Username, u.Roles, now, now.Add(min(ttl, j.cfg.MaxTTL)))
+}
+
+func (j *JWT) sign(user string, roles []string, orig, exp time.Time) (string, *Claims, error) {
+	now := j.now()
 	claims := &Claims{
-		Username: u.Username,
-		Roles:    u.Roles,
+		Username:     user,
+		Roles:        roles,
+		OrigIssuedAt: jwt.NewNumericDate(orig),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(exp),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.key)
+	tok, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(j.key)
+	return tok, claims, err
 }
 
+// Refresh swaps c for a new token with the same lifetime (never past
+// max_ttl from the login) and revokes c, so each token refreshes once.
+func (j *JWT) Refresh(c *Claims) (string, *Claims, error) {
+	now := j.now()
+	if c.OrigIssuedAt == nil || c.IssuedAt == nil || c.ExpiresAt == nil { return "", nil, errors.New("token can't be refreshed") }
+	limit := c.OrigIssuedAt.Add(j.cfg.MaxTTL)
+	if !now.Before(limit) { return "", nil, ErrRefreshLimit }
+	exp := now.Add(c.ExpiresAt.Sub(c.IssuedAt.Time))
+	if exp.After(limit) { exp = limit }
+	if err := j.Revoke(c.ID, c.ExpiresAt.Time); err != nil { return "", nil, err }
+	return j.sign(c.Username, c.Roles, c.OrigIssuedAt.Time, exp)
+}
+
+// Revoke rejects the token with jti from now on. until is when it expires;
+// zero when unknown (then it is kept for max_ttl).
+func (j *JWT) Revoke(jti string, until time.Time) error {
+	if j.revoked == nil { return errors.New("token revocation is not configured") }
+	if until.IsZero() { until = j.now().Add(j.cfg.MaxTTL) }
+	ok, err := j.revoked.RevokeToken(jti, until)
+	if err != nil { return err }
+	if !ok { return ErrTokenRevoked }
+	return nil
+}
+
+// Parse accepts only HS256 tokens signed with our key that carry exp and a
+// jti, and are not revoked. Pinning the method keeps "alg": "none" and
+// RS/HS confusion out.
 func (j *JWT) Parse(token string) (*Claims, error) {
-	tok, err := jwt.ParseWithClaims(token, &Claims{}, func(t *jwt.Token) (interface{}, error) { return j.key, nil })
+	tok, err := jwt.ParseWithClaims(token, &Claims{}, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 { return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"]) }
+		return j.key, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired(), jwt.WithTimeFunc(j.now))
 	if err != nil { return nil, err }
-	if c, ok := tok.Claims.(*Claims); ok && tok.Valid { return c, nil }
-	return nil, errors.New("invalid token")
+	c, ok := tok.Claims.(*Claims)
+	if !ok || !tok.Valid || c.ID == "" { return nil, errors.New("invalid token") }
+	if j.revoked != nil {
+		revoked, err := j.revoked.TokenRevoked(c.ID)
+		if err != nil { return nil, err }
+		if revoked { return nil, ErrTokenRevoked }
+	}
+	return c, nil
 }
 
+// unsealPaths need no token: for init and unseal the unseal keys are the
+// credential.
+var unsealPaths = map[string]bool{"/sys/init": true, "/sys/unseal": true, "/sys/seal-status": true}
+
 func (j *JWT) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasPrefix(r.URL.Path, "/healthz") || strings.HasPrefix(r.URL.Path, "/login") {
+		if strings.HasPrefix(r.URL.Path, "/healthz") || strings.HasPrefix(r.URL.Path, "/login") || unsealPaths[r.URL.Path] {
 			next.ServeHTTP(w, r); return
 		}
 		h := r.Header.Get("Authorization")
@@ -55,9 +141,9 @@ func (j *JWT) Middleware(next http.Handler) http.Handler {
 			http.Error(w, "missing bearer token", http.StatusUnauthorized); return
 		}
 		claims, err := j.Parse(parts[1])
+		if errors.Is(err, ErrTokenRevoked) { http.Error(w, "token revoked", http.StatusUnauthorized); return }
 		if err != nil { http.Error(w, "invalid token", http.StatusUnauthorized); return }
-		// attach user to context (simplified)
-		r = r.WithContext(WithUser(r.Context(), claims.Username, claims.Roles))
+		r = r.WithContext(WithClaims(WithUser(r.Context(), claims.Username, claims.Roles), claims))
 		next.ServeHTTP(w, r)
 	})
-}
\ No newline at end of file
+}
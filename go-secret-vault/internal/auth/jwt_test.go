@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"go-secret-vault/internal/config"
+)
+
+type memRevocations map[string]time.Time
+
+func (m memRevocations) RevokeToken(jti string, until time.Time) (bool, error) {
+	if _, ok := m[jti]; ok { return false, nil }
+	m[jti] = until
+	return true, nil
+}
+
+func (m memRevocations) TokenRevoked(jti string) (bool, error) { _, ok := m[jti]; return ok, nil }
+
+const testSecret = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=" // 32 bytes
+
+func newTestJWT(t *testing.T, now *time.Time) (*JWT, memRevocations) {
+	rev := memRevocations{}
+	j, err := NewJWT(testSecret, config.TokenCfg{DefaultTTL: time.Hour, MaxTTL: 3 * time.Hour, RoleTTLs: map[string]time.Duration{"admin": 15 * time.Minute, "ci": 30 * time.Minute}}, rev)
+	if err != nil { t.Fatal(err) }
+	j.now = func() time.Time { return *now }
+	return j, rev
+}
+
+func TestTTL(t *testing.T) {
+	now := time.Now()
+	j, _ := newTestJWT(t, &now)
+	for roles, want := range map[string]time.Duration{"": time.Hour, "team-a": time.Hour, "ci": 30 * time.Minute, "ci,admin": 15 * time.Minute} {
+		if got := j.TTL(strings.Split(roles, ",")); got != want { t.Errorf("TTL(%q) = %v, want %v", roles, got, want) }
+	}
+}
+
+func TestRefreshRevoke(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	j, _ := newTestJWT(t, &now)
+	tok, c, err := j.Issue(User{Username: "alice", Roles: []string{"team-a"}}, time.Hour)
+	if err != nil { t.Fatal(err) }
+	if _, err := j.Parse(tok); err != nil { t.Fatal(err) }
+
+	now = now.Add(50 * time.Minute)
+	tok2, c2, err := j.Refresh(c)
+	if err != nil || c2.ID == c.ID || !c2.ExpiresAt.Equal(now.Add(time.Hour)) || !c2.OrigIssuedAt.Equal(c.OrigIssuedAt.Time) { t.Fatalf("refresh = %+v %v", c2, err) }
+	if _, err := j.Parse(tok); !errors.Is(err, ErrTokenRevoked) { t.Fatalf("old token after refresh: %v", err) }
+	if _, _, err := j.Refresh(c); !errors.Is(err, ErrTokenRevoked) { t.Fatalf("second refresh of the old token: %v", err) }
+
+	// refreshes stop at max_ttl from the login
+	now = now.Add(100 * time.Minute)
+	_, c3, err := j.Refresh(c2)
+	if err != nil || !c3.ExpiresAt.Equal(c.OrigIssuedAt.Add(3*time.Hour)) { t.Fatalf("capped refresh = %v %v", c3.ExpiresAt, err) }
+	now = c3.ExpiresAt.Time
+	if _, _, err := j.Refresh(c3); !errors.Is(err, ErrRefreshLimit) { t.Fatalf("refresh past max_ttl: %v", err) }
+
+	if err := j.Revoke(c2.ID, c2.ExpiresAt.Time); !errors.Is(err, ErrTokenRevoked) { t.Fatalf("revoke twice: %v", err) }
+	if _, err := j.Parse(tok2); err == nil { t.Fatal("refreshed token still valid") }
+}
+
+func TestParsePinsHS256(t *testing.T) {
+	now := time.Now()
+	j, _ := newTestJWT(t, &now)
+	claims := func() *Claims {
+		return &Claims{Username: "mallory", Roles: []string{"admin"}, RegisteredClaims: jwt.RegisteredClaims{ID: "x", ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))}}
+	}
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	hs512, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, claims()).SignedString(j.key)
+	noExp, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Username: "mallory", RegisteredClaims: jwt.RegisteredClaims{ID: "x"}}).SignedString(j.key)
+	noJTI, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{Username: "mallory", RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))}}).SignedString(j.key)
+	for name, tok := range map[string]string{"none": none, "HS512": hs512, "no exp": noExp, "no jti": noJTI} {
+		if _, err := j.Parse(tok); err == nil { t.Errorf("%s: accepted", name) }
+	}
+	ok, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString(j.key)
+	if _, err := j.Parse(ok); err != nil { t.Fatalf("HS256: %v", err) }
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"go-secret-vault/internal/config"
+)
+
+// KubeAuth logs pods in with their ServiceAccount token: the API server
+// checks it (TokenReview) and the role maps the ServiceAccount to vault
+// roles.
+type KubeAuth struct {
+	cs        kubernetes.Interface
+	audiences []string
+	roles     map[string]config.KubeRoleCfg
+}
+
+func NewKubeAuth(cs kubernetes.Interface, cfg config.KubeAuthCfg) (*KubeAuth, error) {
+	k := &KubeAuth{cs: cs, audiences: cfg.Audiences, roles: map[string]config.KubeRoleCfg{}}
+	for _, r := range cfg.Roles {
+		if r.Name == "" || len(r.ServiceAccounts) == 0 || len(r.Namespaces) == 0 { return nil, fmt.Errorf("auth.kubernetes role %q: name, service_accounts and namespaces are required", r.Name) }
+		if _, dup := k.roles[r.Name]; dup { return nil, fmt.Errorf("auth.kubernetes role %q: duplicated name", r.Name) }
+		k.roles[r.Name] = r
+	}
+	return k, nil
+}
+
+// Login reviews the token and checks that its ServiceAccount is bound to
+// role. It returns the user to issue a token for and the token TTL.
+func (k *KubeAuth) Login(ctx context.Context, role, token string) (User, time.Duration, error) {
+	r, ok := k.roles[role]
+	if !ok || token == "" { return User{}, 0, ErrInvalidLogin }
+	tr, err := k.cs.AuthenticationV1().TokenReviews().Create(ctx, &authv1.TokenReview{Spec: authv1.TokenReviewSpec{Token: token, Audiences: k.audiences}}, meta.CreateOptions{})
+	if err != nil { return User{}, 0, fmt.Errorf("token review: %w", err) }
+	if !tr.Status.Authenticated { return User{}, 0, ErrInvalidLogin }
+	ns, sa, ok := serviceAccount(tr.Status.User.Username)
+	if !ok { return User{}, 0, ErrInvalidLogin }
+	if !matches(r.Namespaces, ns) || !matches(r.ServiceAccounts, sa) { return User{}, 0, ErrInvalidLogin }
+	return User{Username: "k8s:" + ns + "/" + sa, Roles: r.Roles}, r.TokenTTL, nil
+}
+
+// serviceAccount splits "system:serviceaccount:<namespace>:<name>".
+func serviceAccount(username string) (ns, name string, ok bool) {
+	parts := strings.Split(username, ":")
+	if len(parts) != 4 || parts[0] != "system" || parts[1] != "serviceaccount" || parts[2] == "" || parts[3] == "" { return "", "", false }
+	return parts[2], parts[3], true
+}
+
+func matches(allowed []string, v string) bool {
+	return slices.Contains(allowed, "*") || slices.Contains(allowed, v)
+}
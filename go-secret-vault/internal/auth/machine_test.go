@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"go-secret-vault/internal/config"
+)
+
+// memSecretIDs is the bolt bucket in a map.
+type memSecretIDs map[string][]byte
+
+func (m memSecretIDs) PutSecretID(hash string, b []byte) error { m[hash] = b; return nil }
+
+func (m memSecretIDs) UpdateSecretID(hash string, fn func(b []byte) ([]byte, error)) error {
+	next, err := fn(m[hash])
+	if next == nil { delete(m, hash) } else { m[hash] = next }
+	return err
+}
+
+func (m memSecretIDs) PruneSecretIDs(drop func(b []byte) bool) (int, error) {
+	n := 0
+	for k, v := range m {
+		if drop(v) { delete(m, k); n++ }
+	}
+	return n, nil
+}
+
+func TestAppRole(t *testing.T) {
+	store := memSecretIDs{}
+	cfgs := []config.AppRoleCfg{
+		{Name: "ci", RoleID: "ci-role-id-0123456789", Roles: []string{"deployer"}, SecretIDTTL: time.Hour, SecretIDUses: 2, TokenTTL: 15 * time.Minute},
+		{Name: "batch", RoleID: "batch-role-id-0123456789", Roles: []string{"batch"}, SecretIDTTL: time.Hour, TokenTTL: time.Hour},
+	}
+	a, err := NewAppRole(cfgs, store)
+	if err != nil { t.Fatal(err) }
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	sid, err := a.NewSecretID("ci")
+	if err != nil { t.Fatal(err) }
+	if _, err := a.NewSecretID("nope"); !errors.Is(err, ErrUnknownAppRole) { t.Fatalf("unknown approle: %v", err) }
+	if _, _, _, err := a.Login("batch-role-id-0123456789", sid.SecretID); !errors.Is(err, ErrInvalidLogin) { t.Fatalf("secret_id of another role: %v", err) }
+
+	u, ttl, acc, err := a.Login("ci-role-id-0123456789", sid.SecretID)
+	if err != nil || u.Username != "approle:ci" || u.Roles[0] != "deployer" || ttl != 15*time.Minute || acc != sid.Accessor { t.Fatalf("login = %+v %v %q %v", u, ttl, acc, err) }
+	if _, _, _, err := a.Login("ci-role-id-0123456789", sid.SecretID); err != nil { t.Fatalf("second use: %v", err) }
+	if _, _, _, err := a.Login("ci-role-id-0123456789", sid.SecretID); !errors.Is(err, ErrInvalidLogin) { t.Fatalf("third use: %v", err) }
+	if len(store) != 0 { t.Fatal("used-up secret_id kept") }
+
+	// unlimited uses, until it expires
+	sid, _ = a.NewSecretID("batch")
+	for i := 0; i < 3; i++ {
+		if _, _, _, err := a.Login("batch-role-id-0123456789", sid.SecretID); err != nil { t.Fatalf("use %d: %v", i, err) }
+	}
+	a.NewSecretID("ci")
+	now = now.Add(time.Hour)
+	if _, _, _, err := a.Login("batch-role-id-0123456789", sid.SecretID); !errors.Is(err, ErrInvalidLogin) { t.Fatalf("expired: %v", err) }
+	if n, _ := a.Tidy(); n != 1 || len(store) != 0 { t.Fatalf("tidy = %d, left %d", n, len(store)) }
+
+	if _, err := NewAppRole([]config.AppRoleCfg{{Name: "x", RoleID: "short"}}, store); err == nil { t.Fatal("short role_id accepted") }
+}
+
+func TestKubeAuth(t *testing.T) {
+	cs := fake.NewSimpleClientset()
+	tokens := map[string]string{"web-token": "system:serviceaccount:prod:web", "job-token": "system:serviceaccount:batch:job"}
+	cs.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		tr := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview).DeepCopy()
+		if user, ok := tokens[tr.Spec.Token]; ok {
+			tr.Status = authv1.TokenReviewStatus{Authenticated: true, User: authv1.UserInfo{Username: user}}
+		}
+		return true, tr, nil
+	})
+	k, err := NewKubeAuth(cs, config.KubeAuthCfg{Roles: []config.KubeRoleCfg{
+		{Name: "web", ServiceAccounts: []string{"web"}, Namespaces: []string{"prod", "staging"}, Roles: []string{"app"}, TokenTTL: time.Hour},
+		{Name: "any-batch", ServiceAccounts: []string{"*"}, Namespaces: []string{"batch"}, Roles: []string{"batch"}, TokenTTL: time.Hour},
+	}})
+	if err != nil { t.Fatal(err) }
+	ctx := context.Background()
+
+	u, ttl, err := k.Login(ctx, "web", "web-token")
+	if err != nil || u.Username != "k8s:prod/web" || u.Roles[0] != "app" || ttl != time.Hour { t.Fatalf("login = %+v %v %v", u, ttl, err) }
+	if u, _, err = k.Login(ctx, "any-batch", "job-token"); err != nil || u.Roles[0] != "batch" { t.Fatalf("wildcard = %+v %v", u, err) }
+	for _, c := range []struct{ role, token string }{{"web", "job-token"}, {"any-batch", "web-token"}, {"web", "forged"}, {"nope", "web-token"}} {
+		if _, _, err := k.Login(ctx, c.role, c.token); !errors.Is(err, ErrInvalidLogin) { t.Fatalf("%s with %s: %v", c.role, c.token, err) }
+	}
+}
@@ -2,7 +2,9 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,9 +19,21 @@ type SecurityCfg struct {
 	JWTSecretB64 string `yaml:"jwt_secret_b64"`
 }
 
-type AuditCfg struct { File string `yaml:"file"` }
+// AuditCfg: the log is hash-chained with an HMAC keyed by hmac_key_b64
+// (required, 32+ bytes). Index backs GET /sys/audit and Syslog/Webhook
+// forward every entry.
+type AuditCfg struct {
+	File       string `yaml:"file"`
+	Index      string `yaml:"index"` // default <file>.idx
+	HMACKeyB64 string `yaml:"hmac_key_b64"`
+	Syslog     string `yaml:"syslog"` // local | udp://host:514 | tcp://host:514
+	Webhook    string `yaml:"webhook"`
+}
 
-type StorageCfg struct { BoltPath string `yaml:"bolt_path"` }
+type StorageCfg struct {
+	BoltPath    string `yaml:"bolt_path"`
+	MaxVersions int    `yaml:"max_versions"` // versions kept per secret, oldest trimmed; 0 = default 10, -1 = unlimited
+}
 
 type User struct {
 	Username       string   `yaml:"username"`
@@ -27,19 +41,127 @@ type User struct {
 	Roles          []string `yaml:"roles"`
 }
 
+// PolicyCfg grants a role capabilities (read, create, update, delete, list,
+// export) on secret name globs such as "team-a/*".
+type PolicyCfg struct {
+	Role  string    `yaml:"role"`
+	Rules []RuleCfg `yaml:"rules"`
+}
+
+type RuleCfg struct {
+	Path         string   `yaml:"path"`
+	Capabilities []string `yaml:"capabilities"`
+}
+
+// TransitCfg enables the /transit encryption-as-a-service API.
 type TransitCfg struct {
-	Enabled bool   `yaml:"enabled"`
+	Enabled bool `yaml:"enabled"`
+	// Deprecated: ignored since transit runs in this server; only read to warn.
 	BaseURL string `yaml:"base_url"`
-	Token   string `yaml:"token"`
+	// Deprecated: ignored, see BaseURL.
+	Token string `yaml:"token"`
+}
+
+// SealCfg: with Shamir on, the root key comes from unseal key shares
+// ("gsv operator init") instead of master_key_b64, and the server starts
+// sealed.
+type SealCfg struct {
+	Shamir bool `yaml:"shamir"`
+}
+
+// DatabaseCfg is a dynamic credentials engine: every request creates a new
+// user that lives for a lease. Type "postgres" runs the SQL statements on
+// DSN; type "command" runs the Create/Renew/Revoke argv instead. Both
+// accept {{name}}, {{password}} and {{expiration}}.
+type DatabaseCfg struct {
+	Name       string        `yaml:"name"`
+	Type       string        `yaml:"type"` // postgres | command
+	DSN        string        `yaml:"dsn"`
+	Create     []string      `yaml:"create"`
+	Renew      []string      `yaml:"renew"`
+	Revoke     []string      `yaml:"revoke"`
+	DefaultTTL time.Duration `yaml:"default_ttl"`
+	MaxTTL     time.Duration `yaml:"max_ttl"`
+}
+
+// K8sSyncCfg mirrors vault secrets into Kubernetes Secrets: the controller
+// creates and updates them and deletes the ones whose mapping is gone.
+type K8sSyncCfg struct {
+	Enabled    bool             `yaml:"enabled"`
+	Kubeconfig string           `yaml:"kubeconfig"` // "" = in-cluster
+	Interval   time.Duration    `yaml:"interval"`
+	Mappings   []K8sSyncMapping `yaml:"mappings"`
+}
+
+// K8sSyncMapping puts the current value of a vault secret under Key of the
+// Secret Namespace/Name; several mappings can share a Secret.
+type K8sSyncMapping struct {
+	Secret    string `yaml:"secret"` // vault secret name
+	Namespace string `yaml:"namespace"`
+	Name      string `yaml:"name"`
+	Key       string `yaml:"key"`
+}
+
+// AuthCfg: token lifetimes and the machine logins next to Users. Every
+// login issues the usual JWT with the roles of the matched entry.
+type AuthCfg struct {
+	Token      TokenCfg     `yaml:"token"`
+	AppRoles   []AppRoleCfg `yaml:"approles"`
+	Kubernetes KubeAuthCfg  `yaml:"kubernetes"`
+}
+
+// TokenCfg: users get the shortest RoleTTLs entry among their roles
+// (DefaultTTL when none is listed). No token, refreshed or not, lives past
+// MaxTTL from its login.
+type TokenCfg struct {
+	DefaultTTL time.Duration            `yaml:"default_ttl"`
+	MaxTTL     time.Duration            `yaml:"max_ttl"`
+	RoleTTLs   map[string]time.Duration `yaml:"role_ttls"`
+}
+
+// AppRoleCfg: a client logs in with RoleID plus a secret_id issued by
+// POST /auth/approle/<name>/secret-id, valid for SecretIDTTL and
+// SecretIDUses logins (0 = unlimited).
+type AppRoleCfg struct {
+	Name         string        `yaml:"name"`
+	RoleID       string        `yaml:"role_id"`
+	Roles        []string      `yaml:"roles"`
+	SecretIDTTL  time.Duration `yaml:"secret_id_ttl"`
+	SecretIDUses int           `yaml:"secret_id_num_uses"`
+	TokenTTL     time.Duration `yaml:"token_ttl"`
+}
+
+// KubeAuthCfg: pods log in with their ServiceAccount token, checked with
+// the TokenReview API.
+type KubeAuthCfg struct {
+	Enabled    bool          `yaml:"enabled"`
+	Kubeconfig string        `yaml:"kubeconfig"` // "" = in-cluster
+	Audiences  []string      `yaml:"audiences"`
+	Roles      []KubeRoleCfg `yaml:"roles"`
+}
+
+// KubeRoleCfg maps ServiceAccounts (names and namespaces, "*" = any) to
+// vault roles.
+type KubeRoleCfg struct {
+	Name            string        `yaml:"name"`
+	ServiceAccounts []string      `yaml:"service_accounts"`
+	Namespaces      []string      `yaml:"namespaces"`
+	Roles           []string      `yaml:"roles"`
+	TokenTTL        time.Duration `yaml:"token_ttl"`
 }
 
 type Config struct {
-	Server   ServerCfg   `yaml:"server"`
-	Security SecurityCfg `yaml:"security"`
-	Audit    AuditCfg    `yaml:"audit"`
-	Storage  StorageCfg  `yaml:"storage"`
-	Users    []User      `yaml:"users"`
-	Transit  TransitCfg  `yaml:"transit"`
+	Server    ServerCfg     `yaml:"server"`
+	Security  SecurityCfg   `yaml:"security"`
+	Audit     AuditCfg      `yaml:"audit"`
+	Storage   StorageCfg    `yaml:"storage"`
+	Users     []User        `yaml:"users"`
+	Policies  []PolicyCfg   `yaml:"policies"`
+	Transit   TransitCfg    `yaml:"transit"`
+	Seal      SealCfg       `yaml:"seal"`
+	Databases []DatabaseCfg `yaml:"databases"`
+	K8sSync   K8sSyncCfg    `yaml:"k8s_sync"`
+	Auth      AuthCfg       `yaml:"auth"`
 }
 
 func Load(path string) (*Config, error) {
@@ -50,11 +172,38 @@ func Load(path string) (*Config, error) {
 	// Env overrides
 	if v := os.Getenv("GSV_MASTER_KEY"); v != "" { c.Security.MasterKeyB64 = v }
 	if v := os.Getenv("GSV_JWT_SECRET"); v != "" { c.Security.JWTSecretB64 = v }
-	if c.Security.MasterKeyB64 == "" || c.Security.JWTSecretB64 == "" {
+	if v := os.Getenv("GSV_AUDIT_KEY"); v != "" { c.Audit.HMACKeyB64 = v }
+	if (c.Security.MasterKeyB64 == "" && !c.Seal.Shamir) || c.Security.JWTSecretB64 == "" {
 		return nil, errors.New("missing master/jwt secrets; set in config or env")
 	}
 	if c.Server.Addr == "" { c.Server.Addr = ":8080" }
 	if c.Storage.BoltPath == "" { c.Storage.BoltPath = "./data/vault.db" }
+	if c.Storage.MaxVersions == 0 { c.Storage.MaxVersions = 10 }
+	if c.Storage.MaxVersions < -1 { return nil, errors.New("storage.max_versions: use -1 for unlimited") }
 	if c.Audit.File == "" { c.Audit.File = "./data/audit.log" }
+	if c.Audit.Index == "" { c.Audit.Index = c.Audit.File + ".idx" }
+	if c.K8sSync.Interval == 0 { c.K8sSync.Interval = time.Minute }
+	for i := range c.K8sSync.Mappings {
+		m := &c.K8sSync.Mappings[i]
+		if m.Namespace == "" { m.Namespace = "default" }
+		if m.Key == "" { m.Key = "VALUE" }
+	}
+	if c.Auth.Token.DefaultTTL == 0 { c.Auth.Token.DefaultTTL = time.Hour }
+	if c.Auth.Token.MaxTTL == 0 { c.Auth.Token.MaxTTL = 24 * time.Hour }
+	if c.Auth.Token.DefaultTTL > c.Auth.Token.MaxTTL { return nil, errors.New("auth.token: default_ttl is above max_ttl") }
+	for i := range c.Auth.AppRoles {
+		r := &c.Auth.AppRoles[i]
+		if r.SecretIDTTL == 0 { r.SecretIDTTL = 24 * time.Hour }
+		if r.TokenTTL == 0 { r.TokenTTL = time.Hour }
+	}
+	for i := range c.Auth.Kubernetes.Roles {
+		if c.Auth.Kubernetes.Roles[i].TokenTTL == 0 { c.Auth.Kubernetes.Roles[i].TokenTTL = time.Hour }
+	}
+	for i := range c.Databases {
+		d := &c.Databases[i]
+		if d.DefaultTTL == 0 { d.DefaultTTL = time.Hour }
+		if d.MaxTTL == 0 { d.MaxTTL = 24 * time.Hour }
+		if d.DefaultTTL > d.MaxTTL { return nil, fmt.Errorf("database %q: default_ttl is above max_ttl", d.Name) }
+	}
 	return &c, nil
 }
\ No newline at end of file
@@ -3,12 +3,13 @@ package crypto
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/hkdf"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"io"
+
+	"golang.org/x/crypto/hkdf"
 )
 
 type AESEncryptor struct { key []byte }
@@ -21,6 +22,15 @@ func NewAESEncryptor(masterKeyB64 string) (*AESEncryptor, error) {
 	return &AESEncryptor{key: k}, nil
 }
 
+// NewAESEncryptorKey uses a raw 32-byte key (the unsealed root key).
+func NewAESEncryptorKey(k []byte) (*AESEncryptor, error) {
+	if len(k) != 32 { return nil, errors.New("root key must be 32 bytes") }
+	return &AESEncryptor{key: append([]byte(nil), k...)}, nil
+}
+
+// Wipe zeroes the key; the encryptor is unusable afterwards.
+func (e *AESEncryptor) Wipe() { clear(e.key) }
+
 func (e *AESEncryptor) deriveKey(salt []byte) ([]byte, error) {
 	h := hkdf.New(sha256.New, e.key, salt, []byte("gsv-aesgcm"))
 	out := make([]byte, 32)
@@ -0,0 +1,225 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeyringStore persists the keyring, already encrypted with the root key.
+type KeyringStore interface {
+	LoadKeyring() (string, error) // "" = no keyring yet
+	SaveKeyring(cipher string) error
+}
+
+// KEK is a key-encryption key; only the active one wraps new data keys.
+type KEK struct {
+	Version   int       `json:"version"`
+	Key       []byte    `json:"key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type keyring struct {
+	Active int   `json:"active"`
+	Keys   []KEK `json:"keys"`
+}
+
+// Envelope encrypts every value with a fresh data key (DEK) and wraps the
+// DEK with the active KEK. Ciphertexts look like "gsv:v<kek>:<base64>" so
+// old KEKs keep decrypting after a rotation; values without the header are
+// from before the keyring and are read with the root key.
+type Envelope struct {
+	mu    sync.RWMutex
+	root  *AESEncryptor
+	store KeyringStore
+	ring  keyring
+}
+
+const envelopePrefix = "gsv:v"
+
+// NewEnvelope loads the keyring (encrypted with root) or creates it with a
+// first random KEK.
+func NewEnvelope(root *AESEncryptor, store KeyringStore) (*Envelope, error) {
+	e := &Envelope{root: root, store: store}
+	c, err := store.LoadKeyring()
+	if err != nil { return nil, err }
+	if c == "" {
+		if _, err := e.Rotate(); err != nil { return nil, err }
+		return e, nil
+	}
+	b, err := root.Decrypt(c)
+	if err != nil { return nil, fmt.Errorf("keyring: %w (wrong master key?)", err) }
+	if err := json.Unmarshal(b, &e.ring); err != nil { return nil, fmt.Errorf("keyring: %w", err) }
+	return e, nil
+}
+
+// Rotate adds a new KEK and makes it active; existing values still decrypt
+// with their KEK until Rewrap.
+func (e *Envelope) Rotate() (int, error) {
+	k := make([]byte, 32)
+	if _, err := rand.Read(k); err != nil { return 0, err }
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	next := e.ring
+	next.Keys = append(append([]KEK(nil), e.ring.Keys...), KEK{Version: e.ring.Active + 1, Key: k, CreatedAt: time.Now().UTC()})
+	next.Active++
+	b, _ := json.Marshal(next)
+	c, err := e.root.Encrypt(b)
+	if err != nil { return 0, err }
+	if err := e.store.SaveKeyring(c); err != nil { return 0, err }
+	e.ring = next
+	return next.Active, nil
+}
+
+// Rekey encrypts the keyring with a new root key (moving from the
+// configured master key to a Shamir root key) and returns it without saving
+// it, so the caller can store it together with the seal config. The KEKs
+// don't change.
+func (e *Envelope) Rekey(root *AESEncryptor) (string, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	b, _ := json.Marshal(e.ring)
+	defer clear(b)
+	return root.Encrypt(b)
+}
+
+// Wipe zeroes the root key and every KEK (sealing the vault).
+func (e *Envelope) Wipe() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.root.Wipe()
+	for _, k := range e.ring.Keys { clear(k.Key) }
+	e.ring = keyring{}
+}
+
+// Active is the version of the KEK used for new values.
+func (e *Envelope) Active() int { e.mu.RLock(); defer e.mu.RUnlock(); return e.ring.Active }
+
+// Keys lists the KEK versions and creation times (never the key material).
+func (e *Envelope) Keys() []KEK {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out := make([]KEK, 0, len(e.ring.Keys))
+	for _, k := range e.ring.Keys { out = append(out, KEK{Version: k.Version, CreatedAt: k.CreatedAt}) }
+	return out
+}
+
+func (e *Envelope) kek(v int) ([]byte, error) {
+	for _, k := range e.ring.Keys {
+		if k.Version == v { return k.Key, nil }
+	}
+	return nil, fmt.Errorf("unknown key version %d", v)
+}
+
+func (e *Envelope) Encrypt(plaintext []byte) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil { return "", err }
+	data, err := seal(dek, plaintext, nil)
+	if err != nil { return "", err }
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.wrap(e.ring.Active, dek, data)
+}
+
+// wrap seals dek with KEK v (the header is the AAD) and builds the ciphertext.
+func (e *Envelope) wrap(v int, dek, data []byte) (string, error) {
+	kek, err := e.kek(v)
+	if err != nil { return "", err }
+	header := envelopePrefix + strconv.Itoa(v)
+	wrapped, err := seal(kek, dek, []byte(header))
+	if err != nil { return "", err }
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(wrapped)))
+	buf = append(append(buf, wrapped...), data...)
+	return header + ":" + base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// unwrap returns the DEK and the encrypted data of an envelope ciphertext.
+func (e *Envelope) unwrap(c string) (dek, data []byte, err error) {
+	v, body, err := parseHeader(c)
+	if err != nil { return nil, nil, err }
+	buf, err := base64.StdEncoding.DecodeString(body)
+	if err != nil { return nil, nil, err }
+	if len(buf) < 2 { return nil, nil, errors.New("cipher too short") }
+	n := int(binary.BigEndian.Uint16(buf))
+	if len(buf) < 2+n { return nil, nil, errors.New("cipher too short") }
+	e.mu.RLock()
+	kek, err := e.kek(v)
+	e.mu.RUnlock()
+	if err != nil { return nil, nil, err }
+	dek, err = open(kek, buf[2:2+n], []byte(envelopePrefix+strconv.Itoa(v)))
+	if err != nil { return nil, nil, fmt.Errorf("unwrap data key: %w", err) }
+	return dek, buf[2+n:], nil
+}
+
+func (e *Envelope) Decrypt(c string) ([]byte, error) {
+	if !strings.HasPrefix(c, envelopePrefix) { return e.root.Decrypt(c) }
+	dek, data, err := e.unwrap(c)
+	if err != nil { return nil, err }
+	return open(dek, data, nil)
+}
+
+// KeyVersion is the KEK version of a ciphertext (0 = written before the keyring).
+func KeyVersion(c string) int {
+	v, _, err := parseHeader(c)
+	if err != nil { return 0 }
+	return v
+}
+
+// Rewrap re-encrypts c under the active KEK. Envelope values only get their
+// DEK re-wrapped; older values are decrypted and encrypted again. changed is
+// false when c already uses the active KEK.
+func (e *Envelope) Rewrap(c string) (out string, changed bool, err error) {
+	active := e.Active()
+	if c == "" || KeyVersion(c) == active { return c, false, nil }
+	if !strings.HasPrefix(c, envelopePrefix) {
+		pt, err := e.root.Decrypt(c)
+		if err != nil { return "", false, err }
+		out, err = e.Encrypt(pt)
+		return out, err == nil, err
+	}
+	dek, data, err := e.unwrap(c)
+	if err != nil { return "", false, err }
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	out, err = e.wrap(active, dek, data)
+	return out, err == nil, err
+}
+
+func parseHeader(c string) (int, string, error) {
+	if !strings.HasPrefix(c, envelopePrefix) { return 0, "", errors.New("not an envelope ciphertext") }
+	head, body, ok := strings.Cut(c[len(envelopePrefix):], ":")
+	v, err := strconv.Atoi(head)
+	if !ok || err != nil || v < 1 { return 0, "", errors.New("bad ciphertext header") }
+	return v, body, nil
+}
+
+// seal returns nonce|ciphertext of plaintext under key (AES-256-GCM).
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil { return nil, err }
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil { return nil, err }
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, buf, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil { return nil, err }
+	if len(buf) < gcm.NonceSize() { return nil, errors.New("nonce too short") }
+	return gcm.Open(nil, buf[:gcm.NonceSize()], buf[gcm.NonceSize():], aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil { return nil, err }
+	return cipher.NewGCM(block)
+}
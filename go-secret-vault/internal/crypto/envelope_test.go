@@ -0,0 +1,47 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+type memKeyring struct{ c string }
+
+func (m *memKeyring) LoadKeyring() (string, error) { return m.c, nil }
+func (m *memKeyring) SaveKeyring(c string) error   { m.c = c; return nil }
+
+func TestEnvelopeRotateAndRewrap(t *testing.T) {
+	root, err := NewAESEncryptor(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil { t.Fatal(err) }
+	ks := &memKeyring{}
+	e, err := NewEnvelope(root, ks)
+	if err != nil { t.Fatal(err) }
+	if e.Active() != 1 || ks.c == "" { t.Fatalf("active = %d", e.Active()) }
+
+	legacy, _ := root.Encrypt([]byte("legacy"))
+	c1, err := e.Encrypt([]byte("s3cr3t"))
+	if err != nil { t.Fatal(err) }
+	if !strings.HasPrefix(c1, "gsv:v1:") || KeyVersion(c1) != 1 || KeyVersion(legacy) != 0 { t.Fatalf("c1 = %s", c1) }
+
+	if v, err := e.Rotate(); err != nil || v != 2 { t.Fatalf("rotate = %d, %v", v, err) }
+	// the reloaded keyring still has both KEKs
+	e, err = NewEnvelope(root, ks)
+	if err != nil { t.Fatal(err) }
+	if pt, err := e.Decrypt(c1); err != nil || string(pt) != "s3cr3t" { t.Fatalf("decrypt v1 after rotate: %q %v", pt, err) }
+
+	for c, want := range map[string]string{c1: "s3cr3t", legacy: "legacy"} {
+		out, changed, err := e.Rewrap(c)
+		if err != nil || !changed || KeyVersion(out) != 2 { t.Fatalf("rewrap = %s %v %v", out, changed, err) }
+		if pt, err := e.Decrypt(out); err != nil || string(pt) != want { t.Fatalf("decrypt rewrapped: %q %v", pt, err) }
+		if _, changed, _ := e.Rewrap(out); changed { t.Fatal("rewrap of current cipher changed it") }
+	}
+
+	// the header is bound to the wrapped key: editing the version breaks it
+	c2, _ := e.Encrypt([]byte("x"))
+	if _, err := e.Decrypt(strings.Replace(c2, "gsv:v2:", "gsv:v1:", 1)); err == nil { t.Fatal("tampered header decrypted") }
+
+	other, _ := NewAESEncryptor(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))
+	if _, err := NewEnvelope(other, ks); err == nil { t.Fatal("keyring opened with another master key") }
+}
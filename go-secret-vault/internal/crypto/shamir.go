@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// SplitShares splits secret into n shares, any k of which rebuild it
+// (Shamir over GF(2^8), one random polynomial per byte). Each share is
+// len(secret)+1 bytes: the y values followed by the x coordinate.
+func SplitShares(secret []byte, n, k int) ([][]byte, error) {
+	if len(secret) == 0 { return nil, errors.New("empty secret") }
+	if k < 2 || n < k || n > 255 { return nil, errors.New("need 2 <= threshold <= shares <= 255") }
+	xs, err := shareXs(n)
+	if err != nil { return nil, err }
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = xs[i]
+	}
+	coef := make([]byte, k)
+	for b, s := range secret {
+		if _, err := rand.Read(coef[1:]); err != nil { return nil, err }
+		coef[0] = s
+		for i, x := range xs { shares[i][b] = evalPoly(coef, x) }
+	}
+	clear(coef)
+	return shares, nil
+}
+
+// CombineShares rebuilds the secret from k or more shares of SplitShares.
+// Wrong shares give a wrong secret, so callers must verify the result.
+func CombineShares(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 { return nil, errors.New("need at least 2 shares") }
+	size := len(shares[0])
+	if size < 2 { return nil, errors.New("share too short") }
+	seen := map[byte]bool{}
+	for _, s := range shares {
+		if len(s) != size { return nil, errors.New("shares have different lengths") }
+		x := s[size-1]
+		if x == 0 || seen[x] { return nil, errors.New("duplicated or invalid share") }
+		seen[x] = true
+	}
+	secret := make([]byte, size-1)
+	for b := range secret {
+		// Lagrange interpolation at x = 0
+		var acc byte
+		for i, si := range shares {
+			xi, num, den := si[size-1], byte(1), byte(1)
+			for j, sj := range shares {
+				if i == j { continue }
+				xj := sj[size-1]
+				num = gfMul(num, xj)
+				den = gfMul(den, xi^xj)
+			}
+			acc ^= gfMul(si[b], gfMul(num, gfInv(den)))
+		}
+		secret[b] = acc
+	}
+	return secret, nil
+}
+
+// shareXs picks n distinct non-zero x coordinates in random order.
+func shareXs(n int) ([]byte, error) {
+	xs := make([]byte, 255)
+	for i := range xs { xs[i] = byte(i + 1) }
+	r := make([]byte, len(xs))
+	if _, err := rand.Read(r); err != nil { return nil, err }
+	for i := len(xs) - 1; i > 0; i-- {
+		j := int(r[i]) % (i + 1)
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+	return xs[:n], nil
+}
+
+func evalPoly(coef []byte, x byte) byte {
+	var y byte
+	for i := len(coef) - 1; i >= 0; i-- { y = gfMul(y, x) ^ coef[i] }
+	return y
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial x^8+x^4+x^3+x+1.
+func gfMul(a, b byte) byte {
+	var p byte
+	for b > 0 {
+		if b&1 == 1 { p ^= a }
+		hi := a & 0x80
+		a <<= 1
+		if hi != 0 { a ^= 0x1b }
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv is a^254, the multiplicative inverse of a != 0.
+func gfInv(a byte) byte {
+	out := byte(1)
+	for i := 0; i < 254; i++ { out = gfMul(out, a) }
+	return out
+}
+
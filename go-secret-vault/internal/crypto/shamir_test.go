@@ -0,0 +1,24 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestShamirSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	shares, err := SplitShares(secret, 5, 3)
+	if err != nil { t.Fatal(err) }
+	if len(shares) != 5 || len(shares[0]) != len(secret)+1 { t.Fatalf("shares = %d x %d", len(shares), len(shares[0])) }
+
+	for _, pick := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		var in [][]byte
+		for _, i := range pick { in = append(in, shares[i]) }
+		got, err := CombineShares(in)
+		if err != nil || !bytes.Equal(got, secret) { t.Fatalf("combine %v = %x, %v", pick, got, err) }
+	}
+	// below the threshold the result is just noise
+	if got, _ := CombineShares(shares[:2]); bytes.Equal(got, secret) { t.Fatal("2 of 3 shares rebuilt the secret") }
+	if _, err := CombineShares([][]byte{shares[0], shares[0]}); err == nil { t.Fatal("duplicated share accepted") }
+	if _, err := SplitShares(secret, 2, 3); err == nil { t.Fatal("threshold above shares accepted") }
+}
@@ -0,0 +1,51 @@
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"go-secret-vault/internal/config"
+)
+
+// command runs an argv per step (no shell). Besides the {{...}} templates,
+// the process gets GSV_USERNAME, GSV_PASSWORD and GSV_EXPIRATION, which
+// keep the password out of the process list.
+type command struct {
+	create, renew, revoke []string
+}
+
+func newCommand(c config.DatabaseCfg) (*command, error) {
+	if len(c.Create) == 0 || len(c.Revoke) == 0 { return nil, errors.New("create and revoke commands are required") }
+	return &command{create: c.Create, renew: c.Renew, revoke: c.Revoke}, nil
+}
+
+func (c *command) Create(ctx context.Context, user, password string, expires time.Time) error {
+	return run(ctx, c.create, user, password, expires)
+}
+
+// Renew without a command only extends the lease.
+func (c *command) Renew(ctx context.Context, user string, expires time.Time) error {
+	if len(c.renew) == 0 { return nil }
+	return run(ctx, c.renew, user, "", expires)
+}
+
+func (c *command) Revoke(ctx context.Context, user string) error {
+	return run(ctx, c.revoke, user, "", time.Time{})
+}
+
+func run(ctx context.Context, argv []string, user, password string, expires time.Time) error {
+	r := vars(user, password, expires)
+	args := make([]string, len(argv))
+	for i, a := range argv { args[i] = r.Replace(a) }
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "GSV_USERNAME="+user, "GSV_PASSWORD="+password, "GSV_EXPIRATION="+r.Replace("{{expiration}}"))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", argv[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Package dynamic issues short-lived database credentials: each request
+// creates a new user on the backend, tracked by a lease that can be renewed
+// up to the max TTL and is revoked when it expires.
+package dynamic
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"go-secret-vault/internal/config"
+)
+
+// Store keeps the leases (no passwords, only who to revoke and when).
+type Store interface {
+	GetLease(id string) ([]byte, error) // nil = missing
+	PutLease(id string, b []byte) error
+	DeleteLease(id string) error
+	ListLeases() ([][]byte, error)
+}
+
+// Backend creates and drops the users of one database.
+type Backend interface {
+	Create(ctx context.Context, user, password string, expires time.Time) error
+	Renew(ctx context.Context, user string, expires time.Time) error
+	Revoke(ctx context.Context, user string) error
+}
+
+var (
+	ErrUnknownDatabase = errors.New("unknown database")
+	ErrLeaseNotFound   = errors.New("lease not found")
+	ErrLeaseExpired    = errors.New("lease expired")
+)
+
+type Lease struct {
+	ID           string    `json:"lease_id"`
+	Database     string    `json:"database"`
+	Username     string    `json:"username"`
+	IssuedAt     time.Time `json:"issued_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	MaxExpiresAt time.Time `json:"max_expires_at"` // renewals stop here
+}
+
+// Creds is a new credential; the password is only returned here.
+type Creds struct {
+	Lease
+	Password string `json:"password"`
+}
+
+type database struct {
+	cfg     config.DatabaseCfg
+	backend Backend
+}
+
+type Manager struct {
+	mu    sync.Mutex // serializes renew/revoke of a lease with the reaper
+	store Store
+	dbs   map[string]*database
+	now   func() time.Time
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+func New(cfgs []config.DatabaseCfg, store Store) (*Manager, error) {
+	m := &Manager{store: store, dbs: map[string]*database{}, now: time.Now}
+	for _, c := range cfgs {
+		if !validName.MatchString(c.Name) { return nil, fmt.Errorf("database %q: name must match %s", c.Name, validName) }
+		if _, dup := m.dbs[c.Name]; dup { return nil, fmt.Errorf("database %q: duplicated name", c.Name) }
+		var b Backend
+		var err error
+		switch c.Type {
+		case "postgres": b, err = newPostgres(c)
+		case "command": b, err = newCommand(c)
+		default: err = fmt.Errorf("unknown type %q (postgres|command)", c.Type)
+		}
+		if err != nil { return nil, fmt.Errorf("database %q: %w", c.Name, err) }
+		m.dbs[c.Name] = &database{cfg: c, backend: b}
+	}
+	return m, nil
+}
+
+// Creds creates a user valid for ttl (0 = default_ttl, capped at max_ttl).
+// The lease is stored first, so a crash never leaves a user nobody revokes.
+func (m *Manager) Creds(ctx context.Context, name string, ttl time.Duration) (Creds, error) {
+	db, ok := m.dbs[name]
+	if !ok { return Creds{}, fmt.Errorf("%w: %s", ErrUnknownDatabase, name) }
+	if ttl <= 0 { ttl = db.cfg.DefaultTTL }
+	if ttl > db.cfg.MaxTTL { ttl = db.cfg.MaxTTL }
+	now := m.now().UTC()
+	suffix, password := make([]byte, 4), make([]byte, 18)
+	if _, err := rand.Read(suffix); err != nil { return Creds{}, err }
+	if _, err := rand.Read(password); err != nil { return Creds{}, err }
+	c := Creds{
+		Lease: Lease{
+			ID: uuid.NewString(), Database: name, IssuedAt: now, ExpiresAt: now.Add(ttl), MaxExpiresAt: now.Add(db.cfg.MaxTTL),
+			Username: fmt.Sprintf("v_%s_%d_%s", strings.ToLower(strings.ReplaceAll(name, "-", "_")), now.Unix(), hex.EncodeToString(suffix)),
+		},
+		Password: base64.RawURLEncoding.EncodeToString(password),
+	}
+	if err := m.put(c.Lease); err != nil { return Creds{}, err }
+	if err := db.backend.Create(ctx, c.Username, c.Password, c.ExpiresAt); err != nil {
+		// Create may fail after the user exists (a later statement or step):
+		// revoke it now, or leave the lease expired for the reaper to retry.
+		rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		if rerr := m.revoke(rctx, c.Lease); rerr != nil {
+			c.ExpiresAt = now
+			_ = m.put(c.Lease)
+		}
+		return Creds{}, fmt.Errorf("create user on %s: %w", name, err)
+	}
+	return c, nil
+}
+
+func (m *Manager) Lease(id string) (Lease, error) {
+	b, err := m.store.GetLease(id)
+	if err != nil { return Lease{}, err }
+	if b == nil { return Lease{}, ErrLeaseNotFound }
+	var l Lease
+	return l, json.Unmarshal(b, &l)
+}
+
+// Renew extends the lease by increment (0 = default_ttl) from now, never
+// past its max TTL.
+func (m *Manager) Renew(ctx context.Context, id string, increment time.Duration) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, err := m.Lease(id)
+	if err != nil { return Lease{}, err }
+	now := m.now().UTC()
+	if !now.Before(l.ExpiresAt) { return Lease{}, ErrLeaseExpired }
+	db, ok := m.dbs[l.Database]
+	if !ok { return Lease{}, fmt.Errorf("%w: %s", ErrUnknownDatabase, l.Database) }
+	if increment <= 0 { increment = db.cfg.DefaultTTL }
+	exp := now.Add(increment)
+	if exp.After(l.MaxExpiresAt) { exp = l.MaxExpiresAt }
+	if err := db.backend.Renew(ctx, l.Username, exp); err != nil { return Lease{}, fmt.Errorf("renew user on %s: %w", l.Database, err) }
+	l.ExpiresAt = exp
+	return l, m.put(l)
+}
+
+// Revoke drops the user and deletes the lease.
+func (m *Manager) Revoke(ctx context.Context, id string) (Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l, err := m.Lease(id)
+	if err != nil { return Lease{}, err }
+	return l, m.revoke(ctx, l)
+}
+
+// RevokeExpired revokes every expired lease; failures stay for the next run.
+func (m *Manager) RevokeExpired(ctx context.Context) ([]Lease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	raw, err := m.store.ListLeases()
+	if err != nil { return nil, err }
+	now := m.now()
+	var done []Lease
+	var errs []error
+	for _, b := range raw {
+		var l Lease
+		if err := json.Unmarshal(b, &l); err != nil { errs = append(errs, err); continue }
+		if now.Before(l.ExpiresAt) { continue }
+		if err := m.revoke(ctx, l); err != nil { errs = append(errs, fmt.Errorf("lease %s: %w", l.ID, err)); continue }
+		done = append(done, l)
+	}
+	return done, errors.Join(errs...)
+}
+
+func (m *Manager) revoke(ctx context.Context, l Lease) error {
+	db, ok := m.dbs[l.Database]
+	if !ok { return fmt.Errorf("%w: %s", ErrUnknownDatabase, l.Database) }
+	if err := db.backend.Revoke(ctx, l.Username); err != nil { return fmt.Errorf("revoke user on %s: %w", l.Database, err) }
+	return m.store.DeleteLease(l.ID)
+}
+
+func (m *Manager) put(l Lease) error {
+	b, _ := json.Marshal(l)
+	return m.store.PutLease(l.ID, b)
+}
+
+// vars fills {{name}}, {{password}} and {{expiration}} in statements and
+// command arguments.
+func vars(user, password string, expires time.Time) *strings.Replacer {
+	exp := ""
+	if !expires.IsZero() { exp = expires.UTC().Format("2006-01-02 15:04:05+00") }
+	return strings.NewReplacer("{{name}}", user, "{{password}}", password, "{{expiration}}", exp)
+}
@@ -0,0 +1,106 @@
+package dynamic
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+
+	"go-secret-vault/internal/config"
+)
+
+type memStore map[string][]byte
+
+func (m memStore) GetLease(id string) ([]byte, error) { return m[id], nil }
+func (m memStore) PutLease(id string, b []byte) error { m[id] = b; return nil }
+func (m memStore) DeleteLease(id string) error        { delete(m, id); return nil }
+func (m memStore) ListLeases() ([][]byte, error) {
+	var out [][]byte
+	for _, b := range m { out = append(out, b) }
+	return out, nil
+}
+
+func TestPostgresLease(t *testing.T) {
+	cfg := config.DatabaseCfg{Name: "app", Type: "postgres", DSN: "postgres://vault@db/app", DefaultTTL: time.Hour, MaxTTL: 2 * time.Hour,
+		Create: []string{pgCreate[0], `GRANT SELECT ON ALL TABLES IN SCHEMA public TO "{{name}}"`}}
+	ms := memStore{}
+	m, err := New([]config.DatabaseCfg{cfg}, ms)
+	if err != nil { t.Fatal(err) }
+	db, mock, err := sqlmock.New()
+	if err != nil { t.Fatal(err) }
+	m.dbs["app"].backend = newPostgresDB(db, cfg)
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE ROLE "v_app_\d+_[0-9a-f]{8}" WITH LOGIN PASSWORD '[A-Za-z0-9_-]{24}' VALID UNTIL '2026-01-02 12:00:00\+00'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`GRANT SELECT ON ALL TABLES IN SCHEMA public TO "v_app_`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	c, err := m.Creds(context.Background(), "app", 3*time.Hour) // capped at max_ttl
+	if err != nil { t.Fatal(err) }
+	if !c.ExpiresAt.Equal(now.Add(2*time.Hour)) || ms[c.ID] == nil || strings.Contains(string(ms[c.ID]), c.Password) { t.Fatalf("lease = %+v, stored %s", c, ms[c.ID]) }
+
+	// renewals stop at the max TTL
+	now = now.Add(90 * time.Minute)
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER ROLE "` + c.Username + `" VALID UNTIL '2026-01-02 12:00:00\+00'`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+	l, err := m.Renew(context.Background(), c.ID, time.Hour)
+	if err != nil || !l.ExpiresAt.Equal(c.MaxExpiresAt) { t.Fatalf("renew = %+v, %v", l, err) }
+
+	// a failed revoke keeps the lease for the next run
+	now = now.Add(time.Hour)
+	mock.ExpectBegin()
+	mock.ExpectExec(`REASSIGN OWNED BY`).WillReturnError(errors.New("connection refused"))
+	mock.ExpectRollback()
+	if done, err := m.RevokeExpired(context.Background()); len(done) != 0 || err == nil || ms[c.ID] == nil { t.Fatalf("revoke = %v, %v", done, err) }
+	mock.ExpectBegin()
+	for _, s := range []string{"REASSIGN OWNED BY", "DROP OWNED BY", "DROP ROLE IF EXISTS"} { mock.ExpectExec(s).WillReturnResult(sqlmock.NewResult(0, 0)) }
+	mock.ExpectCommit()
+	if done, err := m.RevokeExpired(context.Background()); len(done) != 1 || err != nil || len(ms) != 0 { t.Fatalf("revoke = %v, %v", done, err) }
+
+	// a failed create is revoked at once; a role that was never created counts as revoked
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE ROLE`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`GRANT SELECT`).WillReturnError(errors.New("permission denied"))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`REASSIGN OWNED BY`).WillReturnError(&pq.Error{Code: "42704"})
+	mock.ExpectRollback()
+	if _, err := m.Creds(context.Background(), "app", 0); err == nil || len(ms) != 0 { t.Fatalf("creds = %v, leases = %d", err, len(ms)) }
+	if err := mock.ExpectationsWereMet(); err != nil { t.Fatal(err) }
+	if _, err := m.Renew(context.Background(), c.ID, 0); !errors.Is(err, ErrLeaseNotFound) { t.Fatalf("renew revoked lease: %v", err) }
+}
+
+func TestCommandBackend(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "log")
+	sh := func(step string) []string { return []string{"sh", "-c", `echo "` + step + ` {{name}} $GSV_PASSWORD" >> ` + log} }
+	cfg := config.DatabaseCfg{Name: "mq", Type: "command", Create: sh("create"), Revoke: sh("revoke"), DefaultTTL: time.Minute, MaxTTL: time.Hour}
+	m, err := New([]config.DatabaseCfg{cfg}, memStore{})
+	if err != nil { t.Fatal(err) }
+	c, err := m.Creds(context.Background(), "mq", 0)
+	if err != nil { t.Fatal(err) }
+	if _, err := m.Renew(context.Background(), c.ID, 0); err != nil { t.Fatal(err) } // no renew command: lease only
+	if _, err := m.Revoke(context.Background(), c.ID); err != nil { t.Fatal(err) }
+	b, _ := os.ReadFile(log)
+	if want := "create " + c.Username + " " + c.Password + "\nrevoke " + c.Username + " \n"; string(b) != want { t.Fatalf("log = %q, want %q", b, want) }
+
+	// a failed create revokes the user; if that fails too the lease stays, expired
+	_ = os.Remove(log)
+	cfg.Create = []string{"sh", "-c", "echo create {{name}} >> " + log + "; false"}
+	ms := memStore{}
+	m, _ = New([]config.DatabaseCfg{cfg}, ms)
+	if _, err := m.Creds(context.Background(), "mq", 0); err == nil { t.Fatal("failed create command accepted") }
+	if b, _ := os.ReadFile(log); strings.Count(string(b), "revoke ") != 1 || len(ms) != 0 { t.Fatalf("log = %q, leases = %d", b, len(ms)) }
+	cfg.Revoke = []string{"false"}
+	m, _ = New([]config.DatabaseCfg{cfg}, ms)
+	if _, err := m.Creds(context.Background(), "mq", 0); err == nil { t.Fatal("failed create command accepted") }
+	if done, err := m.RevokeExpired(context.Background()); len(ms) != 1 || len(done) != 0 || err == nil { t.Fatalf("lease not kept for the reaper: %d %v", len(ms), err) }
+	if _, err := New([]config.DatabaseCfg{{Name: "x", Type: "command"}}, memStore{}); err == nil { t.Fatal("command without create accepted") }
+}
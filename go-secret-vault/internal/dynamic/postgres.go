@@ -0,0 +1,67 @@
+package dynamic
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+
+	"go-secret-vault/internal/config"
+)
+
+// Default statements; create usually also needs the GRANTs of the app.
+var (
+	pgCreate = []string{`CREATE ROLE "{{name}}" WITH LOGIN PASSWORD '{{password}}' VALID UNTIL '{{expiration}}'`}
+	pgRenew  = []string{`ALTER ROLE "{{name}}" VALID UNTIL '{{expiration}}'`}
+	pgRevoke = []string{`REASSIGN OWNED BY "{{name}}" TO CURRENT_USER`, `DROP OWNED BY "{{name}}"`, `DROP ROLE IF EXISTS "{{name}}"`}
+)
+
+// postgres runs each step's statements in one transaction.
+type postgres struct {
+	db                    *sql.DB
+	create, renew, revoke []string
+}
+
+func newPostgres(c config.DatabaseCfg) (*postgres, error) {
+	if c.DSN == "" { return nil, errors.New("dsn is required") }
+	db, err := sql.Open("postgres", c.DSN) // connects on first use
+	if err != nil { return nil, err }
+	return newPostgresDB(db, c), nil
+}
+
+func newPostgresDB(db *sql.DB, c config.DatabaseCfg) *postgres {
+	p := &postgres{db: db, create: c.Create, renew: c.Renew, revoke: c.Revoke}
+	if len(p.create) == 0 { p.create = pgCreate }
+	if len(p.renew) == 0 { p.renew = pgRenew }
+	if len(p.revoke) == 0 { p.revoke = pgRevoke }
+	return p
+}
+
+func (p *postgres) Create(ctx context.Context, user, password string, expires time.Time) error {
+	return p.exec(ctx, p.create, user, password, expires)
+}
+
+func (p *postgres) Renew(ctx context.Context, user string, expires time.Time) error {
+	return p.exec(ctx, p.renew, user, "", expires)
+}
+
+// Revoke treats a missing role as already revoked (Creds revokes after a
+// failed create, which may never have created it).
+func (p *postgres) Revoke(ctx context.Context, user string) error {
+	err := p.exec(ctx, p.revoke, user, "", time.Time{})
+	var pe *pq.Error
+	if errors.As(err, &pe) && pe.Code == "42704" { return nil } // undefined_object
+	return err
+}
+
+func (p *postgres) exec(ctx context.Context, stmts []string, user, password string, expires time.Time) error {
+	r := vars(user, password, expires)
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil { return err }
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, r.Replace(s)); err != nil { _ = tx.Rollback(); return err }
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,165 @@
+// Package k8ssync keeps Kubernetes Secrets in step with vault secrets: each
+// run creates or updates the mapped Secrets and deletes the managed ones no
+// mapping points to anymore. A vault secret that is deleted, destroyed or
+// expired drops its key; a Secret left with no keys is deleted.
+package k8ssync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"go-secret-vault/internal/config"
+	"go-secret-vault/internal/vault"
+)
+
+const (
+	// ManagedByLabel marks the Secrets the controller owns; only those are
+	// updated or deleted.
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "go-secret-vault"
+	// VersionsAnnotation maps each data key to the vault secret and version
+	// it holds, e.g. {"PASSWORD":"prod/db/password@v3"}.
+	VersionsAnnotation = "gsv.io/versions"
+)
+
+// Source reads the current version of a vault secret (vault.Service).
+type Source interface {
+	GetByName(name string) (vault.Secret, vault.Version, []byte, error)
+}
+
+// Result counts what a run changed; Errors are per Secret and don't stop
+// the others.
+type Result struct {
+	Created, Updated, Deleted, Unchanged int
+	Errors []string
+}
+
+func (r Result) Changed() bool { return r.Created+r.Updated+r.Deleted > 0 }
+
+type Syncer struct {
+	cs       kubernetes.Interface
+	mappings []config.K8sSyncMapping
+}
+
+// NewClientset connects with kubeconfig, or in-cluster when it is "".
+func NewClientset(kubeconfig string) (kubernetes.Interface, error) {
+	var rc *rest.Config
+	var err error
+	if kubeconfig == "" { rc, err = rest.InClusterConfig() } else { rc, err = clientcmd.BuildConfigFromFlags("", kubeconfig) }
+	if err != nil { return nil, err }
+	return kubernetes.NewForConfig(rc)
+}
+
+func New(cs kubernetes.Interface, mappings []config.K8sSyncMapping) (*Syncer, error) {
+	seen := map[string]bool{}
+	for _, m := range mappings {
+		if m.Secret == "" || m.Namespace == "" || m.Name == "" || m.Key == "" { return nil, fmt.Errorf("k8s_sync mapping %+v: secret, namespace, name and key are required", m) }
+		k := m.Namespace + "/" + m.Name + ":" + m.Key
+		if seen[k] { return nil, fmt.Errorf("k8s_sync: %s is mapped twice", k) }
+		seen[k] = true
+	}
+	return &Syncer{cs: cs, mappings: mappings}, nil
+}
+
+type desired struct {
+	namespace, name string
+	data            map[string][]byte
+	versions        map[string]string
+	err             error // a mapped secret could not be read: leave the Secret alone
+}
+
+// gone reports whether the vault no longer holds a value for a mapping, as
+// opposed to a read that failed and may work next run.
+func gone(sec vault.Secret, err error) bool {
+	if errors.Is(err, vault.ErrNotFound) || errors.Is(err, vault.ErrDeleted) || errors.Is(err, vault.ErrDestroyed) { return true }
+	return err == nil && sec.ExpiresAt != nil && !time.Now().Before(*sec.ExpiresAt) // not reaped yet
+}
+
+// Sync runs one reconciliation.
+func (s *Syncer) Sync(ctx context.Context, src Source) (Result, error) {
+	want := map[string]*desired{}
+	for _, m := range s.mappings {
+		k := m.Namespace + "/" + m.Name
+		d := want[k]
+		if d == nil {
+			d = &desired{namespace: m.Namespace, name: m.Name, data: map[string][]byte{}, versions: map[string]string{}}
+			want[k] = d
+		}
+		sec, v, pt, err := src.GetByName(m.Secret)
+		if gone(sec, err) { continue }
+		if err != nil { d.err = errors.Join(d.err, fmt.Errorf("%s: %w", m.Secret, err)); continue }
+		d.data[m.Key] = pt
+		d.versions[m.Key] = fmt.Sprintf("%s@v%d", sec.Name, v.Version)
+	}
+
+	var res Result
+	keys := make([]string, 0, len(want))
+	for k, d := range want {
+		if d.err == nil && len(d.data) == 0 { delete(want, k); continue } // every key is gone: delete below
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d := want[k]
+		if d.err != nil { res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", k, d.err)); continue }
+		if err := s.apply(ctx, d, &res); err != nil { res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", k, err)) }
+	}
+
+	list, err := s.cs.CoreV1().Secrets("").List(ctx, meta.ListOptions{LabelSelector: ManagedByLabel + "=" + managedBy})
+	if err != nil { return res, fmt.Errorf("list managed secrets: %w", err) }
+	for _, sec := range list.Items {
+		k := sec.Namespace + "/" + sec.Name
+		if _, ok := want[k]; ok { continue }
+		if err := s.cs.CoreV1().Secrets(sec.Namespace).Delete(ctx, sec.Name, meta.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: delete: %v", k, err))
+			continue
+		}
+		res.Deleted++
+	}
+	return res, nil
+}
+
+func (s *Syncer) apply(ctx context.Context, d *desired, res *Result) error {
+	vb, _ := json.Marshal(d.versions)
+	api := s.cs.CoreV1().Secrets(d.namespace)
+	cur, err := api.Get(ctx, d.name, meta.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = api.Create(ctx, &corev1.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: d.name, Namespace: d.namespace, Labels: map[string]string{ManagedByLabel: managedBy}, Annotations: map[string]string{VersionsAnnotation: string(vb)}},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       d.data,
+		}, meta.CreateOptions{})
+		if err == nil { res.Created++ }
+		return err
+	}
+	if err != nil { return err }
+	if cur.Labels[ManagedByLabel] != managedBy { return fmt.Errorf("secret exists and is not managed by %s", managedBy) }
+	if cur.Annotations[VersionsAnnotation] == string(vb) && sameData(cur.Data, d.data) { res.Unchanged++; return nil }
+	next := cur.DeepCopy()
+	if next.Annotations == nil { next.Annotations = map[string]string{} }
+	next.Annotations[VersionsAnnotation] = string(vb)
+	next.Data = d.data
+	if _, err := api.Update(ctx, next, meta.UpdateOptions{}); err != nil { return err }
+	res.Updated++
+	return nil
+}
+
+func sameData(a, b map[string][]byte) bool {
+	if len(a) != len(b) { return false }
+	for k, v := range a {
+		if w, ok := b[k]; !ok || !bytes.Equal(v, w) { return false }
+	}
+	return true
+}
@@ -0,0 +1,86 @@
+package k8ssync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"go-secret-vault/internal/config"
+	"go-secret-vault/internal/vault"
+)
+
+type current struct {
+	v       int // 0: the read fails
+	value   string
+	expired bool
+}
+
+// memSource holds the current version and value of each secret.
+type memSource map[string]current
+
+func (m memSource) GetByName(name string) (vault.Secret, vault.Version, []byte, error) {
+	s, ok := m[name]
+	if !ok { return vault.Secret{}, vault.Version{}, nil, vault.ErrNotFound }
+	if s.v == 0 { return vault.Secret{}, vault.Version{}, nil, errors.New("decrypt: message authentication failed") }
+	sec := vault.Secret{Name: name, Version: s.v}
+	if s.expired { t := time.Now().Add(-time.Second); sec.ExpiresAt = &t }
+	return sec, vault.Version{Version: s.v}, []byte(s.value), nil
+}
+
+func TestSync(t *testing.T) {
+	ctx := context.Background()
+	foreign := &corev1.Secret{ObjectMeta: meta.ObjectMeta{Name: "manual", Namespace: "prod"}, Data: map[string][]byte{"X": []byte("keep")}}
+	cs := fake.NewSimpleClientset(foreign)
+	src := memSource{"prod/db/user": {1, "app", false}, "prod/db/password": {3, "s3cr3t", false}, "team-a/token": {1, "t0k", false}}
+	mappings := []config.K8sSyncMapping{
+		{Secret: "prod/db/user", Namespace: "prod", Name: "db", Key: "USER"},
+		{Secret: "prod/db/password", Namespace: "prod", Name: "db", Key: "PASSWORD"},
+		{Secret: "team-a/token", Namespace: "team-a", Name: "api", Key: "TOKEN"},
+		{Secret: "prod/db/password", Namespace: "prod", Name: "manual", Key: "PASSWORD"},
+	}
+	s, err := New(cs, mappings)
+	if err != nil { t.Fatal(err) }
+	res, err := s.Sync(ctx, src)
+	if err != nil { t.Fatal(err) }
+	if res.Created != 2 || len(res.Errors) != 1 { t.Fatalf("first run = %+v", res) } // "manual" is not ours
+	db := get(t, cs, "prod", "db")
+	if string(db.Data["PASSWORD"]) != "s3cr3t" || string(db.Data["USER"]) != "app" { t.Fatalf("data = %v", db.Data) }
+	if a := db.Annotations[VersionsAnnotation]; a != `{"PASSWORD":"prod/db/password@v3","USER":"prod/db/user@v1"}` { t.Fatalf("annotation = %s", a) }
+	if string(get(t, cs, "prod", "manual").Data["X"]) != "keep" { t.Fatal("unmanaged secret changed") }
+
+	// a new version updates the Secret; the rest is left alone
+	src["prod/db/password"] = current{4, "n3w", false}
+	s, _ = New(cs, mappings[:3])
+	if res, _ = s.Sync(ctx, src); res.Updated != 1 || res.Unchanged != 1 || len(res.Errors) != 0 { t.Fatalf("second run = %+v", res) }
+	if db = get(t, cs, "prod", "db"); string(db.Data["PASSWORD"]) != "n3w" { t.Fatalf("data = %v", db.Data) }
+
+	// a deleted secret drops its key; removed mappings delete their Secrets
+	delete(src, "prod/db/user")
+	s, _ = New(cs, mappings[:2])
+	if res, _ = s.Sync(ctx, src); res.Updated != 1 || res.Deleted != 1 || len(res.Errors) != 0 { t.Fatalf("third run = %+v", res) }
+	if _, err := cs.CoreV1().Secrets("team-a").Get(ctx, "api", meta.GetOptions{}); err == nil { t.Fatal("unmapped secret not deleted") }
+	if db = get(t, cs, "prod", "db"); len(db.Data) != 1 || db.Annotations[VersionsAnnotation] != `{"PASSWORD":"prod/db/password@v4"}` { t.Fatalf("db = %v %v", db.Data, db.Annotations) }
+
+	// a failed read leaves the Secret alone; once every key is gone it is deleted
+	src["prod/db/password"] = current{}
+	if res, _ = s.Sync(ctx, src); res.Changed() || len(res.Errors) != 1 { t.Fatalf("read error = %+v", res) }
+	get(t, cs, "prod", "db")
+	src["prod/db/password"] = current{4, "n3w", true}
+	if res, _ = s.Sync(ctx, src); res.Deleted != 1 || len(res.Errors) != 0 { t.Fatalf("expired = %+v", res) }
+	if _, err := cs.CoreV1().Secrets("prod").Get(ctx, "db", meta.GetOptions{}); err == nil { t.Fatal("secret with every key gone not deleted") }
+	get(t, cs, "prod", "manual")
+
+	if _, err := New(cs, []config.K8sSyncMapping{mappings[0], mappings[0]}); err == nil { t.Fatal("duplicated mapping accepted") }
+}
+
+func get(t *testing.T, cs *fake.Clientset, ns, name string) *corev1.Secret {
+	t.Helper()
+	s, err := cs.CoreV1().Secrets(ns).Get(context.Background(), name, meta.GetOptions{})
+	if err != nil { t.Fatal(err) }
+	return s
+}
@@ -0,0 +1,92 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"go-secret-vault/internal/config"
+)
+
+type Capability string
+
+const (
+	Read   Capability = "read"
+	Create Capability = "create"
+	Update Capability = "update"
+	Delete Capability = "delete"
+	List   Capability = "list"
+	Export Capability = "export"
+	// transit keys ("transit/<key>")
+	Encrypt Capability = "encrypt"
+	Decrypt Capability = "decrypt"
+)
+
+var known = map[Capability]bool{Read: true, Create: true, Update: true, Delete: true, List: true, Export: true, Encrypt: true, Decrypt: true}
+
+type rule struct {
+	path string
+	caps map[Capability]bool
+}
+
+// Engine maps roles to capabilities on secret name globs. Anything not
+// explicitly granted is denied.
+type Engine struct { roles map[string][]rule }
+
+// Default is used when the config has no policies: role admin gets every
+// capability on every path, as the admin user had before policies existed.
+// Other roles are denied until policies are configured.
+func Default() []config.PolicyCfg {
+	caps := make([]string, 0, len(known))
+	for _, c := range []Capability{Read, Create, Update, Delete, List, Export, Encrypt, Decrypt} { caps = append(caps, string(c)) }
+	return []config.PolicyCfg{{Role: "admin", Rules: []config.RuleCfg{{Path: "*", Capabilities: caps}}}}
+}
+
+func New(cfg []config.PolicyCfg) (*Engine, error) {
+	e := &Engine{roles: map[string][]rule{}}
+	for _, p := range cfg {
+		if p.Role == "" { return nil, fmt.Errorf("policy: role is required") }
+		for _, r := range p.Rules {
+			if _, err := path.Match(r.Path, ""); err != nil || r.Path == "" { return nil, fmt.Errorf("policy %s: bad path %q", p.Role, r.Path) }
+			caps := map[Capability]bool{}
+			for _, c := range r.Capabilities {
+				if !known[Capability(c)] { return nil, fmt.Errorf("policy %s: unknown capability %q", p.Role, c) }
+				caps[Capability(c)] = true
+			}
+			e.roles[p.Role] = append(e.roles[p.Role], rule{path: r.Path, caps: caps})
+		}
+	}
+	return e, nil
+}
+
+// Allowed reports whether any of roles grants c on the secret name.
+func (e *Engine) Allowed(roles []string, c Capability, name string) bool {
+	for _, role := range roles {
+		for _, r := range e.roles[role] {
+			if r.caps[c] && Match(r.path, name) { return true }
+		}
+	}
+	return false
+}
+
+// Match is path.Match, except that a trailing "*" also matches deeper
+// names: "prod/*" covers "prod/db/password" and "*" covers everything.
+func Match(pattern, name string) bool {
+	if ok, _ := path.Match(pattern, name); ok || !strings.HasSuffix(pattern, "*") { return ok }
+	n := strings.Count(pattern, "/") + 1
+	parts := strings.SplitN(name, "/", n+1)
+	if len(parts) <= n { return false }
+	ok, _ := path.Match(pattern, strings.Join(parts[:n], "/"))
+	return ok
+}
+
+// Any reports whether roles grant c on at least one path (used by list,
+// which is then filtered per secret).
+func (e *Engine) Any(roles []string, c Capability) bool {
+	for _, role := range roles {
+		for _, r := range e.roles[role] {
+			if r.caps[c] { return true }
+		}
+	}
+	return false
+}
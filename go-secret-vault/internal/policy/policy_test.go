@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"testing"
+
+	"go-secret-vault/internal/config"
+)
+
+func TestMatch(t *testing.T) {
+	for _, c := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"team-a/*", "team-a/api.key", true},
+		{"team-a/*", "team-a/db/password", true},
+		{"team-a/*", "team-b/api.key", false},
+		{"team-a/*", "team-a", false},
+		{"prod/db/*", "prod/db/main", true},
+		{"prod/db/*", "prod/cache/main", false},
+		{"*", "anything/at/all", true},
+		{"*/db/*", "staging/db/main", true},
+		{"api.key", "api.key", true},
+		{"api.key", "api.key2", false},
+	} {
+		if got := Match(c.pattern, c.name); got != c.want { t.Errorf("Match(%q, %q) = %v", c.pattern, c.name, got) }
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	e, err := New([]config.PolicyCfg{
+		{Role: "admin", Rules: []config.RuleCfg{{Path: "*", Capabilities: []string{"read", "create", "update", "delete", "list", "export"}}}},
+		{Role: "team-a", Rules: []config.RuleCfg{{Path: "team-a/*", Capabilities: []string{"read", "list"}}}},
+		{Role: "dba", Rules: []config.RuleCfg{{Path: "prod/db/*", Capabilities: []string{"read", "update"}}}},
+	})
+	if err != nil { t.Fatal(err) }
+	for _, c := range []struct {
+		roles []string
+		cap   Capability
+		name  string
+		want  bool
+	}{
+		{[]string{"admin"}, Delete, "prod/db/main", true},
+		{[]string{"team-a"}, Read, "team-a/api.key", true},
+		{[]string{"team-a"}, Update, "team-a/api.key", false},
+		{[]string{"team-a"}, Read, "prod/db/main", false},
+		{[]string{"team-a", "dba"}, Update, "prod/db/main", true},
+		{nil, Read, "team-a/api.key", false},
+		{[]string{"unknown"}, Read, "team-a/api.key", false},
+	} {
+		if got := e.Allowed(c.roles, c.cap, c.name); got != c.want { t.Errorf("Allowed(%v, %s, %q) = %v", c.roles, c.cap, c.name, got) }
+	}
+	if !e.Any([]string{"team-a"}, List) || e.Any([]string{"dba"}, List) { t.Error("Any") }
+
+	if _, err := New([]config.PolicyCfg{{Role: "x", Rules: []config.RuleCfg{{Path: "a/*", Capabilities: []string{"sudo"}}}}}); err == nil { t.Error("unknown capability accepted") }
+	if _, err := New([]config.PolicyCfg{{Role: "x", Rules: []config.RuleCfg{{Path: "[", Capabilities: []string{"read"}}}}}); err == nil { t.Error("bad glob accepted") }
+}
+
+func TestDefault(t *testing.T) {
+	e, err := New(Default())
+	if err != nil { t.Fatal(err) }
+	for _, c := range []Capability{Read, Create, Update, Delete, List, Export, Encrypt, Decrypt} {
+		if !e.Allowed([]string{"admin"}, c, "team-a/db/password") { t.Errorf("admin denied %s", c) }
+	}
+	if e.Allowed([]string{"dev"}, Read, "team-a/db/password") { t.Fatal("other roles must stay denied") }
+}
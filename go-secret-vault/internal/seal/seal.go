@@ -0,0 +1,148 @@
+// Package seal keeps the root key out of the config: "gsv operator init"
+// splits a random root key into Shamir shares and the server stays sealed
+// until enough of them are submitted again.
+package seal
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go-secret-vault/internal/crypto"
+)
+
+// Store keeps the seal config (never the key or the shares).
+type Store interface {
+	LoadSeal() ([]byte, error) // nil = not initialized
+	// SaveSeal stores the config and, when keyring != "", the keyring
+	// rekeyed to the root key in the same write: either both land or neither.
+	SaveSeal(b []byte, keyring string) error
+}
+
+var (
+	ErrNotInitialized = errors.New("vault is not initialized")
+	ErrInitialized    = errors.New("vault is already initialized")
+	ErrUnsealed       = errors.New("vault is already unsealed")
+	ErrBadShare       = errors.New("bad unseal key")
+)
+
+// checkValue is encrypted with the root key at init so a wrong set of
+// shares is caught before it is used.
+const checkValue = "gsv-seal-check"
+
+type config struct {
+	Shares    int       `json:"shares"`
+	Threshold int       `json:"threshold"`
+	Check     string    `json:"check"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Status is what GET /sys/seal-status reports.
+type Status struct {
+	Initialized bool `json:"initialized"`
+	Sealed      bool `json:"sealed"`
+	Shares      int  `json:"shares,omitempty"`
+	Threshold   int  `json:"threshold,omitempty"`
+	Progress    int  `json:"progress"` // shares submitted towards the threshold
+}
+
+type Seal struct {
+	mu      sync.Mutex
+	store   Store
+	cfg     *config
+	pending [][]byte
+	sealed  bool
+}
+
+// New loads the seal config; the vault always starts sealed.
+func New(store Store) (*Seal, error) {
+	s := &Seal{store: store, sealed: true}
+	b, err := store.LoadSeal()
+	if err != nil { return nil, err }
+	if b != nil {
+		s.cfg = &config{}
+		if err := json.Unmarshal(b, s.cfg); err != nil { return nil, err }
+	}
+	return s, nil
+}
+
+// Init creates the root key, runs prepare with it and returns the shares
+// (base64). prepare returns the keyring encrypted with the root key ("" if
+// there is none), saved together with the seal config, so a failed save
+// leaves the old keyring in place. The shares are shown once; only a check
+// value is stored and the vault stays sealed.
+func (s *Seal) Init(shares, threshold int, prepare func(root *crypto.AESEncryptor) (string, error)) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg != nil { return nil, ErrInitialized }
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil { return nil, err }
+	defer clear(key)
+	parts, err := crypto.SplitShares(key, shares, threshold)
+	if err != nil { return nil, err }
+	root, _ := crypto.NewAESEncryptorKey(key)
+	defer root.Wipe()
+	check, err := root.Encrypt([]byte(checkValue))
+	if err != nil { return nil, err }
+	keyring := ""
+	if prepare != nil {
+		if keyring, err = prepare(root); err != nil { return nil, err }
+	}
+	cfg := &config{Shares: shares, Threshold: threshold, Check: check, CreatedAt: time.Now().UTC()}
+	b, _ := json.Marshal(cfg)
+	if err := s.store.SaveSeal(b, keyring); err != nil { return nil, err }
+	s.cfg = cfg
+	out := make([]string, len(parts))
+	for i, p := range parts { out[i] = base64.StdEncoding.EncodeToString(p); clear(p) }
+	return out, nil
+}
+
+// Unseal adds one share. Once the threshold is reached it returns the root
+// key; a wrong combination resets the progress.
+func (s *Seal) Unseal(shareB64 string) (*crypto.AESEncryptor, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg == nil { return nil, ErrNotInitialized }
+	if !s.sealed { return nil, ErrUnsealed }
+	share, err := base64.StdEncoding.DecodeString(shareB64)
+	if err != nil || len(share) != 33 { return nil, ErrBadShare }
+	for _, p := range s.pending {
+		if p[32] == share[32] { return nil, nil } // same share twice doesn't count
+	}
+	s.pending = append(s.pending, share)
+	if len(s.pending) < s.cfg.Threshold { return nil, nil }
+
+	key, err := crypto.CombineShares(s.pending)
+	s.reset()
+	if err != nil { return nil, ErrBadShare }
+	defer clear(key)
+	root, _ := crypto.NewAESEncryptorKey(key)
+	if pt, err := root.Decrypt(s.cfg.Check); err != nil || string(pt) != checkValue { root.Wipe(); return nil, ErrBadShare }
+	s.sealed = false
+	return root, nil
+}
+
+// Seal drops the submitted shares and marks the vault sealed; the caller
+// wipes the keys it built from the root key.
+func (s *Seal) Seal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reset()
+	s.sealed = true
+}
+
+func (s *Seal) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := Status{Initialized: s.cfg != nil, Sealed: s.sealed, Progress: len(s.pending)}
+	if s.cfg != nil { st.Shares, st.Threshold = s.cfg.Shares, s.cfg.Threshold }
+	return st
+}
+
+func (s *Seal) reset() {
+	for _, p := range s.pending { clear(p) }
+	s.pending = nil
+}
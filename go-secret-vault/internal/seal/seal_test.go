@@ -0,0 +1,63 @@
+package seal
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"go-secret-vault/internal/crypto"
+)
+
+type memStore struct {
+	b       []byte
+	keyring string
+	err     error
+}
+
+func (m *memStore) LoadSeal() ([]byte, error) { return m.b, nil }
+func (m *memStore) SaveSeal(b []byte, keyring string) error {
+	if m.err != nil { return m.err }
+	m.b, m.keyring = b, keyring
+	return nil
+}
+
+func TestInitUnseal(t *testing.T) {
+	ms := &memStore{}
+	s, err := New(ms)
+	if err != nil { t.Fatal(err) }
+	if _, err := s.Unseal("x"); !errors.Is(err, ErrNotInitialized) { t.Fatalf("unseal before init: %v", err) }
+	var check string
+	keys, err := s.Init(3, 2, func(root *crypto.AESEncryptor) (string, error) { check, _ = root.Encrypt([]byte("hi")); return "ring", nil })
+	if err != nil || len(keys) != 3 || ms.keyring != "ring" { t.Fatalf("init = %v, %v (keyring %q)", keys, err, ms.keyring) }
+	if _, err := s.Init(3, 2, nil); !errors.Is(err, ErrInitialized) { t.Fatalf("second init: %v", err) }
+
+	// a restart reads the config and is still sealed
+	s, _ = New(ms)
+	if st := s.Status(); !st.Initialized || !st.Sealed || st.Threshold != 2 { t.Fatalf("status = %+v", st) }
+
+	// a forged share reaches the threshold but fails the check
+	bad, _ := base64.StdEncoding.DecodeString(keys[1])
+	bad[0] ^= 1
+	if root, err := s.Unseal(keys[0]); root != nil || err != nil { t.Fatalf("first share: %v %v", root, err) }
+	if _, err := s.Unseal(base64.StdEncoding.EncodeToString(bad)); !errors.Is(err, ErrBadShare) { t.Fatalf("bad share: %v", err) }
+	if st := s.Status(); !st.Sealed || st.Progress != 0 { t.Fatalf("status after bad share = %+v", st) }
+
+	s.Unseal(keys[2])
+	if root, _ := s.Unseal(keys[2]); root != nil { t.Fatal("same share counted twice") }
+	root, err := s.Unseal(keys[0])
+	if err != nil || root == nil { t.Fatalf("unseal = %v", err) }
+	if pt, err := root.Decrypt(check); err != nil || string(pt) != "hi" { t.Fatalf("root key differs from init: %v", err) }
+	if _, err := s.Unseal(keys[1]); !errors.Is(err, ErrUnsealed) { t.Fatalf("unseal when unsealed: %v", err) }
+	s.Seal()
+	if !s.Status().Sealed { t.Fatal("still unsealed") }
+}
+
+func TestInitSaveFails(t *testing.T) {
+	ms := &memStore{err: errors.New("disk full")}
+	s, _ := New(ms)
+	if _, err := s.Init(3, 2, func(*crypto.AESEncryptor) (string, error) { return "ring", nil }); err == nil { t.Fatal("init succeeded without saving") }
+	// nothing was stored and init can run again
+	if st := s.Status(); st.Initialized || ms.keyring != "" { t.Fatalf("status = %+v, keyring %q", st, ms.keyring) }
+	ms.err = nil
+	if keys, err := s.Init(3, 2, nil); err != nil || len(keys) != 3 { t.Fatalf("retry = %v", err) }
+}
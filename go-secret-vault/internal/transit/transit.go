@@ -0,0 +1,195 @@
+// Package transit is encryption as a service: named keys that never leave
+// the vault, used to encrypt and decrypt application data the vault does
+// not store.
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store keeps each key, encrypted by the vault keyring.
+type Store interface {
+	GetTransitKey(name string) (string, error) // "" = missing
+	PutTransitKey(name, cipher string) error
+}
+
+// Sealer encrypts the key material at rest (crypto.Envelope).
+type Sealer interface {
+	Encrypt(plaintext []byte) (string, error)
+	Decrypt(cipher string) ([]byte, error)
+}
+
+var (
+	ErrNotFound = errors.New("transit key not found")
+	ErrExists   = errors.New("transit key already exists")
+	ErrBadInput = errors.New("bad transit request")
+)
+
+// Key is a named key with all its versions; ciphertexts carry the version
+// ("vault:v2:...") and decrypt while it is >= MinDecryptionVersion.
+type Key struct {
+	Name                 string         `json:"name"`
+	LatestVersion        int            `json:"latest_version"`
+	MinDecryptionVersion int            `json:"min_decryption_version"`
+	Versions             map[int][]byte `json:"versions"`
+	CreatedAt            time.Time      `json:"created_at"`
+	RotatedAt            time.Time      `json:"rotated_at"`
+}
+
+type Engine struct {
+	mu    sync.Mutex
+	store Store
+	seal  Sealer
+}
+
+func New(store Store, seal Sealer) *Engine { return &Engine{store: store, seal: seal} }
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+func (e *Engine) CreateKey(name string) (Key, error) {
+	if !validName.MatchString(name) { return Key{}, fmt.Errorf("%w: invalid key name %q", ErrBadInput, name) }
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, err := e.load(name); err == nil { return Key{}, ErrExists } else if !errors.Is(err, ErrNotFound) { return Key{}, err }
+	now := time.Now().UTC()
+	k := Key{Name: name, MinDecryptionVersion: 1, Versions: map[int][]byte{}, CreatedAt: now}
+	if err := addVersion(&k, now); err != nil { return Key{}, err }
+	return k, e.save(k)
+}
+
+// Key returns the key metadata (Versions without the key material).
+func (e *Engine) Key(name string) (Key, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	k, err := e.load(name)
+	if err != nil { return Key{}, err }
+	return k.public(), nil
+}
+
+// Rotate adds a version used for every new encryption.
+func (e *Engine) Rotate(name string) (Key, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	k, err := e.load(name)
+	if err != nil { return Key{}, err }
+	if err := addVersion(&k, time.Now().UTC()); err != nil { return Key{}, err }
+	return k.public(), e.save(k)
+}
+
+// SetMinDecryptionVersion stops decrypting ciphertexts of older versions
+// (rewrap them first).
+func (e *Engine) SetMinDecryptionVersion(name string, v int) (Key, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	k, err := e.load(name)
+	if err != nil { return Key{}, err }
+	if v < 1 || v > k.LatestVersion { return Key{}, fmt.Errorf("%w: min_decryption_version must be between 1 and %d", ErrBadInput, k.LatestVersion) }
+	k.MinDecryptionVersion = v
+	return k.public(), e.save(k)
+}
+
+func (e *Engine) Encrypt(name string, plaintext []byte) (string, error) {
+	e.mu.Lock()
+	k, err := e.load(name)
+	e.mu.Unlock()
+	if err != nil { return "", err }
+	return k.encrypt(plaintext)
+}
+
+func (e *Engine) Decrypt(name, ciphertext string) ([]byte, error) {
+	e.mu.Lock()
+	k, err := e.load(name)
+	e.mu.Unlock()
+	if err != nil { return nil, err }
+	return k.decrypt(ciphertext)
+}
+
+// Rewrap decrypts and encrypts again with the latest version; the plaintext
+// never leaves the vault.
+func (e *Engine) Rewrap(name, ciphertext string) (string, error) {
+	e.mu.Lock()
+	k, err := e.load(name)
+	e.mu.Unlock()
+	if err != nil { return "", err }
+	pt, err := k.decrypt(ciphertext)
+	if err != nil { return "", err }
+	return k.encrypt(pt)
+}
+
+func (e *Engine) load(name string) (Key, error) {
+	c, err := e.store.GetTransitKey(name)
+	if err != nil { return Key{}, err }
+	if c == "" { return Key{}, ErrNotFound }
+	b, err := e.seal.Decrypt(c)
+	if err != nil { return Key{}, err }
+	var k Key
+	return k, json.Unmarshal(b, &k)
+}
+
+func (e *Engine) save(k Key) error {
+	b, _ := json.Marshal(k)
+	c, err := e.seal.Encrypt(b)
+	if err != nil { return err }
+	return e.store.PutTransitKey(k.Name, c)
+}
+
+func addVersion(k *Key, now time.Time) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil { return err }
+	k.LatestVersion++
+	k.Versions[k.LatestVersion] = b
+	k.RotatedAt = now
+	return nil
+}
+
+func (k Key) public() Key {
+	p := k
+	p.Versions = map[int][]byte{}
+	for v := range k.Versions { p.Versions[v] = nil }
+	return p
+}
+
+const prefix = "vault:v"
+
+func (k Key) encrypt(plaintext []byte) (string, error) {
+	gcm, err := newGCM(k.Versions[k.LatestVersion])
+	if err != nil { return "", err }
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil { return "", err }
+	ct := gcm.Seal(nonce, nonce, plaintext, []byte(k.Name))
+	return prefix + strconv.Itoa(k.LatestVersion) + ":" + base64.StdEncoding.EncodeToString(ct), nil
+}
+
+func (k Key) decrypt(ciphertext string) ([]byte, error) {
+	head, body, ok := strings.Cut(strings.TrimPrefix(ciphertext, prefix), ":")
+	v, err := strconv.Atoi(head)
+	if !strings.HasPrefix(ciphertext, prefix) || !ok || err != nil { return nil, fmt.Errorf("%w: invalid ciphertext", ErrBadInput) }
+	if v < k.MinDecryptionVersion { return nil, fmt.Errorf("%w: key version %d is below min_decryption_version %d", ErrBadInput, v, k.MinDecryptionVersion) }
+	key, ok := k.Versions[v]
+	if !ok { return nil, fmt.Errorf("%w: unknown key version %d", ErrBadInput, v) }
+	buf, err := base64.StdEncoding.DecodeString(body)
+	if err != nil { return nil, fmt.Errorf("%w: invalid ciphertext", ErrBadInput) }
+	gcm, err := newGCM(key)
+	if err != nil { return nil, err }
+	if len(buf) < gcm.NonceSize() { return nil, fmt.Errorf("%w: invalid ciphertext", ErrBadInput) }
+	pt, err := gcm.Open(nil, buf[:gcm.NonceSize()], buf[gcm.NonceSize():], []byte(k.Name))
+	if err != nil { return nil, fmt.Errorf("%w: decryption failed", ErrBadInput) }
+	return pt, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil { return nil, err }
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,53 @@
+package transit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"go-secret-vault/internal/crypto"
+)
+
+type memStore map[string]string
+
+func (m memStore) GetTransitKey(name string) (string, error) { return m[name], nil }
+func (m memStore) PutTransitKey(name, c string) error         { m[name] = c; return nil }
+func (m memStore) LoadKeyring() (string, error)               { return m["_keyring"], nil }
+func (m memStore) SaveKeyring(c string) error                 { m["_keyring"] = c; return nil }
+
+func TestTransit(t *testing.T) {
+	root, _ := crypto.NewAESEncryptor(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	st := memStore{}
+	env, err := crypto.NewEnvelope(root, st)
+	if err != nil { t.Fatal(err) }
+	e := New(st, env)
+
+	if _, err := e.CreateKey("pii"); err != nil { t.Fatal(err) }
+	if _, err := e.CreateKey("pii"); !errors.Is(err, ErrExists) { t.Fatalf("duplicate key: %v", err) }
+	if _, err := e.CreateKey("bad/name"); !errors.Is(err, ErrBadInput) { t.Fatalf("bad name: %v", err) }
+	if strings.Contains(st["pii"], "pii") { t.Fatal("key stored in clear") }
+
+	c1, err := e.Encrypt("pii", []byte("123.456.789-00"))
+	if err != nil || !strings.HasPrefix(c1, "vault:v1:") { t.Fatalf("encrypt = %s, %v", c1, err) }
+	if _, err := e.Rotate("pii"); err != nil { t.Fatal(err) }
+	c2, _ := e.Encrypt("pii", []byte("x"))
+	if !strings.HasPrefix(c2, "vault:v2:") { t.Fatalf("after rotate = %s", c2) }
+	if pt, err := e.Decrypt("pii", c1); err != nil || string(pt) != "123.456.789-00" { t.Fatalf("decrypt v1 = %q, %v", pt, err) }
+
+	re, err := e.Rewrap("pii", c1)
+	if err != nil || !strings.HasPrefix(re, "vault:v2:") { t.Fatalf("rewrap = %s, %v", re, err) }
+	if _, err := e.SetMinDecryptionVersion("pii", 2); err != nil { t.Fatal(err) }
+	if _, err := e.Decrypt("pii", c1); !errors.Is(err, ErrBadInput) { t.Fatalf("v1 below min decrypted: %v", err) }
+	if pt, err := e.Decrypt("pii", re); err != nil || string(pt) != "123.456.789-00" { t.Fatalf("decrypt rewrapped = %q, %v", pt, err) }
+	if _, err := e.SetMinDecryptionVersion("pii", 3); !errors.Is(err, ErrBadInput) { t.Fatal("min above latest accepted") }
+
+	// ciphertexts are bound to the key name
+	if _, err := e.CreateKey("other"); err != nil { t.Fatal(err) }
+	if _, err := e.Decrypt("other", c2); err == nil { t.Fatal("decrypted with another key") }
+	if _, err := e.Encrypt("missing", nil); !errors.Is(err, ErrNotFound) { t.Fatalf("missing key: %v", err) }
+
+	k, _ := e.Key("pii")
+	if k.LatestVersion != 2 || k.MinDecryptionVersion != 2 || len(k.Versions) != 2 || k.Versions[1] != nil { t.Fatalf("key = %+v", k) }
+}
@@ -3,6 +3,7 @@ package vault
 import (
 	"bytes"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"time"
 
@@ -15,16 +16,29 @@ type Store interface {
 	GetByName(name string) (Secret, error)
 	Update(id string, cipher string, ttl *time.Duration, meta map[string]string) (Secret, error)
 	Delete(id string) error
-	List(includeExpired bool) ([]Secret, error)
+	Undelete(id string) (Secret, error)
+	DestroyVersions(id string, versions []int) (Secret, error)
+	Destroy(id string) error
+	Rewrap(id string, fn func(cipher string) (string, bool, error)) (bool, error)
+	List(includeExpired, includeDeleted bool) ([]Secret, error)
 	ReapExpired() (int, error)
 }
 
+// Encryptor is implemented by crypto.Envelope.
+type Encryptor interface {
+	Encrypt(plaintext []byte) (string, error)
+	Decrypt(cipher string) ([]byte, error)
+	Rewrap(cipher string) (string, bool, error)
+}
+
+var _ Encryptor = (*crypto.Envelope)(nil)
+
 type Service struct {
 	store Store
-	enc   *crypto.AESEncryptor
+	enc   Encryptor
 }
 
-func NewService(store Store, enc *crypto.AESEncryptor) *Service { return &Service{store: store, enc: enc} }
+func NewService(store Store, enc Encryptor) *Service { return &Service{store: store, enc: enc} }
 
 func (s *Service) Create(name string, plaintext []byte, ttl time.Duration, meta map[string]string) (Secret, error) {
 	cipher, err := s.enc.Encrypt(plaintext)
@@ -32,11 +46,32 @@ func (s *Service) Create(name string, plaintext []byte, ttl time.Duration, meta
 	return s.store.Create(name, cipher, ttl, meta)
 }
 
-func (s *Service) GetDecrypted(id string) (Secret, []byte, error) {
+// GetDecrypted returns version n of the secret (0 = current).
+func (s *Service) GetDecrypted(id string, n int) (Secret, Version, []byte, error) {
 	sec, err := s.store.Get(id)
-	if err != nil { return Secret{}, nil, err }
-	pt, err := s.enc.Decrypt(sec.CipherB64)
-	return sec, pt, err
+	if err != nil { return Secret{}, Version{}, nil, err }
+	if sec.DeletedAt != nil { return sec, Version{}, nil, ErrDeleted }
+	v, err := sec.VersionOf(n)
+	if err != nil { return sec, v, nil, err }
+	pt, err := s.enc.Decrypt(v.CipherB64)
+	return sec, v, pt, err
+}
+
+// GetByName returns the current version of the secret called name.
+func (s *Service) GetByName(name string) (Secret, Version, []byte, error) {
+	sec, err := s.store.GetByName(name)
+	if err != nil { return Secret{}, Version{}, nil, err }
+	return s.GetDecrypted(sec.ID, 0)
+}
+
+// Rollback makes the value of version n current again, as a new version.
+func (s *Service) Rollback(id string, n int) (Secret, error) {
+	sec, err := s.store.Get(id)
+	if err != nil { return Secret{}, err }
+	if sec.DeletedAt != nil { return Secret{}, ErrDeleted }
+	v, err := sec.VersionOf(n)
+	if err != nil { return Secret{}, err }
+	return s.store.Update(id, v.CipherB64, nil, nil)
 }
 
 func (s *Service) Update(id string, newPlain []byte, ttl *time.Duration, meta map[string]string) (Secret, error) {
@@ -45,8 +80,24 @@ func (s *Service) Update(id string, newPlain []byte, ttl *time.Duration, meta ma
 	return s.store.Update(id, cipher, ttl, meta)
 }
 
+// RewrapAll re-encrypts every secret still on an older KEK with the active
+// one, one secret per transaction, so reads and writes keep working while it
+// runs. It returns how many secrets changed.
+func (s *Service) RewrapAll() (int, error) {
+	secs, err := s.store.List(true, true)
+	if err != nil { return 0, err }
+	n := 0
+	for _, sec := range secs {
+		changed, err := s.store.Rewrap(sec.ID, s.enc.Rewrap)
+		if errors.Is(err, ErrNotFound) { continue } // removed meanwhile
+		if err != nil { return n, fmt.Errorf("rewrap %s: %w", sec.ID, err) }
+		if changed { n++ }
+	}
+	return n, nil
+}
+
 func (s *Service) ExportK8sYAML(id, namespace, key string) ([]byte, error) {
-	sec, pt, err := s.GetDecrypted(id)
+	sec, _, pt, err := s.GetDecrypted(id, 0)
 	if err != nil { return nil, err }
 	if key == "" { key = "VALUE" }
 	b64 := base64.StdEncoding.EncodeToString(pt)
@@ -3,6 +3,7 @@ package vault
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -12,25 +13,71 @@ import (
 type Secret struct {
 	ID        string            `json:"id"`
 	Name      string            `json:"name"`
-	CipherB64 string            `json:"cipher_b64"`
+	CipherB64 string            `json:"cipher_b64"` // current version
+	Version   int               `json:"version"`
+	Versions  []Version         `json:"versions,omitempty"`
 	Meta      map[string]string `json:"meta,omitempty"`
 	CreatedAt time.Time         `json:"created_at"`
 	UpdatedAt time.Time         `json:"updated_at"`
 	ExpiresAt *time.Time        `json:"expires_at,omitempty"`
+	DeletedAt *time.Time        `json:"deleted_at,omitempty"` // soft delete; see Undelete/Destroy
 }
 
-type BoltStore struct { db *bolt.DB }
+// Version is one value of a secret. Destroyed versions keep their metadata
+// but lose the ciphertext.
+type Version struct {
+	Version     int        `json:"version"`
+	CipherB64   string     `json:"cipher_b64,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DestroyedAt *time.Time `json:"destroyed_at,omitempty"`
+}
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDeleted   = errors.New("secret is deleted")
+	ErrDestroyed = errors.New("version destroyed")
+)
+
+// VersionOf returns version n of sec (0 = current).
+func (sec Secret) VersionOf(n int) (Version, error) {
+	if n == 0 { n = sec.Version }
+	for _, v := range sec.Versions {
+		if v.Version != n { continue }
+		if v.DestroyedAt != nil { return v, ErrDestroyed }
+		return v, nil
+	}
+	return Version{}, fmt.Errorf("version %d: %w", n, ErrNotFound)
+}
+
+type BoltStore struct {
+	db          *bolt.DB
+	maxVersions int
+}
 
-var bucket = []byte("secrets")
+var (
+	bucket         = []byte("secrets")
+	sysBucket      = []byte("sys")
+	transitBucket  = []byte("transit")
+	leaseBucket    = []byte("leases")
+	secretIDBucket = []byte("approle_secret_ids")
+	revokedBucket  = []byte("revoked_tokens")
+)
 
-func NewBolt(path string) (*BoltStore, error) {
+// NewBolt opens the store; each secret keeps at most maxVersions versions
+// (<= 0 keeps all of them).
+func NewBolt(path string, maxVersions int) (*BoltStore, error) {
 	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
 	if err != nil { return nil, err }
 	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(sysBucket); err != nil { return err }
+		if _, err := tx.CreateBucketIfNotExists(transitBucket); err != nil { return err }
+		if _, err := tx.CreateBucketIfNotExists(leaseBucket); err != nil { return err }
+		if _, err := tx.CreateBucketIfNotExists(secretIDBucket); err != nil { return err }
+		if _, err := tx.CreateBucketIfNotExists(revokedBucket); err != nil { return err }
 		_, err := tx.CreateBucketIfNotExists(bucket)
 		return err
 	}); err != nil { return nil, err }
-	return &BoltStore{db: db}, nil
+	return &BoltStore{db: db, maxVersions: maxVersions}, nil
 }
 
 func (s *BoltStore) Close() error { return s.db.Close() }
@@ -40,12 +87,16 @@ func (s *BoltStore) Create(name, cipher string, ttl time.Duration, meta map[stri
 	now := time.Now().UTC()
 	var exp *time.Time
 	if ttl > 0 { t := now.Add(ttl); exp = &t }
-	sec := Secret{ID: id, Name: name, CipherB64: cipher, Meta: meta, CreatedAt: now, UpdatedAt: now, ExpiresAt: exp}
+	sec := Secret{ID: id, Name: name, CipherB64: cipher, Version: 1, Versions: []Version{{Version: 1, CipherB64: cipher, CreatedAt: now}}, Meta: meta, CreatedAt: now, UpdatedAt: now, ExpiresAt: exp}
 	err := s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
 		_, err := b.CreateBucketIfNotExists([]byte("byname"))
 		if err != nil { return err }
-		if b.Bucket([]byte("byname")).Get([]byte(name)) != nil { return errors.New("name exists") }
+		if id := b.Bucket([]byte("byname")).Get([]byte(name)); id != nil {
+			var old Secret
+			if json.Unmarshal(b.Get(id), &old) == nil && old.DeletedAt != nil { return errors.New("name exists (deleted; undelete or destroy it)") }
+			return errors.New("name exists")
+		}
 		js, _ := json.Marshal(sec)
 		if err := b.Put([]byte(id), js); err != nil { return err }
 		return b.Bucket([]byte("byname")).Put([]byte(name), []byte(id))
@@ -58,18 +109,29 @@ func (s *BoltStore) Get(id string) (Secret, error) {
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
 		v := b.Get([]byte(id))
-		if v == nil { return errors.New("not found") }
-		return json.Unmarshal(v, &sec)
+		if v == nil { return ErrNotFound }
+		return decode(v, &sec)
 	})
 	return sec, err
 }
 
+// decode reads a stored secret; records written before versioning become
+// version 1.
+func decode(v []byte, sec *Secret) error {
+	if err := json.Unmarshal(v, sec); err != nil { return err }
+	if len(sec.Versions) == 0 {
+		sec.Version = 1
+		sec.Versions = []Version{{Version: 1, CipherB64: sec.CipherB64, CreatedAt: sec.CreatedAt}}
+	}
+	return nil
+}
+
 func (s *BoltStore) GetByName(name string) (Secret, error) {
 	var id string
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
 		v := b.Bucket([]byte("byname")).Get([]byte(name))
-		if v == nil { return errors.New("not found") }
+		if v == nil { return ErrNotFound }
 		id = string(v)
 		return nil
 	})
@@ -77,20 +139,40 @@ func (s *BoltStore) GetByName(name string) (Secret, error) {
 	return s.Get(id)
 }
 
+// Update stores cipher (if set) as a new version; ttl and meta only change
+// the metadata.
 func (s *BoltStore) Update(id string, cipher string, ttl *time.Duration, meta map[string]string) (Secret, error) {
-	var out Secret
-	err := s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(bucket)
-		v := b.Get([]byte(id))
-		if v == nil { return errors.New("not found") }
-		var sec Secret
-		if err := json.Unmarshal(v, &sec); err != nil { return err }
-		if cipher != "" { sec.CipherB64 = cipher }
+	return s.modify(id, func(sec *Secret) error {
+		if sec.DeletedAt != nil { return ErrDeleted }
+		if cipher != "" { s.addVersion(sec, cipher) }
 		if ttl != nil {
 			if *ttl > 0 { t := time.Now().UTC().Add(*ttl); sec.ExpiresAt = &t } else { sec.ExpiresAt = nil }
 		}
 		if meta != nil { sec.Meta = meta }
 		sec.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// addVersion makes cipher the current value and trims the oldest versions
+// beyond maxVersions.
+func (s *BoltStore) addVersion(sec *Secret, cipher string) {
+	sec.Version = sec.Versions[len(sec.Versions)-1].Version + 1
+	sec.CipherB64 = cipher
+	sec.Versions = append(sec.Versions, Version{Version: sec.Version, CipherB64: cipher, CreatedAt: time.Now().UTC()})
+	if s.maxVersions > 0 && len(sec.Versions) > s.maxVersions { sec.Versions = sec.Versions[len(sec.Versions)-s.maxVersions:] }
+}
+
+// modify loads the secret id, applies fn and saves it.
+func (s *BoltStore) modify(id string, fn func(sec *Secret) error) (Secret, error) {
+	var out Secret
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		v := b.Get([]byte(id))
+		if v == nil { return ErrNotFound }
+		var sec Secret
+		if err := decode(v, &sec); err != nil { return err }
+		if err := fn(&sec); err != nil { return err }
 		js, _ := json.Marshal(sec)
 		if err := b.Put([]byte(id), js); err != nil { return err }
 		out = sec
@@ -99,11 +181,70 @@ func (s *BoltStore) Update(id string, cipher string, ttl *time.Duration, meta ma
 	return out, err
 }
 
+// Delete is a soft delete: the secret disappears from List and reads but
+// keeps its versions until Destroy.
 func (s *BoltStore) Delete(id string) error {
+	_, err := s.modify(id, func(sec *Secret) error {
+		if sec.DeletedAt != nil { return ErrDeleted }
+		now := time.Now().UTC()
+		sec.DeletedAt = &now
+		return nil
+	})
+	return err
+}
+
+func (s *BoltStore) Undelete(id string) (Secret, error) {
+	return s.modify(id, func(sec *Secret) error {
+		if sec.DeletedAt == nil { return errors.New("secret is not deleted") }
+		sec.DeletedAt = nil
+		sec.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// DestroyVersions permanently wipes the ciphertext of the given versions.
+func (s *BoltStore) DestroyVersions(id string, versions []int) (Secret, error) {
+	return s.modify(id, func(sec *Secret) error {
+		for _, n := range versions {
+			found := false
+			for i := range sec.Versions {
+				if sec.Versions[i].Version != n { continue }
+				found = true
+				if sec.Versions[i].DestroyedAt == nil {
+					now := time.Now().UTC()
+					sec.Versions[i].CipherB64, sec.Versions[i].DestroyedAt = "", &now
+				}
+			}
+			if !found { return fmt.Errorf("version %d: %w", n, ErrNotFound) }
+			if n == sec.Version { sec.CipherB64 = "" }
+		}
+		sec.UpdatedAt = time.Now().UTC()
+		return nil
+	})
+}
+
+// Rewrap replaces every stored ciphertext of the secret (all versions) with
+// fn(cipher), in one transaction; the secret metadata is untouched.
+func (s *BoltStore) Rewrap(id string, fn func(cipher string) (string, bool, error)) (bool, error) {
+	changed := false
+	_, err := s.modify(id, func(sec *Secret) error {
+		for i := range sec.Versions {
+			c, ok, err := fn(sec.Versions[i].CipherB64)
+			if err != nil { return fmt.Errorf("version %d: %w", sec.Versions[i].Version, err) }
+			if ok { sec.Versions[i].CipherB64, changed = c, true }
+			if sec.Versions[i].Version == sec.Version { sec.CipherB64 = sec.Versions[i].CipherB64 }
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// Destroy permanently removes the secret and all its versions.
+func (s *BoltStore) Destroy(id string) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
 		v := b.Get([]byte(id))
-		if v == nil { return errors.New("not found") }
+		if v == nil { return ErrNotFound }
 		var sec Secret
 		_ = json.Unmarshal(v, &sec)
 		if err := b.Delete([]byte(id)); err != nil { return err }
@@ -112,14 +253,15 @@ func (s *BoltStore) Delete(id string) error {
 	})
 }
 
-func (s *BoltStore) List(includeExpired bool) ([]Secret, error) {
+func (s *BoltStore) List(includeExpired, includeDeleted bool) ([]Secret, error) {
 	var out []Secret
 	err := s.db.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(bucket)
 		return b.ForEach(func(k, v []byte) error {
 			if string(k) == "byname" { return nil }
 			var sec Secret
-			if err := json.Unmarshal(v, &sec); err != nil { return err }
+			if err := decode(v, &sec); err != nil { return err }
+			if sec.DeletedAt != nil && !includeDeleted { return nil }
 			if !includeExpired && sec.ExpiresAt != nil && time.Now().UTC().After(*sec.ExpiresAt) {
 				return nil
 			}
@@ -154,4 +296,156 @@ func (s *BoltStore) ReapExpired() (int, error) {
 		return nil
 	})
 	return count, err
-}
\ No newline at end of file
+}
+// LoadKeyring/SaveKeyring keep the encrypted keyring (crypto.KeyringStore).
+func (s *BoltStore) LoadKeyring() (string, error) {
+	var out string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		out = string(tx.Bucket(sysBucket).Get([]byte("keyring")))
+		return nil
+	})
+	return out, err
+}
+
+func (s *BoltStore) SaveKeyring(cipher string) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return tx.Bucket(sysBucket).Put([]byte("keyring"), []byte(cipher)) })
+}
+
+// LoadSeal/SaveSeal keep the Shamir seal config (seal.Store).
+func (s *BoltStore) LoadSeal() ([]byte, error) {
+	var out []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(sysBucket).Get([]byte("seal")); v != nil { out = append([]byte(nil), v...) }
+		return nil
+	})
+	return out, err
+}
+
+// SaveSeal writes the seal config and the rekeyed keyring in one transaction.
+func (s *BoltStore) SaveSeal(b []byte, keyring string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sys := tx.Bucket(sysBucket)
+		if keyring != "" {
+			if err := sys.Put([]byte("keyring"), []byte(keyring)); err != nil { return err }
+		}
+		return sys.Put([]byte("seal"), b)
+	})
+}
+
+// GetTransitKey/PutTransitKey keep the encrypted transit keys (transit.Store).
+func (s *BoltStore) GetTransitKey(name string) (string, error) {
+	var out string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		out = string(tx.Bucket(transitBucket).Get([]byte(name)))
+		return nil
+	})
+	return out, err
+}
+
+func (s *BoltStore) PutTransitKey(name, cipher string) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return tx.Bucket(transitBucket).Put([]byte(name), []byte(cipher)) })
+}
+
+// GetLease/PutLease/DeleteLease/ListLeases keep the dynamic credential
+// leases (dynamic.Store).
+func (s *BoltStore) GetLease(id string) ([]byte, error) {
+	var out []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(leaseBucket).Get([]byte(id)); v != nil { out = append([]byte(nil), v...) }
+		return nil
+	})
+	return out, err
+}
+
+func (s *BoltStore) PutLease(id string, b []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return tx.Bucket(leaseBucket).Put([]byte(id), b) })
+}
+
+func (s *BoltStore) DeleteLease(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return tx.Bucket(leaseBucket).Delete([]byte(id)) })
+}
+
+func (s *BoltStore) ListLeases() ([][]byte, error) {
+	var out [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(leaseBucket).ForEach(func(_, v []byte) error { out = append(out, append([]byte(nil), v...)); return nil })
+	})
+	return out, err
+}
+
+// PutSecretID/UpdateSecretID/PruneSecretIDs keep the AppRole secret_ids
+// (auth.SecretIDStore), keyed by their hash.
+func (s *BoltStore) PutSecretID(hash string, b []byte) error {
+	return s.db.Update(func(tx *bolt.Tx) error { return tx.Bucket(secretIDBucket).Put([]byte(hash), b) })
+}
+
+// UpdateSecretID replaces the record with what fn returns (nil deletes it)
+// in one transaction. fn's error is returned after the write is committed,
+// so a login that fails on an expired secret_id still removes it.
+func (s *BoltStore) UpdateSecretID(hash string, fn func(b []byte) ([]byte, error)) error {
+	var ferr error
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(secretIDBucket)
+		var cur []byte
+		if v := b.Get([]byte(hash)); v != nil { cur = append([]byte(nil), v...) }
+		var next []byte
+		next, ferr = fn(cur)
+		if next == nil { return b.Delete([]byte(hash)) }
+		return b.Put([]byte(hash), next)
+	})
+	if err != nil { return err }
+	return ferr
+}
+
+func (s *BoltStore) PruneSecretIDs(drop func(b []byte) bool) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(secretIDBucket)
+		var keys [][]byte
+		_ = b.ForEach(func(k, v []byte) error { if drop(v) { keys = append(keys, append([]byte(nil), k...)) }; return nil })
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil { return err }
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
+
+// RevokeToken/TokenRevoked/PruneRevokedTokens keep the revoked JWT ids
+// (auth.Revocations) with the time their token expires.
+func (s *BoltStore) RevokeToken(jti string, until time.Time) (bool, error) {
+	added := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revokedBucket)
+		if b.Get([]byte(jti)) != nil { return nil }
+		added = true
+		return b.Put([]byte(jti), []byte(until.UTC().Format(time.RFC3339)))
+	})
+	return added && err == nil, err
+}
+
+func (s *BoltStore) TokenRevoked(jti string) (bool, error) {
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error { found = tx.Bucket(revokedBucket).Get([]byte(jti)) != nil; return nil })
+	return found, err
+}
+
+// PruneRevokedTokens forgets the entries whose tokens have expired.
+func (s *BoltStore) PruneRevokedTokens(now time.Time) (int, error) {
+	n := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(revokedBucket)
+		var keys [][]byte
+		_ = b.ForEach(func(k, v []byte) error {
+			if t, err := time.Parse(time.RFC3339, string(v)); err == nil && !now.Before(t) { keys = append(keys, append([]byte(nil), k...)) }
+			return nil
+		})
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil { return err }
+		}
+		n = len(keys)
+		return nil
+	})
+	return n, err
+}
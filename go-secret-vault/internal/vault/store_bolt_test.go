@@ -0,0 +1,38 @@
+package vault
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestVersions(t *testing.T) {
+	st, err := NewBolt(filepath.Join(t.TempDir(), "vault.db"), 3)
+	if err != nil { t.Fatal(err) }
+	defer st.Close()
+	sec, err := st.Create("team-a/db", "c1", 0, nil)
+	if err != nil { t.Fatal(err) }
+	for _, c := range []string{"c2", "c3", "c4"} {
+		if sec, err = st.Update(sec.ID, c, nil, nil); err != nil { t.Fatal(err) }
+	}
+	if sec.Version != 4 || len(sec.Versions) != 3 || sec.Versions[0].Version != 2 { t.Fatalf("versions = %d %+v", sec.Version, sec.Versions) }
+	if _, err := sec.VersionOf(1); !errors.Is(err, ErrNotFound) { t.Fatalf("trimmed version: %v", err) }
+	if v, _ := sec.VersionOf(0); v.CipherB64 != "c4" { t.Fatalf("current = %+v", v) }
+
+	// meta-only updates keep the version
+	if sec, _ = st.Update(sec.ID, "", nil, map[string]string{"env": "prod"}); sec.Version != 4 { t.Fatalf("meta update created version %d", sec.Version) }
+
+	if sec, err = st.DestroyVersions(sec.ID, []int{3}); err != nil { t.Fatal(err) }
+	if _, err := sec.VersionOf(3); !errors.Is(err, ErrDestroyed) { t.Fatalf("destroyed version: %v", err) }
+
+	if err := st.Delete(sec.ID); err != nil { t.Fatal(err) }
+	if list, _ := st.List(false, false); len(list) != 0 { t.Fatalf("deleted secret listed: %+v", list) }
+	if _, err := st.Update(sec.ID, "c5", nil, nil); !errors.Is(err, ErrDeleted) { t.Fatalf("update of deleted secret: %v", err) }
+	if _, err := st.Create("team-a/db", "x", 0, nil); err == nil { t.Fatal("name of deleted secret reused") }
+	if _, err := st.Undelete(sec.ID); err != nil { t.Fatal(err) }
+	if list, _ := st.List(false, false); len(list) != 1 { t.Fatalf("undeleted secret missing: %+v", list) }
+
+	if err := st.Destroy(sec.ID); err != nil { t.Fatal(err) }
+	if _, err := st.Get(sec.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("destroyed secret: %v", err) }
+	if _, err := st.Create("team-a/db", "x", 0, nil); err != nil { t.Fatalf("name not released: %v", err) }
+}